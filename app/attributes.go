@@ -0,0 +1,224 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skartikey/sensor-metadata/app/jsonschema"
+)
+
+// ErrSensorTypeNotFound is returned when no sensor type matches the lookup.
+var ErrSensorTypeNotFound = errors.New("sensor type not found")
+
+// ErrSensorTypeExists is returned when creating a sensor type whose name is already taken.
+var ErrSensorTypeExists = errors.New("sensor type already exists")
+
+// ErrInvalidAttributes is returned when sensor attributes don't satisfy the sensor type.
+var ErrInvalidAttributes = errors.New("invalid attributes")
+
+// ErrInvalidAttributeFilter is returned when an attribute filter cannot be parsed.
+var ErrInvalidAttributeFilter = errors.New("invalid attribute filter")
+
+// SensorType represents a kind of sensor and the JSON Schema its attributes must satisfy.
+type SensorType struct {
+	Name        string          `json:"name" validate:"required,max=255"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema" validate:"required"`
+}
+
+// ValidateAttributes checks sensor attributes against the type's schema.
+func (t *SensorType) ValidateAttributes(attributes map[string]interface{}) error {
+	schema, err := jsonschema.Parse(t.Schema)
+	if err != nil {
+		return err
+	}
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	return schema.Validate(attributes)
+}
+
+// AttributeFilter represents a comparison against a sensor attribute, e.g. attr.sampling_rate>=10.
+type AttributeFilter struct {
+	Path     []string
+	Operator string
+	Value    interface{}
+}
+
+// attributeFilterPattern splits "attr.<path><op><value>".
+var attributeFilterPattern = regexp.MustCompile(`^attr\.([A-Za-z0-9_\-]+(?:\.[A-Za-z0-9_\-]+)*)(>=|<=|!=|=|>|<)(.*)$`)
+
+// ParseAttributeFilters extracts the attr.* comparisons from a raw query string.
+// The raw form is needed because operators such as >= don't survive url.ParseQuery intact.
+func ParseAttributeFilters(rawQuery string) ([]AttributeFilter, error) {
+	var filters []AttributeFilter
+	for _, part := range strings.Split(rawQuery, "&") {
+		decoded, err := url.QueryUnescape(part)
+		if err != nil || !strings.HasPrefix(decoded, "attr.") {
+			continue
+		}
+
+		match := attributeFilterPattern.FindStringSubmatch(decoded)
+		if match == nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAttributeFilter, decoded)
+		}
+		filters = append(filters, AttributeFilter{
+			Path:     strings.Split(match[1], "."),
+			Operator: match[2],
+			Value:    parseAttributeValue(match[3]),
+		})
+	}
+	return filters, nil
+}
+
+// ParseAttributeFilterList parses attribute filters given one per string, in the form of the
+// query parameters, e.g. attr.sampling_rate>=10.
+func ParseAttributeFilterList(filters []string) ([]AttributeFilter, error) {
+	parts := make([]string, len(filters))
+	for i, filter := range filters {
+		if !strings.HasPrefix(filter, "attr.") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAttributeFilter, filter)
+		}
+		parts[i] = url.QueryEscape(filter)
+	}
+	return ParseAttributeFilters(strings.Join(parts, "&"))
+}
+
+// parseAttributeValue interprets a filter operand as a number, boolean or string.
+// Double quotes force a string, e.g. attr.model="10".
+func parseAttributeValue(value string) interface{} {
+	if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+		return unquoted
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+		return number
+	}
+	if b, err := strconv.ParseBool(value); err == nil && (value == "true" || value == "false") {
+		return b
+	}
+	return value
+}
+
+// Match reports whether the attributes satisfy the comparison.
+// As in PostgreSQL's jsonpath, comparing values of different types never matches.
+func (f AttributeFilter) Match(attributes map[string]interface{}) bool {
+	var current interface{} = attributes
+	for _, key := range f.Path {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current, ok = object[key]
+		if !ok {
+			return false
+		}
+	}
+
+	var cmp int
+	switch value := f.Value.(type) {
+	case float64:
+		actual, ok := toFloat(current)
+		if !ok {
+			return false
+		}
+		cmp = compareFloats(actual, value)
+	case string:
+		actual, ok := current.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(actual, value)
+	case bool:
+		actual, ok := current.(bool)
+		if !ok {
+			return false
+		}
+		switch f.Operator {
+		case "=":
+			return actual == value
+		case "!=":
+			return actual != value
+		}
+		return false
+	default:
+		return false
+	}
+
+	switch f.Operator {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// sql returns a predicate over the attributes column. Equality is a containment test
+// (attributes @> '{"a":{"b":1}}'), which the jsonb_path_ops GIN index serves. The other operators
+// are jsonpath predicates that the index can't serve, so they only narrow the rows found by the
+// other conditions of the query, or scan the table.
+func (f AttributeFilter) sql(q *sqlQuery) string {
+	if f.Operator == "=" {
+		var contained interface{} = f.Value
+		for i := len(f.Path) - 1; i >= 0; i-- {
+			contained = map[string]interface{}{f.Path[i]: contained}
+		}
+		value, _ := json.Marshal(contained)
+		return fmt.Sprintf("attributes @> %s::jsonb", q.arg(string(value)))
+	}
+
+	path := "$"
+	for _, key := range f.Path {
+		path += "." + strconv.Quote(key)
+	}
+
+	value, _ := json.Marshal(f.Value)
+	return fmt.Sprintf("attributes @@ %s::jsonpath", q.arg(path+" "+f.Operator+" "+string(value)))
+}
+
+// String returns the filter in its query parameter form.
+func (f AttributeFilter) String() string {
+	value, _ := json.Marshal(f.Value)
+	return "attr." + strings.Join(f.Path, ".") + f.Operator + string(value)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
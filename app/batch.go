@@ -0,0 +1,72 @@
+package app
+
+import (
+	"errors"
+)
+
+// ErrSensorExists is returned when creating a sensor whose name is already taken.
+var ErrSensorExists = errors.New("sensor metadata already exists")
+
+// ErrBatchAborted is the outcome of every operation in an atomic batch that was rolled back
+// because another operation failed.
+var ErrBatchAborted = errors.New("batch aborted")
+
+// Batch operation kinds.
+const (
+	BatchCreate = "create"
+	BatchUpsert = "upsert"
+	BatchDelete = "delete"
+)
+
+// BatchOperation represents one create, upsert or delete in a batch.
+// Create and upsert carry the sensor; delete names the sensor to remove.
+type BatchOperation struct {
+	Op     string
+	Name   string
+	Sensor *SensorMetadata
+}
+
+// sensorName returns the name of the sensor the operation applies to.
+func (op BatchOperation) sensorName() string {
+	if op.Op != BatchDelete && op.Sensor != nil {
+		return op.Sensor.Name
+	}
+	return op.Name
+}
+
+// BatchOutcome reports how a batch operation was applied. Err is nil on success, and
+// Created tells an upsert that inserted a new sensor from one that updated an existing one.
+type BatchOutcome struct {
+	Created bool
+	Err     error
+}
+
+// batchRuns splits operations into runs of consecutive operations of the same kind, each
+// naming a sensor at most once, so that a run can be applied as a single statement
+// without changing the order in which operations take effect.
+func batchRuns(operations []BatchOperation) [][2]int {
+	var runs [][2]int
+	start := 0
+	names := map[string]bool{}
+	for i, op := range operations {
+		if i > start && (op.Op != operations[start].Op || names[op.sensorName()]) {
+			runs = append(runs, [2]int{start, i})
+			start = i
+			names = map[string]bool{}
+		}
+		names[op.sensorName()] = true
+	}
+	if start < len(operations) {
+		runs = append(runs, [2]int{start, len(operations)})
+	}
+	return runs
+}
+
+// abortBatch marks every operation that didn't fail itself as aborted.
+func abortBatch(outcomes []BatchOutcome) {
+	for i := range outcomes {
+		if outcomes[i].Err == nil {
+			outcomes[i] = BatchOutcome{Err: ErrBatchAborted}
+		}
+	}
+}
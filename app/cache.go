@@ -0,0 +1,286 @@
+package app
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CachingRepository decorates a Repository with a read-through cache of the sensors looked up by
+// name. The cache holds up to a fixed number of sensors, evicting the least recently used, and
+// remembers names that have no sensor as well. Concurrent misses for the same name share a single
+// lookup. Writes made through the decorator invalidate the sensors they touch; to follow the writes
+// of other replicas, subscribe it to a SensorChangeListener.
+type CachingRepository struct {
+	Repository
+
+	// TTL is how long a sensor is cached.
+	TTL time.Duration
+	// NegativeTTL is how long a name without a sensor is cached; zero disables negative caching.
+	NegativeTTL time.Duration
+
+	mu       sync.Mutex
+	capacity int
+	lru      *list.List
+	byName   map[string]*list.Element
+	byID     map[int]*list.Element
+	lookups  map[string]*cacheLookup
+	// generation is incremented by every invalidation, so that lookups which started before
+	// a write don't cache what they read
+	generation uint64
+	stats      CacheStats
+}
+
+// CacheStats represents the counters of a CachingRepository.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Shared    uint64 `json:"shared"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// cacheEntry is the cached outcome of looking up a name: its sensor, or nil when it has none.
+type cacheEntry struct {
+	name    string
+	sensor  *SensorMetadata
+	expires time.Time
+}
+
+// cacheLookup is a lookup in flight, whose outcome is shared by the misses for the same name.
+type cacheLookup struct {
+	done   chan struct{}
+	sensor *SensorMetadata
+	err    error
+}
+
+// Defaults of a CachingRepository.
+const (
+	defaultCacheTTL         = time.Minute
+	defaultCacheNegativeTTL = 10 * time.Second
+)
+
+// NewCachingRepository creates a cache of up to capacity sensors in front of the repository.
+func NewCachingRepository(repo Repository, capacity int) *CachingRepository {
+	return &CachingRepository{
+		Repository:  repo,
+		TTL:         defaultCacheTTL,
+		NegativeTTL: defaultCacheNegativeTTL,
+		capacity:    capacity,
+		lru:         list.New(),
+		byName:      make(map[string]*list.Element),
+		byID:        make(map[int]*list.Element),
+		lookups:     make(map[string]*cacheLookup),
+	}
+}
+
+// Stats returns the counters of the cache. Hits and Misses count lookups answered with and
+// without the cache; Shared counts the misses answered by a lookup already in flight.
+func (r *CachingRepository) Stats() CacheStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	stats.Size = r.lru.Len()
+	return stats
+}
+
+// GetSensorMetadataByName retrieves the sensor metadata by its name, from the cache when possible.
+func (r *CachingRepository) GetSensorMetadataByName(name string) (*SensorMetadata, error) {
+	r.mu.Lock()
+	if element, ok := r.byName[name]; ok {
+		entry := element.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			r.lru.MoveToFront(element)
+			r.stats.Hits++
+			r.mu.Unlock()
+			return entry.result()
+		}
+		r.remove(element)
+	}
+	r.stats.Misses++
+
+	if lookup, ok := r.lookups[name]; ok {
+		r.stats.Shared++
+		r.mu.Unlock()
+		<-lookup.done
+		return lookup.result()
+	}
+	lookup := &cacheLookup{done: make(chan struct{})}
+	r.lookups[name] = lookup
+	generation := r.generation
+	r.mu.Unlock()
+
+	// Misses read from the primary, so that a lagging replica doesn't fill the cache with stale sensors
+	lookup.sensor, lookup.err = primaryOf(r.Repository).GetSensorMetadataByName(name)
+
+	r.mu.Lock()
+	if r.lookups[name] == lookup {
+		delete(r.lookups, name)
+	}
+	if r.generation == generation {
+		if lookup.err == nil {
+			r.add(name, cloneSensorMetadata(*lookup.sensor), r.TTL)
+		} else if errors.Is(lookup.err, ErrSensorNotFound) && r.NegativeTTL > 0 {
+			r.add(name, nil, r.NegativeTTL)
+		}
+	}
+	r.mu.Unlock()
+	close(lookup.done)
+
+	return lookup.result()
+}
+
+// CreateSensorMetadata creates a new sensor metadata entry, forgetting that its name had no sensor.
+func (r *CachingRepository) CreateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	defer r.invalidate(nil, sensorMetadata.Name)
+	return r.Repository.CreateSensorMetadata(sensorMetadata)
+}
+
+// UpdateSensorMetadata updates an existing sensor metadata entry, invalidating it under its old and new names.
+func (r *CachingRepository) UpdateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	defer r.invalidate([]int{sensorMetadata.ID}, sensorMetadata.Name)
+	return r.Repository.UpdateSensorMetadata(sensorMetadata)
+}
+
+// AddSensorTags adds tags to a sensor, invalidating it.
+func (r *CachingRepository) AddSensorTags(name string, tags []string) (*SensorMetadata, error) {
+	defer r.invalidate(nil, name)
+	return r.Repository.AddSensorTags(name, tags)
+}
+
+// RemoveSensorTag removes a tag from a sensor, invalidating it.
+func (r *CachingRepository) RemoveSensorTag(name, tag string) (*SensorMetadata, error) {
+	defer r.invalidate(nil, name)
+	return r.Repository.RemoveSensorTag(name, tag)
+}
+
+// ApplySensorBatch applies a batch of operations, invalidating every sensor it names.
+func (r *CachingRepository) ApplySensorBatch(operations []BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	names := make([]string, 0, len(operations))
+	for _, op := range operations {
+		names = append(names, op.sensorName())
+	}
+	defer r.invalidate(nil, names...)
+	return r.Repository.ApplySensorBatch(operations, atomic)
+}
+
+// SensorChanged invalidates a sensor changed by any replica.
+func (r *CachingRepository) SensorChanged(change SensorChange) {
+	r.invalidate([]int{change.ID}, change.Name, change.PreviousName)
+}
+
+// ResyncSensors empties the cache after changes may have been missed.
+func (r *CachingRepository) ResyncSensors() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.lru.Init()
+	r.byName = make(map[string]*list.Element)
+	r.byID = make(map[int]*list.Element)
+	r.lookups = make(map[string]*cacheLookup)
+}
+
+// Primary returns a view of the repository that reads from the primary, bypassing the cache.
+func (r *CachingRepository) Primary() Repository {
+	return primaryOf(r.Repository)
+}
+
+// ReplicaStaleness bounds how long a write may take to be seen by the reads sent to replicas.
+func (r *CachingRepository) ReplicaStaleness() time.Duration {
+	return replicaStaleness(r.Repository)
+}
+
+// invalidate drops the cached sensors with the IDs or names. Later misses start new lookups
+// rather than share those in flight, which may have read the sensors before they changed.
+func (r *CachingRepository) invalidate(ids []int, names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	for _, id := range ids {
+		if element, ok := r.byID[id]; ok {
+			r.remove(element)
+		}
+	}
+	for _, name := range names {
+		if element, ok := r.byName[name]; ok {
+			r.remove(element)
+		}
+		delete(r.lookups, name)
+	}
+}
+
+// add caches the outcome of looking up a name, evicting the least recently used entries beyond capacity.
+func (r *CachingRepository) add(name string, sensor *SensorMetadata, ttl time.Duration) {
+	if element, ok := r.byName[name]; ok {
+		r.remove(element)
+	}
+	element := r.lru.PushFront(&cacheEntry{name: name, sensor: sensor, expires: time.Now().Add(ttl)})
+	r.byName[name] = element
+	if sensor != nil {
+		r.byID[sensor.ID] = element
+	}
+
+	for r.lru.Len() > r.capacity {
+		r.remove(r.lru.Back())
+		r.stats.Evictions++
+	}
+}
+
+// remove drops an entry from the cache.
+func (r *CachingRepository) remove(element *list.Element) {
+	entry := r.lru.Remove(element).(*cacheEntry)
+	delete(r.byName, entry.name)
+	if entry.sensor != nil && r.byID[entry.sensor.ID] == element {
+		delete(r.byID, entry.sensor.ID)
+	}
+}
+
+// result returns a copy of the cached sensor, or ErrSensorNotFound.
+func (entry *cacheEntry) result() (*SensorMetadata, error) {
+	if entry.sensor == nil {
+		return nil, ErrSensorNotFound
+	}
+	return cloneSensorMetadata(*entry.sensor), nil
+}
+
+// result returns a copy of the sensor read by the lookup, or its error.
+func (lookup *cacheLookup) result() (*SensorMetadata, error) {
+	if lookup.err != nil {
+		return nil, lookup.err
+	}
+	return cloneSensorMetadata(*lookup.sensor), nil
+}
+
+// cloneSensorMetadata returns a copy that shares nothing with the original, so callers can't change
+// the sensors kept by a cache or an index.
+func cloneSensorMetadata(sensorMetadata SensorMetadata) *SensorMetadata {
+	attributes := sensorMetadata.Attributes
+	sensorMetadata.Attributes = nil
+	copied := copySensorMetadata(sensorMetadata)
+	if attributes != nil {
+		copied.Attributes = copyJSONValue(attributes).(map[string]interface{})
+	}
+	return &copied
+}
+
+// copyJSONValue deep copies a value decoded from JSON. Unlike copyAttributes, it doesn't
+// re-encode the value, which matters on the hot path of the cache.
+func copyJSONValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			copied[key] = copyJSONValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = copyJSONValue(item)
+		}
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCSV is returned when a CSV file cannot be read at all, e.g. because of a bad header.
+var ErrInvalidCSV = errors.New("invalid CSV")
+
+// ErrInvalidCSVRow is returned when a single CSV row cannot be read; the following rows may still be valid.
+var ErrInvalidCSVRow = errors.New("invalid CSV row")
+
+// sensorCSVColumns lists the CSV columns in export order. Only name, latitude and longitude are
+// required on import; the other columns may be left out or left empty.
+var sensorCSVColumns = []string{"name", "latitude", "longitude", "altitude", "altitude_datum", "floor", "accuracy_m", "tags", "type", "attributes"}
+
+// csvTagSeparator separates the tags within the tags column.
+const csvTagSeparator = ";"
+
+// sensorCSVReader reads sensor metadata from CSV rows, one sensor per row after the header.
+type sensorCSVReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// newSensorCSVReader reads the header row and checks its columns.
+func newSensorCSVReader(r io.Reader) (*sensorCSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: missing header row", ErrInvalidCSV)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		if i == 0 {
+			// Spreadsheets often save CSV with a byte order mark
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !isSensorCSVColumn(column) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidCSV, column)
+		}
+		if _, ok := columns[column]; ok {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidCSV, column)
+		}
+		columns[column] = i
+	}
+	for _, column := range sensorCSVColumns[:3] {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidCSV, column)
+		}
+	}
+
+	return &sensorCSVReader{reader: reader, columns: columns}, nil
+}
+
+// Read returns the next sensor and the line it starts on. It returns io.EOF after the last row.
+// Errors wrapping ErrInvalidCSVRow concern that row only; any other error ends the file.
+func (r *sensorCSVReader) Read() (SensorMetadata, int, error) {
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return SensorMetadata{}, 0, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+		return SensorMetadata{}, parseErr.StartLine, fmt.Errorf("%w: expected %d fields, got %d", ErrInvalidCSVRow, len(r.columns), len(record))
+	}
+	if err != nil {
+		return SensorMetadata{}, 0, fmt.Errorf("%w: %w", ErrInvalidCSV, err)
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	sensorMetadata, err := r.parseRecord(record)
+	return sensorMetadata, line, err
+}
+
+// parseRecord converts a CSV record into sensor metadata. Validation is left to the caller.
+func (r *sensorCSVReader) parseRecord(record []string) (SensorMetadata, error) {
+	field := func(column string) string {
+		if i, ok := r.columns[column]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var sensorMetadata SensorMetadata
+	var err error
+	sensorMetadata.Name = unescapeCSVFormula(field("name"))
+	sensorMetadata.Type = unescapeCSVFormula(field("type"))
+	sensorMetadata.Location.AltitudeDatum = unescapeCSVFormula(field("altitude_datum"))
+
+	if value := field("latitude"); value != "" {
+		if sensorMetadata.Location.Latitude, err = strconv.ParseFloat(value, 64); err != nil {
+			return sensorMetadata, fmt.Errorf("%w: invalid latitude %q", ErrInvalidCSVRow, value)
+		}
+	}
+	if value := field("longitude"); value != "" {
+		if sensorMetadata.Location.Longitude, err = strconv.ParseFloat(value, 64); err != nil {
+			return sensorMetadata, fmt.Errorf("%w: invalid longitude %q", ErrInvalidCSVRow, value)
+		}
+	}
+	if value := field("altitude"); value != "" {
+		altitude, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return sensorMetadata, fmt.Errorf("%w: invalid altitude %q", ErrInvalidCSVRow, value)
+		}
+		sensorMetadata.Location.Altitude = &altitude
+	}
+	if value := field("floor"); value != "" {
+		floor, err := strconv.Atoi(value)
+		if err != nil {
+			return sensorMetadata, fmt.Errorf("%w: invalid floor %q", ErrInvalidCSVRow, value)
+		}
+		sensorMetadata.Location.Floor = &floor
+	}
+	if value := field("accuracy_m"); value != "" {
+		accuracy, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return sensorMetadata, fmt.Errorf("%w: invalid accuracy_m %q", ErrInvalidCSVRow, value)
+		}
+		sensorMetadata.Location.AccuracyM = &accuracy
+	}
+
+	for _, tag := range strings.Split(unescapeCSVFormula(field("tags")), csvTagSeparator) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			sensorMetadata.Tags = append(sensorMetadata.Tags, tag)
+		}
+	}
+
+	if value := field("attributes"); value != "" {
+		if err := json.Unmarshal([]byte(value), &sensorMetadata.Attributes); err != nil || sensorMetadata.Attributes == nil {
+			return sensorMetadata, fmt.Errorf("%w: attributes must be a JSON object", ErrInvalidCSVRow)
+		}
+	}
+
+	return sensorMetadata, nil
+}
+
+// isSensorCSVColumn reports whether column is one of sensorCSVColumns.
+func isSensorCSVColumn(column string) bool {
+	for _, known := range sensorCSVColumns {
+		if column == known {
+			return true
+		}
+	}
+	return false
+}
+
+// sensorEncoder streams sensors in an export format.
+type sensorEncoder interface {
+	// Write encodes a sensor, possibly buffering it.
+	Write(sensorMetadata SensorMetadata) error
+	// Flush writes any buffered output.
+	Flush() error
+	// Close writes any trailer that ends the document and flushes.
+	Close() error
+}
+
+// sensorCSVWriter writes sensor metadata as CSV rows with every column of sensorCSVColumns.
+type sensorCSVWriter struct {
+	writer *csv.Writer
+}
+
+// newSensorCSVWriter writes the header row.
+func newSensorCSVWriter(w io.Writer) (*sensorCSVWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(sensorCSVColumns); err != nil {
+		return nil, err
+	}
+	return &sensorCSVWriter{writer: writer}, nil
+}
+
+// Write writes a sensor as a CSV row.
+func (w *sensorCSVWriter) Write(sensorMetadata SensorMetadata) error {
+	location := sensorMetadata.Location
+
+	var attributes string
+	if len(sensorMetadata.Attributes) > 0 {
+		data, err := json.Marshal(sensorMetadata.Attributes)
+		if err != nil {
+			return err
+		}
+		attributes = string(data)
+	}
+
+	// Text cells come from clients, so they are escaped; numbers and JSON objects can't start a formula
+	return w.writer.Write([]string{
+		escapeCSVFormula(sensorMetadata.Name),
+		formatCSVFloat(&location.Latitude),
+		formatCSVFloat(&location.Longitude),
+		formatCSVFloat(location.Altitude),
+		escapeCSVFormula(location.AltitudeDatum),
+		formatCSVInt(location.Floor),
+		formatCSVFloat(location.AccuracyM),
+		escapeCSVFormula(strings.Join(sensorMetadata.Tags, csvTagSeparator)),
+		escapeCSVFormula(sensorMetadata.Type),
+		attributes,
+	})
+}
+
+// csvFormulaPrefixes are the first characters with which spreadsheets read a cell as a formula.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVFormula prefixes a cell that a spreadsheet would run as a formula with a quote, which
+// makes it read as text (CSV injection).
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVFormula removes the quote that escapeCSVFormula adds, so exports import unchanged.
+func unescapeCSVFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (w *sensorCSVWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Close flushes the remaining rows; CSV has no trailer.
+func (w *sensorCSVWriter) Close() error {
+	return w.Flush()
+}
+
+func formatCSVFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func formatCSVInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrSensorEventsPruned is returned when reading the change log after an event older than the
+// events it retains: the events in between have been pruned.
+var ErrSensorEventsPruned = errors.New("sensor events pruned")
+
+// Types of sensor events.
+const (
+	SensorCreated = "created"
+	SensorUpdated = "updated"
+	SensorDeleted = "deleted"
+)
+
+// SensorEvent represents a change to a sensor, as recorded in the change log.
+// IDs increase monotonically in the order the change log hands the changes out: an event is only
+// read once every event with a lower ID is committed or rolled back. Sensor is the sensor
+// after the change, or as it was before being deleted; Previous is the sensor before an update.
+type SensorEvent struct {
+	ID       int64
+	Type     string
+	Sensor   SensorMetadata
+	Previous *SensorMetadata
+}
+
+// SensorEventFilter selects the events of the sensors matching a tag expression and attribute
+// filters and located inside a bounding box. An update matches when the sensor matched before
+// or after it, so a client also learns of the sensors that leave its selection.
+type SensorEventFilter struct {
+	Tags        TagExpr
+	Attributes  []AttributeFilter
+	BoundingBox *BoundingBox
+}
+
+// Match reports whether the event is selected by the filter.
+func (filter SensorEventFilter) Match(event SensorEvent) bool {
+	return filter.matchSensor(event.Sensor) || (event.Previous != nil && filter.matchSensor(*event.Previous))
+}
+
+// matchSensor reports whether the sensor is selected by the filter.
+func (filter SensorEventFilter) matchSensor(sensorMetadata SensorMetadata) bool {
+	if filter.Tags != nil && !filter.Tags.Match(sensorMetadata.Tags) {
+		return false
+	}
+	if filter.BoundingBox != nil && !filter.BoundingBox.Contains(sensorMetadata.Location.Latitude, sensorMetadata.Location.Longitude) {
+		return false
+	}
+	return matchAttributes(filter.Attributes, sensorMetadata.Attributes)
+}
+
+// The event stream polls the change log every sensorEventPollInterval, reading up to
+// sensorEventBatchSize events at a time, and sends a comment after sensorEventKeepAlive
+// without events so that proxies don't close an idle connection.
+const (
+	sensorEventPollInterval = time.Second
+	sensorEventBatchSize    = 500
+	sensorEventKeepAlive    = 15 * time.Second
+)
+
+// StreamSensorEvents handles the HTTP GET request to stream changes to sensors as Server-Sent Events.
+// The stream resumes after the event in the Last-Event-ID header or 'last_event_id' parameter,
+// and otherwise starts with the next change. Resuming after an event older than those the change
+// log retains responds 410 Gone, so that the client reloads the sensors instead.
+func (h *Handler) StreamSensorEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSensorEventFilter(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastID < 0 {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid last event ID")
+			return
+		}
+	} else {
+		lastID, err = h.repo.LastSensorEventID()
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to read sensor events")
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendErrorResponse(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	// Read the first events before responding, so that pruned events can still be reported
+	events, err := h.repo.ListSensorEvents(lastID, sensorEventBatchSize)
+	if errors.Is(err, ErrSensorEventsPruned) {
+		sendErrorResponse(w, http.StatusGone, "Events after the last event ID have been pruned")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to read sensor events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stop nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(sensorEventPollInterval)
+	defer ticker.Stop()
+	idle := time.Now()
+	for {
+		sent := false
+		for _, event := range events {
+			lastID = event.ID
+			if !filter.Match(event) {
+				continue
+			}
+			if err := writeSensorEvent(w, event); err != nil {
+				return
+			}
+			sent = true
+		}
+		if !sent && time.Since(idle) >= sensorEventKeepAlive {
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			sent = true
+		}
+		if sent {
+			flusher.Flush()
+			idle = time.Now()
+		}
+
+		// Catch up without waiting while the change log has more events
+		if len(events) < sensorEventBatchSize {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		events, err = h.repo.ListSensorEvents(lastID, sensorEventBatchSize)
+		if err != nil {
+			// End the stream; the client reconnects with the ID of the last event it received
+			return
+		}
+	}
+}
+
+// PruneSensorEvents deletes the events older than retention from the change log of the
+// repository every interval, until the context is cancelled. Events the webhook outbox hasn't
+// read yet are kept.
+func PruneSensorEvents(ctx context.Context, repo Repository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := repo.PruneSensorEvents(time.Now().Add(-retention)); err != nil && ctx.Err() == nil {
+			log.Println("Error pruning sensor events:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Helper function to write an event in the Server-Sent Events format, with the sensor as its JSON data.
+func writeSensorEvent(w http.ResponseWriter, event SensorEvent) error {
+	data, err := json.Marshal(NewSensorMetadataV1(event.Sensor))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// Helper function to parse the event filter from the 'tags', 'bbox' and attribute filter parameters.
+func parseSensorEventFilter(r *http.Request) (SensorEventFilter, error) {
+	var filter SensorEventFilter
+
+	var err error
+	filter.Tags, err = parseTagsParameter(r)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Attributes, err = ParseAttributeFilters(r.URL.RawQuery)
+	if err != nil {
+		return filter, err
+	}
+
+	if bbox := r.URL.Query().Get("bbox"); bbox != "" {
+		boundingBox, err := ParseBoundingBox(bbox)
+		if err != nil {
+			return filter, err
+		}
+		filter.BoundingBox = &boundingBox
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Response formats, selected with the 'format' parameter or the Accept header.
+const (
+	FormatJSON    = "json"
+	FormatGeoJSON = "geojson"
+	FormatKML     = "kml"
+	FormatGPX     = "gpx"
+	FormatCSV     = "csv"
+)
+
+// Media types of the response formats.
+const (
+	KMLMediaType = "application/vnd.google-earth.kml+xml"
+	GPXMediaType = "application/gpx+xml"
+)
+
+// formatMediaTypes maps each response format to its media type.
+var formatMediaTypes = map[string]string{
+	FormatJSON:    "application/json",
+	FormatGeoJSON: GeoJSONMediaType,
+	FormatKML:     KMLMediaType,
+	FormatGPX:     GPXMediaType,
+	FormatCSV:     "text/csv",
+}
+
+// responseFormat picks the response format for a request among the supported ones, the
+// first of which is the default. An explicit 'format' parameter must be supported; otherwise
+// the most preferred supported media type in the Accept header wins.
+func responseFormat(r *http.Request, supported ...string) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		for _, candidate := range supported {
+			if format == candidate {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("Unsupported 'format' parameter %q, expected one of %s", format, strings.Join(supported, ", "))
+	}
+
+	for _, mediaType := range acceptedMediaTypes(r.Header.Get("Accept")) {
+		for _, candidate := range supported {
+			if formatMediaTypes[candidate] == mediaType {
+				return candidate, nil
+			}
+		}
+	}
+	return supported[0], nil
+}
+
+// acceptedMediaTypes lists the media types of an Accept header from most to least preferred,
+// leaving out those with a quality of zero. Wildcards are kept but match no format.
+func acceptedMediaTypes(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+
+	var ranges []mediaRange
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mediaType, quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	mediaTypes := make([]string, len(ranges))
+	for i, mediaRange := range ranges {
+		mediaTypes[i] = mediaRange.mediaType
+	}
+	return mediaTypes
+}
@@ -0,0 +1,360 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidGeometry is returned when a bounding box or GeoJSON geometry is malformed.
+var ErrInvalidGeometry = errors.New("invalid geometry")
+
+// maxPolygonVertices bounds the size of a polygon accepted by within queries.
+const maxPolygonVertices = 10000
+
+// BoundingBox represents a longitude/latitude box. A box whose MinLon is greater
+// than its MaxLon crosses the antimeridian, e.g. 170,-10,-170,10.
+type BoundingBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// ParseBoundingBox parses "minLon,minLat,maxLon,maxLat".
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, fmt.Errorf("%w: bbox must be minLon,minLat,maxLon,maxLat", ErrInvalidGeometry)
+	}
+
+	var numbers [4]float64
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return BoundingBox{}, fmt.Errorf("%w: bbox value %q is not a number", ErrInvalidGeometry, part)
+		}
+		numbers[i] = number
+	}
+
+	box := BoundingBox{MinLon: numbers[0], MinLat: numbers[1], MaxLon: numbers[2], MaxLat: numbers[3]}
+	if err := box.Validate(); err != nil {
+		return BoundingBox{}, err
+	}
+
+	return box, nil
+}
+
+// Validate checks that the box's coordinates are in range. A box may cross the antimeridian
+// but not the poles.
+func (b BoundingBox) Validate() error {
+	if b.MinLon < -180 || b.MinLon > 180 || b.MaxLon < -180 || b.MaxLon > 180 {
+		return fmt.Errorf("%w: bbox longitudes must be between -180 and 180", ErrInvalidGeometry)
+	}
+	if b.MinLat < -90 || b.MaxLat > 90 || b.MinLat > b.MaxLat {
+		return fmt.Errorf("%w: bbox latitudes must be between -90 and 90 with minLat <= maxLat", ErrInvalidGeometry)
+	}
+	return nil
+}
+
+// CrossesAntimeridian reports whether the box wraps around longitude 180.
+func (b BoundingBox) CrossesAntimeridian() bool {
+	return b.MinLon > b.MaxLon
+}
+
+// Contains reports whether a point lies inside the box, edges included.
+func (b BoundingBox) Contains(latitude, longitude float64) bool {
+	if latitude < b.MinLat || latitude > b.MaxLat {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return longitude >= b.MinLon || longitude <= b.MaxLon
+	}
+	return longitude >= b.MinLon && longitude <= b.MaxLon
+}
+
+// split returns the box as one or two boxes that don't cross the antimeridian.
+func (b BoundingBox) split() []BoundingBox {
+	if !b.CrossesAntimeridian() {
+		return []BoundingBox{b}
+	}
+	return []BoundingBox{
+		{MinLon: b.MinLon, MinLat: b.MinLat, MaxLon: 180, MaxLat: b.MaxLat},
+		{MinLon: -180, MinLat: b.MinLat, MaxLon: b.MaxLon, MaxLat: b.MaxLat},
+	}
+}
+
+// sql returns an index-backed predicate on point(location_longitude, location_latitude).
+func (b BoundingBox) sql(q *sqlQuery) string {
+	var terms []string
+	for _, box := range b.split() {
+		terms = append(terms, fmt.Sprintf("point(location_longitude, location_latitude) <@ box(point(%s, %s), point(%s, %s))",
+			q.arg(box.MinLon), q.arg(box.MinLat), q.arg(box.MaxLon), q.arg(box.MaxLat)))
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// Position is a GeoJSON position in [longitude, latitude] order.
+type Position [2]float64
+
+// Polygon represents a GeoJSON polygon: an outer ring followed by zero or more holes.
+// Rings are closed, and longitudes are unwrapped so that a ring crossing the
+// antimeridian is continuous (e.g. 170 to 190 rather than 170 to -170).
+type Polygon struct {
+	Rings [][]Position
+}
+
+// ParseGeoJSONPolygons parses a GeoJSON Polygon or MultiPolygon geometry, or a Feature
+// wrapping one, into its polygons.
+func ParseGeoJSONPolygons(data []byte) ([]Polygon, error) {
+	var object struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("%w: body is not a GeoJSON object", ErrInvalidGeometry)
+	}
+
+	switch object.Type {
+	case "Feature":
+		if len(object.Geometry) == 0 || string(object.Geometry) == "null" {
+			return nil, fmt.Errorf("%w: feature has no geometry", ErrInvalidGeometry)
+		}
+		return ParseGeoJSONPolygons(object.Geometry)
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(object.Coordinates, &rings); err != nil {
+			return nil, fmt.Errorf("%w: Polygon coordinates must be an array of rings", ErrInvalidGeometry)
+		}
+		polygon, err := newPolygon(rings)
+		if err != nil {
+			return nil, err
+		}
+		return []Polygon{polygon}, nil
+	case "MultiPolygon":
+		var polygonRings [][][][]float64
+		if err := json.Unmarshal(object.Coordinates, &polygonRings); err != nil {
+			return nil, fmt.Errorf("%w: MultiPolygon coordinates must be an array of polygons", ErrInvalidGeometry)
+		}
+		if len(polygonRings) == 0 {
+			return nil, fmt.Errorf("%w: MultiPolygon has no polygons", ErrInvalidGeometry)
+		}
+		polygons := make([]Polygon, 0, len(polygonRings))
+		vertices := 0
+		for i, rings := range polygonRings {
+			polygon, err := newPolygon(rings)
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d: %w", i, err)
+			}
+			vertices += polygon.vertices()
+			polygons = append(polygons, polygon)
+		}
+		if vertices > maxPolygonVertices {
+			return nil, fmt.Errorf("%w: geometry has more than %d vertices", ErrInvalidGeometry, maxPolygonVertices)
+		}
+		return polygons, nil
+	default:
+		return nil, fmt.Errorf("%w: expected a Polygon or MultiPolygon, got %q", ErrInvalidGeometry, object.Type)
+	}
+}
+
+// newPolygon validates GeoJSON rings and unwraps their longitudes.
+func newPolygon(rings [][][]float64) (Polygon, error) {
+	if len(rings) == 0 {
+		return Polygon{}, fmt.Errorf("%w: polygon has no rings", ErrInvalidGeometry)
+	}
+
+	polygon := Polygon{Rings: make([][]Position, 0, len(rings))}
+	for i, coordinates := range rings {
+		if len(coordinates) < 4 {
+			return Polygon{}, fmt.Errorf("%w: ring %d must have at least 4 positions", ErrInvalidGeometry, i)
+		}
+
+		ring := make([]Position, len(coordinates))
+		for j, coordinate := range coordinates {
+			if len(coordinate) < 2 {
+				return Polygon{}, fmt.Errorf("%w: ring %d position %d must have a longitude and latitude", ErrInvalidGeometry, i, j)
+			}
+			lon, lat := coordinate[0], coordinate[1]
+			if lon < -360 || lon > 360 || lat < -90 || lat > 90 {
+				return Polygon{}, fmt.Errorf("%w: ring %d position %d is out of range", ErrInvalidGeometry, i, j)
+			}
+			ring[j] = Position{lon, lat}
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return Polygon{}, fmt.Errorf("%w: ring %d is not closed", ErrInvalidGeometry, i)
+		}
+
+		// Start the outer ring within [-180, 180] and align holes with it so they
+		// unwrap onto the same side of the antimeridian
+		reference := math.Mod(ring[0][0]+540, 360) - 180
+		if i > 0 {
+			minLon, maxLon := polygon.lonRange()
+			reference = (minLon + maxLon) / 2
+		}
+		polygon.Rings = append(polygon.Rings, unwrapRing(ring, reference))
+	}
+
+	if polygon.vertices() > maxPolygonVertices {
+		return Polygon{}, fmt.Errorf("%w: geometry has more than %d vertices", ErrInvalidGeometry, maxPolygonVertices)
+	}
+	return polygon, nil
+}
+
+// unwrapRing shifts longitudes by multiples of 360 so that consecutive vertices are
+// never more than 180 degrees apart, starting within 180 degrees of reference.
+func unwrapRing(ring []Position, reference float64) []Position {
+	unwrapped := make([]Position, len(ring))
+	previous := reference
+	for i, position := range ring {
+		lon := position[0]
+		for lon-previous > 180 {
+			lon -= 360
+		}
+		for previous-lon > 180 {
+			lon += 360
+		}
+		unwrapped[i] = Position{lon, position[1]}
+		previous = lon
+	}
+	return unwrapped
+}
+
+func (p Polygon) vertices() int {
+	count := 0
+	for _, ring := range p.Rings {
+		count += len(ring)
+	}
+	return count
+}
+
+// Bounds returns the polygon's bounding box, crossing the antimeridian when the
+// unwrapped outer ring extends past longitude 180 or -180.
+func (p Polygon) Bounds() BoundingBox {
+	minLon, maxLon := p.lonRange()
+	box := BoundingBox{MinLon: minLon, MaxLon: maxLon, MinLat: 90, MaxLat: -90}
+	for _, position := range p.Rings[0] {
+		box.MinLat = math.Min(box.MinLat, position[1])
+		box.MaxLat = math.Max(box.MaxLat, position[1])
+	}
+
+	switch {
+	case maxLon-minLon >= 360:
+		box.MinLon, box.MaxLon = -180, 180
+	case minLon < -180:
+		box.MinLon += 360
+	case maxLon > 180:
+		box.MaxLon -= 360
+	}
+	return box
+}
+
+func (p Polygon) lonRange() (float64, float64) {
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, position := range p.Rings[0] {
+		minLon = math.Min(minLon, position[0])
+		maxLon = math.Max(maxLon, position[0])
+	}
+	return minLon, maxLon
+}
+
+// shifts returns the longitude offsets a point needs to be tested at to meet the unwrapped rings.
+func (p Polygon) shifts() []float64 {
+	minLon, maxLon := p.lonRange()
+	shifts := []float64{0}
+	if maxLon > 180 {
+		shifts = append(shifts, 360)
+	}
+	if minLon < -180 {
+		shifts = append(shifts, -360)
+	}
+	return shifts
+}
+
+// Contains reports whether a point lies inside the polygon. Points on the outer
+// boundary are inside; points on a hole's boundary are outside.
+func (p Polygon) Contains(latitude, longitude float64) bool {
+	for _, shift := range p.shifts() {
+		point := Position{longitude + shift, latitude}
+		if !ringContains(p.Rings[0], point) {
+			continue
+		}
+		inHole := false
+		for _, hole := range p.Rings[1:] {
+			if ringContains(hole, point) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains reports whether a point lies inside or on a closed ring, using ray casting.
+func ringContains(ring []Position, point Position) bool {
+	x, y := point[0], point[1]
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+
+		if onSegment(ring[j], ring[i], point) {
+			return true
+		}
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// onSegment reports whether p lies on the segment from a to b.
+func onSegment(a, b, p Position) bool {
+	const epsilon = 1e-12
+	cross := (b[0]-a[0])*(p[1]-a[1]) - (b[1]-a[1])*(p[0]-a[0])
+	if math.Abs(cross) > epsilon {
+		return false
+	}
+	return p[0] >= math.Min(a[0], b[0])-epsilon && p[0] <= math.Max(a[0], b[0])+epsilon &&
+		p[1] >= math.Min(a[1], b[1])-epsilon && p[1] <= math.Max(a[1], b[1])+epsilon
+}
+
+// sql returns a predicate testing point(location_longitude, location_latitude) against the
+// polygon with PostgreSQL's geometric operators, prefiltered by the index-backed bounding box.
+func (p Polygon) sql(q *sqlQuery) string {
+	rings := make([]string, len(p.Rings))
+	for i, ring := range p.Rings {
+		rings[i] = q.arg(polygonLiteral(ring)) + "::polygon"
+	}
+
+	var alternatives []string
+	for _, shift := range p.shifts() {
+		point := "point(location_longitude, location_latitude)"
+		if shift != 0 {
+			point = fmt.Sprintf("point(location_longitude + %s, location_latitude)", q.arg(shift))
+		}
+		term := point + " <@ " + rings[0]
+		for _, hole := range rings[1:] {
+			term += " AND NOT " + point + " <@ " + hole
+		}
+		alternatives = append(alternatives, "("+term+")")
+	}
+
+	return "(" + p.Bounds().sql(q) + " AND (" + strings.Join(alternatives, " OR ") + "))"
+}
+
+// polygonLiteral formats a ring as a PostgreSQL polygon literal.
+func polygonLiteral(ring []Position) string {
+	points := make([]string, len(ring))
+	for i, position := range ring {
+		points[i] = "(" + strconv.FormatFloat(position[0], 'f', -1, 64) + "," + strconv.FormatFloat(position[1], 'f', -1, 64) + ")"
+	}
+	return "(" + strings.Join(points, ",") + ")"
+}
@@ -0,0 +1,335 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+// GeoJSONMediaType is the media type of GeoJSON documents (RFC 7946).
+const GeoJSONMediaType = "application/geo+json"
+
+// ErrInvalidFeature is returned when a GeoJSON feature cannot be read as a sensor.
+var ErrInvalidFeature = errors.New("invalid feature")
+
+// FeatureCollection represents a GeoJSON FeatureCollection of sensors.
+// Next is a foreign member carrying the list cursor, as in SensorListV1.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+	Next     string    `json:"next,omitempty"`
+}
+
+// Feature represents a sensor as a GeoJSON Point feature.
+type Feature struct {
+	Type       string           `json:"type"`
+	ID         int              `json:"id,omitempty"`
+	Geometry   *PointGeometry   `json:"geometry"`
+	Properties SensorProperties `json:"properties"`
+}
+
+// PointGeometry represents a GeoJSON Point in [longitude, latitude] order.
+type PointGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// SensorProperties represents the properties of a sensor feature: everything but the latitude and longitude.
+type SensorProperties struct {
+	Name          string                 `json:"name"`
+	Type          string                 `json:"type,omitempty"`
+	Tags          []string               `json:"tags"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Altitude      *float64               `json:"altitude,omitempty"`
+	AltitudeDatum string                 `json:"altitude_datum,omitempty"`
+	Floor         *int                   `json:"floor,omitempty"`
+	AccuracyM     *float64               `json:"accuracy_m,omitempty"`
+	Distance      float64                `json:"distance,omitempty"`
+}
+
+// NewSensorFeature converts sensor metadata into a Point feature.
+func NewSensorFeature(sensorMetadata SensorMetadata) Feature {
+	location := sensorMetadata.Location
+	return Feature{
+		Type:     "Feature",
+		ID:       sensorMetadata.ID,
+		Geometry: &PointGeometry{Type: "Point", Coordinates: []float64{location.Longitude, location.Latitude}},
+		Properties: SensorProperties{
+			Name:          sensorMetadata.Name,
+			Type:          sensorMetadata.Type,
+			Tags:          sensorMetadata.Tags,
+			Attributes:    sensorMetadata.Attributes,
+			Altitude:      location.Altitude,
+			AltitudeDatum: location.AltitudeDatum,
+			Floor:         location.Floor,
+			AccuracyM:     location.AccuracyM,
+		},
+	}
+}
+
+// NewNearestSensorFeature converts a sensor found by a nearest query into a Point feature, with
+// its distance among the properties.
+func NewNearestSensorFeature(sensor NearestSensor) Feature {
+	feature := NewSensorFeature(sensor.SensorMetadata)
+	feature.Properties.Distance = sensor.Distance
+	return feature
+}
+
+// NewSensorFeatureCollection converts sensors into a FeatureCollection.
+func NewSensorFeatureCollection(sensors []SensorMetadata, next string) FeatureCollection {
+	features := make([]Feature, len(sensors))
+	for i, sensorMetadata := range sensors {
+		features[i] = NewSensorFeature(sensorMetadata)
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features, Next: next}
+}
+
+// featureCollectionWriter streams sensors as the features of a single FeatureCollection.
+type featureCollectionWriter struct {
+	writer   *bufio.Writer
+	features int
+}
+
+// newFeatureCollectionWriter writes the start of the FeatureCollection.
+func newFeatureCollectionWriter(w io.Writer) (*featureCollectionWriter, error) {
+	writer := bufio.NewWriter(w)
+	if _, err := writer.WriteString(`{"type":"FeatureCollection","features":[`); err != nil {
+		return nil, err
+	}
+	return &featureCollectionWriter{writer: writer}, nil
+}
+
+// Write writes a sensor as a feature.
+func (w *featureCollectionWriter) Write(sensorMetadata SensorMetadata) error {
+	data, err := json.Marshal(NewSensorFeature(sensorMetadata))
+	if err != nil {
+		return err
+	}
+	if w.features > 0 {
+		if err := w.writer.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	w.features++
+	_, err = w.writer.Write(data)
+	return err
+}
+
+// Flush writes any buffered features to the underlying writer.
+func (w *featureCollectionWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// Close ends the FeatureCollection.
+func (w *featureCollectionWriter) Close() error {
+	if _, err := w.writer.WriteString("]}\n"); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+// sensorFeatureReader reads the features of a GeoJSON FeatureCollection one at a time, so that an
+// import is never held in memory as a whole. A single Feature is read as a collection of one.
+type sensorFeatureReader struct {
+	decoder    *json.Decoder
+	objectType string
+	members    map[string]json.RawMessage
+	inFeatures bool
+	features   int
+	done       bool
+}
+
+// newSensorFeatureReader reads the start of the GeoJSON object.
+func newSensorFeatureReader(r io.Reader) (*sensorFeatureReader, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, invalidGeoJSON(err)
+	}
+	if token != json.Delim('{') {
+		return nil, fmt.Errorf("%w: body is not a GeoJSON object", ErrInvalidGeometry)
+	}
+	return &sensorFeatureReader{decoder: decoder, members: make(map[string]json.RawMessage)}, nil
+}
+
+// Read returns the next sensor and the index of its feature. It returns io.EOF after the last one.
+// Errors wrapping ErrInvalidFeature concern that feature only; any other error ends the document.
+func (r *sensorFeatureReader) Read() (SensorMetadata, int, error) {
+	for {
+		if r.inFeatures {
+			if r.decoder.More() {
+				var feature json.RawMessage
+				if err := r.decoder.Decode(&feature); err != nil {
+					return SensorMetadata{}, 0, invalidGeoJSON(err)
+				}
+				index := r.features
+				r.features++
+				sensorMetadata, err := parseSensorFeature(feature)
+				return sensorMetadata, index, err
+			}
+			if _, err := r.decoder.Token(); err != nil {
+				return SensorMetadata{}, 0, invalidGeoJSON(err)
+			}
+			r.inFeatures = false
+			continue
+		}
+		if r.done {
+			return SensorMetadata{}, 0, io.EOF
+		}
+		if !r.decoder.More() {
+			return r.end()
+		}
+
+		token, err := r.decoder.Token()
+		if err != nil {
+			return SensorMetadata{}, 0, invalidGeoJSON(err)
+		}
+		switch key, _ := token.(string); key {
+		case "type":
+			if err := r.decoder.Decode(&r.objectType); err != nil {
+				return SensorMetadata{}, 0, invalidGeoJSON(err)
+			}
+			if r.objectType != "FeatureCollection" && r.objectType != "Feature" {
+				return SensorMetadata{}, 0, fmt.Errorf("%w: expected a FeatureCollection or Feature, got %q", ErrInvalidGeometry, r.objectType)
+			}
+		case "features":
+			if r.objectType == "Feature" {
+				if err := r.skip(); err != nil {
+					return SensorMetadata{}, 0, err
+				}
+				continue
+			}
+			token, err := r.decoder.Token()
+			if err != nil {
+				return SensorMetadata{}, 0, invalidGeoJSON(err)
+			}
+			if token == json.Delim('[') {
+				r.inFeatures = true
+			} else if token != nil {
+				return SensorMetadata{}, 0, fmt.Errorf("%w: features must be an array", ErrInvalidGeometry)
+			}
+		case "geometry", "properties":
+			// Kept in case the object turns out to be a single Feature
+			var member json.RawMessage
+			if err := r.decoder.Decode(&member); err != nil {
+				return SensorMetadata{}, 0, invalidGeoJSON(err)
+			}
+			r.members[key] = member
+		default:
+			if err := r.skip(); err != nil {
+				return SensorMetadata{}, 0, err
+			}
+		}
+	}
+}
+
+// end reads the end of the GeoJSON object, and returns a single Feature as its only sensor.
+func (r *sensorFeatureReader) end() (SensorMetadata, int, error) {
+	if _, err := r.decoder.Token(); err != nil {
+		return SensorMetadata{}, 0, invalidGeoJSON(err)
+	}
+	r.done = true
+
+	switch r.objectType {
+	case "FeatureCollection":
+		return SensorMetadata{}, 0, io.EOF
+	case "Feature":
+		r.members["type"] = json.RawMessage(`"Feature"`)
+		feature, err := json.Marshal(r.members)
+		if err != nil {
+			return SensorMetadata{}, 0, err
+		}
+		sensorMetadata, err := parseSensorFeature(feature)
+		return sensorMetadata, 0, err
+	default:
+		return SensorMetadata{}, 0, fmt.Errorf("%w: expected a FeatureCollection or Feature, got %q", ErrInvalidGeometry, r.objectType)
+	}
+}
+
+// skip reads past a member value that the import doesn't use.
+func (r *sensorFeatureReader) skip() error {
+	var value json.RawMessage
+	if err := r.decoder.Decode(&value); err != nil {
+		return invalidGeoJSON(err)
+	}
+	return nil
+}
+
+// Helper function to report a GeoJSON document that can't be decoded. Errors reading the body,
+// such as *http.MaxBytesError, are returned as they are.
+func invalidGeoJSON(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return fmt.Errorf("%w: body is not a GeoJSON object", ErrInvalidGeometry)
+	}
+	return err
+}
+
+// parseSensorFeature converts a Point feature into sensor metadata. Validation is left to the caller.
+// A third coordinate is an altitude above the WGS84 ellipsoid, unless the properties give one.
+func parseSensorFeature(data json.RawMessage) (SensorMetadata, error) {
+	var feature struct {
+		Type       string          `json:"type"`
+		Geometry   json.RawMessage `json:"geometry"`
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &feature); err != nil || feature.Type != "Feature" {
+		return SensorMetadata{}, fmt.Errorf("%w: not a GeoJSON Feature", ErrInvalidFeature)
+	}
+
+	var properties SensorProperties
+	if len(feature.Properties) > 0 && string(feature.Properties) != "null" {
+		if err := json.Unmarshal(feature.Properties, &properties); err != nil {
+			return SensorMetadata{}, fmt.Errorf("%w: invalid properties: %v", ErrInvalidFeature, err)
+		}
+	}
+	sensorMetadata := SensorMetadata{
+		Name:       properties.Name,
+		Type:       properties.Type,
+		Tags:       properties.Tags,
+		Attributes: properties.Attributes,
+		Location: Location{
+			Altitude:      properties.Altitude,
+			AltitudeDatum: properties.AltitudeDatum,
+			Floor:         properties.Floor,
+			AccuracyM:     properties.AccuracyM,
+		},
+	}
+
+	if len(feature.Geometry) == 0 || string(feature.Geometry) == "null" {
+		return sensorMetadata, fmt.Errorf("%w: feature has no geometry", ErrInvalidFeature)
+	}
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(feature.Geometry, &geometry); err != nil {
+		return sensorMetadata, fmt.Errorf("%w: invalid geometry", ErrInvalidFeature)
+	}
+	if geometry.Type != "Point" {
+		return sensorMetadata, fmt.Errorf("%w: geometry must be a Point, got %q", ErrInvalidFeature, geometry.Type)
+	}
+
+	var coordinates []float64
+	if err := json.Unmarshal(geometry.Coordinates, &coordinates); err != nil || len(coordinates) < 2 || len(coordinates) > 3 {
+		return sensorMetadata, fmt.Errorf("%w: Point coordinates must be [longitude, latitude] or [longitude, latitude, altitude]", ErrInvalidFeature)
+	}
+	sensorMetadata.Location.Longitude, sensorMetadata.Location.Latitude = coordinates[0], coordinates[1]
+	if len(coordinates) == 3 && sensorMetadata.Location.Altitude == nil {
+		altitude := coordinates[2]
+		sensorMetadata.Location.Altitude = &altitude
+		sensorMetadata.Location.AltitudeDatum = DatumWGS84
+	}
+
+	return sensorMetadata, nil
+}
+
+// isGeoJSONContentType reports whether a Content-Type header is GeoJSON or plain JSON.
+func isGeoJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == GeoJSONMediaType || mediaType == "application/json")
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// gpxStatusSymbols maps the statuses with a built-in look to the waypoint symbol that GPS
+// devices show for them. Every waypoint also carries its status as its type.
+var gpxStatusSymbols = map[string]string{
+	StatusActive:         "Flag, Green",
+	StatusStale:          "Flag, Red",
+	StatusDecommissioned: "Block, Red",
+}
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+type gpxWaypoint struct {
+	Latitude    float64  `xml:"lat,attr"`
+	Longitude   float64  `xml:"lon,attr"`
+	Elevation   *float64 `xml:"ele,omitempty"`
+	Name        string   `xml:"name"`
+	Description string   `xml:"desc,omitempty"`
+	Symbol      string   `xml:"sym,omitempty"`
+	Type        string   `xml:"type"`
+}
+
+// WriteGPX writes sensors as GPX 1.1 waypoints. Only altitudes above mean sea level are kept
+// as elevations, which is what GPS devices expect.
+func WriteGPX(w io.Writer, sensors []SensorMetadata, options WaypointOptions) error {
+	document := gpxDocument{Version: "1.1", Creator: "sensor-metadata", Waypoints: make([]gpxWaypoint, len(sensors))}
+	for i, sensorMetadata := range sensors {
+		status := options.status(sensorMetadata)
+		waypoint := gpxWaypoint{
+			Latitude:    sensorMetadata.Location.Latitude,
+			Longitude:   sensorMetadata.Location.Longitude,
+			Name:        sensorMetadata.Name,
+			Description: describeSensor(sensorMetadata),
+			Symbol:      gpxStatusSymbols[status],
+			Type:        status,
+		}
+		if sensorMetadata.Location.AltitudeDatum == DatumMSL {
+			waypoint.Elevation = sensorMetadata.Location.Altitude
+		}
+		document.Waypoints[i] = waypoint
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,764 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// GraphQLRequest represents the body of a GraphQL request.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Codes of the errors returned by the GraphQL API, in the 'code' of their extensions.
+const (
+	GraphQLBadUserInput    = "BAD_USER_INPUT"
+	GraphQLNotFound        = "NOT_FOUND"
+	GraphQLAlreadyExists   = "ALREADY_EXISTS"
+	GraphQLQueryTooComplex = "QUERY_TOO_COMPLEX"
+	GraphQLInternalError   = "INTERNAL_SERVER_ERROR"
+)
+
+// GraphQL handles the HTTP POST request to run a GraphQL query or mutation, and the HTTP GET
+// request to run a query given in the 'query', 'operationName' and 'variables' parameters.
+// Requests that can't run respond 400 Bad Request; all others respond 200 OK with their data
+// and the errors of the fields that failed.
+func (h *Handler) GraphQL(w http.ResponseWriter, r *http.Request) {
+	var request GraphQLRequest
+	if r.Method == http.MethodGet {
+		params := r.URL.Query()
+		request.Query = params.Get("query")
+		request.OperationName = params.Get("operationName")
+		if variables := params.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				graphQLErrorResponse(w, http.StatusBadRequest, "Invalid 'variables' parameter")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		graphQLErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if request.Query == "" {
+		graphQLErrorResponse(w, http.StatusBadRequest, "Missing 'query'")
+		return
+	}
+
+	// Parse and validate the document
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(request.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if validation := graphql.ValidateDocument(&graphQLSchema, document, nil); !validation.IsValid {
+		jsonResponse(w, http.StatusBadRequest, graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	operation, err := graphQLOperation(document, request.OperationName)
+	if err != nil {
+		graphQLErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.Method == http.MethodGet && operation.Operation != ast.OperationTypeQuery {
+		w.Header().Set("Allow", http.MethodPost)
+		graphQLErrorResponse(w, http.StatusMethodNotAllowed, "Mutations must be sent with POST")
+		return
+	}
+
+	// Refuse operations that would do too much work
+	complexity := graphQLComplexity(document, operation, request.Variables)
+	if complexity > maxGraphQLComplexity {
+		jsonResponse(w, http.StatusBadRequest, graphql.Result{Errors: []gqlerrors.FormattedError{{
+			Message:    fmt.Sprintf("Query complexity %d exceeds the limit of %d", complexity, maxGraphQLComplexity),
+			Extensions: map[string]interface{}{"code": GraphQLQueryTooComplex},
+		}}})
+		return
+	}
+
+	// Mutations read the sensors they save from the primary
+	reader := h.reader(r)
+	if operation.Operation == ast.OperationTypeMutation {
+		reader = primaryOf(h.repo)
+	}
+	ctx := context.WithValue(r.Context(), graphQLContextKey{}, &graphQLContext{handler: h, reader: reader, loaders: newGraphQLLoaders(reader)})
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphQLSchema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       ctx,
+	})
+	restoreGraphQLErrorExtensions(result.Errors)
+	jsonResponse(w, http.StatusOK, result)
+}
+
+// Helper function to restore the extensions of errors returned by the thunks of batch loaders,
+// which graphql-go wraps in errors without them.
+func restoreGraphQLErrorExtensions(errs []gqlerrors.FormattedError) {
+	for i := range errs {
+		var err error = errs[i]
+		for err != nil && errs[i].Extensions == nil {
+			switch e := err.(type) {
+			case *graphQLError:
+				errs[i].Extensions = e.Extensions()
+			case gqlerrors.FormattedError:
+				err = e.OriginalError()
+			case *gqlerrors.Error:
+				err = e.OriginalError
+			default:
+				err = nil
+			}
+		}
+	}
+}
+
+// Helper function to send a GraphQL response with a single error and no data.
+func graphQLErrorResponse(w http.ResponseWriter, statusCode int, message string) {
+	jsonResponse(w, statusCode, graphql.Result{Errors: gqlerrors.FormatErrors(errors.New(message))})
+}
+
+// graphQLContextKey is the context key of the graphQLContext of a request.
+type graphQLContextKey struct{}
+
+// graphQLContext is the state that the resolvers of a request share.
+type graphQLContext struct {
+	handler *Handler
+	// reader serves the reads of the request
+	reader  Repository
+	loaders *graphQLLoaders
+}
+
+// graphQLRequestContext returns the state of the request a resolver runs for.
+func graphQLRequestContext(p graphql.ResolveParams) *graphQLContext {
+	return p.Context.Value(graphQLContextKey{}).(*graphQLContext)
+}
+
+// graphQLError is an error with a code, and the failures of individual fields for invalid input, as extensions.
+type graphQLError struct {
+	message string
+	code    string
+	fields  []FieldError
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *graphQLError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.code}
+	if len(e.fields) > 0 {
+		extensions["fields"] = e.fields
+	}
+	return extensions
+}
+
+// graphQLInternalError reports a failure without revealing its details.
+func graphQLInternalError(message string) error {
+	return &graphQLError{message: message, code: GraphQLInternalError}
+}
+
+// graphQLBadUserInput reports invalid input, with the failures of individual fields when the
+// error comes from validation. Fields are named by their path in the input, e.g. input.location.altitudeDatum.
+func graphQLBadUserInput(err error) error {
+	fields := validationFields(err)
+	for i := range fields {
+		fields[i].Field = graphQLFieldPath(fields[i].Field)
+	}
+	return &graphQLError{message: err.Error(), code: GraphQLBadUserInput, fields: fields}
+}
+
+// graphQLRepositoryError maps a repository error to a GraphQL error.
+func graphQLRepositoryError(err error, message string) error {
+	switch {
+	case errors.Is(err, ErrSensorNotFound):
+		return &graphQLError{message: "Sensor metadata not found", code: GraphQLNotFound}
+	case errors.Is(err, ErrSensorExists):
+		return &graphQLError{message: "Sensor metadata already exists", code: GraphQLAlreadyExists}
+	case errors.Is(err, ErrInvalidAttributes):
+		return graphQLBadUserInput(err)
+	default:
+		return graphQLInternalError(message)
+	}
+}
+
+// graphQLFieldPath turns the JSON path of a sensor field into its path in a GraphQL input,
+// leaving the keys of attributes as they are.
+func graphQLFieldPath(field string) string {
+	segments := strings.Split(field, ".")
+	for i, segment := range segments {
+		segments[i] = snakeToCamel(segment)
+		if segment == "attributes" {
+			break
+		}
+	}
+	return "input." + strings.Join(segments, ".")
+}
+
+// snakeToCamel converts a snake_case name to camelCase.
+func snakeToCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// graphQLJSON is a scalar for arbitrary JSON values such as attributes and schemas.
+var graphQLJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "An arbitrary JSON value.",
+	Serialize: func(value interface{}) interface{} {
+		if raw, ok := value.(json.RawMessage); ok {
+			var decoded interface{}
+			if err := json.Unmarshal(raw, &decoded); err != nil {
+				return nil
+			}
+			return decoded
+		}
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: graphQLLiteralValue,
+})
+
+// graphQLLiteralValue converts a literal in a query to the value JSON decoding would give.
+func graphQLLiteralValue(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		number, _ := strconv.ParseFloat(value.Value, 64)
+		return number
+	case *ast.FloatValue:
+		number, _ := strconv.ParseFloat(value.Value, 64)
+		return number
+	case *ast.StringValue:
+		return value.Value
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.EnumValue:
+		return value.Value
+	case *ast.ListValue:
+		list := make([]interface{}, len(value.Values))
+		for i, item := range value.Values {
+			list[i] = graphQLLiteralValue(item)
+		}
+		return list
+	case *ast.ObjectValue:
+		object := make(map[string]interface{}, len(value.Fields))
+		for _, field := range value.Fields {
+			object[field.Name.Value] = graphQLLiteralValue(field.Value)
+		}
+		return object
+	default:
+		return nil
+	}
+}
+
+var graphQLLocation = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Location",
+	Fields: graphql.Fields{
+		"latitude":      &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"longitude":     &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"altitude":      &graphql.Field{Type: graphql.Float, Description: "Meters above the altitude datum."},
+		"altitudeDatum": &graphql.Field{Type: graphql.String, Description: "WGS84 or MSL."},
+		"floor":         &graphql.Field{Type: graphql.Int},
+		"accuracyM":     &graphql.Field{Type: graphql.Float, Description: "Horizontal accuracy in meters."},
+	},
+})
+
+var graphQLTag = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Tag",
+	Fields: graphql.Fields{
+		"key":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var graphQLTagCount = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TagCount",
+	Fields: graphql.Fields{
+		"tag":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var graphQLSensorType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "SensorType",
+	Fields: graphql.Fields{
+		"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.String},
+		"schema":      &graphql.Field{Type: graphql.NewNonNull(graphQLJSON), Description: "JSON Schema of the attributes of sensors of the type."},
+	},
+})
+
+var graphQLSensor = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Sensor",
+	Fields: graphql.Fields{
+		"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"type": &graphql.Field{
+			Type: graphQLSensorType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				sensorType := p.Source.(graphQLSensorNode).Type
+				if sensorType == "" {
+					return nil, nil
+				}
+				return graphQLRequestContext(p).loaders.sensorTypes.load(sensorType), nil
+			},
+		},
+		"location":       &graphql.Field{Type: graphql.NewNonNull(graphQLLocation)},
+		"tags":           &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+		"structuredTags": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphQLTag)))},
+		"attributes":     &graphql.Field{Type: graphQLJSON},
+		"distance": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Distance in meters from the location of a nearest query.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if distance := p.Source.(graphQLSensorNode).Distance; distance != nil {
+					return *distance, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+var graphQLSensorConnection = graphql.NewObject(graphql.ObjectConfig{
+	Name: "SensorConnection",
+	Fields: graphql.Fields{
+		"nodes": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphQLSensor)))},
+		"next":  &graphql.Field{Type: graphql.String, Description: "Cursor of the next page, given as 'after'. Null on the last page."},
+	},
+})
+
+var graphQLSensorFilter = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "SensorFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"tags":       &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Tag filter expression, e.g. 'vendor:acme AND NOT retired'."},
+		"attributes": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Attribute filters, e.g. 'attr.sampling_rate>=10'."},
+		"names":      &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+	},
+})
+
+var graphQLLocationInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "LocationInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"latitude":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+		"longitude":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+		"altitude":      &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"altitudeDatum": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"floor":         &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"accuracyM":     &graphql.InputObjectFieldConfig{Type: graphql.Float},
+	},
+})
+
+var graphQLSensorInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "SensorInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"type":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"location":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphQLLocationInput)},
+		"tags":       &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		"attributes": &graphql.InputObjectFieldConfig{Type: graphQLJSON},
+	},
+})
+
+var graphQLQuery = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"sensor": &graphql.Field{
+			Type:        graphQLSensor,
+			Description: "A sensor by name, or null if there is none.",
+			Args: graphql.FieldConfigArgument{
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return graphQLRequestContext(p).loaders.sensors.load(p.Args["name"].(string)), nil
+			},
+		},
+		"sensors": &graphql.Field{
+			Type:        graphql.NewNonNull(graphQLSensorConnection),
+			Description: "A page of sensors ordered by ID.",
+			Args: graphql.FieldConfigArgument{
+				"filter": &graphql.ArgumentConfig{Type: graphQLSensorFilter},
+				"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultListLimit, Description: "Page size, at most 1000."},
+				"after":  &graphql.ArgumentConfig{Type: graphql.String, Description: "The 'next' cursor of the previous page."},
+			},
+			Resolve: resolveSensors,
+		},
+		"nearest": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphQLSensor))),
+			Description: "The k sensors nearest to a location, nearest first.",
+			Args: graphql.FieldConfigArgument{
+				"lat":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+				"lon":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+				"k":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1, Description: "Number of sensors, at most 1000."},
+				"tags": &graphql.ArgumentConfig{Type: graphql.String, Description: "Tag filter expression."},
+			},
+			Resolve: resolveNearest,
+		},
+		"tags": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphQLTagCount))),
+			Description: "Distinct tags with their usage counts.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				tagCounts, err := graphQLRequestContext(p).reader.GetTagCounts()
+				if err != nil {
+					return nil, graphQLInternalError("Failed to list tags")
+				}
+				return tagCounts, nil
+			},
+		},
+		"sensorType": &graphql.Field{
+			Type: graphQLSensorType,
+			Args: graphql.FieldConfigArgument{
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return graphQLRequestContext(p).loaders.sensorTypes.load(p.Args["name"].(string)), nil
+			},
+		},
+		"sensorTypes": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphQLSensorType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				sensorTypes, err := graphQLRequestContext(p).reader.ListSensorTypes()
+				if err != nil {
+					return nil, graphQLInternalError("Failed to list sensor types")
+				}
+				return sensorTypes, nil
+			},
+		},
+	},
+})
+
+var graphQLMutation = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createSensor": &graphql.Field{
+			Type: graphql.NewNonNull(graphQLSensor),
+			Args: graphql.FieldConfigArgument{
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphQLSensorInput)},
+			},
+			Resolve: resolveCreateSensor,
+		},
+		"updateSensor": &graphql.Field{
+			Type:        graphql.NewNonNull(graphQLSensor),
+			Description: "Replaces a sensor, which may rename it.",
+			Args: graphql.FieldConfigArgument{
+				"name":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphQLSensorInput)},
+			},
+			Resolve: resolveUpdateSensor,
+		},
+		"deleteSensor": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Args: graphql.FieldConfigArgument{
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				repo := graphQLRequestContext(p).handler.repo
+				outcomes, err := repo.ApplySensorBatch([]BatchOperation{{Op: BatchDelete, Name: p.Args["name"].(string)}}, false)
+				if err == nil {
+					err = outcomes[0].Err
+				}
+				if err != nil {
+					return nil, graphQLRepositoryError(err, "Failed to delete sensor metadata")
+				}
+				return true, nil
+			},
+		},
+		"addSensorTags": &graphql.Field{
+			Type: graphql.NewNonNull(graphQLSensor),
+			Args: graphql.FieldConfigArgument{
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"tags": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				tags, err := NormalizeTags(graphQLStrings(p.Args["tags"]))
+				if err != nil {
+					return nil, graphQLBadUserInput(err)
+				}
+				if len(tags) == 0 {
+					return nil, graphQLBadUserInput(errors.New("Missing 'tags'"))
+				}
+				sensorMetadata, err := graphQLRequestContext(p).handler.repo.AddSensorTags(p.Args["name"].(string), tags)
+				if err != nil {
+					return nil, graphQLRepositoryError(err, "Failed to add sensor tags")
+				}
+				return newGraphQLSensor(*sensorMetadata), nil
+			},
+		},
+		"removeSensorTag": &graphql.Field{
+			Type: graphql.NewNonNull(graphQLSensor),
+			Args: graphql.FieldConfigArgument{
+				"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"tag":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				tag, err := NormalizeTag(p.Args["tag"].(string))
+				if err != nil {
+					return nil, graphQLBadUserInput(err)
+				}
+				sensorMetadata, err := graphQLRequestContext(p).handler.repo.RemoveSensorTag(p.Args["name"].(string), tag)
+				if err != nil {
+					return nil, graphQLRepositoryError(err, "Failed to remove sensor tag")
+				}
+				return newGraphQLSensor(*sensorMetadata), nil
+			},
+		},
+	},
+})
+
+// graphQLSchema is the schema of the GraphQL API. Its resolvers find the Handler of a request in its context.
+var graphQLSchema = func() graphql.Schema {
+	// A field of Sensor returning sensors can't be declared with the type that it belongs to
+	graphQLSensor.AddFieldConfig("neighbours", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphQLSensor))),
+		Description: "The k sensors nearest to this one, nearest first.",
+		Args: graphql.FieldConfigArgument{
+			"k":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 5, Description: "Number of sensors, at most 1000."},
+			"tags": &graphql.ArgumentConfig{Type: graphql.String, Description: "Tag filter expression."},
+		},
+		Resolve: resolveNeighbours,
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: graphQLQuery, Mutation: graphQLMutation})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+// resolveSensors lists a page of sensors like the REST list endpoint.
+func resolveSensors(p graphql.ResolveParams) (interface{}, error) {
+	filter := SensorFilter{Limit: p.Args["first"].(int)}
+	if filter.Limit < 1 || filter.Limit > maxListLimit {
+		return nil, graphQLBadUserInput(errors.New("Invalid 'first' argument"))
+	}
+	if after, ok := p.Args["after"].(string); ok && after != "" {
+		var err error
+		filter.AfterID, err = strconv.Atoi(after)
+		if err != nil {
+			return nil, graphQLBadUserInput(errors.New("Invalid 'after' argument"))
+		}
+	}
+
+	if input, ok := p.Args["filter"].(map[string]interface{}); ok {
+		var err error
+		if tags, ok := input["tags"].(string); ok && tags != "" {
+			filter.Tags, err = ParseTagExpr(tags)
+			if err != nil {
+				return nil, graphQLBadUserInput(err)
+			}
+		}
+		filter.Attributes, err = ParseAttributeFilterList(graphQLStrings(input["attributes"]))
+		if err != nil {
+			return nil, graphQLBadUserInput(err)
+		}
+		if names, ok := input["names"]; ok && names != nil {
+			filter.Names = graphQLStrings(names)
+		}
+	}
+
+	sensors, err := graphQLRequestContext(p).reader.ListSensorMetadata(filter)
+	if err != nil {
+		return nil, graphQLInternalError("Failed to list sensor metadata")
+	}
+
+	nodes := make([]graphQLSensorNode, len(sensors))
+	for i, sensorMetadata := range sensors {
+		nodes[i] = newGraphQLSensor(sensorMetadata)
+	}
+	connection := map[string]interface{}{"nodes": nodes, "next": nil}
+	if len(sensors) == filter.Limit {
+		connection["next"] = strconv.Itoa(sensors[len(sensors)-1].ID)
+	}
+	return connection, nil
+}
+
+// resolveNearest finds the sensors nearest to a location.
+func resolveNearest(p graphql.ResolveParams) (interface{}, error) {
+	query := NearestQuery{Latitude: p.Args["lat"].(float64), Longitude: p.Args["lon"].(float64), Limit: p.Args["k"].(int)}
+	if query.Limit < 1 || query.Limit > maxListLimit {
+		return nil, graphQLBadUserInput(errors.New("Invalid 'k' argument"))
+	}
+	if tags, ok := p.Args["tags"].(string); ok && tags != "" {
+		var err error
+		query.Tags, err = ParseTagExpr(tags)
+		if err != nil {
+			return nil, graphQLBadUserInput(err)
+		}
+	}
+
+	sensors, err := graphQLRequestContext(p).reader.FindNearestSensorMetadata(query)
+	if err != nil {
+		return nil, graphQLInternalError("Failed to find sensor metadata")
+	}
+	nodes := make([]graphQLSensorNode, len(sensors))
+	for i, sensor := range sensors {
+		nodes[i] = newGraphQLSensor(sensor.SensorMetadata)
+		nodes[i].Distance = &sensors[i].Distance
+	}
+	return nodes, nil
+}
+
+// resolveNeighbours finds the sensors nearest to a sensor, through a loader that finds those of
+// every sensor of the request with the same arguments at once.
+func resolveNeighbours(p graphql.ResolveParams) (interface{}, error) {
+	k := p.Args["k"].(int)
+	if k < 1 || k > maxListLimit {
+		return nil, graphQLBadUserInput(errors.New("Invalid 'k' argument"))
+	}
+	var tags TagExpr
+	if expr, ok := p.Args["tags"].(string); ok && expr != "" {
+		var err error
+		tags, err = ParseTagExpr(expr)
+		if err != nil {
+			return nil, graphQLBadUserInput(err)
+		}
+	}
+
+	id := strconv.Itoa(p.Source.(graphQLSensorNode).ID)
+	return graphQLRequestContext(p).loaders.neighboursOf(k, tags).load(id), nil
+}
+
+// resolveCreateSensor creates a sensor and returns it as saved.
+func resolveCreateSensor(p graphql.ResolveParams) (interface{}, error) {
+	h := graphQLRequestContext(p).handler
+	sensorMetadata := sensorFromGraphQLInput(p.Args["input"].(map[string]interface{}))
+	if err := h.prepareGraphQLSensor(&sensorMetadata, "Failed to create sensor metadata"); err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.CreateSensorMetadata(&sensorMetadata); err != nil {
+		return nil, graphQLRepositoryError(err, "Failed to create sensor metadata")
+	}
+	return h.graphQLSensor(sensorMetadata.Name)
+}
+
+// resolveUpdateSensor replaces the sensor with a name and returns it as saved.
+func resolveUpdateSensor(p graphql.ResolveParams) (interface{}, error) {
+	h := graphQLRequestContext(p).handler
+	existing, err := primaryOf(h.repo).GetSensorMetadataByName(p.Args["name"].(string))
+	if err != nil {
+		return nil, graphQLRepositoryError(err, "Failed to update sensor metadata")
+	}
+
+	sensorMetadata := sensorFromGraphQLInput(p.Args["input"].(map[string]interface{}))
+	sensorMetadata.ID = existing.ID
+	if err := h.prepareGraphQLSensor(&sensorMetadata, "Failed to update sensor metadata"); err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.UpdateSensorMetadata(&sensorMetadata); err != nil {
+		return nil, graphQLRepositoryError(err, "Failed to update sensor metadata")
+	}
+	return h.graphQLSensor(sensorMetadata.Name)
+}
+
+// Helper function to validate and normalize sensor metadata from a mutation.
+func (h *Handler) prepareGraphQLSensor(sensorMetadata *SensorMetadata, message string) error {
+	if status, err := h.prepareSensorMetadata(sensorMetadata); err != nil {
+		if status == http.StatusInternalServerError {
+			return graphQLInternalError(message)
+		}
+		return graphQLBadUserInput(err)
+	}
+	return nil
+}
+
+// Helper function to read a sensor that was just saved.
+func (h *Handler) graphQLSensor(name string) (interface{}, error) {
+	sensorMetadata, err := primaryOf(h.repo).GetSensorMetadataByName(name)
+	if err != nil {
+		return nil, graphQLRepositoryError(err, "Failed to get sensor metadata")
+	}
+	return newGraphQLSensor(*sensorMetadata), nil
+}
+
+// graphQLSensorNode is the source of the Sensor type, mapped from the sensors of the repository
+// with the fields the GraphQL API derives from them. Distance is only set for nearest queries.
+type graphQLSensorNode struct {
+	ID             int
+	Name           string
+	Type           string
+	Location       Location
+	Tags           []string
+	StructuredTags []Tag
+	Attributes     map[string]interface{}
+	Distance       *float64
+}
+
+// newGraphQLSensor maps a sensor to the source of the Sensor type.
+func newGraphQLSensor(sensorMetadata SensorMetadata) graphQLSensorNode {
+	return graphQLSensorNode{
+		ID:             sensorMetadata.ID,
+		Name:           sensorMetadata.Name,
+		Type:           sensorMetadata.Type,
+		Location:       sensorMetadata.Location,
+		Tags:           sensorMetadata.Tags,
+		StructuredTags: sensorMetadata.structuredTags(),
+		Attributes:     sensorMetadata.Attributes,
+	}
+}
+
+// sensorFromGraphQLInput converts a SensorInput. Its required fields have been checked against the schema.
+func sensorFromGraphQLInput(input map[string]interface{}) SensorMetadata {
+	sensorMetadata := SensorMetadata{Name: input["name"].(string)}
+	if sensorType, ok := input["type"].(string); ok {
+		sensorMetadata.Type = sensorType
+	}
+	sensorMetadata.Tags = graphQLStrings(input["tags"])
+	if attributes, ok := input["attributes"].(map[string]interface{}); ok {
+		sensorMetadata.Attributes = attributes
+	}
+
+	location := input["location"].(map[string]interface{})
+	sensorMetadata.Location.Latitude = location["latitude"].(float64)
+	sensorMetadata.Location.Longitude = location["longitude"].(float64)
+	if altitude, ok := location["altitude"].(float64); ok {
+		sensorMetadata.Location.Altitude = &altitude
+	}
+	if datum, ok := location["altitudeDatum"].(string); ok {
+		sensorMetadata.Location.AltitudeDatum = datum
+	}
+	if floor, ok := location["floor"].(int); ok {
+		sensorMetadata.Location.Floor = &floor
+	}
+	if accuracy, ok := location["accuracyM"].(float64); ok {
+		sensorMetadata.Location.AccuracyM = &accuracy
+	}
+	return sensorMetadata
+}
+
+// graphQLStrings converts a list argument of strings.
+func graphQLStrings(value interface{}) []string {
+	list, _ := value.([]interface{})
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
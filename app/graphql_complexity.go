@@ -0,0 +1,141 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// maxGraphQLComplexity bounds the work of a GraphQL request. It allows a full page of 1000
+// sensors with a dozen fields each.
+const maxGraphQLComplexity = 15000
+
+// graphQLListSizes are the arguments and defaults that give the length of the lists returned
+// by fields, which multiply the cost of the fields selected from their items.
+var graphQLListSizes = map[string]struct {
+	argument string
+	size     int
+}{
+	"sensors":    {"first", defaultListLimit},
+	"nearest":    {"k", 1},
+	"neighbours": {"k", 5},
+}
+
+// graphQLOperation returns the operation of a document to run: the one with the given name, or
+// the only one when no name is given.
+func graphQLOperation(document *ast.Document, operationName string) (*ast.OperationDefinition, error) {
+	var operations []*ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			if operationName == "" || (operation.Name != nil && operation.Name.Value == operationName) {
+				operations = append(operations, operation)
+			}
+		}
+	}
+
+	switch {
+	case len(operations) == 1:
+		return operations[0], nil
+	case operationName != "":
+		return nil, fmt.Errorf("Unknown operation %q", operationName)
+	default:
+		return nil, errors.New("Missing 'operationName' for a document with several operations")
+	}
+}
+
+// graphQLComplexity estimates the cost of running an operation: one per field resolved, with
+// the fields selected from a list counted once per item it may hold.
+func graphQLComplexity(document *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) int {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	// Variables left out of the request take their defaults
+	values := map[string]interface{}{}
+	for _, definition := range operation.VariableDefinitions {
+		if definition.DefaultValue != nil {
+			values[definition.Variable.Name.Value] = definition.DefaultValue.GetValue()
+		}
+	}
+	for name, value := range variables {
+		values[name] = value
+	}
+
+	c := complexityCounter{fragments: fragments, variables: values, visiting: map[string]bool{}}
+	return c.selectionSet(operation.SelectionSet)
+}
+
+// complexityCounter walks the selection sets of an operation.
+type complexityCounter struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+func (c *complexityCounter) selectionSet(selectionSet *ast.SelectionSet) int {
+	if selectionSet == nil {
+		return 0
+	}
+
+	cost := 0
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost += 1 + c.listSize(selection)*c.selectionSet(selection.SelectionSet)
+		case *ast.InlineFragment:
+			cost += c.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			// Validation rejects fragment cycles, but the walk mustn't rely on running after it
+			name := selection.Name.Value
+			if fragment, ok := c.fragments[name]; ok && !c.visiting[name] {
+				c.visiting[name] = true
+				cost += c.selectionSet(fragment.SelectionSet)
+				c.visiting[name] = false
+			}
+		}
+	}
+	return cost
+}
+
+// listSize returns the number of items a field may return, which is 1 for fields that aren't lists.
+func (c *complexityCounter) listSize(field *ast.Field) int {
+	list, ok := graphQLListSizes[field.Name.Value]
+	if !ok {
+		return 1
+	}
+
+	size := list.size
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != list.argument {
+			continue
+		}
+		var value interface{}
+		switch v := argument.Value.(type) {
+		case *ast.Variable:
+			value = c.variables[v.Name.Value]
+		default:
+			value = v.GetValue()
+		}
+		switch v := value.(type) {
+		case float64:
+			size = int(v)
+		case int:
+			size = v
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				size = n
+			}
+		}
+	}
+
+	// Sizes out of range are rejected when the field resolves
+	if size < 1 {
+		return 1
+	}
+	return size
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// batchLoader batches and caches the lookups of a GraphQL request, in the manner of a dataloader.
+// GraphQL resolves a query a level at a time, so the keys requested by the fields of one level
+// are collected before any of them is needed, and then fetched with a single repository call.
+type batchLoader struct {
+	mu      sync.Mutex
+	fetch   func(keys []string) (map[string]interface{}, error)
+	pending []string
+	loaded  map[string]bool
+	values  map[string]interface{}
+	errs    map[string]error
+}
+
+func newBatchLoader(fetch func(keys []string) (map[string]interface{}, error)) *batchLoader {
+	return &batchLoader{
+		fetch:  fetch,
+		loaded: map[string]bool{},
+		values: map[string]interface{}{},
+		errs:   map[string]error{},
+	}
+}
+
+// load queues a key and returns a thunk for its value, which is nil for a key that wasn't found.
+// The first thunk to be called fetches every queued key.
+func (l *batchLoader) load(key string) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.loaded[key] && !containsString(l.pending, key) {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			values, err := l.fetch(keys)
+			for _, k := range keys {
+				l.loaded[k] = true
+				if err != nil {
+					l.errs[k] = err
+				} else if value, ok := values[k]; ok {
+					l.values[k] = value
+				}
+			}
+		}
+
+		if err := l.errs[key]; err != nil {
+			return nil, err
+		}
+		return l.values[key], nil
+	}
+}
+
+// graphQLLoaders are the batch loaders of a GraphQL request.
+type graphQLLoaders struct {
+	sensors     *batchLoader
+	sensorTypes *batchLoader
+
+	repo Repository
+	// neighbours holds a loader of the neighbours of sensors per k and tag filter
+	mu         sync.Mutex
+	neighbours map[string]*batchLoader
+}
+
+// newGraphQLLoaders creates the loaders of a request over the repository.
+func newGraphQLLoaders(repo Repository) *graphQLLoaders {
+	return &graphQLLoaders{
+		repo:       repo,
+		neighbours: map[string]*batchLoader{},
+
+		// Sensors by name, with one list query for all the names
+		sensors: newBatchLoader(func(names []string) (map[string]interface{}, error) {
+			sensors, err := repo.ListSensorMetadata(SensorFilter{Names: names, Limit: len(names)})
+			if err != nil {
+				return nil, graphQLInternalError("Failed to get sensor metadata")
+			}
+			values := make(map[string]interface{}, len(sensors))
+			for _, sensorMetadata := range sensors {
+				values[sensorMetadata.Name] = newGraphQLSensor(sensorMetadata)
+			}
+			return values, nil
+		}),
+
+		// Sensor types by name. There are few sensor types, so several are read with one list query.
+		sensorTypes: newBatchLoader(func(names []string) (map[string]interface{}, error) {
+			if len(names) == 1 {
+				sensorType, err := repo.GetSensorType(names[0])
+				if errors.Is(err, ErrSensorTypeNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, graphQLInternalError("Failed to get sensor type")
+				}
+				return map[string]interface{}{sensorType.Name: *sensorType}, nil
+			}
+
+			sensorTypes, err := repo.ListSensorTypes()
+			if err != nil {
+				return nil, graphQLInternalError("Failed to list sensor types")
+			}
+			values := make(map[string]interface{}, len(sensorTypes))
+			for _, sensorType := range sensorTypes {
+				values[sensorType.Name] = sensorType
+			}
+			return values, nil
+		}),
+	}
+}
+
+// neighboursOf returns the loader of the k neighbours matching the tag filter of sensors by ID,
+// which finds those of all the IDs with one repository call. Sensors without neighbours load an
+// empty list.
+func (l *graphQLLoaders) neighboursOf(k int, tags TagExpr) *batchLoader {
+	key := strconv.Itoa(k)
+	if tags != nil {
+		key += " " + tags.String()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	loader, ok := l.neighbours[key]
+	if !ok {
+		loader = newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			query := NeighbourQuery{IDs: make([]int, len(keys)), Tags: tags, Limit: k}
+			for i, key := range keys {
+				query.IDs[i], _ = strconv.Atoi(key)
+			}
+			neighbours, err := l.repo.FindNeighbourSensorMetadata(query)
+			if err != nil {
+				return nil, graphQLInternalError("Failed to find sensor metadata")
+			}
+
+			values := make(map[string]interface{}, len(keys))
+			for i, key := range keys {
+				sensors := neighbours[query.IDs[i]]
+				nodes := make([]graphQLSensorNode, len(sensors))
+				for j, sensor := range sensors {
+					nodes[j] = newGraphQLSensor(sensor.SensorMetadata)
+					nodes[j].Distance = &sensors[j].Distance
+				}
+				values[key] = nodes
+			}
+			return values, nil
+		})
+		l.neighbours[key] = loader
+	}
+	return loader
+}
@@ -0,0 +1,513 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	sensorv1 "github.com/skartikey/sensor-metadata/proto/sensor/v1"
+)
+
+// GRPCServer implements the gRPC API over the same Repository, validation and paging as the REST API.
+type GRPCServer struct {
+	sensorv1.UnimplementedSensorMetadataServiceServer
+	handler *Handler
+}
+
+// Metadata keys with which gRPC clients ask to read their own writes, like ReadYourWritesHeader
+// and SessionTokenHeader over HTTP. The session token is sent in the response header of writes.
+const (
+	ReadYourWritesMetadataKey = "x-read-your-writes"
+	SessionTokenMetadataKey   = "x-session-token"
+)
+
+// NewGRPCServer creates a new instance of the GRPCServer.
+func NewGRPCServer(repo Repository) *GRPCServer {
+	return &GRPCServer{handler: NewHandler(repo)}
+}
+
+// Register registers the server's services on a gRPC server.
+func (s *GRPCServer) Register(server *grpc.Server) {
+	sensorv1.RegisterSensorMetadataServiceServer(server, s)
+}
+
+// MultiplexGRPC serves gRPC requests with the gRPC server and all others with the HTTP handler,
+// over HTTP/2 without TLS (h2c) as well as HTTP/1.1, so that both APIs can share a port.
+func MultiplexGRPC(server *grpc.Server, handler http.Handler) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			server.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}), &http2.Server{})
+}
+
+// CreateSensor creates a sensor and returns it as saved.
+func (s *GRPCServer) CreateSensor(ctx context.Context, request *sensorv1.CreateSensorRequest) (*sensorv1.CreateSensorResponse, error) {
+	sensorMetadata, err := s.prepareSensor(request.GetSensor(), "Failed to create sensor metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.handler.repo.CreateSensorMetadata(&sensorMetadata); err != nil {
+		return nil, grpcError(err, "Failed to create sensor metadata")
+	}
+	s.setSessionToken(ctx)
+
+	sensor, err := s.getSensor(primaryOf(s.handler.repo), sensorMetadata.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &sensorv1.CreateSensorResponse{Sensor: sensor}, nil
+}
+
+// GetSensor returns a sensor by name.
+func (s *GRPCServer) GetSensor(ctx context.Context, request *sensorv1.GetSensorRequest) (*sensorv1.GetSensorResponse, error) {
+	sensor, err := s.getSensor(s.reader(ctx), request.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return &sensorv1.GetSensorResponse{Sensor: sensor}, nil
+}
+
+// UpdateSensor replaces the sensor with the ID of the given sensor and returns it as saved.
+func (s *GRPCServer) UpdateSensor(ctx context.Context, request *sensorv1.UpdateSensorRequest) (*sensorv1.UpdateSensorResponse, error) {
+	sensorMetadata, err := s.prepareSensor(request.GetSensor(), "Failed to update sensor metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.handler.repo.UpdateSensorMetadata(&sensorMetadata); err != nil {
+		return nil, grpcError(err, "Failed to update sensor metadata")
+	}
+	s.setSessionToken(ctx)
+
+	sensor, err := s.getSensor(primaryOf(s.handler.repo), sensorMetadata.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &sensorv1.UpdateSensorResponse{Sensor: sensor}, nil
+}
+
+// DeleteSensor deletes a sensor by name.
+func (s *GRPCServer) DeleteSensor(ctx context.Context, request *sensorv1.DeleteSensorRequest) (*sensorv1.DeleteSensorResponse, error) {
+	outcomes, err := s.handler.repo.ApplySensorBatch([]BatchOperation{{Op: BatchDelete, Name: request.GetName()}}, false)
+	if err == nil {
+		err = outcomes[0].Err
+	}
+	if err != nil {
+		return nil, grpcError(err, "Failed to delete sensor metadata")
+	}
+	s.setSessionToken(ctx)
+	return &sensorv1.DeleteSensorResponse{}, nil
+}
+
+// ListSensors returns a page of sensors, with a token for the next page when the page is full.
+func (s *GRPCServer) ListSensors(ctx context.Context, request *sensorv1.ListSensorsRequest) (*sensorv1.ListSensorsResponse, error) {
+	filter, err := grpcSensorFilter(request.GetFilter(), request.GetPageSize(), request.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	sensors, err := s.reader(ctx).ListSensorMetadata(filter)
+	if err != nil {
+		return nil, grpcError(err, "Failed to list sensor metadata")
+	}
+
+	response := &sensorv1.ListSensorsResponse{Sensors: sensorsToProto(sensors)}
+	response.NextPageToken = nextPageToken(sensors, filter.Limit)
+	return response, nil
+}
+
+// StreamSensors streams every sensor matching the filter, reading them from the repository a page at a time.
+func (s *GRPCServer) StreamSensors(request *sensorv1.StreamSensorsRequest, stream sensorv1.SensorMetadataService_StreamSensorsServer) error {
+	filter, err := grpcSensorFilter(request.GetFilter(), maxListLimit, "")
+	if err != nil {
+		return err
+	}
+
+	reader := s.reader(stream.Context())
+	for {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+
+		sensors, err := reader.ListSensorMetadata(filter)
+		if err != nil {
+			return grpcError(err, "Failed to list sensor metadata")
+		}
+		for _, sensorMetadata := range sensors {
+			if err := stream.Send(&sensorv1.StreamSensorsResponse{Sensor: sensorToProto(sensorMetadata)}); err != nil {
+				return err
+			}
+		}
+
+		if len(sensors) < filter.Limit {
+			return nil
+		}
+		filter.AfterID = sensors[len(sensors)-1].ID
+	}
+}
+
+// NearestSensors returns the sensors nearest to a location, nearest first, with their distances.
+func (s *GRPCServer) NearestSensors(ctx context.Context, request *sensorv1.NearestSensorsRequest) (*sensorv1.NearestSensorsResponse, error) {
+	query := NearestQuery{Latitude: request.GetLatitude(), Longitude: request.GetLongitude(), Limit: int(request.GetLimit())}
+	if query.Limit == 0 {
+		query.Limit = 1
+	}
+	if query.Limit < 0 || query.Limit > maxListLimit {
+		return nil, status.Error(codes.InvalidArgument, "Invalid 'limit'")
+	}
+
+	var err error
+	query.Tags, err = grpcTagExpr(request.GetFilter().GetTags())
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Altitude != nil {
+		altitude := request.GetAltitude()
+		query.Altitude = &altitude
+		query.AltitudeDatum = DatumWGS84
+	}
+	switch datum := request.GetAltitudeDatum(); datum {
+	case "":
+	case DatumWGS84, DatumMSL:
+		query.AltitudeDatum = datum
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Invalid 'altitude_datum'")
+	}
+	switch request.GetDistanceMode() {
+	case sensorv1.DistanceMode_DISTANCE_MODE_UNSPECIFIED, sensorv1.DistanceMode_DISTANCE_MODE_SURFACE:
+	case sensorv1.DistanceMode_DISTANCE_MODE_3D:
+		if query.Altitude == nil {
+			return nil, status.Error(codes.InvalidArgument, "Missing 'altitude' for 3D distance")
+		}
+		query.Use3D = true
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Invalid 'distance_mode'")
+	}
+
+	if request.Floor != nil {
+		floor := int(request.GetFloor())
+		query.Floor = &floor
+	}
+	if request.GetPreferSameFloor() {
+		if query.Floor == nil {
+			return nil, status.Error(codes.InvalidArgument, "Missing 'floor' for 'prefer_same_floor'")
+		}
+		query.PreferSameFloor = true
+	}
+
+	sensors, err := s.reader(ctx).FindNearestSensorMetadata(query)
+	if err != nil {
+		return nil, grpcError(err, "Failed to find sensor metadata")
+	}
+
+	response := &sensorv1.NearestSensorsResponse{Sensors: make([]*sensorv1.NearestSensor, len(sensors))}
+	for i, sensor := range sensors {
+		response.Sensors[i] = &sensorv1.NearestSensor{Sensor: sensorToProto(sensor.SensorMetadata), Distance: sensor.Distance}
+	}
+	return response, nil
+}
+
+// SensorsWithin returns a page of the sensors inside a bounding box or a set of polygons.
+func (s *GRPCServer) SensorsWithin(ctx context.Context, request *sensorv1.SensorsWithinRequest) (*sensorv1.SensorsWithinResponse, error) {
+	var query WithinQuery
+	switch area := request.GetArea().(type) {
+	case *sensorv1.SensorsWithinRequest_BoundingBox:
+		box := BoundingBox{
+			MinLon: area.BoundingBox.GetMinLongitude(),
+			MinLat: area.BoundingBox.GetMinLatitude(),
+			MaxLon: area.BoundingBox.GetMaxLongitude(),
+			MaxLat: area.BoundingBox.GetMaxLatitude(),
+		}
+		if err := box.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		query.BoundingBox = &box
+	case *sensorv1.SensorsWithinRequest_Geojson:
+		if len(area.Geojson) > maxGeometrySize {
+			return nil, status.Error(codes.InvalidArgument, "GeoJSON geometry is too large")
+		}
+		polygons, err := ParseGeoJSONPolygons([]byte(area.Geojson))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		query.Polygons = polygons
+	default:
+		return nil, status.Error(codes.InvalidArgument, "Missing 'bounding_box' or 'geojson'")
+	}
+
+	var err error
+	query.SensorFilter, err = grpcSensorFilter(request.GetFilter(), request.GetPageSize(), request.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	sensors, err := s.reader(ctx).FindSensorMetadataWithin(query)
+	if err != nil {
+		return nil, grpcError(err, "Failed to find sensor metadata")
+	}
+
+	response := &sensorv1.SensorsWithinResponse{Sensors: sensorsToProto(sensors)}
+	response.NextPageToken = nextPageToken(sensors, query.Limit)
+	return response, nil
+}
+
+// prepareSensor converts, validates and normalizes a sensor from a request like the REST API does.
+func (s *GRPCServer) prepareSensor(sensor *sensorv1.Sensor, message string) (SensorMetadata, error) {
+	if sensor == nil {
+		return SensorMetadata{}, status.Error(codes.InvalidArgument, "Missing 'sensor'")
+	}
+	sensorMetadata, err := sensorFromProto(sensor)
+	if err != nil {
+		return SensorMetadata{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if code, err := s.handler.prepareSensorMetadata(&sensorMetadata); err != nil {
+		if code == http.StatusInternalServerError {
+			return SensorMetadata{}, status.Error(codes.Internal, message)
+		}
+		return SensorMetadata{}, invalidArgument(err)
+	}
+	return sensorMetadata, nil
+}
+
+// getSensor reads a sensor by name from repo and converts it for a response.
+func (s *GRPCServer) getSensor(repo Repository, name string) (*sensorv1.Sensor, error) {
+	sensorMetadata, err := repo.GetSensorMetadataByName(name)
+	if err != nil {
+		return nil, grpcError(err, "Failed to get sensor metadata")
+	}
+	return sensorToProto(*sensorMetadata), nil
+}
+
+// reader picks the repository serving the reads of a call from its metadata, like Handler.reader.
+func (s *GRPCServer) reader(ctx context.Context) Repository {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return s.handler.readerFor(firstMetadata(md, ReadYourWritesMetadataKey), firstMetadata(md, SessionTokenMetadataKey))
+}
+
+// setSessionToken sends a session token in the response header of a successful write, when reads
+// may go to replicas.
+func (s *GRPCServer) setSessionToken(ctx context.Context) {
+	if replicaStaleness(s.handler.repo) > 0 {
+		grpc.SetHeader(ctx, metadata.Pairs(SessionTokenMetadataKey, newSessionToken()))
+	}
+}
+
+// firstMetadata returns the first value of a metadata key, or "" when it has none.
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcError maps a repository error to a gRPC status. Errors without a sentinel are
+// reported as Internal with the message, hiding their details from clients.
+func grpcError(err error, message string) error {
+	switch {
+	case errors.Is(err, ErrSensorNotFound):
+		return status.Error(codes.NotFound, "Sensor metadata not found")
+	case errors.Is(err, ErrSensorExists):
+		return status.Error(codes.AlreadyExists, "Sensor metadata already exists")
+	case errors.Is(err, ErrInvalidAttributes):
+		return invalidArgument(err)
+	default:
+		return status.Error(codes.Internal, message)
+	}
+}
+
+// invalidArgument reports invalid input as InvalidArgument, with the failures of individual
+// fields as BadRequest details when the error comes from validation.
+func invalidArgument(err error) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+	fields := validationFields(err)
+	if len(fields) == 0 {
+		return st.Err()
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Message,
+		})
+	}
+	if detailed, detailErr := st.WithDetails(badRequest); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcSensorFilter builds a list filter from a request's filter and paging fields.
+// The page token is the ID of the last sensor of the previous page, as in the REST API.
+func grpcSensorFilter(filter *sensorv1.SensorFilter, pageSize int32, pageToken string) (SensorFilter, error) {
+	sensorFilter := SensorFilter{Limit: int(pageSize)}
+	if sensorFilter.Limit == 0 {
+		sensorFilter.Limit = defaultListLimit
+	}
+	if sensorFilter.Limit < 0 || sensorFilter.Limit > maxListLimit {
+		return sensorFilter, status.Error(codes.InvalidArgument, "Invalid 'page_size'")
+	}
+
+	if pageToken != "" {
+		var err error
+		sensorFilter.AfterID, err = strconv.Atoi(pageToken)
+		if err != nil {
+			return sensorFilter, status.Error(codes.InvalidArgument, "Invalid 'page_token'")
+		}
+	}
+
+	var err error
+	sensorFilter.Tags, err = grpcTagExpr(filter.GetTags())
+	if err != nil {
+		return sensorFilter, err
+	}
+
+	sensorFilter.Attributes, err = ParseAttributeFilterList(filter.GetAttributes())
+	if err != nil {
+		return sensorFilter, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return sensorFilter, nil
+}
+
+// grpcTagExpr parses an optional tag filter expression.
+func grpcTagExpr(tags string) (TagExpr, error) {
+	if tags == "" {
+		return nil, nil
+	}
+	expr, err := ParseTagExpr(tags)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return expr, nil
+}
+
+// nextPageToken returns the token of the page after a full page of sensors, or "" after the last page.
+func nextPageToken(sensors []SensorMetadata, limit int) string {
+	if len(sensors) == 0 || len(sensors) < limit {
+		return ""
+	}
+	return strconv.Itoa(sensors[len(sensors)-1].ID)
+}
+
+// sensorsToProto converts sensors for a response.
+func sensorsToProto(sensors []SensorMetadata) []*sensorv1.Sensor {
+	converted := make([]*sensorv1.Sensor, len(sensors))
+	for i, sensorMetadata := range sensors {
+		converted[i] = sensorToProto(sensorMetadata)
+	}
+	return converted
+}
+
+// sensorToProto converts a sensor for a response.
+func sensorToProto(sensorMetadata SensorMetadata) *sensorv1.Sensor {
+	location := sensorMetadata.Location
+	sensor := &sensorv1.Sensor{
+		Id:   int64(sensorMetadata.ID),
+		Name: sensorMetadata.Name,
+		Type: sensorMetadata.Type,
+		Location: &sensorv1.Location{
+			Latitude:      location.Latitude,
+			Longitude:     location.Longitude,
+			Altitude:      location.Altitude,
+			AltitudeDatum: location.AltitudeDatum,
+			AccuracyM:     location.AccuracyM,
+		},
+		Tags: sensorMetadata.Tags,
+	}
+	if location.Floor != nil {
+		floor := int32(*location.Floor)
+		sensor.Location.Floor = &floor
+	}
+
+	if len(sensorMetadata.Attributes) > 0 {
+		sensor.Attributes = make(map[string]*sensorv1.AttributeValue, len(sensorMetadata.Attributes))
+		for key, value := range sensorMetadata.Attributes {
+			sensor.Attributes[key] = attributeToProto(value)
+		}
+	}
+	return sensor
+}
+
+// attributeToProto converts a decoded JSON attribute value. Objects, arrays and null are
+// carried as JSON text.
+func attributeToProto(value interface{}) *sensorv1.AttributeValue {
+	switch v := value.(type) {
+	case float64:
+		return &sensorv1.AttributeValue{Kind: &sensorv1.AttributeValue_Number{Number: v}}
+	case string:
+		return &sensorv1.AttributeValue{Kind: &sensorv1.AttributeValue_String_{String_: v}}
+	case bool:
+		return &sensorv1.AttributeValue{Kind: &sensorv1.AttributeValue_Bool{Bool: v}}
+	default:
+		// Attributes were decoded from JSON, so they always encode again
+		data, _ := json.Marshal(v)
+		return &sensorv1.AttributeValue{Kind: &sensorv1.AttributeValue_Json{Json: string(data)}}
+	}
+}
+
+// sensorFromProto converts a sensor from a request.
+func sensorFromProto(sensor *sensorv1.Sensor) (SensorMetadata, error) {
+	sensorMetadata := SensorMetadata{
+		ID:   int(sensor.GetId()),
+		Name: sensor.GetName(),
+		Type: sensor.GetType(),
+		Tags: sensor.GetTags(),
+	}
+
+	if location := sensor.GetLocation(); location != nil {
+		sensorMetadata.Location = Location{
+			Latitude:      location.GetLatitude(),
+			Longitude:     location.GetLongitude(),
+			Altitude:      location.Altitude,
+			AltitudeDatum: location.GetAltitudeDatum(),
+			AccuracyM:     location.AccuracyM,
+		}
+		if location.Floor != nil {
+			floor := int(location.GetFloor())
+			sensorMetadata.Location.Floor = &floor
+		}
+	}
+
+	if len(sensor.GetAttributes()) > 0 {
+		sensorMetadata.Attributes = make(map[string]interface{}, len(sensor.GetAttributes()))
+		for key, value := range sensor.GetAttributes() {
+			switch kind := value.GetKind().(type) {
+			case *sensorv1.AttributeValue_Number:
+				sensorMetadata.Attributes[key] = kind.Number
+			case *sensorv1.AttributeValue_String_:
+				sensorMetadata.Attributes[key] = kind.String_
+			case *sensorv1.AttributeValue_Bool:
+				sensorMetadata.Attributes[key] = kind.Bool
+			case *sensorv1.AttributeValue_Json:
+				var decoded interface{}
+				if err := json.Unmarshal([]byte(kind.Json), &decoded); err != nil {
+					return sensorMetadata, fmt.Errorf("invalid JSON in attribute %q", key)
+				}
+				sensorMetadata.Attributes[key] = decoded
+			default:
+				return sensorMetadata, fmt.Errorf("missing value of attribute %q", key)
+			}
+		}
+	}
+
+	return sensorMetadata, nil
+}
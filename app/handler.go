@@ -2,13 +2,25 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+
+	"github.com/skartikey/sensor-metadata/app/jsonschema"
 )
 
 // Handler represents the HTTP handlers for the API endpoints.
 type Handler struct {
+	// AllowPrivateWebhooks accepts webhooks on loopback, private and link-local addresses, which
+	// are rejected otherwise so that webhooks can't be pointed at internal services.
+	AllowPrivateWebhooks bool
+
 	repo      Repository
 	validator *validator.Validate
 }
@@ -17,33 +29,104 @@ type Handler struct {
 func NewHandler(repo Repository) *Handler {
 	return &Handler{
 		repo:      repo,
-		validator: validator.New(),
+		validator: newValidator(),
 	}
 }
 
 // ErrorResponse represents the structure of error responses.
+// Fields details the failures of a request that didn't pass validation.
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// Default and maximum page sizes for list requests.
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// maxGeometrySize bounds the size of a GeoJSON request body.
+const maxGeometrySize = 1 << 20
+
+// maxImportSize bounds the size of an import request body. Imports are read as they arrive, so
+// the bound is about how long a single request may keep the server busy rather than memory.
+const maxImportSize = 32 << 20
+
+// BatchRequest represents the request body for applying several sensor operations at once.
+// An atomic batch is applied all-or-nothing; otherwise each operation succeeds or fails on its own.
+// The operation limit keeps the multi-row statements well below PostgreSQL's parameter limit.
+type BatchRequest struct {
+	Atomic     bool               `json:"atomic"`
+	Operations []BatchOperationV1 `json:"operations" validate:"required,min=1,max=1000"`
+}
+
+// BatchResult represents the result of one operation in a batch, in the same position as the operation.
+type BatchResult struct {
+	Status int    `json:"status"`
+	ID     int    `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchResponse represents the response body of a batch request.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// importBatchSize is the number of CSV rows imported per batch.
+const importBatchSize = 500
+
+// ImportResult represents the response body of a CSV import.
+type ImportResult struct {
+	DryRun   bool       `json:"dry_run"`
+	Rows     int        `json:"rows"`
+	Valid    int        `json:"valid"`
+	Imported int        `json:"imported"`
+	Errors   []RowError `json:"errors"`
+}
+
+// RowError represents a CSV row or GeoJSON feature that failed validation or could not be imported.
+// Line is the row's line number in a CSV file; Feature is the feature's index in a FeatureCollection.
+type RowError struct {
+	Line    int    `json:"line,omitempty"`
+	Feature *int   `json:"feature,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Error   string `json:"error"`
+}
+
+// TagsRequest represents the request body for adding tags to a sensor.
+type TagsRequest struct {
+	Tags []string `json:"tags" validate:"required,min=1"`
 }
 
 // CreateSensorMetadata handles the HTTP POST request to create sensor metadata.
 func (h *Handler) CreateSensorMetadata(w http.ResponseWriter, r *http.Request) {
-	var sensorMetadata SensorMetadata
-	err := json.NewDecoder(r.Body).Decode(&sensorMetadata)
+	var request SensorMetadataV1
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	// Validate the input
-	if err := h.validator.Struct(sensorMetadata); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+	// Validate and normalize the input
+	sensorMetadata, status, err := h.prepareSensorMetadataV1(request)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			sendErrorResponse(w, status, "Failed to create sensor metadata")
+			return
+		}
+		sendValidationErrorResponse(w, err)
 		return
 	}
 
 	// Save the sensor metadata
 	err = h.repo.CreateSensorMetadata(&sensorMetadata)
 	if err != nil {
+		if errors.Is(err, ErrSensorExists) {
+			sendErrorResponse(w, http.StatusConflict, "Sensor metadata already exists")
+			return
+		}
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create sensor metadata")
 		return
 	}
@@ -52,48 +135,538 @@ func (h *Handler) CreateSensorMetadata(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetSensorMetadata handles the HTTP GET request to retrieve sensor metadata by name.
+// Without a 'name' parameter it lists sensor metadata instead.
 func (h *Handler) GetSensorMetadata(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
 	if name == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "Missing 'name' parameter")
+		h.ListSensorMetadata(w, r)
 		return
 	}
 
-	sensorMetadata, err := h.repo.GetSensorMetadataByName(name)
+	sensorMetadata, err := h.reader(r).GetSensorMetadataByName(name)
 	if err != nil {
 		sendErrorResponse(w, http.StatusNotFound, "Sensor metadata not found")
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, sensorMetadata)
+	jsonResponse(w, http.StatusOK, NewSensorMetadataV1(*sensorMetadata))
 }
 
-// UpdateSensorMetadata handles the HTTP PUT request to update sensor metadata.
-func (h *Handler) UpdateSensorMetadata(w http.ResponseWriter, r *http.Request) {
-	var sensorMetadata SensorMetadata
-	err := json.NewDecoder(r.Body).Decode(&sensorMetadata)
+// ListSensorMetadata handles the HTTP GET request to list sensor metadata, optionally filtered by a tag expression.
+func (h *Handler) ListSensorMetadata(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSensorFilter(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := parseListFormat(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sensors, err := h.reader(r).ListSensorMetadata(filter)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list sensor metadata")
+		return
+	}
+
+	sensorListResponse(w, r, format, sensors, filter.Limit)
+}
+
+// GetSensorMetadataWithin handles the HTTP GET request to list the sensors inside a 'bbox'.
+func (h *Handler) GetSensorMetadataWithin(w http.ResponseWriter, r *http.Request) {
+	bbox := r.URL.Query().Get("bbox")
+	if bbox == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Missing 'bbox' parameter")
+		return
+	}
+
+	boundingBox, err := ParseBoundingBox(bbox)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.findSensorMetadataWithin(w, r, WithinQuery{BoundingBox: &boundingBox})
+}
+
+// PostSensorMetadataWithin handles the HTTP POST request to list the sensors inside a GeoJSON Polygon or MultiPolygon.
+func (h *Handler) PostSensorMetadataWithin(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxGeometrySize))
 	if err != nil {
 		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	// Validate the input
-	if err := h.validator.Struct(sensorMetadata); err != nil {
+	polygons, err := ParseGeoJSONPolygons(body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.findSensorMetadataWithin(w, r, WithinQuery{Polygons: polygons})
+}
+
+// Helper function to run a within query with the list filter from the query parameters.
+func (h *Handler) findSensorMetadataWithin(w http.ResponseWriter, r *http.Request, query WithinQuery) {
+	var err error
+	query.SensorFilter, err = parseSensorFilter(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := parseListFormat(r)
+	if err != nil {
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	sensors, err := h.reader(r).FindSensorMetadataWithin(query)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to find sensor metadata")
+		return
+	}
+
+	sensorListResponse(w, r, format, sensors, query.Limit)
+}
+
+// UpdateSensorMetadata handles the HTTP PUT request to replace the sensor with a name.
+// The body may rename the sensor; an ID in the body must be the sensor's.
+func (h *Handler) UpdateSensorMetadata(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var request SensorMetadataV1
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// The path names the sensor to replace
+	existing, err := primaryOf(h.repo).GetSensorMetadataByName(name)
+	if err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Sensor metadata not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update sensor metadata")
+		return
+	}
+	if request.ID != 0 && request.ID != existing.ID {
+		sendErrorResponse(w, http.StatusBadRequest, "Sensor ID doesn't match the sensor named in the path")
+		return
+	}
+	request.ID = existing.ID
+
+	h.updateSensorMetadata(w, request)
+}
+
+// UpdateSensorMetadataByID handles the HTTP PUT request to replace the sensor with the ID in
+// the body, served at the unprefixed path only.
+func (h *Handler) UpdateSensorMetadataByID(w http.ResponseWriter, r *http.Request) {
+	var request SensorMetadataV1
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	h.updateSensorMetadata(w, request)
+}
+
+// Helper function to validate and save a sensor replacing the one with its ID.
+func (h *Handler) updateSensorMetadata(w http.ResponseWriter, request SensorMetadataV1) {
+	// Validate and normalize the input
+	sensorMetadata, status, err := h.prepareSensorMetadataV1(request)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			sendErrorResponse(w, status, "Failed to update sensor metadata")
+			return
+		}
+		sendValidationErrorResponse(w, err)
+		return
+	}
+
 	// Update the sensor metadata
 	err = h.repo.UpdateSensorMetadata(&sensorMetadata)
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update sensor metadata")
+		switch {
+		case errors.Is(err, ErrSensorNotFound):
+			sendErrorResponse(w, http.StatusNotFound, "Sensor metadata not found")
+		case errors.Is(err, ErrSensorExists):
+			sendErrorResponse(w, http.StatusConflict, "Sensor metadata already exists")
+		default:
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to update sensor metadata")
+		}
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// DeleteSensorMetadata handles the HTTP DELETE request to delete sensor metadata by name.
+func (h *Handler) DeleteSensorMetadata(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	outcomes, err := h.repo.ApplySensorBatch([]BatchOperation{{Op: BatchDelete, Name: name}}, false)
+	if err == nil {
+		err = outcomes[0].Err
+	}
+	if err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Sensor metadata not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete sensor metadata")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BatchSensorMetadata handles the HTTP POST request to create, upsert and delete many sensors at once.
+// The response carries a result per operation. A best-effort batch always responds 200 OK; an
+// atomic batch that fails responds with the status of the operation that failed.
+func (h *Handler) BatchSensorMetadata(w http.ResponseWriter, r *http.Request) {
+	var request BatchRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Validate the input
+	if err := h.validator.Struct(request); err != nil {
+		sendValidationErrorResponse(w, err)
+		return
+	}
+
+	// Validate and normalize each operation, keeping the valid ones
+	results := make([]BatchResult, len(request.Operations))
+	var operations []BatchOperation
+	var positions []int
+	for i, request := range request.Operations {
+		op, status, err := h.prepareBatchOperation(request)
+		results[i].Name = op.sensorName()
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				sendErrorResponse(w, status, "Failed to apply batch")
+				return
+			}
+			results[i].Status, results[i].Error = status, err.Error()
+			continue
+		}
+		operations = append(operations, op)
+		positions = append(positions, i)
+	}
+
+	if request.Atomic && len(operations) < len(request.Operations) {
+		for i := range results {
+			if results[i].Status == 0 {
+				results[i].Status, results[i].Error = http.StatusFailedDependency, ErrBatchAborted.Error()
+			}
+		}
+		jsonResponse(w, http.StatusBadRequest, BatchResponse{Results: results})
+		return
+	}
+
+	// Apply the valid operations
+	if len(operations) > 0 {
+		outcomes, err := h.repo.ApplySensorBatch(operations, request.Atomic)
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to apply batch")
+			return
+		}
+		for j, outcome := range outcomes {
+			results[positions[j]] = batchResult(operations[j], outcome)
+		}
+	}
+
+	status := http.StatusOK
+	if request.Atomic {
+		for _, result := range results {
+			if result.Status >= http.StatusBadRequest && result.Status != http.StatusFailedDependency {
+				status = result.Status
+				break
+			}
+		}
+	}
+
+	jsonResponse(w, status, BatchResponse{Results: results})
+}
+
+// ImportSensorMetadata handles the HTTP POST request to upsert sensors from a CSV file, or from a
+// GeoJSON FeatureCollection of Point features when the Content-Type is application/geo+json.
+// Sensors are validated like any other sensor metadata; invalid ones are reported and skipped.
+// With 'dry_run=true' nothing is saved. Rows and features are imported in batches as they are
+// read, so a malformed file stops the import after the sensors before it have been saved, and so
+// does a body larger than maxImportSize, with 413 Request Entity Too Large.
+func (h *Handler) ImportSensorMetadata(w http.ResponseWriter, r *http.Request) {
+	var dryRun bool
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid 'dry_run' parameter")
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	importer := &sensorImporter{handler: h, result: ImportResult{DryRun: dryRun, Errors: []RowError{}}}
+	if isGeoJSONContentType(r.Header.Get("Content-Type")) {
+		h.importGeoJSON(w, r, importer)
+		return
+	}
+
+	reader, err := newSensorCSVReader(r.Body)
+	if err != nil {
+		sendImportError(w, err)
+		return
+	}
+
+	for {
+		sensorMetadata, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, ErrInvalidCSVRow) {
+			sendImportError(w, err)
+			return
+		}
+
+		if err := importer.add(sensorMetadata, err, RowError{Line: line}); err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to import sensor metadata")
+			return
+		}
+	}
+	if err := importer.flush(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to import sensor metadata")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, importer.result)
+}
+
+// Helper function to import the features of a GeoJSON FeatureCollection as they are decoded.
+func (h *Handler) importGeoJSON(w http.ResponseWriter, r *http.Request, importer *sensorImporter) {
+	reader, err := newSensorFeatureReader(r.Body)
+	if err != nil {
+		sendImportError(w, err)
+		return
+	}
+
+	for {
+		sensorMetadata, index, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, ErrInvalidFeature) {
+			sendImportError(w, err)
+			return
+		}
+
+		if err := importer.add(sensorMetadata, err, RowError{Feature: &index}); err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to import sensor metadata")
+			return
+		}
+	}
+	if err := importer.flush(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to import sensor metadata")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, importer.result)
+}
+
+// Helper function to send the error that stopped an import.
+func sendImportError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		sendErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import exceeds the limit of %d bytes", maxBytesErr.Limit))
+		return
+	}
+	sendErrorResponse(w, http.StatusBadRequest, err.Error())
+}
+
+// sensorImporter validates imported sensors and upserts the valid ones in batches.
+type sensorImporter struct {
+	handler    *Handler
+	result     ImportResult
+	operations []BatchOperation
+	positions  []RowError
+}
+
+// add validates a sensor read from position, or records readErr if it couldn't be read.
+// It only returns an error when the import can't go on.
+func (i *sensorImporter) add(sensorMetadata SensorMetadata, readErr error, position RowError) error {
+	i.result.Rows++
+
+	err := readErr
+	if err == nil {
+		var status int
+		status, err = i.handler.prepareSensorMetadata(&sensorMetadata)
+		if status == http.StatusInternalServerError {
+			return err
+		}
+	}
+	if err != nil {
+		position.Name, position.Error = sensorMetadata.Name, err.Error()
+		i.result.Errors = append(i.result.Errors, position)
+		return nil
+	}
+	i.result.Valid++
+
+	if i.result.DryRun {
+		return nil
+	}
+	i.operations = append(i.operations, BatchOperation{Op: BatchUpsert, Sensor: &sensorMetadata})
+	i.positions = append(i.positions, position)
+	if len(i.operations) == importBatchSize {
+		return i.flush()
+	}
+	return nil
+}
+
+// flush upserts the pending sensors.
+func (i *sensorImporter) flush() error {
+	if len(i.operations) == 0 {
+		return nil
+	}
+
+	outcomes, err := i.handler.repo.ApplySensorBatch(i.operations, false)
+	if err != nil {
+		return err
+	}
+	for j, outcome := range outcomes {
+		if outcome.Err != nil {
+			position := i.positions[j]
+			position.Name, position.Error = i.operations[j].Sensor.Name, batchResult(i.operations[j], outcome).Error
+			i.result.Errors = append(i.result.Errors, position)
+			continue
+		}
+		i.result.Imported++
+	}
+
+	i.operations, i.positions = i.operations[:0], i.positions[:0]
+	return nil
+}
+
+// ExportSensorMetadata handles the HTTP GET request to download sensor metadata as CSV, or as a
+// GeoJSON FeatureCollection when the request accepts application/geo+json or asks for
+// 'format=geojson'. It takes the same filters as listing, and streams every matching sensor page by page.
+func (h *Handler) ExportSensorMetadata(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSensorFilter(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Limit = maxListLimit
+	format, err := responseFormat(r, FormatCSV, FormatGeoJSON)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Fetch the first page before writing anything, so a failure can still be reported
+	sensors, err := h.reader(r).ListSensorMetadata(filter)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to export sensor metadata")
+		return
+	}
+
+	var encoder sensorEncoder
+	if format == FormatGeoJSON {
+		w.Header().Set("Content-Type", GeoJSONMediaType)
+		w.Header().Set("Content-Disposition", `attachment; filename="sensors.geojson"`)
+		encoder, err = newFeatureCollectionWriter(w)
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="sensors.csv"`)
+		encoder, err = newSensorCSVWriter(w)
+	}
+	if err != nil {
+		return
+	}
+
+	for {
+		for _, sensorMetadata := range sensors {
+			if err := encoder.Write(sensorMetadata); err != nil {
+				return
+			}
+		}
+
+		if len(sensors) < filter.Limit {
+			encoder.Close()
+			return
+		}
+
+		if err := encoder.Flush(); err != nil {
+			return
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		filter.AfterID = sensors[len(sensors)-1].ID
+		sensors, err = h.reader(r).ListSensorMetadata(filter)
+		if err != nil {
+			// Abort the response so the client sees a truncated download rather than a complete file
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// Helper function to validate a batch operation from a version 1 request, and map it to the
+// operation the repositories apply with its sensor normalized.
+// It returns the HTTP status to report alongside any error.
+func (h *Handler) prepareBatchOperation(request BatchOperationV1) (BatchOperation, int, error) {
+	op := request.BatchOperation()
+	switch op.Op {
+	case BatchCreate, BatchUpsert:
+		if request.Sensor == nil {
+			return op, http.StatusBadRequest, errors.New("Missing 'sensor'")
+		}
+		sensorMetadata, status, err := h.prepareSensorMetadataV1(*request.Sensor)
+		op.Sensor = &sensorMetadata
+		return op, status, err
+	case BatchDelete:
+		if op.Name == "" {
+			return op, http.StatusBadRequest, errors.New("Missing 'name'")
+		}
+		return op, http.StatusOK, nil
+	default:
+		return op, http.StatusBadRequest, fmt.Errorf("Unknown operation %q", op.Op)
+	}
+}
+
+// Helper function to report the outcome of an applied batch operation.
+func batchResult(op BatchOperation, outcome BatchOutcome) BatchResult {
+	result := BatchResult{Name: op.sensorName()}
+	switch {
+	case outcome.Err == nil && op.Op == BatchDelete:
+		result.Status = http.StatusNoContent
+	case outcome.Err == nil:
+		result.Status, result.ID = http.StatusOK, op.Sensor.ID
+		if outcome.Created {
+			result.Status = http.StatusCreated
+		}
+	case errors.Is(outcome.Err, ErrSensorExists):
+		result.Status, result.Error = http.StatusConflict, "Sensor metadata already exists"
+	case errors.Is(outcome.Err, ErrSensorNotFound):
+		result.Status, result.Error = http.StatusNotFound, "Sensor metadata not found"
+	case errors.Is(outcome.Err, ErrBatchAborted):
+		result.Status, result.Error = http.StatusFailedDependency, outcome.Err.Error()
+	default:
+		result.Status, result.Error = http.StatusInternalServerError, "Failed to apply operation"
+	}
+	return result
+}
+
 // GetNearestSensor handles the HTTP GET request to find the sensor nearest to a given location.
+// An optional 'tags' expression restricts the candidate sensors; 'altitude' with 'distance=3d'
+// ranks by 3D distance and 'floor' with 'prefer_same_floor=true' ranks same-floor sensors first.
 func (h *Handler) GetNearestSensorMetadata(w http.ResponseWriter, r *http.Request) {
 	latitude := r.URL.Query().Get("latitude")
 	longitude := r.URL.Query().Get("longitude")
@@ -102,14 +675,431 @@ func (h *Handler) GetNearestSensorMetadata(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Query the nearest sensor
-	sensorMetadata, err := h.repo.GetNearestSensorMetadata(latitude, longitude)
+	query, err := parseNearestQuery(latitude, longitude)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Tags, err = parseTagsParameter(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := parseNearestOptions(r, &query); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := responseFormat(r, FormatJSON, FormatGeoJSON)
 	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Query the nearest sensor
+	sensors, err := h.reader(r).FindNearestSensorMetadata(query)
+	if err != nil || len(sensors) == 0 {
 		sendErrorResponse(w, http.StatusNotFound, "No nearest sensor found")
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, sensorMetadata)
+	if format == FormatGeoJSON {
+		geoJSONResponse(w, http.StatusOK, FeatureCollection{Type: "FeatureCollection", Features: []Feature{NewNearestSensorFeature(sensors[0])}})
+		return
+	}
+	jsonResponse(w, http.StatusOK, NewNearestSensorV1(sensors[0]))
+}
+
+// AddSensorTags handles the HTTP POST request to add tags to a sensor.
+func (h *Handler) AddSensorTags(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var tagsRequest TagsRequest
+	err := json.NewDecoder(r.Body).Decode(&tagsRequest)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Validate the input
+	if err := h.validator.Struct(tagsRequest); err != nil {
+		sendValidationErrorResponse(w, err)
+		return
+	}
+
+	// Normalize the tags
+	tags, err := NormalizeTags(tagsRequest.Tags)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sensorMetadata, err := h.repo.AddSensorTags(name, tags)
+	if err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Sensor metadata not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to add sensor tags")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, NewSensorMetadataV1(*sensorMetadata))
+}
+
+// RemoveSensorTag handles the HTTP DELETE request to remove a tag from a sensor.
+func (h *Handler) RemoveSensorTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Normalize the tag so it matches the stored form
+	tag, err := NormalizeTag(vars["tag"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sensorMetadata, err := h.repo.RemoveSensorTag(vars["name"], tag)
+	if err != nil {
+		if errors.Is(err, ErrSensorNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Sensor metadata not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to remove sensor tag")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, NewSensorMetadataV1(*sensorMetadata))
+}
+
+// GetTags handles the HTTP GET request to list distinct tags with their usage counts.
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	tagCounts, err := h.reader(r).GetTagCounts()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list tags")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, tagCounts)
+}
+
+// CreateSensorType handles the HTTP POST request to create a sensor type.
+func (h *Handler) CreateSensorType(w http.ResponseWriter, r *http.Request) {
+	var sensorType SensorType
+	err := json.NewDecoder(r.Body).Decode(&sensorType)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Validate the input, including the schema itself
+	if err := h.validator.Struct(sensorType); err != nil {
+		sendValidationErrorResponse(w, err)
+		return
+	}
+	if _, err := jsonschema.ParseStrict(sensorType.Schema); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid schema: "+err.Error())
+		return
+	}
+
+	// Save the sensor type
+	err = h.repo.CreateSensorType(&sensorType)
+	if err != nil {
+		if errors.Is(err, ErrSensorTypeExists) {
+			sendErrorResponse(w, http.StatusConflict, "Sensor type already exists")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create sensor type")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GetSensorTypes handles the HTTP GET request to list sensor types.
+func (h *Handler) GetSensorTypes(w http.ResponseWriter, r *http.Request) {
+	sensorTypes, err := h.reader(r).ListSensorTypes()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list sensor types")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, sensorTypes)
+}
+
+// GetSensorType handles the HTTP GET request to retrieve a sensor type by name.
+func (h *Handler) GetSensorType(w http.ResponseWriter, r *http.Request) {
+	sensorType, err := h.reader(r).GetSensorType(mux.Vars(r)["name"])
+	if err != nil {
+		if errors.Is(err, ErrSensorTypeNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Sensor type not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get sensor type")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, sensorType)
+}
+
+// UpdateSensorType handles the HTTP PUT request to update a sensor type's description and schema.
+func (h *Handler) UpdateSensorType(w http.ResponseWriter, r *http.Request) {
+	var sensorType SensorType
+	err := json.NewDecoder(r.Body).Decode(&sensorType)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	sensorType.Name = mux.Vars(r)["name"]
+
+	// Validate the input, including the schema itself
+	if err := h.validator.Struct(sensorType); err != nil {
+		sendValidationErrorResponse(w, err)
+		return
+	}
+	if _, err := jsonschema.ParseStrict(sensorType.Schema); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid schema: "+err.Error())
+		return
+	}
+
+	// Update the sensor type
+	err = h.repo.UpdateSensorType(&sensorType)
+	if err != nil {
+		if errors.Is(err, ErrSensorTypeNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Sensor type not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update sensor type")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Helper function to validate and normalize sensor metadata from a request.
+// It returns the HTTP status to report alongside any error.
+func (h *Handler) prepareSensorMetadata(sensorMetadata *SensorMetadata) (int, error) {
+	// Validate the input
+	if err := h.validator.Struct(sensorMetadata); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// Normalize the location and the tags
+	var err error
+	sensorMetadata.normalizeLocation()
+	sensorMetadata.Tags, err = NormalizeTags(sensorMetadata.Tags)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// Validate the attributes against the sensor type's schema
+	if err := h.validateAttributes(sensorMetadata); err != nil {
+		if errors.Is(err, ErrInvalidAttributes) {
+			return http.StatusBadRequest, err
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// Helper function to validate a sensor from a version 1 request, and map it to the type the
+// repositories store, validated and normalized like any other sensor metadata.
+// It returns the HTTP status to report alongside any error.
+func (h *Handler) prepareSensorMetadataV1(request SensorMetadataV1) (SensorMetadata, int, error) {
+	// Validate the fields only requests have, such as the structured tags
+	sensorMetadata := request.SensorMetadata()
+	if err := h.validator.Struct(request); err != nil {
+		return sensorMetadata, http.StatusBadRequest, err
+	}
+
+	status, err := h.prepareSensorMetadata(&sensorMetadata)
+	return sensorMetadata, status, err
+}
+
+// Helper function to validate sensor attributes against the schema of the sensor's type.
+// Sensors without a type may carry arbitrary attributes.
+func (h *Handler) validateAttributes(sensorMetadata *SensorMetadata) error {
+	if sensorMetadata.Type == "" {
+		return nil
+	}
+
+	sensorType, err := primaryOf(h.repo).GetSensorType(sensorMetadata.Type)
+	if err != nil {
+		if errors.Is(err, ErrSensorTypeNotFound) {
+			return fmt.Errorf("%w: unknown sensor type %q", ErrInvalidAttributes, sensorMetadata.Type)
+		}
+		return err
+	}
+
+	if err := sensorType.ValidateAttributes(sensorMetadata.Attributes); err != nil {
+		return fmt.Errorf("%w for sensor type %q: %w", ErrInvalidAttributes, sensorMetadata.Type, err)
+	}
+	return nil
+}
+
+// Helper function to parse the list filter from the query parameters.
+func parseSensorFilter(r *http.Request) (SensorFilter, error) {
+	filter := SensorFilter{Limit: defaultListLimit}
+
+	var err error
+	filter.Tags, err = parseTagsParameter(r)
+	if err != nil {
+		return filter, err
+	}
+
+	if after := r.URL.Query().Get("after"); after != "" {
+		filter.AfterID, err = strconv.Atoi(after)
+		if err != nil {
+			return filter, errors.New("Invalid 'after' parameter")
+		}
+	}
+
+	filter.Attributes, err = ParseAttributeFilters(r.URL.RawQuery)
+	if err != nil {
+		return filter, err
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxListLimit {
+			return filter, errors.New("Invalid 'limit' parameter")
+		}
+	}
+
+	return filter, nil
+}
+
+// Helper function to parse the optional altitude and floor parameters of a nearest query.
+func parseNearestOptions(r *http.Request, query *NearestQuery) error {
+	params := r.URL.Query()
+
+	if altitude := params.Get("altitude"); altitude != "" {
+		value, err := strconv.ParseFloat(altitude, 64)
+		if err != nil {
+			return errors.New("Invalid 'altitude' parameter")
+		}
+		query.Altitude = &value
+		query.AltitudeDatum = DatumWGS84
+	}
+	if datum := params.Get("altitude_datum"); datum != "" {
+		if datum != DatumWGS84 && datum != DatumMSL {
+			return errors.New("Invalid 'altitude_datum' parameter")
+		}
+		query.AltitudeDatum = datum
+	}
+
+	switch params.Get("distance") {
+	case "", "surface":
+	case "3d":
+		if query.Altitude == nil {
+			return errors.New("Missing 'altitude' parameter for 3D distance")
+		}
+		query.Use3D = true
+	default:
+		return errors.New("Invalid 'distance' parameter")
+	}
+
+	if floor := params.Get("floor"); floor != "" {
+		value, err := strconv.Atoi(floor)
+		if err != nil {
+			return errors.New("Invalid 'floor' parameter")
+		}
+		query.Floor = &value
+	}
+	if prefer := params.Get("prefer_same_floor"); prefer != "" {
+		value, err := strconv.ParseBool(prefer)
+		if err != nil {
+			return errors.New("Invalid 'prefer_same_floor' parameter")
+		}
+		if value && query.Floor == nil {
+			return errors.New("Missing 'floor' parameter for 'prefer_same_floor'")
+		}
+		query.PreferSameFloor = value
+	}
+
+	return nil
+}
+
+// Helper function to parse the optional 'tags' filter expression.
+func parseTagsParameter(r *http.Request) (TagExpr, error) {
+	tags := r.URL.Query().Get("tags")
+	if tags == "" {
+		return nil, nil
+	}
+	return ParseTagExpr(tags)
+}
+
+// listFormat is the response format of a sensor list, with the grouping and styling of KML and GPX documents.
+type listFormat struct {
+	format    string
+	waypoints WaypointOptions
+}
+
+// Helper function to parse the response format of a sensor list and the 'group_by' and 'style_by' tag keys.
+func parseListFormat(r *http.Request) (listFormat, error) {
+	format, err := responseFormat(r, FormatJSON, FormatGeoJSON, FormatKML, FormatGPX)
+	if err != nil {
+		return listFormat{}, err
+	}
+
+	waypoints := WaypointOptions{StyleBy: DefaultStyleBy}
+	params := []struct {
+		name string
+		key  *string
+	}{{"group_by", &waypoints.GroupBy}, {"style_by", &waypoints.StyleBy}}
+	for _, param := range params {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+		key, err := NormalizeTag(value)
+		if err != nil || strings.Contains(key, ":") {
+			return listFormat{}, fmt.Errorf("Invalid '%s' parameter, expected a tag key", param.name)
+		}
+		*param.key = key
+	}
+
+	return listFormat{format: format, waypoints: waypoints}, nil
+}
+
+// Helper function to send a page of sensors, with a cursor for the next page when the page is full.
+// Sensors are sent as JSON, a GeoJSON FeatureCollection, or a KML or GPX document. The last two
+// have no place for the cursor, so it is sent as a Link header instead.
+func sensorListResponse(w http.ResponseWriter, r *http.Request, format listFormat, sensors []SensorMetadata, limit int) {
+	var next string
+	if len(sensors) == limit {
+		next = strconv.Itoa(sensors[len(sensors)-1].ID)
+	}
+
+	switch format.format {
+	case FormatGeoJSON:
+		geoJSONResponse(w, http.StatusOK, NewSensorFeatureCollection(sensors, next))
+	case FormatKML, FormatGPX:
+		if next != "" {
+			nextURL := *r.URL
+			query := nextURL.Query()
+			query.Set("after", next)
+			nextURL.RawQuery = query.Encode()
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL.RequestURI()))
+		}
+		write := WriteKML
+		if format.format == FormatGPX {
+			write = WriteGPX
+		}
+		w.Header().Set("Content-Type", formatMediaTypes[format.format])
+		w.WriteHeader(http.StatusOK)
+		write(w, sensors, format.waypoints)
+	default:
+		jsonResponse(w, http.StatusOK, NewSensorListV1(sensors, next))
+	}
+}
+
+// Helper function to send GeoJSON response with appropriate status code.
+func geoJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", GeoJSONMediaType)
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
 // Helper function to send JSON response with appropriate status code.
@@ -123,6 +1113,12 @@ func jsonResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
+// Helper function to send a 400 Bad Request response for invalid input, with the failures of
+// individual fields when the error comes from validation.
+func sendValidationErrorResponse(w http.ResponseWriter, err error) {
+	jsonResponse(w, http.StatusBadRequest, ErrorResponse{Message: err.Error(), Fields: validationFields(err)})
+}
+
 // Helper function to send error response with appropriate status code.
 func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	errorResponse := ErrorResponse{
@@ -0,0 +1,564 @@
+// Package jsonschema validates decoded JSON documents against a subset of JSON Schema (draft 2020-12).
+//
+// The supported keywords are type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength, pattern,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf, not
+// and local $ref pointers such as "#/$defs/Name" or "#/components/schemas/Name".
+// Parse and New ignore unknown keywords, as the specification requires. ParseStrict rejects
+// them, other than annotations such as title and description, so that a schema can't carry
+// constraints such as format or multipleOf that would never be enforced.
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Error describes a single validation failure at a location in the document.
+type Error struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Error returns the failure as "path: message".
+func (e Error) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError collects every failure found while validating a document.
+type ValidationError struct {
+	Errors []Error
+}
+
+// Error joins the individual failures.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Schema is a parsed JSON Schema document.
+type Schema struct {
+	root interface{}
+	// strict rejects the keywords outside the supported subset
+	strict bool
+}
+
+// supportedKeywords are the keywords that validation enforces or that only annotate a schema.
+var supportedKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "properties": true, "required": true,
+	"additionalProperties": true, "items": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true, "minimum": true, "maximum": true,
+	"exclusiveMinimum": true, "exclusiveMaximum": true, "allOf": true, "anyOf": true,
+	"oneOf": true, "not": true, "$ref": true, "$defs": true, "definitions": true,
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// Parse decodes a JSON Schema and checks that the keywords it uses are well formed.
+func Parse(data []byte) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	return New(root)
+}
+
+// ParseStrict decodes a JSON Schema like Parse, and also rejects any keyword outside the
+// supported subset, naming it in the error.
+func ParseStrict(data []byte) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+
+	s := &Schema{root: root, strict: true}
+	if err := s.check(root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// New wraps an already decoded JSON Schema and checks that it is well formed.
+func New(root interface{}) (*Schema, error) {
+	s := &Schema{root: root}
+	if err := s.check(root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks a decoded JSON document against the root of the schema.
+func (s *Schema) Validate(document interface{}) error {
+	return s.ValidateRef("#", document)
+}
+
+// ValidateRef checks a decoded JSON document against the subschema at a local reference, e.g. "#/$defs/Sensor".
+func (s *Schema) ValidateRef(ref string, document interface{}) error {
+	schema, err := s.resolve(ref)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{schema: s}
+	v.validate(schema, normalize(document), "")
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+// ValidateJSON decodes data and validates it against the root of the schema.
+func (s *Schema) ValidateJSON(data []byte) error {
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return &ValidationError{Errors: []Error{{Path: "/", Message: "document is not valid JSON"}}}
+	}
+	return s.Validate(document)
+}
+
+// resolve follows a local JSON pointer reference within the schema document.
+func (s *Schema) resolve(ref string) (interface{}, error) {
+	if ref == "#" || ref == "" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema reference %q", ref)
+	}
+
+	node := s.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+		}
+		node, ok = object[token]
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+		}
+	}
+	return node, nil
+}
+
+var validTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+// check walks a schema and rejects malformed keywords so that errors surface when the schema is stored.
+func (s *Schema) check(node interface{}, path string) error {
+	if _, ok := node.(bool); ok {
+		return nil
+	}
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or a boolean", path)
+	}
+
+	if s.strict {
+		keywords := make([]string, 0, len(schema))
+		for keyword := range schema {
+			keywords = append(keywords, keyword)
+		}
+		sort.Strings(keywords)
+		for _, keyword := range keywords {
+			if !supportedKeywords[keyword] {
+				return fmt.Errorf("%s: unsupported keyword %q", path, keyword)
+			}
+		}
+	}
+
+	if t, ok := schema["type"]; ok {
+		var names []interface{}
+		switch t := t.(type) {
+		case string:
+			names = []interface{}{t}
+		case []interface{}:
+			names = t
+		default:
+			return fmt.Errorf("%s/type: must be a string or an array of strings", path)
+		}
+		for _, name := range names {
+			if n, ok := name.(string); !ok || !validTypes[n] {
+				return fmt.Errorf("%s/type: unknown type %v", path, name)
+			}
+		}
+	}
+
+	if pattern, ok := schema["pattern"]; ok {
+		p, ok := pattern.(string)
+		if !ok {
+			return fmt.Errorf("%s/pattern: must be a string", path)
+		}
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("%s/pattern: %v", path, err)
+		}
+	}
+
+	for _, keyword := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "minLength", "maxLength", "minItems", "maxItems"} {
+		if value, ok := schema[keyword]; ok {
+			if _, ok := value.(float64); !ok {
+				return fmt.Errorf("%s/%s: must be a number", path, keyword)
+			}
+		}
+	}
+
+	if required, ok := schema["required"]; ok {
+		names, ok := required.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s/required: must be an array of strings", path)
+		}
+		for _, name := range names {
+			if _, ok := name.(string); !ok {
+				return fmt.Errorf("%s/required: must be an array of strings", path)
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if _, ok := enum.([]interface{}); !ok {
+			return fmt.Errorf("%s/enum: must be an array", path)
+		}
+	}
+
+	if ref, ok := schema["$ref"]; ok {
+		r, ok := ref.(string)
+		if !ok {
+			return fmt.Errorf("%s/$ref: must be a string", path)
+		}
+		if _, err := s.resolve(r); err != nil {
+			return fmt.Errorf("%s/$ref: %v", path, err)
+		}
+	}
+
+	for _, keyword := range []string{"properties", "$defs", "definitions"} {
+		if value, ok := schema[keyword]; ok {
+			children, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s/%s: must be an object", path, keyword)
+			}
+			for name, child := range children {
+				if err := s.check(child, path+"/"+keyword+"/"+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, keyword := range []string{"items", "additionalProperties", "not"} {
+		if child, ok := schema[keyword]; ok {
+			if err := s.check(child, path+"/"+keyword); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		if value, ok := schema[keyword]; ok {
+			children, ok := value.([]interface{})
+			if !ok || len(children) == 0 {
+				return fmt.Errorf("%s/%s: must be a non-empty array", path, keyword)
+			}
+			for i, child := range children {
+				if err := s.check(child, fmt.Sprintf("%s/%s/%d", path, keyword, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validator accumulates errors for a single validation run.
+type validator struct {
+	schema *Schema
+	errors []Error
+	depth  int
+}
+
+// maxRefDepth bounds $ref recursion so that self-referencing schemas can't loop forever.
+const maxRefDepth = 64
+
+var errRefDepth = errors.New("schema references nest too deeply")
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "/"
+	}
+	v.errors = append(v.errors, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// valid reports whether the document satisfies the schema without recording errors.
+func (v *validator) valid(schema, document interface{}, path string) bool {
+	sub := &validator{schema: v.schema, depth: v.depth}
+	sub.validate(schema, document, path)
+	return len(sub.errors) == 0
+}
+
+func (v *validator) validate(node, document interface{}, path string) {
+	if b, ok := node.(bool); ok {
+		if !b {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	}
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.schema.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		if v.depth >= maxRefDepth {
+			v.fail(path, "%v", errRefDepth)
+			return
+		}
+		v.depth++
+		v.validate(target, document, path)
+		v.depth--
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, document) {
+		v.fail(path, "must be of type %s", describeType(t))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if equal(candidate, document) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", mustMarshal(enum))
+		}
+	}
+
+	if constant, ok := schema["const"]; ok && !equal(constant, document) {
+		v.fail(path, "must be %s", mustMarshal(constant))
+	}
+
+	switch value := document.(type) {
+	case float64:
+		v.validateNumber(schema, value, path)
+	case string:
+		v.validateString(schema, value, path)
+	case []interface{}:
+		v.validateArray(schema, value, path)
+	case map[string]interface{}:
+		v.validateObject(schema, value, path)
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.validate(sub, document, path)
+		}
+	}
+
+	if options, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range options {
+			if v.valid(sub, document, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "must match at least one schema in anyOf")
+		}
+	}
+
+	if one, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, sub := range one {
+			if v.valid(sub, document, path) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			v.fail(path, "must match exactly one schema in oneOf, matched %d", matches)
+		}
+	}
+
+	if not, ok := schema["not"]; ok && v.valid(not, document, path) {
+		v.fail(path, "must not match the schema in not")
+	}
+}
+
+func (v *validator) validateNumber(schema map[string]interface{}, value float64, path string) {
+	if minimum, ok := schema["minimum"].(float64); ok && value < minimum {
+		v.fail(path, "must be >= %v", minimum)
+	}
+	if maximum, ok := schema["maximum"].(float64); ok && value > maximum {
+		v.fail(path, "must be <= %v", maximum)
+	}
+	if minimum, ok := schema["exclusiveMinimum"].(float64); ok && value <= minimum {
+		v.fail(path, "must be > %v", minimum)
+	}
+	if maximum, ok := schema["exclusiveMaximum"].(float64); ok && value >= maximum {
+		v.fail(path, "must be < %v", maximum)
+	}
+}
+
+func (v *validator) validateString(schema map[string]interface{}, value string, path string) {
+	length := float64(len([]rune(value)))
+	if minLength, ok := schema["minLength"].(float64); ok && length < minLength {
+		v.fail(path, "must be at least %v characters long", minLength)
+	}
+	if maxLength, ok := schema["maxLength"].(float64); ok && length > maxLength {
+		v.fail(path, "must be at most %v characters long", maxLength)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(value) {
+			v.fail(path, "must match pattern %q", pattern)
+		}
+	}
+}
+
+func (v *validator) validateArray(schema map[string]interface{}, value []interface{}, path string) {
+	if minItems, ok := schema["minItems"].(float64); ok && float64(len(value)) < minItems {
+		v.fail(path, "must have at least %v items", minItems)
+	}
+	if maxItems, ok := schema["maxItems"].(float64); ok && float64(len(value)) > maxItems {
+		v.fail(path, "must have at most %v items", maxItems)
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s/%d", path, i))
+		}
+	}
+}
+
+func (v *validator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if n, ok := name.(string); ok {
+				if _, present := value[n]; !present {
+					v.fail(path+"/"+escape(n), "is required")
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+
+	// Visit properties in a stable order so error lists are deterministic
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		childPath := path + "/" + escape(name)
+		if property, ok := properties[name]; ok {
+			v.validate(property, value[name], childPath)
+		} else if hasAdditional {
+			if b, ok := additional.(bool); ok && !b {
+				v.fail(childPath, "is not an allowed property")
+			} else {
+				v.validate(additional, value[name], childPath)
+			}
+		}
+	}
+}
+
+// matchesType reports whether the document is of one of the named JSON types.
+func matchesType(t interface{}, document interface{}) bool {
+	var names []interface{}
+	switch t := t.(type) {
+	case string:
+		names = []interface{}{t}
+	case []interface{}:
+		names = t
+	}
+
+	for _, name := range names {
+		switch name {
+		case "null":
+			if document == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := document.(bool); ok {
+				return true
+			}
+		case "object":
+			if _, ok := document.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := document.([]interface{}); ok {
+				return true
+			}
+		case "number":
+			if _, ok := document.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := document.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "string":
+			if _, ok := document.(string); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeType(t interface{}) string {
+	if names, ok := t.([]interface{}); ok {
+		parts := make([]string, len(names))
+		for i, name := range names {
+			parts[i] = fmt.Sprint(name)
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// normalize converts a document into the generic form produced by encoding/json,
+// so that callers may pass typed Go values such as structs or map[string]int.
+func normalize(document interface{}) interface{} {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return document
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return document
+	}
+	return generic
+}
+
+func equal(a, b interface{}) bool {
+	return mustMarshal(a) == mustMarshal(b)
+}
+
+func mustMarshal(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// escape encodes a property name as a JSON pointer token.
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kmlIcon is the icon of every sensor placemark; styles only change its colour.
+const kmlIcon = "https://maps.google.com/mapfiles/kml/shapes/placemark_circle.png"
+
+// kmlStatusColors maps the statuses with a built-in style to their icon colour, in KML's aabbggrr order.
+// Other statuses get a white icon under their own style ID, which Google Earth users can restyle.
+var kmlStatusColors = map[string]string{
+	StatusActive:         "ff00aa00",
+	StatusStale:          "ff00a5ff",
+	StatusDecommissioned: "ff808080",
+}
+
+type kmlDocument struct {
+	XMLName  xml.Name    `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document kmlFeatures `xml:"Document"`
+}
+
+type kmlFeatures struct {
+	Name       string         `xml:"name,omitempty"`
+	Styles     []kmlStyle     `xml:"Style"`
+	Folders    []kmlFeatures  `xml:"Folder"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlStyle struct {
+	ID        string       `xml:"id,attr"`
+	IconStyle kmlIconStyle `xml:"IconStyle"`
+}
+
+type kmlIconStyle struct {
+	Color string `xml:"color"`
+	Href  string `xml:"Icon>href"`
+}
+
+type kmlPlacemark struct {
+	ID           string    `xml:"id,attr,omitempty"`
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description,omitempty"`
+	StyleURL     string    `xml:"styleUrl"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data,omitempty"`
+	Point        kmlPoint  `xml:"Point"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	AltitudeMode string `xml:"altitudeMode,omitempty"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+// WriteKML writes sensors as a KML document of placemarks, styled by status and, with
+// GroupBy set, placed in a folder per value of the GroupBy tag.
+func WriteKML(w io.Writer, sensors []SensorMetadata, options WaypointOptions) error {
+	document := kmlDocument{Document: kmlFeatures{Name: "Sensors"}}
+
+	statuses := map[string]bool{}
+	folders := map[string]*kmlFeatures{}
+	for _, sensorMetadata := range sensors {
+		status := options.status(sensorMetadata)
+		statuses[status] = true
+		placemark := newKMLPlacemark(sensorMetadata, status)
+
+		if options.GroupBy == "" {
+			document.Document.Placemarks = append(document.Document.Placemarks, placemark)
+			continue
+		}
+		group, ok := tagValue(sensorMetadata.Tags, options.GroupBy)
+		if !ok {
+			group = "No " + options.GroupBy
+		}
+		folder, ok := folders[group]
+		if !ok {
+			folder = &kmlFeatures{Name: group}
+			folders[group] = folder
+		}
+		folder.Placemarks = append(folder.Placemarks, placemark)
+	}
+
+	// Styles and folders are sorted so the same sensors always give the same document
+	for _, status := range sortedKeys(statuses) {
+		color, ok := kmlStatusColors[status]
+		if !ok {
+			color = "ffffffff"
+		}
+		document.Document.Styles = append(document.Document.Styles, kmlStyle{ID: kmlStyleID(status), IconStyle: kmlIconStyle{Color: color, Href: kmlIcon}})
+	}
+	groups := make(map[string]bool, len(folders))
+	for group := range folders {
+		groups[group] = true
+	}
+	for _, group := range sortedKeys(groups) {
+		document.Document.Folders = append(document.Document.Folders, *folders[group])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// newKMLPlacemark converts a sensor into a placemark. Only altitudes above mean sea level are
+// kept, since KML's absolute altitudes are relative to sea level rather than the ellipsoid.
+func newKMLPlacemark(sensorMetadata SensorMetadata, status string) kmlPlacemark {
+	location := sensorMetadata.Location
+	coordinates := strconv.FormatFloat(location.Longitude, 'f', -1, 64) + "," + strconv.FormatFloat(location.Latitude, 'f', -1, 64)
+	point := kmlPoint{Coordinates: coordinates}
+	if location.Altitude != nil && location.AltitudeDatum == DatumMSL {
+		point = kmlPoint{AltitudeMode: "absolute", Coordinates: coordinates + "," + strconv.FormatFloat(*location.Altitude, 'f', -1, 64)}
+	}
+
+	data := []kmlData{{Name: "status", Value: status}}
+	if len(sensorMetadata.Tags) > 0 {
+		data = append(data, kmlData{Name: "tags", Value: strings.Join(sensorMetadata.Tags, ",")})
+	}
+	if location.Floor != nil {
+		data = append(data, kmlData{Name: "floor", Value: strconv.Itoa(*location.Floor)})
+	}
+
+	var id string
+	if sensorMetadata.ID != 0 {
+		id = "sensor-" + strconv.Itoa(sensorMetadata.ID)
+	}
+	return kmlPlacemark{
+		ID:           id,
+		Name:         sensorMetadata.Name,
+		Description:  describeSensor(sensorMetadata),
+		StyleURL:     "#" + kmlStyleID(status),
+		ExtendedData: data,
+		Point:        point,
+	}
+}
+
+// kmlStyleID returns the ID of the style for a status. Colons aren't allowed in XML IDs.
+func kmlStyleID(status string) string {
+	return "status-" + strings.ReplaceAll(status, ":", "-")
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
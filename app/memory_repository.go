@@ -0,0 +1,815 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// earthRadius is the radius in meters used by PostgreSQL's earthdistance extension.
+const earthRadius = 6378168.0
+
+// MemoryRepository represents an in-memory repository implementation.
+// It is intended for tests and small deployments that don't need PostgreSQL.
+// Its change log and webhook outbox last as long as the process, or until events are pruned.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	sensors map[int]SensorMetadata
+	// byName indexes the IDs of the sensors by name
+	byName         map[string]int
+	sensorTypes    map[string]SensorType
+	nextID         int
+	events         []SensorEvent
+	eventTimes     []time.Time
+	prunedThrough  int64
+	webhooks       map[int]Webhook
+	nextWebhookID  int
+	deliveries     map[int64]WebhookDelivery
+	nextDeliveryID int64
+	outboxEventID  int64
+}
+
+// NewMemoryRepository creates a new, empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		sensors:        make(map[int]SensorMetadata),
+		byName:         make(map[string]int),
+		sensorTypes:    make(map[string]SensorType),
+		nextID:         1,
+		webhooks:       make(map[int]Webhook),
+		nextWebhookID:  1,
+		deliveries:     make(map[int64]WebhookDelivery),
+		nextDeliveryID: 1,
+	}
+}
+
+// CreateSensorMetadata stores a new sensor metadata entry and assigns its ID.
+func (r *MemoryRepository) CreateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createSensorMetadata(sensorMetadata)
+}
+
+// createSensorMetadata stores a new sensor metadata entry. The caller must hold the lock.
+func (r *MemoryRepository) createSensorMetadata(sensorMetadata *SensorMetadata) error {
+	if _, ok := r.idByName(sensorMetadata.Name); ok {
+		return ErrSensorExists
+	}
+
+	sensorMetadata.ID = r.nextID
+	r.nextID++
+	r.putSensor(copySensorMetadata(*sensorMetadata))
+	r.recordEvent(SensorCreated, r.sensors[sensorMetadata.ID], nil)
+
+	return nil
+}
+
+// GetSensorMetadataByName retrieves sensor metadata by name.
+func (r *MemoryRepository) GetSensorMetadataByName(name string) (*SensorMetadata, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.idByName(name)
+	if !ok {
+		return nil, ErrSensorNotFound
+	}
+
+	sensorMetadata := copySensorMetadata(r.sensors[id])
+	return &sensorMetadata, nil
+}
+
+// UpdateSensorMetadata replaces the sensor metadata entry with the same ID, or returns
+// ErrSensorNotFound when there is none.
+func (r *MemoryRepository) UpdateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, ok := r.sensors[sensorMetadata.ID]
+	if !ok {
+		return ErrSensorNotFound
+	}
+	if id, ok := r.idByName(sensorMetadata.Name); ok && id != sensorMetadata.ID {
+		return ErrSensorExists
+	}
+	r.putSensor(copySensorMetadata(*sensorMetadata))
+	r.recordEvent(SensorUpdated, r.sensors[sensorMetadata.ID], &previous)
+
+	return nil
+}
+
+// GetNearestSensorMetadata retrieves the sensor nearest to the given location.
+func (r *MemoryRepository) GetNearestSensorMetadata(latitude, longitude string) (*NearestSensor, error) {
+	query, err := parseNearestQuery(latitude, longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	sensors, err := r.FindNearestSensorMetadata(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(sensors) == 0 {
+		return nil, ErrSensorNotFound
+	}
+
+	return &sensors[0], nil
+}
+
+// AddSensorTags appends the given tags to a sensor, skipping tags it already has.
+func (r *MemoryRepository) AddSensorTags(name string, tags []string) (*SensorMetadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.idByName(name)
+	if !ok {
+		return nil, ErrSensorNotFound
+	}
+
+	previous := r.sensors[id]
+	sensorMetadata := copySensorMetadata(previous)
+	for _, tag := range tags {
+		if !(tagTermExpr{tag}).Match(sensorMetadata.Tags) {
+			sensorMetadata.Tags = append(sensorMetadata.Tags, tag)
+		}
+	}
+	r.sensors[id] = sensorMetadata
+	r.recordEvent(SensorUpdated, sensorMetadata, &previous)
+
+	result := copySensorMetadata(sensorMetadata)
+	return &result, nil
+}
+
+// RemoveSensorTag removes a single tag from a sensor.
+func (r *MemoryRepository) RemoveSensorTag(name, tag string) (*SensorMetadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.idByName(name)
+	if !ok {
+		return nil, ErrSensorNotFound
+	}
+
+	previous := r.sensors[id]
+	sensorMetadata := copySensorMetadata(previous)
+	tags := sensorMetadata.Tags[:0]
+	for _, t := range sensorMetadata.Tags {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+	sensorMetadata.Tags = tags
+	r.sensors[id] = sensorMetadata
+	r.recordEvent(SensorUpdated, sensorMetadata, &previous)
+
+	result := copySensorMetadata(sensorMetadata)
+	return &result, nil
+}
+
+// GetTagCounts lists the distinct tags in use together with the number of sensors carrying each.
+func (r *MemoryRepository) GetTagCounts() ([]TagCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, sensorMetadata := range r.sensors {
+		for _, tag := range sensorMetadata.Tags {
+			counts[tag]++
+		}
+	}
+
+	tagCounts := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tagCounts = append(tagCounts, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tagCounts, func(i, j int) bool {
+		if tagCounts[i].Count != tagCounts[j].Count {
+			return tagCounts[i].Count > tagCounts[j].Count
+		}
+		return tagCounts[i].Tag < tagCounts[j].Tag
+	})
+
+	return tagCounts, nil
+}
+
+// ListSensorMetadata retrieves a page of sensor metadata matching the filter, ordered by ID.
+func (r *MemoryRepository) ListSensorMetadata(filter SensorFilter) ([]SensorMetadata, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filterSensors(filter, func(SensorMetadata) bool { return true }), nil
+}
+
+// FindSensorMetadataWithin retrieves a page of the sensors inside a bounding box or polygons, ordered by ID.
+func (r *MemoryRepository) FindSensorMetadataWithin(query WithinQuery) ([]SensorMetadata, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filterSensors(query.SensorFilter, func(sensorMetadata SensorMetadata) bool {
+		return query.Contains(sensorMetadata.Location)
+	}), nil
+}
+
+// ApplySensorBatch applies the operations in order. With atomic set, the repository is
+// restored to its previous state as soon as one operation fails.
+func (r *MemoryRepository) ApplySensorBatch(operations []BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Stored sensors are never modified in place, so a shallow copy is a snapshot
+	sensors := make(map[int]SensorMetadata, len(r.sensors))
+	for id, sensorMetadata := range r.sensors {
+		sensors[id] = sensorMetadata
+	}
+	byName := make(map[string]int, len(r.byName))
+	for name, id := range r.byName {
+		byName[name] = id
+	}
+	nextID := r.nextID
+	events := len(r.events)
+	eventTimes := len(r.eventTimes)
+
+	outcomes := make([]BatchOutcome, len(operations))
+	for i, op := range operations {
+		outcomes[i] = r.applyBatchOperation(op)
+		if atomic && outcomes[i].Err != nil {
+			r.sensors, r.byName, r.nextID = sensors, byName, nextID
+			r.events, r.eventTimes = r.events[:events], r.eventTimes[:eventTimes]
+			abortBatch(outcomes)
+			return outcomes, nil
+		}
+	}
+
+	return outcomes, nil
+}
+
+// applyBatchOperation applies a single batch operation. The caller must hold the lock.
+func (r *MemoryRepository) applyBatchOperation(op BatchOperation) BatchOutcome {
+	switch op.Op {
+	case BatchCreate:
+		return BatchOutcome{Created: true, Err: r.createSensorMetadata(op.Sensor)}
+	case BatchUpsert:
+		id, ok := r.idByName(op.Sensor.Name)
+		if !ok {
+			return BatchOutcome{Created: true, Err: r.createSensorMetadata(op.Sensor)}
+		}
+		op.Sensor.ID = id
+		previous := r.sensors[id]
+		r.putSensor(copySensorMetadata(*op.Sensor))
+		r.recordEvent(SensorUpdated, r.sensors[id], &previous)
+		return BatchOutcome{}
+	case BatchDelete:
+		id, ok := r.idByName(op.Name)
+		if !ok {
+			return BatchOutcome{Err: ErrSensorNotFound}
+		}
+		r.recordEvent(SensorDeleted, r.sensors[id], nil)
+		delete(r.sensors, id)
+		delete(r.byName, op.Name)
+		return BatchOutcome{}
+	}
+	return BatchOutcome{Err: fmt.Errorf("unknown batch operation %q", op.Op)}
+}
+
+// filterSensors returns a page of the sensors matching the filter and predicate. The caller must hold the lock.
+func (r *MemoryRepository) filterSensors(filter SensorFilter, predicate func(SensorMetadata) bool) []SensorMetadata {
+	sensors := []SensorMetadata{}
+	for _, sensorMetadata := range r.sortedSensors() {
+		if !filter.match(sensorMetadata) || !predicate(sensorMetadata) {
+			continue
+		}
+		sensors = append(sensors, copySensorMetadata(sensorMetadata))
+		if len(sensors) == filter.Limit {
+			break
+		}
+	}
+	return sensors
+}
+
+// FindNearestSensorMetadata retrieves the sensors nearest to a location that match the query's tag filter.
+func (r *MemoryRepository) FindNearestSensorMetadata(query NearestQuery) ([]NearestSensor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sensors := []NearestSensor{}
+	for _, sensorMetadata := range r.sortedSensors() {
+		if query.Tags != nil && !query.Tags.Match(sensorMetadata.Tags) {
+			continue
+		}
+		sensors = append(sensors, NearestSensor{SensorMetadata: copySensorMetadata(sensorMetadata), Distance: query.distanceTo(sensorMetadata.Location)})
+	}
+
+	sort.SliceStable(sensors, func(i, j int) bool {
+		if query.PreferSameFloor && query.Floor != nil {
+			iSame, jSame := query.sameFloor(sensors[i].Location), query.sameFloor(sensors[j].Location)
+			if iSame != jSame {
+				return iSame
+			}
+		}
+		return sensors[i].Distance < sensors[j].Distance
+	})
+	if len(sensors) > query.Limit {
+		sensors = sensors[:query.Limit]
+	}
+
+	return sensors, nil
+}
+
+// FindNeighbourSensorMetadata retrieves the sensors nearest to each of the query's sensors that
+// match its tag filter, keyed by the ID of the sensor they neighbour.
+func (r *MemoryRepository) FindNeighbourSensorMetadata(query NeighbourQuery) (map[int][]NearestSensor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	neighbours := map[int][]NearestSensor{}
+	for _, id := range query.IDs {
+		origin, ok := r.sensors[id]
+		if !ok {
+			continue
+		}
+		near := NearestQuery{Latitude: origin.Location.Latitude, Longitude: origin.Location.Longitude}
+
+		sensors := []NearestSensor{}
+		for _, sensorMetadata := range r.sortedSensors() {
+			if sensorMetadata.ID == id || (query.Tags != nil && !query.Tags.Match(sensorMetadata.Tags)) {
+				continue
+			}
+			sensors = append(sensors, NearestSensor{SensorMetadata: copySensorMetadata(sensorMetadata), Distance: near.distanceTo(sensorMetadata.Location)})
+		}
+
+		sort.SliceStable(sensors, func(i, j int) bool {
+			return sensors[i].Distance < sensors[j].Distance
+		})
+		if len(sensors) > query.Limit {
+			sensors = sensors[:query.Limit]
+		}
+		neighbours[id] = sensors
+	}
+
+	return neighbours, nil
+}
+
+// CreateSensorType stores a new sensor type.
+func (r *MemoryRepository) CreateSensorType(sensorType *SensorType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sensorTypes[sensorType.Name]; ok {
+		return ErrSensorTypeExists
+	}
+	r.sensorTypes[sensorType.Name] = copySensorType(*sensorType)
+
+	return nil
+}
+
+// GetSensorType retrieves a sensor type by name.
+func (r *MemoryRepository) GetSensorType(name string) (*SensorType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sensorType, ok := r.sensorTypes[name]
+	if !ok {
+		return nil, ErrSensorTypeNotFound
+	}
+
+	sensorType = copySensorType(sensorType)
+	return &sensorType, nil
+}
+
+// ListSensorTypes retrieves all sensor types ordered by name.
+func (r *MemoryRepository) ListSensorTypes() ([]SensorType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sensorTypes := make([]SensorType, 0, len(r.sensorTypes))
+	for _, sensorType := range r.sensorTypes {
+		sensorTypes = append(sensorTypes, copySensorType(sensorType))
+	}
+	sort.Slice(sensorTypes, func(i, j int) bool {
+		return sensorTypes[i].Name < sensorTypes[j].Name
+	})
+
+	return sensorTypes, nil
+}
+
+// UpdateSensorType replaces the description and schema of an existing sensor type.
+func (r *MemoryRepository) UpdateSensorType(sensorType *SensorType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sensorTypes[sensorType.Name]; !ok {
+		return ErrSensorTypeNotFound
+	}
+	r.sensorTypes[sensorType.Name] = copySensorType(*sensorType)
+
+	return nil
+}
+
+// ListSensorEvents retrieves up to limit events from the change log after the given event ID, in order.
+// It returns ErrSensorEventsPruned when events after the given ID have been pruned.
+func (r *MemoryRepository) ListSensorEvents(afterID int64, limit int) ([]SensorEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if afterID < r.prunedThrough {
+		return nil, ErrSensorEventsPruned
+	}
+
+	// Event IDs are positions in the change log, counting from 1, before any was pruned
+	events := []SensorEvent{}
+	for i := afterID - r.prunedThrough; i < int64(len(r.events)) && len(events) < limit; i++ {
+		event := r.events[i]
+		event.Sensor = copySensorMetadata(event.Sensor)
+		if event.Previous != nil {
+			previous := copySensorMetadata(*event.Previous)
+			event.Previous = &previous
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// LastSensorEventID returns the ID of the latest event in the change log, or 0 when it is empty.
+func (r *MemoryRepository) LastSensorEventID() (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lastEventID(), nil
+}
+
+// PruneSensorEvents deletes the events recorded before the given time from the change log, except
+// those the webhook outbox hasn't read yet, and returns the number of events deleted.
+func (r *MemoryRepository) PruneSensorEvents(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(r.events) && r.events[n].ID <= r.outboxEventID && r.eventTimes[n].Before(before) {
+		n++
+	}
+	r.events = append([]SensorEvent(nil), r.events[n:]...)
+	r.eventTimes = append([]time.Time(nil), r.eventTimes[n:]...)
+	r.prunedThrough += int64(n)
+
+	return int64(n), nil
+}
+
+// lastEventID returns the ID of the latest event in the change log. The caller must hold the lock.
+func (r *MemoryRepository) lastEventID() int64 {
+	return r.prunedThrough + int64(len(r.events))
+}
+
+// recordEvent appends a change to the change log, skipping updates that changed nothing.
+// The caller must hold the lock.
+func (r *MemoryRepository) recordEvent(eventType string, sensorMetadata SensorMetadata, previous *SensorMetadata) {
+	if previous != nil && reflect.DeepEqual(*previous, sensorMetadata) {
+		return
+	}
+	r.events = append(r.events, SensorEvent{ID: r.lastEventID() + 1, Type: eventType, Sensor: sensorMetadata, Previous: previous})
+	r.eventTimes = append(r.eventTimes, time.Now())
+}
+
+// CreateWebhook stores a new webhook and assigns its ID. It receives the events recorded from now on.
+func (r *MemoryRepository) CreateWebhook(webhook *Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhook.ID = r.nextWebhookID
+	webhook.AfterEventID = r.lastEventID()
+	r.nextWebhookID++
+	r.webhooks[webhook.ID] = copyWebhook(*webhook)
+
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+func (r *MemoryRepository) GetWebhook(id int) (*Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+
+	webhook = copyWebhook(webhook)
+	return &webhook, nil
+}
+
+// ListWebhooks retrieves all webhooks ordered by ID.
+func (r *MemoryRepository) ListWebhooks() ([]Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhooks := make([]Webhook, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].ID < webhooks[j].ID
+	})
+
+	return webhooks, nil
+}
+
+// UpdateWebhook replaces the URL, event types and tag filter of an existing webhook, and its
+// secret when a new one is given.
+func (r *MemoryRepository) UpdateWebhook(webhook *Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.webhooks[webhook.ID]
+	if !ok {
+		return ErrWebhookNotFound
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = stored.Secret
+	}
+	webhook.AfterEventID = stored.AfterEventID
+	r.webhooks[webhook.ID] = copyWebhook(*webhook)
+
+	return nil
+}
+
+// DeleteWebhook deletes a webhook together with its deliveries.
+func (r *MemoryRepository) DeleteWebhook(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(r.webhooks, id)
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries adds the deliveries of up to limit new events to the outbox, and
+// returns the number of events it read.
+func (r *MemoryRepository) EnqueueWebhookDeliveries(limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	end := r.outboxEventID + int64(limit)
+	if end > r.lastEventID() {
+		end = r.lastEventID()
+	}
+	events := r.events[r.outboxEventID-r.prunedThrough : end-r.prunedThrough]
+
+	webhooks := make([]Webhook, 0, len(r.webhooks))
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	deliveries, err := webhookDeliveries(webhooks, events)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, delivery := range deliveries {
+		delivery.ID = r.nextDeliveryID
+		delivery.NextAttemptAt = now
+		r.deliveries[delivery.ID] = delivery
+		r.nextDeliveryID++
+	}
+	r.outboxEventID = end
+
+	return len(events), nil
+}
+
+// ClaimWebhookDeliveries reserves up to limit pending deliveries that are due for the lease,
+// the earliest due first.
+func (r *MemoryRepository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttemptAt.Equal(deliveries[j].NextAttemptAt) {
+			return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	for i := range deliveries {
+		deliveries[i].NextAttemptAt = now.Add(lease)
+		r.deliveries[deliveries[i].ID] = deliveries[i]
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery records the status, attempts, next attempt and last error of a delivery.
+func (r *MemoryRepository) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Like an UPDATE matching no rows, updating a delivery of a deleted webhook is not an error
+	if stored, ok := r.deliveries[delivery.ID]; ok {
+		stored.Status = delivery.Status
+		stored.Attempts = delivery.Attempts
+		stored.NextAttemptAt = delivery.NextAttemptAt
+		stored.LastError = delivery.LastError
+		r.deliveries[delivery.ID] = stored
+	}
+
+	return nil
+}
+
+// ListWebhookDeliveries retrieves a page of the deliveries of a webhook matching the filter, ordered by ID.
+func (r *MemoryRepository) ListWebhookDeliveries(webhookID int, filter WebhookDeliveryFilter) ([]WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID == webhookID && delivery.ID > filter.AfterID && (filter.Status == "" || delivery.Status == filter.Status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+
+	return deliveries, nil
+}
+
+// RetryWebhookDelivery makes a delivery of a webhook pending again, due now with no attempts made.
+func (r *MemoryRepository) RetryWebhookDelivery(webhookID int, id int64) (*WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok || delivery.WebhookID != webhookID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.LastError = ""
+	r.deliveries[id] = delivery
+
+	return &delivery, nil
+}
+
+// idByName looks up the ID of the sensor with the given name. The caller must hold the lock.
+func (r *MemoryRepository) idByName(name string) (int, bool) {
+	id, ok := r.byName[name]
+	return id, ok
+}
+
+// putSensor stores a sensor under its ID, and indexes it under its name, in place of its
+// previous name when it was renamed. The caller must hold the lock.
+func (r *MemoryRepository) putSensor(sensorMetadata SensorMetadata) {
+	if previous, ok := r.sensors[sensorMetadata.ID]; ok && previous.Name != sensorMetadata.Name {
+		delete(r.byName, previous.Name)
+	}
+	r.sensors[sensorMetadata.ID] = sensorMetadata
+	r.byName[sensorMetadata.Name] = sensorMetadata.ID
+}
+
+// sortedSensors returns the stored sensors ordered by ID. The caller must hold the lock.
+func (r *MemoryRepository) sortedSensors() []SensorMetadata {
+	sensors := make([]SensorMetadata, 0, len(r.sensors))
+	for _, sensorMetadata := range r.sensors {
+		sensors = append(sensors, sensorMetadata)
+	}
+	sort.Slice(sensors, func(i, j int) bool {
+		return sensors[i].ID < sensors[j].ID
+	})
+	return sensors
+}
+
+// copySensorMetadata returns a copy that shares no slices with the original.
+func copySensorMetadata(sensorMetadata SensorMetadata) SensorMetadata {
+	if sensorMetadata.Tags != nil {
+		sensorMetadata.Tags = append(make([]string, 0, len(sensorMetadata.Tags)), sensorMetadata.Tags...)
+	}
+	sensorMetadata.Location.Altitude = copyPointer(sensorMetadata.Location.Altitude)
+	sensorMetadata.Location.Floor = copyPointer(sensorMetadata.Location.Floor)
+	sensorMetadata.Location.AccuracyM = copyPointer(sensorMetadata.Location.AccuracyM)
+	sensorMetadata.Attributes = copyAttributes(sensorMetadata.Attributes)
+	return sensorMetadata
+}
+
+// copyPointer returns a pointer to a copy of the value, or nil.
+func copyPointer[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+// copyAttributes deep copies attributes through JSON, which also gives them the
+// same shape (float64 numbers, generic maps) as attributes read from PostgreSQL.
+func copyAttributes(attributes map[string]interface{}) map[string]interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return attributes
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return attributes
+	}
+	return copied
+}
+
+// copyWebhook returns a copy that shares no event types with the original.
+func copyWebhook(webhook Webhook) Webhook {
+	if webhook.Events != nil {
+		webhook.Events = append(make([]string, 0, len(webhook.Events)), webhook.Events...)
+	}
+	return webhook
+}
+
+// copySensorType returns a copy that shares no schema bytes with the original.
+func copySensorType(sensorType SensorType) SensorType {
+	sensorType.Schema = append(json.RawMessage(nil), sensorType.Schema...)
+	return sensorType
+}
+
+// match reports whether a sensor after the filter's cursor is selected by it. The limit is left to the caller.
+func (filter SensorFilter) match(sensorMetadata SensorMetadata) bool {
+	if sensorMetadata.ID <= filter.AfterID {
+		return false
+	}
+	if filter.Names != nil && !containsString(filter.Names, sensorMetadata.Name) {
+		return false
+	}
+	if filter.Tags != nil && !filter.Tags.Match(sensorMetadata.Tags) {
+		return false
+	}
+	return matchAttributes(filter.Attributes, sensorMetadata.Attributes)
+}
+
+// matchAttributes reports whether the attributes satisfy every filter.
+func matchAttributes(filters []AttributeFilter, attributes map[string]interface{}) bool {
+	for _, filter := range filters {
+		if !filter.Match(attributes) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString reports whether the list contains the value.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// distanceTo returns the distance in meters from the query point to a location, mirroring
+// the PostgreSQL ranking: 3D when requested and both altitudes share a datum, surface otherwise.
+func (query NearestQuery) distanceTo(location Location) float64 {
+	distance := greatCircleDistance(query.Latitude, query.Longitude, location.Latitude, location.Longitude)
+	if query.Use3D && query.Altitude != nil && location.Altitude != nil && location.AltitudeDatum == query.AltitudeDatum {
+		return math.Hypot(distance, *location.Altitude-*query.Altitude)
+	}
+	return distance
+}
+
+// sameFloor reports whether a location is on the query's floor.
+func (query NearestQuery) sameFloor(location Location) bool {
+	return location.Floor != nil && *location.Floor == *query.Floor
+}
+
+// greatCircleDistance returns the distance in meters between two points using the haversine formula.
+func greatCircleDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
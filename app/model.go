@@ -1,16 +1,79 @@
 package app
 
-// SensorMetadata represents the structure of sensor metadata.
+import "strings"
+
+// SensorMetadata represents the structure of sensor metadata, as the repositories store it.
+// The APIs exchange it with clients through their own types, such as SensorMetadataV1, so its
+// JSON names only name the fields of validation errors.
 type SensorMetadata struct {
-	ID       int      `json:"id"`
-	Name     string   `json:"name" validate:"required"`
-	Location Location `json:"location" validate:"required"`
-	Tags     []string `json:"tags"`
-	Distance float64  `json:"distance,omitempty"`
+	ID         int                    `json:"id"`
+	Name       string                 `json:"name" validate:"required"`
+	Type       string                 `json:"type,omitempty" validate:"max=255"`
+	Location   Location               `json:"location" validate:"required"`
+	Tags       []string               `json:"tags"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// NearestSensor represents a sensor found by a nearest query, with its distance in meters from
+// the location of the query.
+type NearestSensor struct {
+	SensorMetadata
+	Distance float64
 }
 
 // Location represents the GPS position of a sensor.
+// Altitude is in meters above the AltitudeDatum, which defaults to WGS84 when an altitude is given.
 type Location struct {
-	Latitude  float64 `json:"latitude" validate:"required"`
-	Longitude float64 `json:"longitude" validate:"required"`
+	Latitude      float64  `json:"latitude" validate:"required"`
+	Longitude     float64  `json:"longitude" validate:"required"`
+	Altitude      *float64 `json:"altitude,omitempty"`
+	AltitudeDatum string   `json:"altitude_datum,omitempty" validate:"omitempty,oneof=WGS84 MSL"`
+	Floor         *int     `json:"floor,omitempty"`
+	AccuracyM     *float64 `json:"accuracy_m,omitempty" validate:"omitempty,gte=0"`
+}
+
+// Altitude datums supported by Location.
+const (
+	DatumWGS84 = "WGS84"
+	DatumMSL   = "MSL"
+)
+
+// Tag represents a structured key:value tag such as floor:3 or vendor:acme.
+type Tag struct {
+	Key   string `json:"key" validate:"required"`
+	Value string `json:"value" validate:"required"`
+}
+
+// ParseTag splits a tag into its key and value. Plain tags have no key and report false.
+func ParseTag(tag string) (Tag, bool) {
+	key, value, found := strings.Cut(tag, ":")
+	if !found || key == "" || value == "" {
+		return Tag{Value: tag}, false
+	}
+	return Tag{Key: key, Value: value}, true
+}
+
+// String returns the tag in its stored key:value form.
+func (t Tag) String() string {
+	return t.Key + ":" + t.Value
+}
+
+// normalizeLocation defaults the altitude datum and drops a datum that has no altitude.
+func (s *SensorMetadata) normalizeLocation() {
+	if s.Location.Altitude == nil {
+		s.Location.AltitudeDatum = ""
+	} else if s.Location.AltitudeDatum == "" {
+		s.Location.AltitudeDatum = DatumWGS84
+	}
+}
+
+// structuredTags returns the structured tags of a sensor, derived from its key:value tags.
+func (s SensorMetadata) structuredTags() []Tag {
+	var tags []Tag
+	for _, t := range s.Tags {
+		if tag, ok := ParseTag(t); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
 }
@@ -0,0 +1,132 @@
+package app
+
+// The handlers of version 1 of the REST API exchange the types of this file with clients, and map
+// them to and from the types the repositories store. The shapes of v1 stay the same when the
+// repository types change, and a later version can register handlers exchanging other shapes
+// over the same Repository.
+
+// SensorMetadataV1 represents a sensor in the requests and responses of version 1 of the API.
+// In requests, StructuredTags are merged into Tags; in responses, they are derived from the
+// key:value tags.
+type SensorMetadataV1 struct {
+	ID             int                    `json:"id"`
+	Name           string                 `json:"name" validate:"required"`
+	Type           string                 `json:"type,omitempty" validate:"max=255"`
+	Location       LocationV1             `json:"location" validate:"required"`
+	Tags           []string               `json:"tags"`
+	StructuredTags []Tag                  `json:"structured_tags,omitempty" validate:"dive"`
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// LocationV1 represents the GPS position of a sensor in version 1 of the API.
+type LocationV1 struct {
+	Latitude      float64  `json:"latitude" validate:"required"`
+	Longitude     float64  `json:"longitude" validate:"required"`
+	Altitude      *float64 `json:"altitude,omitempty"`
+	AltitudeDatum string   `json:"altitude_datum,omitempty" validate:"omitempty,oneof=WGS84 MSL"`
+	Floor         *int     `json:"floor,omitempty"`
+	AccuracyM     *float64 `json:"accuracy_m,omitempty" validate:"omitempty,gte=0"`
+}
+
+// NearestSensorV1 represents the sensor nearest to a location in version 1 of the API, with its
+// distance in meters alongside the fields of the sensor.
+type NearestSensorV1 struct {
+	SensorMetadataV1
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// SensorListV1 represents a page of sensors in version 1 of the API.
+type SensorListV1 struct {
+	Sensors []SensorMetadataV1 `json:"sensors"`
+	Next    string             `json:"next,omitempty"`
+}
+
+// BatchOperationV1 represents one create, upsert or delete of a batch request in version 1 of the API.
+// Create and upsert carry the sensor; delete names the sensor to remove.
+type BatchOperationV1 struct {
+	Op     string            `json:"op"`
+	Name   string            `json:"name,omitempty"`
+	Sensor *SensorMetadataV1 `json:"sensor,omitempty"`
+}
+
+// NewSensorMetadataV1 maps a sensor to version 1 of the API.
+func NewSensorMetadataV1(sensorMetadata SensorMetadata) SensorMetadataV1 {
+	return SensorMetadataV1{
+		ID:             sensorMetadata.ID,
+		Name:           sensorMetadata.Name,
+		Type:           sensorMetadata.Type,
+		Location:       NewLocationV1(sensorMetadata.Location),
+		Tags:           sensorMetadata.Tags,
+		StructuredTags: sensorMetadata.structuredTags(),
+		Attributes:     sensorMetadata.Attributes,
+	}
+}
+
+// NewLocationV1 maps a location to version 1 of the API.
+func NewLocationV1(location Location) LocationV1 {
+	return LocationV1{
+		Latitude:      location.Latitude,
+		Longitude:     location.Longitude,
+		Altitude:      location.Altitude,
+		AltitudeDatum: location.AltitudeDatum,
+		Floor:         location.Floor,
+		AccuracyM:     location.AccuracyM,
+	}
+}
+
+// NewNearestSensorV1 maps a sensor found by a nearest query to version 1 of the API.
+func NewNearestSensorV1(sensor NearestSensor) NearestSensorV1 {
+	return NearestSensorV1{SensorMetadataV1: NewSensorMetadataV1(sensor.SensorMetadata), Distance: sensor.Distance}
+}
+
+// NewSensorListV1 maps a page of sensors to version 1 of the API.
+func NewSensorListV1(sensors []SensorMetadata, next string) SensorListV1 {
+	sensorList := SensorListV1{Sensors: make([]SensorMetadataV1, len(sensors)), Next: next}
+	for i, sensorMetadata := range sensors {
+		sensorList.Sensors[i] = NewSensorMetadataV1(sensorMetadata)
+	}
+	return sensorList
+}
+
+// SensorMetadata maps a sensor of a request to the type the repositories store, with its
+// structured tags merged into its tags.
+func (s SensorMetadataV1) SensorMetadata() SensorMetadata {
+	sensorMetadata := SensorMetadata{
+		ID:         s.ID,
+		Name:       s.Name,
+		Type:       s.Type,
+		Location:   s.Location.Location(),
+		Tags:       s.Tags,
+		Attributes: s.Attributes,
+	}
+	if len(s.StructuredTags) > 0 {
+		sensorMetadata.Tags = append(make([]string, 0, len(s.Tags)+len(s.StructuredTags)), s.Tags...)
+	}
+	for _, tag := range s.StructuredTags {
+		sensorMetadata.Tags = append(sensorMetadata.Tags, tag.String())
+	}
+	return sensorMetadata
+}
+
+// Location maps a location of a request to the type the repositories store.
+func (l LocationV1) Location() Location {
+	return Location{
+		Latitude:      l.Latitude,
+		Longitude:     l.Longitude,
+		Altitude:      l.Altitude,
+		AltitudeDatum: l.AltitudeDatum,
+		Floor:         l.Floor,
+		AccuracyM:     l.AccuracyM,
+	}
+}
+
+// BatchOperation maps a batch operation of a request to the type the repositories apply.
+// The sensor isn't mapped when it's missing, which validation reports.
+func (op BatchOperationV1) BatchOperation() BatchOperation {
+	operation := BatchOperation{Op: op.Op, Name: op.Name}
+	if op.Sensor != nil {
+		sensorMetadata := op.Sensor.SensorMetadata()
+		operation.Sensor = &sensorMetadata
+	}
+	return operation
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SensorChangeChannel is the channel on which migration 13 notifies every change to a sensor.
+const SensorChangeChannel = "sensor_metadata_changed"
+
+// SensorChange represents the notification of a change to a sensor. EventID is the ID of the
+// change in the change log; PreviousName is the name of the sensor before an update.
+type SensorChange struct {
+	EventID      int64  `json:"event_id"`
+	Type         string `json:"type"`
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	PreviousName string `json:"previous_name,omitempty"`
+}
+
+// sensorChange returns the notification of an event read from the change log.
+func sensorChange(event SensorEvent) SensorChange {
+	change := SensorChange{EventID: event.ID, Type: event.Type, ID: event.Sensor.ID, Name: event.Sensor.Name}
+	if event.Previous != nil {
+		change.PreviousName = event.Previous.Name
+	}
+	return change
+}
+
+// SensorChangeSubscriber is implemented by the local state of a replica that must follow the
+// changes made to sensors by every replica, such as caches.
+type SensorChangeSubscriber interface {
+	// SensorChanged is called for every change to a sensor, in the order of the change log.
+	SensorChanged(change SensorChange)
+	// ResyncSensors is called when changes may have been missed, and all local state about
+	// sensors must be dropped or reloaded.
+	ResyncSensors()
+}
+
+// SensorChangeListener listens for the notifications of changes to sensors and passes them on to
+// its subscribers. Notifications are lost while the connection is down, so after reconnecting
+// the listener replays the missed changes from the change log, or asks its subscribers to resync
+// when there are too many of them. Event IDs that skip ahead are replayed the same way, since
+// they may hide a lost notification as well as a rolled back transaction. Transactions commit
+// out of the order of their event IDs, and the change log holds back the events after those of a
+// transaction in flight, so the listener keeps catching up until it has passed on every change
+// notified.
+type SensorChangeListener struct {
+	connStr string
+	repo    Repository
+
+	mu          sync.Mutex
+	subscribers []SensorChangeSubscriber
+
+	// MinReconnectInterval and MaxReconnectInterval bound the delay between reconnection attempts,
+	// which doubles after every failure.
+	MinReconnectInterval time.Duration
+	MaxReconnectInterval time.Duration
+	// PingInterval is the delay after which an idle connection is checked.
+	PingInterval time.Duration
+	// MaxCatchUp is the number of missed changes above which subscribers resync instead.
+	MaxCatchUp int
+	// RetryInterval is the delay after which the listener catches up again with changes notified
+	// but held back by the change log.
+	RetryInterval time.Duration
+}
+
+// Defaults of a SensorChangeListener.
+const (
+	defaultListenerMinReconnect = time.Second
+	defaultListenerMaxReconnect = time.Minute
+	defaultListenerPingInterval = 90 * time.Second
+	defaultListenerMaxCatchUp   = 1000
+	defaultListenerRetry        = time.Second
+)
+
+// NewSensorChangeListener creates a listener for the database configured by the DB_* environment
+// variables, which replays missed changes from the change log of the repository.
+func NewSensorChangeListener(repo Repository) *SensorChangeListener {
+	return &SensorChangeListener{
+		connStr:              postgresConnStr(),
+		repo:                 repo,
+		MinReconnectInterval: defaultListenerMinReconnect,
+		MaxReconnectInterval: defaultListenerMaxReconnect,
+		PingInterval:         defaultListenerPingInterval,
+		MaxCatchUp:           defaultListenerMaxCatchUp,
+		RetryInterval:        defaultListenerRetry,
+	}
+}
+
+// Subscribe adds a subscriber to the changes to sensors.
+func (l *SensorChangeListener) Subscribe(subscriber SensorChangeSubscriber) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subscribers = append(l.subscribers, subscriber)
+}
+
+// Run listens for changes until the context is cancelled, reconnecting whenever the connection is lost.
+func (l *SensorChangeListener) Run(ctx context.Context) error {
+	listener := pq.NewListener(l.connStr, l.MinReconnectInterval, l.MaxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("Error listening for sensor changes:", err)
+		}
+	})
+	defer listener.Close()
+
+	// Listen before reading the last event ID, so that no change falls in between
+	if err := listener.Listen(SensorChangeChannel); err != nil {
+		return err
+	}
+
+	// A connection that died silently is only noticed when it's used
+	go func() {
+		ticker := time.NewTicker(l.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				listener.Ping()
+			}
+		}
+	}()
+
+	l.Consume(ctx, listener.Notify)
+	return nil
+}
+
+// Consume passes on the notifications received from a pq.Listener until the context is cancelled
+// or the channel is closed. A nil notification means the connection was re-established.
+func (l *SensorChangeListener) Consume(ctx context.Context, notifications <-chan *pq.Notification) {
+	lastID, err := l.repo.LastSensorEventID()
+	if err != nil {
+		log.Println("Error reading the last sensor event:", err)
+		lastID = l.resync()
+	}
+
+	// The highest event ID notified, and the timer catching up with it while it's ahead
+	var notifiedID int64
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-retry:
+			retry = nil
+			lastID = l.catchUp(lastID)
+		case notification, ok := <-notifications:
+			if !ok {
+				return
+			}
+			if notification == nil {
+				// Notifications sent while the connection was down are lost
+				lastID = l.catchUp(lastID)
+				break
+			}
+
+			var change SensorChange
+			if err := json.Unmarshal([]byte(notification.Extra), &change); err != nil {
+				log.Println("Error decoding sensor change:", err)
+				break
+			}
+			if change.EventID > notifiedID {
+				notifiedID = change.EventID
+			}
+			if change.EventID <= lastID {
+				// Already replayed from the change log
+				break
+			}
+			if change.EventID > lastID+1 {
+				lastID = l.catchUp(lastID)
+				break
+			}
+			l.publish(change)
+			lastID = change.EventID
+		}
+
+		if lastID < notifiedID && retry == nil {
+			retry = time.After(l.RetryInterval)
+		}
+	}
+}
+
+// catchUp replays the changes after the last event passed on from the change log, or resyncs the
+// subscribers when there are too many of them, and returns the ID of the last change passed on.
+func (l *SensorChangeListener) catchUp(lastID int64) int64 {
+	events, err := l.repo.ListSensorEvents(lastID, l.MaxCatchUp+1)
+	if err != nil {
+		log.Println("Error reading missed sensor events:", err)
+		return l.resync()
+	}
+	if len(events) > l.MaxCatchUp {
+		return l.resync()
+	}
+
+	for _, event := range events {
+		l.publish(sensorChange(event))
+		lastID = event.ID
+	}
+	return lastID
+}
+
+// resync asks the subscribers to drop their state, and returns the ID of the last change they
+// reflect. It's read first, so the changes made while they resync are passed on again.
+func (l *SensorChangeListener) resync() int64 {
+	lastID, err := l.repo.LastSensorEventID()
+	if err != nil {
+		// Every later change is seen as a gap, and resyncs again
+		log.Println("Error reading the last sensor event:", err)
+		lastID = -1
+	}
+
+	l.mu.Lock()
+	subscribers := l.subscribers
+	l.mu.Unlock()
+	for _, subscriber := range subscribers {
+		subscriber.ResyncSensors()
+	}
+	return lastID
+}
+
+// publish passes a change on to the subscribers.
+func (l *SensorChangeListener) publish(change SensorChange) {
+	l.mu.Lock()
+	subscribers := l.subscribers
+	l.mu.Unlock()
+	for _, subscriber := range subscribers {
+		subscriber.SensorChanged(change)
+	}
+}
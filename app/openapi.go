@@ -0,0 +1,33 @@
+package app
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// OpenAPISpec is the OpenAPI 3.1 document describing every route of NewRouter.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+//go:embed swagger-ui.html
+var swaggerUIPage []byte
+
+// GetOpenAPISpec handles the HTTP GET request for the OpenAPI document.
+func (h *Handler) GetOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(OpenAPISpec)
+}
+
+// RegisterSwaggerUI serves a Swagger UI page for the OpenAPI document at /docs.
+// The page loads the Swagger UI scripts from a CDN, so browsers need internet access.
+func RegisterSwaggerUI(router *mux.Router) {
+	router.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(swaggerUIPage)
+	}).Methods(http.MethodGet)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+)
+
+// PostGISRepository represents the PostgreSQL repository implementation backed by PostGIS.
+// It reads the geography column added by migrations/postgis, which is generated from the
+// latitude and longitude columns, so writes are shared with PostgresRepository and
+// only the nearest queries differ: distances are ellipsoidal and ranking uses the
+// GiST index through KNN ordering.
+type PostGISRepository struct {
+	*PostgresRepository
+}
+
+// NewPostGISRepository creates a new instance of the PostGIS repository.
+func NewPostGISRepository() (*PostGISRepository, error) {
+	db, err := openPostgres()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail fast if the PostGIS extension isn't installed
+	var version string
+	if err := db.QueryRow("SELECT postgis_version()").Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("postgis is not available: %w", err)
+	}
+
+	// Nor if the migrations of the backend haven't been applied
+	if _, err := db.Exec("SELECT location FROM sensor_metadata LIMIT 0"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("the postgis migrations have not been applied: %w", err)
+	}
+
+	replicas, err := openReplicas()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostGISRepository{PostgresRepository: &PostgresRepository{Db: db, Replicas: replicas, Statements: NewStatementCache()}}, nil
+}
+
+// Primary returns a view of the repository that reads from the primary.
+func (r *PostGISRepository) Primary() Repository {
+	return &PostGISRepository{PostgresRepository: &PostgresRepository{Db: r.Db, Statements: r.Statements}}
+}
+
+// GetNearestSensorMetadata retrieves the sensor metadata nearest to a location.
+func (r *PostGISRepository) GetNearestSensorMetadata(latitude, longitude string) (*NearestSensor, error) {
+	query, err := parseNearestQuery(latitude, longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	sensors, err := r.FindNearestSensorMetadata(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(sensors) == 0 {
+		return nil, ErrSensorNotFound
+	}
+
+	return &sensors[0], nil
+}
+
+// FindNearestSensorMetadata retrieves the sensors nearest to a location that match the query's tag filter.
+// Sensors are ranked with the KNN operator, which the GiST index on location serves directly.
+func (r *PostGISRepository) FindNearestSensorMetadata(query NearestQuery) ([]NearestSensor, error) {
+	q := &sqlQuery{}
+	point := "ST_SetSRID(ST_MakePoint(" + q.arg(query.Longitude) + ", " + q.arg(query.Latitude) + "), 4326)::geography"
+
+	return r.queryNearestSensors(query.sql(q, "ST_Distance(location, "+point+")", "location <-> "+point, ""), q.args)
+}
+
+// FindNeighbourSensorMetadata retrieves the sensors nearest to each of the query's sensors that
+// match its tag filter, ranked with the KNN operator in a single query.
+func (r *PostGISRepository) FindNeighbourSensorMetadata(query NeighbourQuery) (map[int][]NearestSensor, error) {
+	q := &sqlQuery{}
+	return r.queryNeighbours(query.sql(q, "ST_Distance(location, origin.location)", "location <-> origin.location"), q.args)
+}
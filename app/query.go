@@ -0,0 +1,14 @@
+package app
+
+import "fmt"
+
+// sqlQuery accumulates the positional parameters of a dynamically built SQL statement.
+type sqlQuery struct {
+	args []interface{}
+}
+
+// arg records a parameter value and returns its placeholder.
+func (q *sqlQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicatedRepository is implemented by repositories that send reads to replicas of their database.
+type ReplicatedRepository interface {
+	Repository
+	// Primary returns a view of the repository that sends its reads to the primary as well.
+	Primary() Repository
+	// ReplicaStaleness bounds how long a write may take to be seen by the reads sent to replicas.
+	ReplicaStaleness() time.Duration
+}
+
+// primaryOf returns a view of the repository that reads from the primary, or the repository
+// itself when it has no replicas.
+func primaryOf(repo Repository) Repository {
+	if replicated, ok := repo.(ReplicatedRepository); ok {
+		return replicated.Primary()
+	}
+	return repo
+}
+
+// replicaStaleness returns how long a write may take to be seen by the reads of the repository.
+func replicaStaleness(repo Repository) time.Duration {
+	if replicated, ok := repo.(ReplicatedRepository); ok {
+		return replicated.ReplicaStaleness()
+	}
+	return 0
+}
+
+// ReplicaSet routes reads to the healthy replicas of a database, in turn. Replicas are checked
+// every CheckInterval, and one that fails its check or replays the primary's changes more than
+// MaxLag behind is evicted until a later check finds it healthy again. Replicas are evicted until
+// their first check, so reads go to the primary rather than to a replica that may be far behind.
+type ReplicaSet struct {
+	// CheckInterval is the delay between health checks.
+	CheckInterval time.Duration
+	// MaxLag is the replication lag above which a replica is evicted.
+	MaxLag time.Duration
+
+	replicas []*replica
+	next     atomic.Uint64
+	// healthy holds the replicas that passed their last check
+	mu      sync.RWMutex
+	healthy []*replica
+}
+
+// replica is a replica database, with the outcome of its last health check.
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy bool
+}
+
+// Defaults of a ReplicaSet.
+const (
+	defaultReplicaCheckInterval = 5 * time.Second
+	defaultReplicaMaxLag        = 10 * time.Second
+)
+
+// replicaLagQuery returns how far behind the primary a replica replays, in seconds. A replica
+// that has replayed everything it received is up to date, however old its last transaction is.
+const replicaLagQuery = "SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END"
+
+// NewReplicaSet creates a set of the replica databases. Names identify them in logs.
+func NewReplicaSet(names []string, dbs []*sql.DB) *ReplicaSet {
+	set := &ReplicaSet{CheckInterval: defaultReplicaCheckInterval, MaxLag: defaultReplicaMaxLag}
+	for i, db := range dbs {
+		set.replicas = append(set.replicas, &replica{name: names[i], db: db})
+	}
+	return set
+}
+
+// openReplicas opens the replicas listed as host or host:port in the DB_REPLICA_HOSTS environment
+// variable, which share the other DB_* settings of the primary, and starts checking them.
+// DB_REPLICA_MAX_LAG overrides the lag above which a replica is evicted. It returns nil when no
+// replicas are configured. Replicas that are down are evicted rather than failing the startup.
+func openReplicas() (*ReplicaSet, error) {
+	hosts := os.Getenv("DB_REPLICA_HOSTS")
+	if hosts == "" {
+		return nil, nil
+	}
+
+	var names []string
+	var dbs []*sql.DB
+	for _, name := range strings.Split(hosts, ",") {
+		name = strings.TrimSpace(name)
+		host, port, found := strings.Cut(name, ":")
+		if !found {
+			port = os.Getenv("DB_PORT")
+		}
+		db, err := sql.Open("postgres", postgresHostConnStr(host, port))
+		if err == nil {
+			names = append(names, name)
+			dbs = append(dbs, db)
+			err = configurePool(db)
+		}
+		if err != nil {
+			for _, db := range dbs {
+				db.Close()
+			}
+			return nil, err
+		}
+	}
+
+	set := NewReplicaSet(names, dbs)
+	if maxLag := os.Getenv("DB_REPLICA_MAX_LAG"); maxLag != "" {
+		var err error
+		if set.MaxLag, err = time.ParseDuration(maxLag); err != nil {
+			return nil, fmt.Errorf("invalid DB_REPLICA_MAX_LAG %q", maxLag)
+		}
+	}
+	set.Check(context.Background())
+	go set.Run(context.Background())
+	return set, nil
+}
+
+// Healthy returns the number of replicas that reads are sent to.
+func (s *ReplicaSet) Healthy() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.healthy)
+}
+
+// pick returns the next healthy replica, or nil when there is none.
+func (s *ReplicaSet) pick() *sql.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.healthy) == 0 {
+		return nil
+	}
+	return s.healthy[s.next.Add(1)%uint64(len(s.healthy))].db
+}
+
+// Run checks the replicas until the context is cancelled.
+func (s *ReplicaSet) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.CheckInterval)
+	defer ticker.Stop()
+	for {
+		s.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks every replica once, and sends reads to those found healthy.
+func (s *ReplicaSet) Check(ctx context.Context) {
+	var healthy []*replica
+	for _, replica := range s.replicas {
+		err := s.check(ctx, replica.db)
+		if err != nil && replica.healthy {
+			log.Printf("Evicting replica %s: %v", replica.name, err)
+		} else if err == nil && !replica.healthy {
+			log.Printf("Sending reads to replica %s", replica.name)
+		}
+		replica.healthy = err == nil
+		if replica.healthy {
+			healthy = append(healthy, replica)
+		}
+	}
+
+	s.mu.Lock()
+	s.healthy = healthy
+	s.mu.Unlock()
+}
+
+// check returns why a replica isn't healthy, or nil.
+func (s *ReplicaSet) check(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, s.CheckInterval)
+	defer cancel()
+
+	var seconds float64
+	if err := db.QueryRowContext(ctx, replicaLagQuery).Scan(&seconds); err != nil {
+		return err
+	}
+	if lag := time.Duration(seconds * float64(time.Second)); lag > s.MaxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Millisecond), s.MaxLag)
+	}
+	return nil
+}
+
+// staleness bounds how long a write may take to reach the replicas that reads are sent to. A
+// replica lagging by at most MaxLag when checked falls behind by at most CheckInterval more
+// before it is checked again.
+func (s *ReplicaSet) staleness() time.Duration {
+	return s.MaxLag + s.CheckInterval
+}
+
+// Headers with which clients ask to read their own writes. ReadYourWritesHeader set to "true"
+// sends every read of a request to the primary; SessionTokenHeader, set on the response to every
+// write, sends reads to the primary until the write has reached the replicas.
+const (
+	ReadYourWritesHeader = "X-Read-Your-Writes"
+	SessionTokenHeader   = "X-Session-Token"
+)
+
+// sessionToken sets the session token on the successful responses to writes, when reads may go
+// to replicas. The token is the time of the response in Unix milliseconds, after the write was
+// committed.
+func (h *Handler) sessionToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if replicaStaleness(h.repo) == 0 || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&sessionTokenWriter{ResponseWriter: w}, r)
+	})
+}
+
+// sessionTokenWriter sets the session token header when the response is written.
+type sessionTokenWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *sessionTokenWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode < http.StatusBadRequest {
+		w.Header().Set(SessionTokenHeader, newSessionToken())
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *sessionTokenWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Helper function to pick the repository serving the reads of a request: the primary when the
+// client asks to read its own writes, and the replicas otherwise.
+func (h *Handler) reader(r *http.Request) Repository {
+	return h.readerFor(r.Header.Get(ReadYourWritesHeader), r.Header.Get(SessionTokenHeader))
+}
+
+// Helper function to pick the repository serving reads from the read-your-writes flag and the
+// session token sent by a client, over HTTP or gRPC.
+func (h *Handler) readerFor(readYourWrites, sessionToken string) Repository {
+	staleness := replicaStaleness(h.repo)
+	if staleness == 0 {
+		return h.repo
+	}
+	if readYourWrites == "true" {
+		return primaryOf(h.repo)
+	}
+	if token, err := strconv.ParseInt(sessionToken, 10, 64); err == nil && time.Since(time.UnixMilli(token)) < staleness {
+		return primaryOf(h.repo)
+	}
+	return h.repo
+}
+
+// newSessionToken returns a session token for a write committed now.
+func newSessionToken() string {
+	return strconv.FormatInt(time.Now().UnixMilli(), 10)
+}
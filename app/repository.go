@@ -2,8 +2,14 @@ package app
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
@@ -14,53 +20,317 @@ type Repository interface {
 	CreateSensorMetadata(sensorMetadata *SensorMetadata) error
 	GetSensorMetadataByName(name string) (*SensorMetadata, error)
 	UpdateSensorMetadata(sensorMetadata *SensorMetadata) error
-	GetNearestSensorMetadata(latitude, longitude string) (*SensorMetadata, error)
+	GetNearestSensorMetadata(latitude, longitude string) (*NearestSensor, error)
+	AddSensorTags(name string, tags []string) (*SensorMetadata, error)
+	RemoveSensorTag(name, tag string) (*SensorMetadata, error)
+	GetTagCounts() ([]TagCount, error)
+	ListSensorMetadata(filter SensorFilter) ([]SensorMetadata, error)
+	FindNearestSensorMetadata(query NearestQuery) ([]NearestSensor, error)
+	FindNeighbourSensorMetadata(query NeighbourQuery) (map[int][]NearestSensor, error)
+	FindSensorMetadataWithin(query WithinQuery) ([]SensorMetadata, error)
+	ApplySensorBatch(operations []BatchOperation, atomic bool) ([]BatchOutcome, error)
+	CreateSensorType(sensorType *SensorType) error
+	GetSensorType(name string) (*SensorType, error)
+	ListSensorTypes() ([]SensorType, error)
+	UpdateSensorType(sensorType *SensorType) error
+	ListSensorEvents(afterID int64, limit int) ([]SensorEvent, error)
+	LastSensorEventID() (int64, error)
+	PruneSensorEvents(before time.Time) (int64, error)
+	WebhookRepository
 }
 
+// SensorFilter represents the criteria for listing sensor metadata.
+// Results are ordered by ID; AfterID is the keyset cursor of the previous page.
+// A non-nil Names restricts the results to the sensors with those names.
+type SensorFilter struct {
+	Tags       TagExpr
+	Attributes []AttributeFilter
+	Names      []string
+	AfterID    int
+	Limit      int
+}
+
+// NearestQuery represents the criteria for finding the sensors nearest to a location.
+// With Use3D, sensors whose altitude shares the query's datum are ranked by 3D distance;
+// with PreferSameFloor, sensors on the query's floor are ranked ahead of all others.
+type NearestQuery struct {
+	Latitude        float64
+	Longitude       float64
+	Altitude        *float64
+	AltitudeDatum   string
+	Floor           *int
+	Use3D           bool
+	PreferSameFloor bool
+	Tags            TagExpr
+	Limit           int
+}
+
+// NeighbourQuery represents the criteria for finding the sensors nearest to each of several
+// sensors, given by ID. A sensor isn't its own neighbour, and Limit bounds the neighbours of each.
+type NeighbourQuery struct {
+	IDs   []int
+	Tags  TagExpr
+	Limit int
+}
+
+// WithinQuery represents the criteria for finding the sensors inside an area: either a
+// bounding box or a set of polygons. Results are filtered and paged like SensorFilter.
+type WithinQuery struct {
+	SensorFilter
+	BoundingBox *BoundingBox
+	Polygons    []Polygon
+}
+
+// Contains reports whether the query's area contains the location.
+func (query WithinQuery) Contains(location Location) bool {
+	if query.BoundingBox != nil {
+		return query.BoundingBox.Contains(location.Latitude, location.Longitude)
+	}
+	for _, polygon := range query.Polygons {
+		if polygon.Contains(location.Latitude, location.Longitude) {
+			return true
+		}
+	}
+	return false
+}
+
+// sql returns the predicate selecting rows inside the query's area.
+func (query WithinQuery) sql(q *sqlQuery) string {
+	if query.BoundingBox != nil {
+		return query.BoundingBox.sql(q)
+	}
+	terms := make([]string, len(query.Polygons))
+	for i, polygon := range query.Polygons {
+		terms[i] = polygon.sql(q)
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// parseNearestQuery builds a query for the single sensor nearest to a textual latitude and longitude.
+func parseNearestQuery(latitude, longitude string) (NearestQuery, error) {
+	lat, err := strconv.ParseFloat(latitude, 64)
+	if err != nil {
+		return NearestQuery{}, fmt.Errorf("invalid latitude %q", latitude)
+	}
+	lon, err := strconv.ParseFloat(longitude, 64)
+	if err != nil {
+		return NearestQuery{}, fmt.Errorf("invalid longitude %q", longitude)
+	}
+	return NearestQuery{Latitude: lat, Longitude: lon, Limit: 1}, nil
+}
+
+// ErrSensorNotFound is returned when no sensor metadata matches the lookup.
+var ErrSensorNotFound = errors.New("sensor metadata not found")
+
 // PostgresRepository represents the PostgreSQL repository implementation.
+// With Replicas set, reads of sensors and sensor types are sent to the healthy replicas, and
+// everything else to Db, the primary. The change log and webhooks are always read from the
+// primary, since their readers act on what they read. With Statements set, statements are
+// prepared once and reused; without, they are prepared for every call.
 type PostgresRepository struct {
-	Db *sql.DB
+	Db         *sql.DB
+	Replicas   *ReplicaSet
+	Statements *StatementCache
+}
+
+// NewRepository creates the repository selected by the DB_BACKEND environment variable:
+// "postgres" (the default), "postgis" or "memory". The Postgres backends retry transient errors.
+func NewRepository() (Repository, error) {
+	switch backend := os.Getenv("DB_BACKEND"); backend {
+	case "", "postgres":
+		repo, err := NewPostgresRepository()
+		if err != nil {
+			return nil, err
+		}
+		return NewRetryingRepository(repo), nil
+	case "postgis":
+		repo, err := NewPostGISRepository()
+		if err != nil {
+			return nil, err
+		}
+		return NewRetryingRepository(repo), nil
+	case "memory":
+		return NewMemoryRepository(), nil
+	default:
+		return nil, fmt.Errorf("unknown DB_BACKEND %q", backend)
+	}
 }
 
 // NewPostgresRepository creates a new instance of the PostgreSQL repository.
 func NewPostgresRepository() (*PostgresRepository, error) {
+	db, err := openPostgres()
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := openReplicas()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresRepository{Db: db, Replicas: replicas, Statements: NewStatementCache()}, nil
+}
+
+// postgresConnStr returns the connection string of the database configured by the DB_* environment variables.
+func postgresConnStr() string {
+	return postgresHostConnStr(os.Getenv("DB_HOST"), os.Getenv("DB_PORT"))
+}
+
+// postgresHostConnStr returns the connection string of the configured database on a host.
+func postgresHostConnStr(host, port string) string {
 	// Read the environment variables
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
 	dbName := os.Getenv("DB_NAME")
 	user := os.Getenv("DB_USER")
 	password := os.Getenv("DB_PASSWORD")
 
 	// Construct the connection string
-	connStr := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=disable", host, port, dbName, user, password)
+	return fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=disable", host, port, dbName, user, password)
+}
 
+// openPostgres connects to the database configured by the DB_* environment variables. A database
+// that isn't up yet is pinged again with backoff, for up to DB_CONNECT_TIMEOUT.
+func openPostgres() (*sql.DB, error) {
 	// Connect to the database
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", postgresConnStr())
 	if err != nil {
 		return nil, err
 	}
+	if err := configurePool(db); err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	// Check the database connection
-	err = db.Ping()
+	timeout := defaultConnectTimeout
+	if value := os.Getenv("DB_CONNECT_TIMEOUT"); value != "" {
+		if timeout, err = time.ParseDuration(value); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("invalid DB_CONNECT_TIMEOUT %q", value)
+		}
+	}
+	err = pingWithBackoff(db, timeout)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return &PostgresRepository{Db: db}, nil
+	return db, nil
+}
+
+// Defaults of the connection pool, and of the time to wait for the database at startup.
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 25
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+	defaultConnectTimeout  = 30 * time.Second
+	connectBackoffBase     = 250 * time.Millisecond
+	connectBackoffMax      = 5 * time.Second
+)
+
+// configurePool sizes the connection pool from DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS, and bounds
+// the age of its connections with DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME. Recycling
+// connections spreads them again over the servers behind a load balancer or a failed over address.
+func configurePool(db *sql.DB) error {
+	maxOpenConns, maxIdleConns := defaultMaxOpenConns, defaultMaxIdleConns
+	maxLifetime, maxIdleTime := defaultConnMaxLifetime, defaultConnMaxIdleTime
+
+	for name, value := range map[string]*int{"DB_MAX_OPEN_CONNS": &maxOpenConns, "DB_MAX_IDLE_CONNS": &maxIdleConns} {
+		if env := os.Getenv(name); env != "" {
+			n, err := strconv.Atoi(env)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q", name, env)
+			}
+			*value = n
+		}
+	}
+	for name, value := range map[string]*time.Duration{"DB_CONN_MAX_LIFETIME": &maxLifetime, "DB_CONN_MAX_IDLE_TIME": &maxIdleTime} {
+		if env := os.Getenv(name); env != "" {
+			d, err := time.ParseDuration(env)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid %s %q", name, env)
+			}
+			*value = d
+		}
+	}
+
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(maxLifetime)
+	db.SetConnMaxIdleTime(maxIdleTime)
+	return nil
+}
+
+// pingWithBackoff pings the database until it answers, doubling the delay between attempts, and
+// returns the last error once the timeout has passed. Only errors of a database that isn't up yet
+// are retried; others, such as a wrong password or a missing database, are returned at once.
+func pingWithBackoff(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := connectBackoffBase
+	for {
+		err := db.Ping()
+		if err == nil {
+			return nil
+		}
+		if !isNotExecuted(err) && !isConnectionError(err) {
+			return err
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		log.Printf("Waiting for the database: %v", err)
+		time.Sleep(delay)
+		if delay *= 2; delay > connectBackoffMax {
+			delay = connectBackoffMax
+		}
+	}
+}
+
+// reader returns the database to send a read to: a healthy replica, or the primary when there is none.
+func (r *PostgresRepository) reader() *sql.DB {
+	if r.Replicas != nil {
+		if db := r.Replicas.pick(); db != nil {
+			return db
+		}
+	}
+	return r.Db
+}
+
+// Primary returns a view of the repository that reads from the primary.
+func (r *PostgresRepository) Primary() Repository {
+	return &PostgresRepository{Db: r.Db, Statements: r.Statements}
+}
+
+// ReplicaStaleness bounds how long a write may take to be seen by the reads sent to replicas.
+func (r *PostgresRepository) ReplicaStaleness() time.Duration {
+	if r.Replicas == nil {
+		return 0
+	}
+	return r.Replicas.staleness()
 }
 
 // CreateSensorMetadata creates a new sensor metadata entry in the database.
 func (r *PostgresRepository) CreateSensorMetadata(sensorMetadata *SensorMetadata) error {
 	// Prepare the SQL statement
-	stmt, err := r.Db.Prepare("INSERT INTO sensor_metadata (name, location_latitude, location_longitude, tags) VALUES ($1, $2, $3, $4)")
+	stmt, release, err := r.prepare(r.Db, "INSERT INTO sensor_metadata (name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''), $10)")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	attributes, err := marshalAttributes(sensorMetadata.Attributes)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
 	// Execute the SQL statement
-	_, err = stmt.Exec(sensorMetadata.Name, sensorMetadata.Location.Latitude, sensorMetadata.Location.Longitude, pq.Array(sensorMetadata.Tags))
+	location := sensorMetadata.Location
+	_, err = stmt.Exec(sensorMetadata.Name, location.Latitude, location.Longitude, location.Altitude, location.AltitudeDatum, location.Floor, location.AccuracyM, pq.Array(sensorMetadata.Tags), sensorMetadata.Type, attributes)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrSensorExists
+		}
 		return err
 	}
 
@@ -70,11 +340,11 @@ func (r *PostgresRepository) CreateSensorMetadata(sensorMetadata *SensorMetadata
 // GetSensorMetadataByName retrieves sensor metadata from the database by name.
 func (r *PostgresRepository) GetSensorMetadataByName(name string) (*SensorMetadata, error) {
 	// Prepare the SQL statement
-	stmt, err := r.Db.Prepare("SELECT id, name, location_latitude, location_longitude, tags FROM sensor_metadata WHERE name = $1")
+	stmt, release, err := r.prepare(r.reader(), "SELECT "+sensorColumns+" FROM sensor_metadata WHERE name = $1")
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	defer release()
 
 	// Execute the SQL statement
 	row := stmt.QueryRow(name)
@@ -83,11 +353,11 @@ func (r *PostgresRepository) GetSensorMetadataByName(name string) (*SensorMetada
 	var sensorMetadata SensorMetadata
 
 	// Scan the result into the SensorMetadata struct
-	err = row.Scan(&sensorMetadata.ID, &sensorMetadata.Name, &sensorMetadata.Location.Latitude, &sensorMetadata.Location.Longitude, pq.Array(&sensorMetadata.Tags))
+	err = scanSensorMetadata(row, &sensorMetadata)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Return nil and a custom error if no rows are found
-			return nil, fmt.Errorf("sensor metadata not found")
+			return nil, ErrSensorNotFound
 		}
 		return nil, err
 	}
@@ -95,48 +365,1020 @@ func (r *PostgresRepository) GetSensorMetadataByName(name string) (*SensorMetada
 	return &sensorMetadata, nil
 }
 
-// UpdateSensorMetadata updates an existing sensor metadata entry in the database.
+// UpdateSensorMetadata updates an existing sensor metadata entry in the database, or returns
+// ErrSensorNotFound when no sensor has its ID.
 func (r *PostgresRepository) UpdateSensorMetadata(sensorMetadata *SensorMetadata) error {
 	// Prepare the SQL statement
-	stmt, err := r.Db.Prepare("UPDATE sensor_metadata SET name = $1, location_latitude = $2, location_longitude = $3, tags = $4 WHERE id = $5")
+	stmt, release, err := r.prepare(r.Db, "UPDATE sensor_metadata SET name = $1, location_latitude = $2, location_longitude = $3, location_altitude = $4, location_altitude_datum = NULLIF($5, ''), location_floor = $6, location_accuracy_m = $7, tags = $8, sensor_type = NULLIF($9, ''), attributes = $10 WHERE id = $11")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	attributes, err := marshalAttributes(sensorMetadata.Attributes)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
 	// Execute the SQL statement
-	_, err = stmt.Exec(sensorMetadata.Name, sensorMetadata.Location.Latitude, sensorMetadata.Location.Longitude, pq.Array(sensorMetadata.Tags), sensorMetadata.ID)
+	location := sensorMetadata.Location
+	result, err := stmt.Exec(sensorMetadata.Name, location.Latitude, location.Longitude, location.Altitude, location.AltitudeDatum, location.Floor, location.AccuracyM, pq.Array(sensorMetadata.Tags), sensorMetadata.Type, attributes, sensorMetadata.ID)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrSensorExists
+		}
 		return err
 	}
 
+	// Check that a sensor was updated
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrSensorNotFound
+	}
+
 	return nil
 }
 
 // GetNearestSensorMetadata retrieves the nearest sensor metadata from the database based on location.
-func (r *PostgresRepository) GetNearestSensorMetadata(latitude, longitude string) (*SensorMetadata, error) {
+func (r *PostgresRepository) GetNearestSensorMetadata(latitude, longitude string) (*NearestSensor, error) {
+	query, err := parseNearestQuery(latitude, longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	sensors, err := r.FindNearestSensorMetadata(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(sensors) == 0 {
+		return nil, ErrSensorNotFound
+	}
+
+	return &sensors[0], nil
+}
+
+// AddSensorTags appends the given tags to a sensor, skipping tags it already has.
+// The merge happens inside a single UPDATE so concurrent tag edits don't overwrite each other.
+func (r *PostgresRepository) AddSensorTags(name string, tags []string) (*SensorMetadata, error) {
 	// Prepare the SQL statement
-	stmt, err := r.Db.Prepare("SELECT id, name, location_latitude, location_longitude, tags, earth_distance(ll_to_earth($1, $2), ll_to_earth(location_latitude, location_longitude)) AS distance FROM sensor_metadata ORDER BY distance LIMIT 1")
+	stmt, release, err := r.prepare(r.Db, `UPDATE sensor_metadata SET tags = COALESCE(tags, ARRAY[]::VARCHAR(255)[]) || ARRAY(SELECT t FROM unnest($2::VARCHAR(255)[]) WITH ORDINALITY AS n(t, i) WHERE NOT t = ANY(COALESCE(tags, ARRAY[]::VARCHAR(255)[])) ORDER BY i) WHERE name = $1 RETURNING `+sensorColumns)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	defer release()
 
 	// Execute the SQL statement
-	row := stmt.QueryRow(latitude, longitude)
+	row := stmt.QueryRow(name, pq.Array(tags))
 
 	// Initialize a SensorMetadata struct to store the result
 	var sensorMetadata SensorMetadata
 
 	// Scan the result into the SensorMetadata struct
-	err = row.Scan(&sensorMetadata.ID, &sensorMetadata.Name, &sensorMetadata.Location.Latitude, &sensorMetadata.Location.Longitude, pq.Array(&sensorMetadata.Tags), &sensorMetadata.Distance)
+	err = scanSensorMetadata(row, &sensorMetadata)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			// Return nil and a custom error if no rows are found
-			return nil, fmt.Errorf("sensor metadata not found")
+			return nil, ErrSensorNotFound
+		}
+		return nil, err
+	}
+
+	return &sensorMetadata, nil
+}
+
+// RemoveSensorTag removes a single tag from a sensor using an atomic array_remove.
+func (r *PostgresRepository) RemoveSensorTag(name, tag string) (*SensorMetadata, error) {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "UPDATE sensor_metadata SET tags = array_remove(tags, $2) WHERE name = $1 RETURNING "+sensorColumns)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	row := stmt.QueryRow(name, tag)
+
+	// Initialize a SensorMetadata struct to store the result
+	var sensorMetadata SensorMetadata
+
+	// Scan the result into the SensorMetadata struct
+	err = scanSensorMetadata(row, &sensorMetadata)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSensorNotFound
 		}
 		return nil, err
 	}
 
 	return &sensorMetadata, nil
 }
+
+// GetTagCounts lists the distinct tags in use together with the number of sensors carrying each.
+func (r *PostgresRepository) GetTagCounts() ([]TagCount, error) {
+	// Execute the SQL statement
+	rows, err := r.reader().Query("SELECT tag, COUNT(*) FROM sensor_metadata CROSS JOIN LATERAL unnest(tags) AS tag GROUP BY tag ORDER BY COUNT(*) DESC, tag")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagCounts := []TagCount{}
+	for rows.Next() {
+		var tagCount TagCount
+		if err := rows.Scan(&tagCount.Tag, &tagCount.Count); err != nil {
+			return nil, err
+		}
+		tagCounts = append(tagCounts, tagCount)
+	}
+
+	return tagCounts, rows.Err()
+}
+
+// sensorColumns lists the columns read by scanSensorMetadata, in scan order.
+const sensorColumns = "id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSensorMetadata scans sensorColumns followed by any extra destinations into a SensorMetadata.
+func scanSensorMetadata(row rowScanner, sensorMetadata *SensorMetadata, extra ...interface{}) error {
+	var altitudeDatum, sensorType sql.NullString
+	var attributes []byte
+	location := &sensorMetadata.Location
+	dest := []interface{}{&sensorMetadata.ID, &sensorMetadata.Name, &location.Latitude, &location.Longitude, &location.Altitude, &altitudeDatum, &location.Floor, &location.AccuracyM, pq.Array(&sensorMetadata.Tags), &sensorType, &attributes}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	location.AltitudeDatum = altitudeDatum.String
+	sensorMetadata.Type = sensorType.String
+	sensorMetadata.Attributes = nil
+	if len(attributes) > 0 {
+		if err := json.Unmarshal(attributes, &sensorMetadata.Attributes); err != nil {
+			return err
+		}
+		if len(sensorMetadata.Attributes) == 0 {
+			sensorMetadata.Attributes = nil
+		}
+	}
+	return nil
+}
+
+// marshalAttributes encodes sensor attributes for the JSONB column.
+// It returns a string because lib/pq would send a []byte as bytea.
+func marshalAttributes(attributes map[string]interface{}) (string, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(attributes)
+	return string(data), err
+}
+
+// ListSensorMetadata retrieves a page of sensor metadata matching the filter, ordered by ID.
+func (r *PostgresRepository) ListSensorMetadata(filter SensorFilter) ([]SensorMetadata, error) {
+	q := &sqlQuery{}
+	query := "SELECT " + sensorColumns + " FROM sensor_metadata WHERE " + filter.sql(q) + " ORDER BY id LIMIT " + q.arg(filter.Limit)
+
+	return r.querySensorMetadata(query, q.args)
+}
+
+// FindSensorMetadataWithin retrieves a page of the sensors inside a bounding box or polygons, ordered by ID.
+func (r *PostgresRepository) FindSensorMetadataWithin(query WithinQuery) ([]SensorMetadata, error) {
+	q := &sqlQuery{}
+	sqlText := "SELECT " + sensorColumns + " FROM sensor_metadata WHERE " + query.sql(q) + " AND " + query.SensorFilter.sql(q) + " ORDER BY id LIMIT " + q.arg(query.Limit)
+
+	return r.querySensorMetadata(sqlText, q.args)
+}
+
+// sql returns the predicate for the filter's cursor, names, tags and attributes.
+func (filter SensorFilter) sql(q *sqlQuery) string {
+	predicate := "id > " + q.arg(filter.AfterID)
+	if filter.Names != nil {
+		predicate += " AND name = ANY(" + q.arg(pq.Array(filter.Names)) + ")"
+	}
+	if filter.Tags != nil {
+		predicate += " AND " + filter.Tags.sql(q)
+	}
+	for _, attribute := range filter.Attributes {
+		predicate += " AND " + attribute.sql(q)
+	}
+	return predicate
+}
+
+// The nearest-sensor search starts within nearestSearchRadius metres of the query location
+// and widens by nearestRadiusGrowth until it finds enough sensors. A sparse fleet or a narrow
+// tag filter would miss radius after radius, so after nearestRadiusAttempts it falls back to
+// a full scan, and a lookup takes at most one query more than the attempts.
+const (
+	nearestSearchRadius   = 1000.0
+	nearestRadiusGrowth   = 10
+	nearestRadiusAttempts = 2
+)
+
+// FindNearestSensorMetadata retrieves the sensors nearest to a location that match the query's tag filter.
+// Candidates are prefiltered with earth_box, which the GiST index on ll_to_earth serves, before exact ordering.
+func (r *PostgresRepository) FindNearestSensorMetadata(query NearestQuery) ([]NearestSensor, error) {
+	if query.PreferSameFloor && query.Floor != nil {
+		// A sensor on the same floor outranks any nearer one, so no radius bounds the search
+		return r.findNearestSensorMetadata(query, 0)
+	}
+
+	radius := nearestSearchRadius
+	for attempt := 0; attempt < nearestRadiusAttempts; attempt++ {
+		sensors, err := r.findNearestSensorMetadata(query, radius)
+		if err != nil {
+			return nil, err
+		}
+		// Every sensor outside the radius is further away than the last one found
+		if len(sensors) > 0 && len(sensors) == query.Limit && sensors[len(sensors)-1].Distance <= radius {
+			return sensors, nil
+		}
+		radius *= nearestRadiusGrowth
+	}
+
+	return r.findNearestSensorMetadata(query, 0)
+}
+
+// findNearestSensorMetadata runs the nearest query, limited to sensors within radius metres unless radius is 0.
+func (r *PostgresRepository) findNearestSensorMetadata(query NearestQuery, radius float64) ([]NearestSensor, error) {
+	q := &sqlQuery{}
+	center := "ll_to_earth(" + q.arg(query.Latitude) + ", " + q.arg(query.Longitude) + ")"
+	distance := "earth_distance(" + center + ", ll_to_earth(location_latitude, location_longitude))"
+
+	var prefilter string
+	if radius > 0 {
+		within := q.arg(radius)
+		prefilter = "earth_box(" + center + ", " + within + ") @> ll_to_earth(location_latitude, location_longitude) AND " + distance + " <= " + within
+	}
+
+	return r.queryNearestSensors(query.sql(q, distance, "distance", prefilter), q.args)
+}
+
+// sql builds the nearest query around a surface distance expression in metres, restricted
+// to rows matching prefilter when it isn't empty. Sensors are ranked by order, unless 3D
+// distance is in play, in which case they are ranked by distance.
+func (query NearestQuery) sql(q *sqlQuery, distance, order, prefilter string) string {
+	if query.Use3D && query.Altitude != nil {
+		// Altitudes are only comparable within the same datum; other sensors keep their surface distance
+		distance = "CASE WHEN location_altitude IS NOT NULL AND location_altitude_datum = " + q.arg(query.AltitudeDatum) +
+			" THEN sqrt(power(" + distance + ", 2) + power(location_altitude - " + q.arg(*query.Altitude) + ", 2)) ELSE " + distance + " END"
+		order = "distance"
+	}
+
+	var predicates []string
+	if prefilter != "" {
+		predicates = append(predicates, prefilter)
+	}
+	if query.Tags != nil {
+		predicates = append(predicates, query.Tags.sql(q))
+	}
+
+	sqlText := "SELECT " + sensorColumns + ", " + distance + " AS distance FROM sensor_metadata"
+	if len(predicates) > 0 {
+		sqlText += " WHERE " + strings.Join(predicates, " AND ")
+	}
+	sqlText += " ORDER BY "
+	if query.PreferSameFloor && query.Floor != nil {
+		sqlText += "location_floor IS NOT DISTINCT FROM " + q.arg(*query.Floor) + " DESC, "
+	}
+	return sqlText + order + " LIMIT " + q.arg(query.Limit)
+}
+
+// querySensorMetadata runs a query returning sensorColumns per row.
+func (r *PostgresRepository) querySensorMetadata(query string, args []interface{}) ([]SensorMetadata, error) {
+	rows, err := r.reader().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sensors := []SensorMetadata{}
+	for rows.Next() {
+		var sensorMetadata SensorMetadata
+		if err := scanSensorMetadata(rows, &sensorMetadata); err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, sensorMetadata)
+	}
+
+	return sensors, rows.Err()
+}
+
+// queryNearestSensors runs a query returning sensorColumns per row, followed by the distance.
+func (r *PostgresRepository) queryNearestSensors(query string, args []interface{}) ([]NearestSensor, error) {
+	rows, err := r.reader().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sensors := []NearestSensor{}
+	for rows.Next() {
+		var sensor NearestSensor
+		if err := scanSensorMetadata(rows, &sensor.SensorMetadata, &sensor.Distance); err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, sensor)
+	}
+
+	return sensors, rows.Err()
+}
+
+// FindNeighbourSensorMetadata retrieves the sensors nearest to each of the query's sensors that
+// match its tag filter, keyed by the ID of the sensor they neighbour, with a single query.
+func (r *PostgresRepository) FindNeighbourSensorMetadata(query NeighbourQuery) (map[int][]NearestSensor, error) {
+	q := &sqlQuery{}
+	distance := "earth_distance(ll_to_earth(origin.location_latitude, origin.location_longitude), ll_to_earth(location_latitude, location_longitude))"
+	return r.queryNeighbours(query.sql(q, distance, "distance"), q.args)
+}
+
+// sql builds the neighbour query around a distance expression in metres, in which origin is the
+// sensor whose neighbours are ranked by order.
+func (query NeighbourQuery) sql(q *sqlQuery, distance, order string) string {
+	ids := make([]int64, len(query.IDs))
+	for i, id := range query.IDs {
+		ids[i] = int64(id)
+	}
+
+	sqlText := "SELECT neighbour.*, origin.id FROM sensor_metadata origin CROSS JOIN LATERAL (" +
+		"SELECT " + sensorColumns + ", " + distance + " AS distance FROM sensor_metadata WHERE id <> origin.id"
+	if query.Tags != nil {
+		sqlText += " AND " + query.Tags.sql(q)
+	}
+	sqlText += " ORDER BY " + order + " LIMIT " + q.arg(query.Limit) + ") neighbour"
+	return sqlText + " WHERE origin.id = ANY(" + q.arg(pq.Int64Array(ids)) + ") ORDER BY origin.id, neighbour.distance"
+}
+
+// queryNeighbours runs a neighbour query, returning sensorColumns per row, followed by the
+// distance and the ID of the sensor neighboured. A sensor without neighbours has no entry.
+func (r *PostgresRepository) queryNeighbours(query string, args []interface{}) (map[int][]NearestSensor, error) {
+	rows, err := r.reader().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	neighbours := map[int][]NearestSensor{}
+	for rows.Next() {
+		var sensor NearestSensor
+		var originID int
+		if err := scanSensorMetadata(rows, &sensor.SensorMetadata, &sensor.Distance, &originID); err != nil {
+			return nil, err
+		}
+		neighbours[originID] = append(neighbours[originID], sensor)
+	}
+
+	return neighbours, rows.Err()
+}
+
+// ApplySensorBatch applies the operations in order, running each run of consecutive operations
+// of the same kind as a single multi-row statement. With atomic set the statements share a
+// transaction, which is rolled back as soon as one operation fails. Otherwise the operations of
+// a statement that fails as a whole, such as one row breaking a constraint, are applied again one
+// at a time, so that only the operations at fault get an error.
+func (r *PostgresRepository) ApplySensorBatch(operations []BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	outcomes := make([]BatchOutcome, len(operations))
+
+	var db queryer = r.Db
+	var tx *sql.Tx
+	if atomic {
+		var err error
+		tx, err = r.Db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		db = tx
+	}
+
+	for _, run := range batchRuns(operations) {
+		start, end := run[0], run[1]
+		if err := applyBatchRun(db, operations[start:end], outcomes[start:end]); err != nil {
+			for i := start; i < end; i++ {
+				// The failed statement applied nothing; alone, an operation gets its own error
+				if !atomic && end-start > 1 {
+					err = applyBatchRun(db, operations[i:i+1], outcomes[i:i+1])
+				}
+				if err != nil {
+					outcomes[i] = BatchOutcome{Err: err}
+				}
+			}
+		}
+
+		if atomic {
+			for _, outcome := range outcomes[start:end] {
+				if outcome.Err != nil {
+					abortBatch(outcomes)
+					return outcomes, nil
+				}
+			}
+		}
+	}
+
+	if atomic {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	return outcomes, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// applyBatchRun applies a run of operations of the same kind, each naming a different sensor.
+// Operations that conflict with the stored sensors get an error in their outcome.
+func applyBatchRun(db queryer, operations []BatchOperation, outcomes []BatchOutcome) error {
+	q := &sqlQuery{}
+	var sqlText string
+	switch operations[0].Op {
+	case BatchCreate, BatchUpsert:
+		rows := make([]string, len(operations))
+		for i, op := range operations {
+			attributes, err := marshalAttributes(op.Sensor.Attributes)
+			if err != nil {
+				return err
+			}
+			location := op.Sensor.Location
+			rows[i] = "(" + strings.Join([]string{
+				q.arg(op.Sensor.Name), q.arg(location.Latitude), q.arg(location.Longitude), q.arg(location.Altitude),
+				"NULLIF(" + q.arg(location.AltitudeDatum) + ", '')", q.arg(location.Floor), q.arg(location.AccuracyM),
+				q.arg(pq.Array(op.Sensor.Tags)), "NULLIF(" + q.arg(op.Sensor.Type) + ", '')", q.arg(attributes),
+			}, ", ") + ")"
+		}
+
+		sqlText = "INSERT INTO sensor_metadata (name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes) VALUES " + strings.Join(rows, ", ")
+		if operations[0].Op == BatchCreate {
+			sqlText += " ON CONFLICT (name) DO NOTHING RETURNING id, name, TRUE"
+		} else {
+			// xmax is zero only for a freshly inserted row version
+			sqlText += " ON CONFLICT (name) DO UPDATE SET location_latitude = EXCLUDED.location_latitude, location_longitude = EXCLUDED.location_longitude, " +
+				"location_altitude = EXCLUDED.location_altitude, location_altitude_datum = EXCLUDED.location_altitude_datum, location_floor = EXCLUDED.location_floor, " +
+				"location_accuracy_m = EXCLUDED.location_accuracy_m, tags = EXCLUDED.tags, sensor_type = EXCLUDED.sensor_type, attributes = EXCLUDED.attributes " +
+				"RETURNING id, name, xmax = 0"
+		}
+	case BatchDelete:
+		names := make([]string, len(operations))
+		for i, op := range operations {
+			names[i] = op.Name
+		}
+		sqlText = "DELETE FROM sensor_metadata WHERE name = ANY(" + q.arg(pq.Array(names)) + ") RETURNING id, name, FALSE"
+	default:
+		return fmt.Errorf("unknown batch operation %q", operations[0].Op)
+	}
+
+	rows, err := db.Query(sqlText, q.args...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrSensorExists
+		}
+		return err
+	}
+	defer rows.Close()
+
+	applied := map[string]BatchOutcome{}
+	ids := map[string]int{}
+	for rows.Next() {
+		var id int
+		var name string
+		var created bool
+		if err := rows.Scan(&id, &name, &created); err != nil {
+			return err
+		}
+		applied[name] = BatchOutcome{Created: created}
+		ids[name] = id
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Sensors missing from the result were already taken (create) or didn't exist (delete)
+	for i, op := range operations {
+		outcome, ok := applied[op.sensorName()]
+		switch {
+		case !ok && op.Op == BatchDelete:
+			outcome.Err = ErrSensorNotFound
+		case !ok:
+			outcome.Err = ErrSensorExists
+		case op.Op != BatchDelete:
+			op.Sensor.ID = ids[op.Sensor.Name]
+		}
+		outcomes[i] = outcome
+	}
+
+	return nil
+}
+
+// CreateSensorType creates a new sensor type entry in the database.
+func (r *PostgresRepository) CreateSensorType(sensorType *SensorType) error {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "INSERT INTO sensor_types (name, description, schema) VALUES ($1, $2, $3)")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	_, err = stmt.Exec(sensorType.Name, sensorType.Description, string(sensorType.Schema))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrSensorTypeExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetSensorType retrieves a sensor type from the database by name.
+func (r *PostgresRepository) GetSensorType(name string) (*SensorType, error) {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.reader(), "SELECT name, description, schema FROM sensor_types WHERE name = $1")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	row := stmt.QueryRow(name)
+
+	// Scan the result into the SensorType struct
+	var sensorType SensorType
+	err = scanSensorType(row, &sensorType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSensorTypeNotFound
+		}
+		return nil, err
+	}
+
+	return &sensorType, nil
+}
+
+// ListSensorTypes retrieves all sensor types ordered by name.
+func (r *PostgresRepository) ListSensorTypes() ([]SensorType, error) {
+	// Execute the SQL statement
+	rows, err := r.reader().Query("SELECT name, description, schema FROM sensor_types ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sensorTypes := []SensorType{}
+	for rows.Next() {
+		var sensorType SensorType
+		if err := scanSensorType(rows, &sensorType); err != nil {
+			return nil, err
+		}
+		sensorTypes = append(sensorTypes, sensorType)
+	}
+
+	return sensorTypes, rows.Err()
+}
+
+// UpdateSensorType replaces the description and schema of an existing sensor type.
+// Sensors already using the type are not re-validated against the new schema.
+func (r *PostgresRepository) UpdateSensorType(sensorType *SensorType) error {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "UPDATE sensor_types SET description = $2, schema = $3 WHERE name = $1")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	result, err := stmt.Exec(sensorType.Name, sensorType.Description, string(sensorType.Schema))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrSensorTypeNotFound
+	}
+
+	return nil
+}
+
+// scanSensorType scans the name, description and schema columns into a SensorType.
+func scanSensorType(row rowScanner, sensorType *SensorType) error {
+	// Scan into a []byte so database/sql copies the driver's buffer
+	var schema []byte
+	if err := row.Scan(&sensorType.Name, &sensorType.Description, &schema); err != nil {
+		return err
+	}
+	sensorType.Schema = schema
+	return nil
+}
+
+// ListSensorEvents retrieves up to limit events from the change log after the given event ID, in order.
+// The events are recorded by the triggers of migrations 11 and 14. It returns ErrSensorEventsPruned
+// when events after the given ID have been pruned.
+func (r *PostgresRepository) ListSensorEvents(afterID int64, limit int) ([]SensorEvent, error) {
+	return querySensorEvents(r.Db, afterID, limit)
+}
+
+// querySensorEvents reads up to limit events from the change log after the given event ID, in order.
+// Events at or above the watermark of migration 14 are left for later, since transactions still in
+// flight may commit events below them. The events are read in a statement of their own, after the
+// watermark, so that they are read with a snapshot taken after it.
+func querySensorEvents(db queryer, afterID int64, limit int) ([]SensorEvent, error) {
+	var watermark, prunedThrough int64
+	if err := db.QueryRow("SELECT sensor_events_watermark(), pruned_through FROM sensor_events_retention").Scan(&watermark, &prunedThrough); err != nil {
+		return nil, err
+	}
+	if afterID < prunedThrough {
+		return nil, ErrSensorEventsPruned
+	}
+
+	// Execute the SQL statement
+	rows, err := db.Query("SELECT id, type, sensor, previous FROM sensor_events WHERE id > $1 AND id < $2 ORDER BY id LIMIT $3", afterID, watermark, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []SensorEvent{}
+	for rows.Next() {
+		var event SensorEvent
+		var sensor, previous []byte
+		if err := rows.Scan(&event.ID, &event.Type, &sensor, &previous); err != nil {
+			return nil, err
+		}
+		if event.Sensor, err = unmarshalSensorRow(sensor); err != nil {
+			return nil, err
+		}
+		if previous != nil {
+			previousSensor, err := unmarshalSensorRow(previous)
+			if err != nil {
+				return nil, err
+			}
+			event.Previous = &previousSensor
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// LastSensorEventID returns the ID of the latest event in the change log that readers are handed,
+// the one below the watermark of migration 14, or 0 when there is none.
+func (r *PostgresRepository) LastSensorEventID() (int64, error) {
+	var id int64
+	err := r.Db.QueryRow("SELECT sensor_events_watermark() - 1").Scan(&id)
+	return id, err
+}
+
+// PruneSensorEvents deletes the events recorded before the given time from the change log, except
+// those the webhook outbox hasn't read yet, and returns the number of events deleted.
+func (r *PostgresRepository) PruneSensorEvents(before time.Time) (int64, error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the retention row, so that concurrent prunes record the highest ID pruned
+	var prunedThrough int64
+	if err := tx.QueryRow("SELECT pruned_through FROM sensor_events_retention FOR UPDATE").Scan(&prunedThrough); err != nil {
+		return 0, err
+	}
+
+	var deleted, lastID int64
+	err = tx.QueryRow("WITH pruned AS (DELETE FROM sensor_events WHERE created_at < $1 AND id <= (SELECT last_event_id FROM webhook_outbox) RETURNING id) "+
+		"SELECT COUNT(*), COALESCE(MAX(id), 0) FROM pruned", before).Scan(&deleted, &lastID)
+	if err != nil {
+		return 0, err
+	}
+	if lastID > prunedThrough {
+		if _, err := tx.Exec("UPDATE sensor_events_retention SET pruned_through = $1", lastID); err != nil {
+			return 0, err
+		}
+	}
+
+	return deleted, tx.Commit()
+}
+
+// sensorRow is a sensor_metadata row encoded by to_jsonb, as the change log stores it.
+type sensorRow struct {
+	ID            int                    `json:"id"`
+	Name          string                 `json:"name"`
+	Latitude      float64                `json:"location_latitude"`
+	Longitude     float64                `json:"location_longitude"`
+	Altitude      *float64               `json:"location_altitude"`
+	AltitudeDatum string                 `json:"location_altitude_datum"`
+	Floor         *int                   `json:"location_floor"`
+	AccuracyM     *float64               `json:"location_accuracy_m"`
+	Tags          []string               `json:"tags"`
+	SensorType    string                 `json:"sensor_type"`
+	Attributes    map[string]interface{} `json:"attributes"`
+}
+
+// unmarshalSensorRow decodes a sensor_metadata row encoded by to_jsonb.
+func unmarshalSensorRow(data []byte) (SensorMetadata, error) {
+	var row sensorRow
+	if err := json.Unmarshal(data, &row); err != nil {
+		return SensorMetadata{}, err
+	}
+
+	sensorMetadata := SensorMetadata{
+		ID:   row.ID,
+		Name: row.Name,
+		Type: row.SensorType,
+		Location: Location{
+			Latitude:      row.Latitude,
+			Longitude:     row.Longitude,
+			Altitude:      row.Altitude,
+			AltitudeDatum: row.AltitudeDatum,
+			Floor:         row.Floor,
+			AccuracyM:     row.AccuracyM,
+		},
+		Tags: row.Tags,
+	}
+	if len(row.Attributes) > 0 {
+		sensorMetadata.Attributes = row.Attributes
+	}
+	return sensorMetadata, nil
+}
+
+// CreateWebhook creates a new webhook entry in the database and assigns its ID.
+// It receives the events recorded from now on.
+func (r *PostgresRepository) CreateWebhook(webhook *Webhook) error {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "INSERT INTO webhooks (url, secret, events, tags, after_event_id) VALUES ($1, $2, $3, $4, sensor_events_watermark() - 1) RETURNING id, after_event_id")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	return stmt.QueryRow(webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Tags).Scan(&webhook.ID, &webhook.AfterEventID)
+}
+
+// GetWebhook retrieves a webhook from the database by ID.
+func (r *PostgresRepository) GetWebhook(id int) (*Webhook, error) {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	row := stmt.QueryRow(id)
+
+	// Scan the result into the Webhook struct
+	var webhook Webhook
+	err = scanWebhook(row, &webhook)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooks retrieves all webhooks ordered by ID.
+func (r *PostgresRepository) ListWebhooks() ([]Webhook, error) {
+	return queryWebhooks(r.Db)
+}
+
+// queryWebhooks reads all webhooks ordered by ID.
+func queryWebhooks(db queryer) ([]Webhook, error) {
+	// Execute the SQL statement
+	rows, err := db.Query("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// UpdateWebhook replaces the URL, event types and tag filter of an existing webhook, and its
+// secret when a new one is given.
+func (r *PostgresRepository) UpdateWebhook(webhook *Webhook) error {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "UPDATE webhooks SET url = $2, secret = COALESCE(NULLIF($3, ''), secret), events = $4, tags = $5 WHERE id = $1")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	result, err := stmt.Exec(webhook.ID, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Tags)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// DeleteWebhook deletes a webhook, and through the foreign key, its deliveries.
+func (r *PostgresRepository) DeleteWebhook(id int) error {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "DELETE FROM webhooks WHERE id = $1")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// webhookDeliveryInsertSize bounds the rows of a delivery INSERT, keeping it well below
+// PostgreSQL's parameter limit.
+const webhookDeliveryInsertSize = 1000
+
+// EnqueueWebhookDeliveries adds the deliveries of up to limit new events to the outbox, and
+// returns the number of events it read. The change log is filled by triggers in the transactions
+// that change sensors, and the deliveries and the outbox's position in the log are saved in one
+// transaction, so every committed change is delivered even if the process crashes.
+func (r *PostgresRepository) EnqueueWebhookDeliveries(limit int) (int, error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the position so that concurrent dispatchers enqueue each event once
+	var afterID int64
+	if err := tx.QueryRow("SELECT last_event_id FROM webhook_outbox FOR UPDATE").Scan(&afterID); err != nil {
+		return 0, err
+	}
+
+	events, err := querySensorEvents(tx, afterID, limit)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	webhooks, err := queryWebhooks(tx)
+	if err != nil {
+		return 0, err
+	}
+	deliveries, err := webhookDeliveries(webhooks, events)
+	if err != nil {
+		return 0, err
+	}
+
+	for start := 0; start < len(deliveries); start += webhookDeliveryInsertSize {
+		end := start + webhookDeliveryInsertSize
+		if end > len(deliveries) {
+			end = len(deliveries)
+		}
+
+		q := &sqlQuery{}
+		rows := make([]string, 0, end-start)
+		for _, delivery := range deliveries[start:end] {
+			rows = append(rows, "("+q.arg(delivery.WebhookID)+", "+q.arg(delivery.EventID)+", "+q.arg(delivery.Event)+", "+q.arg(string(delivery.Payload))+")")
+		}
+		if _, err := tx.Exec("INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload) VALUES "+strings.Join(rows, ", "), q.args...); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE webhook_outbox SET last_event_id = $1", events[len(events)-1].ID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+// ClaimWebhookDeliveries reserves up to limit pending deliveries that are due for the lease,
+// by moving their next attempt past it. Rows claimed by another dispatcher are skipped.
+func (r *PostgresRepository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	return r.queryWebhookDeliveries("UPDATE webhook_deliveries SET next_attempt_at = now() + make_interval(secs => $2) "+
+		"WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= now() ORDER BY next_attempt_at, id LIMIT $1 FOR UPDATE SKIP LOCKED) "+
+		"RETURNING "+webhookDeliveryColumns, limit, lease.Seconds())
+}
+
+// UpdateWebhookDelivery records the status, attempts, next attempt and last error of a delivery.
+func (r *PostgresRepository) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	// Prepare the SQL statement
+	stmt, release, err := r.prepare(r.Db, "UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5 WHERE id = $1")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Execute the SQL statement
+	_, err = stmt.Exec(delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError)
+	return err
+}
+
+// ListWebhookDeliveries retrieves a page of the deliveries of a webhook matching the filter, ordered by ID.
+func (r *PostgresRepository) ListWebhookDeliveries(webhookID int, filter WebhookDeliveryFilter) ([]WebhookDelivery, error) {
+	q := &sqlQuery{}
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE webhook_id = " + q.arg(webhookID) + " AND id > " + q.arg(filter.AfterID)
+	if filter.Status != "" {
+		query += " AND status = " + q.arg(filter.Status)
+	}
+	query += " ORDER BY id LIMIT " + q.arg(filter.Limit)
+
+	return r.queryWebhookDeliveries(query, q.args...)
+}
+
+// RetryWebhookDelivery makes a delivery of a webhook pending again, due now with no attempts made.
+func (r *PostgresRepository) RetryWebhookDelivery(webhookID int, id int64) (*WebhookDelivery, error) {
+	deliveries, err := r.queryWebhookDeliveries("UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = now(), last_error = '' "+
+		"WHERE id = $1 AND webhook_id = $2 RETURNING "+webhookDeliveryColumns, id, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	return &deliveries[0], nil
+}
+
+// webhookColumns lists the columns read by scanWebhook, in scan order.
+const webhookColumns = "id, url, secret, events, tags, after_event_id"
+
+// scanWebhook scans webhookColumns into a Webhook.
+func scanWebhook(row rowScanner, webhook *Webhook) error {
+	if err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.Events), &webhook.Tags, &webhook.AfterEventID); err != nil {
+		return err
+	}
+	if len(webhook.Events) == 0 {
+		webhook.Events = nil
+	}
+	return nil
+}
+
+// webhookDeliveryColumns lists the columns read by queryWebhookDeliveries, in scan order.
+const webhookDeliveryColumns = "id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at, last_error"
+
+// queryWebhookDeliveries runs a query returning webhookDeliveryColumns per row.
+func (r *PostgresRepository) queryWebhookDeliveries(query string, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var payload []byte
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Event, &payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError); err != nil {
+			return nil, err
+		}
+		delivery.Payload = payload
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,361 @@
+package app
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RetryPolicy bounds the attempts at a database operation failing with a transient error.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, the first included; 1 or less disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubled before every later one up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Default policies of a RetryingRepository. Writes are retried less, since they hold locks.
+var (
+	DefaultReadRetries  = RetryPolicy{MaxAttempts: 4, InitialBackoff: 50 * time.Millisecond, MaxBackoff: time.Second}
+	DefaultWriteRetries = RetryPolicy{MaxAttempts: 3, InitialBackoff: 50 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}
+)
+
+// backoff returns the delay before a retry, after the attempts made so far. The delay is spread
+// by up to a fifth either way so that clients retrying together don't arrive all at once.
+func (p RetryPolicy) backoff(attempts int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay + time.Duration((rand.Float64()*0.4-0.2)*float64(delay))
+}
+
+// retry runs op until it succeeds, fails with an error that isn't retryable, or runs out of attempts.
+func retry(policy RetryPolicy, retryable func(error) bool, op func() error) error {
+	for attempts := 1; ; attempts++ {
+		err := op()
+		if err == nil || attempts >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+		time.Sleep(policy.backoff(attempts))
+	}
+}
+
+// isTransientReadError reports whether a read failed for reasons that may not last: the
+// serialization failures of concurrent transactions, and the recovery conflicts that standbys
+// report as such, deadlocks, and lost or refused connections. Reads are safe to run again.
+func isTransientReadError(err error) bool {
+	return isSerializationFailure(err) || isConnectionError(err) || isNotExecuted(err)
+}
+
+// isTransientWriteError reports whether a write failed for reasons that may not last, without
+// having taken effect. A connection lost while a write is in flight isn't retried, since the
+// write may have been committed.
+func isTransientWriteError(err error) bool {
+	return isSerializationFailure(err) || isNotExecuted(err)
+}
+
+// isSerializationFailure reports whether err is a serialization failure or a deadlock, after which
+// PostgreSQL has rolled the transaction back.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+// isNotExecuted reports whether err means the statement never reached the database: the
+// connection was refused, is known to be unusable, or couldn't be established by the server.
+func isNotExecuted(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P03", "53300": // cannot_connect_now, too_many_connections
+			return true
+		}
+	}
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isConnectionError reports whether err means the connection was lost, or the server shut down.
+func isConnectionError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection_exception; 57P01 and 57P02 are admin_shutdown and crash_shutdown
+		return pqErr.Code.Class() == "08" || pqErr.Code == "57P01" || pqErr.Code == "57P02"
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryingRepository decorates a Postgres repository with retries of the operations failing with
+// transient errors, classified from their pq.Error codes. Reads are retried under Reads. Writes are
+// retried under Writes, and only when they can't have taken effect; the operations of a batch
+// that isn't atomic fail or succeed on their own, and aren't retried.
+type RetryingRepository struct {
+	Repository
+
+	Reads  RetryPolicy
+	Writes RetryPolicy
+}
+
+// NewRetryingRepository creates the decorator with the default policies.
+func NewRetryingRepository(repo Repository) *RetryingRepository {
+	return &RetryingRepository{Repository: repo, Reads: DefaultReadRetries, Writes: DefaultWriteRetries}
+}
+
+// Primary returns a view of the repository that reads from the primary, with the same retries.
+func (r *RetryingRepository) Primary() Repository {
+	return &RetryingRepository{Repository: primaryOf(r.Repository), Reads: r.Reads, Writes: r.Writes}
+}
+
+// ReplicaStaleness bounds how long a write may take to be seen by the reads sent to replicas.
+func (r *RetryingRepository) ReplicaStaleness() time.Duration {
+	return replicaStaleness(r.Repository)
+}
+
+// read retries a read.
+func (r *RetryingRepository) read(op func() error) error {
+	return retry(r.Reads, isTransientReadError, op)
+}
+
+// write retries a write.
+func (r *RetryingRepository) write(op func() error) error {
+	return retry(r.Writes, isTransientWriteError, op)
+}
+
+// CreateSensorMetadata creates a new sensor metadata entry.
+func (r *RetryingRepository) CreateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	return r.write(func() error { return r.Repository.CreateSensorMetadata(sensorMetadata) })
+}
+
+// GetSensorMetadataByName retrieves sensor metadata by name.
+func (r *RetryingRepository) GetSensorMetadataByName(name string) (sensorMetadata *SensorMetadata, err error) {
+	err = r.read(func() error {
+		sensorMetadata, err = r.Repository.GetSensorMetadataByName(name)
+		return err
+	})
+	return sensorMetadata, err
+}
+
+// UpdateSensorMetadata updates an existing sensor metadata entry.
+func (r *RetryingRepository) UpdateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	return r.write(func() error { return r.Repository.UpdateSensorMetadata(sensorMetadata) })
+}
+
+// GetNearestSensorMetadata retrieves the sensor metadata nearest to a location.
+func (r *RetryingRepository) GetNearestSensorMetadata(latitude, longitude string) (sensor *NearestSensor, err error) {
+	err = r.read(func() error {
+		sensor, err = r.Repository.GetNearestSensorMetadata(latitude, longitude)
+		return err
+	})
+	return sensor, err
+}
+
+// AddSensorTags adds tags to a sensor.
+func (r *RetryingRepository) AddSensorTags(name string, tags []string) (sensorMetadata *SensorMetadata, err error) {
+	err = r.write(func() error {
+		sensorMetadata, err = r.Repository.AddSensorTags(name, tags)
+		return err
+	})
+	return sensorMetadata, err
+}
+
+// RemoveSensorTag removes a tag from a sensor.
+func (r *RetryingRepository) RemoveSensorTag(name, tag string) (sensorMetadata *SensorMetadata, err error) {
+	err = r.write(func() error {
+		sensorMetadata, err = r.Repository.RemoveSensorTag(name, tag)
+		return err
+	})
+	return sensorMetadata, err
+}
+
+// GetTagCounts lists the distinct tags in use together with the number of sensors carrying each.
+func (r *RetryingRepository) GetTagCounts() (tagCounts []TagCount, err error) {
+	err = r.read(func() error {
+		tagCounts, err = r.Repository.GetTagCounts()
+		return err
+	})
+	return tagCounts, err
+}
+
+// ListSensorMetadata retrieves a page of sensor metadata matching the filter.
+func (r *RetryingRepository) ListSensorMetadata(filter SensorFilter) (sensors []SensorMetadata, err error) {
+	err = r.read(func() error {
+		sensors, err = r.Repository.ListSensorMetadata(filter)
+		return err
+	})
+	return sensors, err
+}
+
+// FindNearestSensorMetadata retrieves the sensors nearest to a location.
+func (r *RetryingRepository) FindNearestSensorMetadata(query NearestQuery) (sensors []NearestSensor, err error) {
+	err = r.read(func() error {
+		sensors, err = r.Repository.FindNearestSensorMetadata(query)
+		return err
+	})
+	return sensors, err
+}
+
+// FindNeighbourSensorMetadata retrieves the sensors nearest to each of several sensors.
+func (r *RetryingRepository) FindNeighbourSensorMetadata(query NeighbourQuery) (neighbours map[int][]NearestSensor, err error) {
+	err = r.read(func() error {
+		neighbours, err = r.Repository.FindNeighbourSensorMetadata(query)
+		return err
+	})
+	return neighbours, err
+}
+
+// FindSensorMetadataWithin retrieves a page of the sensors inside a bounding box or polygons.
+func (r *RetryingRepository) FindSensorMetadataWithin(query WithinQuery) (sensors []SensorMetadata, err error) {
+	err = r.read(func() error {
+		sensors, err = r.Repository.FindSensorMetadataWithin(query)
+		return err
+	})
+	return sensors, err
+}
+
+// ApplySensorBatch applies a batch of operations. An atomic batch is retried as a whole.
+func (r *RetryingRepository) ApplySensorBatch(operations []BatchOperation, atomic bool) (outcomes []BatchOutcome, err error) {
+	err = r.write(func() error {
+		outcomes, err = r.Repository.ApplySensorBatch(operations, atomic)
+		return err
+	})
+	return outcomes, err
+}
+
+// CreateSensorType creates a new sensor type.
+func (r *RetryingRepository) CreateSensorType(sensorType *SensorType) error {
+	return r.write(func() error { return r.Repository.CreateSensorType(sensorType) })
+}
+
+// GetSensorType retrieves a sensor type by name.
+func (r *RetryingRepository) GetSensorType(name string) (sensorType *SensorType, err error) {
+	err = r.read(func() error {
+		sensorType, err = r.Repository.GetSensorType(name)
+		return err
+	})
+	return sensorType, err
+}
+
+// ListSensorTypes lists every sensor type.
+func (r *RetryingRepository) ListSensorTypes() (sensorTypes []SensorType, err error) {
+	err = r.read(func() error {
+		sensorTypes, err = r.Repository.ListSensorTypes()
+		return err
+	})
+	return sensorTypes, err
+}
+
+// UpdateSensorType updates an existing sensor type.
+func (r *RetryingRepository) UpdateSensorType(sensorType *SensorType) error {
+	return r.write(func() error { return r.Repository.UpdateSensorType(sensorType) })
+}
+
+// ListSensorEvents lists the events of the change log after an event.
+func (r *RetryingRepository) ListSensorEvents(afterID int64, limit int) (events []SensorEvent, err error) {
+	err = r.read(func() error {
+		events, err = r.Repository.ListSensorEvents(afterID, limit)
+		return err
+	})
+	return events, err
+}
+
+// LastSensorEventID returns the ID of the last event of the change log.
+func (r *RetryingRepository) LastSensorEventID() (id int64, err error) {
+	err = r.read(func() error {
+		id, err = r.Repository.LastSensorEventID()
+		return err
+	})
+	return id, err
+}
+
+// PruneSensorEvents deletes the old events of the change log.
+func (r *RetryingRepository) PruneSensorEvents(before time.Time) (deleted int64, err error) {
+	err = r.write(func() error {
+		deleted, err = r.Repository.PruneSensorEvents(before)
+		return err
+	})
+	return deleted, err
+}
+
+// CreateWebhook creates a webhook subscription.
+func (r *RetryingRepository) CreateWebhook(webhook *Webhook) error {
+	return r.write(func() error { return r.Repository.CreateWebhook(webhook) })
+}
+
+// GetWebhook retrieves a webhook subscription by ID.
+func (r *RetryingRepository) GetWebhook(id int) (webhook *Webhook, err error) {
+	err = r.read(func() error {
+		webhook, err = r.Repository.GetWebhook(id)
+		return err
+	})
+	return webhook, err
+}
+
+// ListWebhooks lists every webhook subscription.
+func (r *RetryingRepository) ListWebhooks() (webhooks []Webhook, err error) {
+	err = r.read(func() error {
+		webhooks, err = r.Repository.ListWebhooks()
+		return err
+	})
+	return webhooks, err
+}
+
+// UpdateWebhook updates a webhook subscription.
+func (r *RetryingRepository) UpdateWebhook(webhook *Webhook) error {
+	return r.write(func() error { return r.Repository.UpdateWebhook(webhook) })
+}
+
+// DeleteWebhook deletes a webhook subscription.
+func (r *RetryingRepository) DeleteWebhook(id int) error {
+	return r.write(func() error { return r.Repository.DeleteWebhook(id) })
+}
+
+// EnqueueWebhookDeliveries records the deliveries of the events recorded since the last call.
+func (r *RetryingRepository) EnqueueWebhookDeliveries(limit int) (count int, err error) {
+	err = r.write(func() error {
+		count, err = r.Repository.EnqueueWebhookDeliveries(limit)
+		return err
+	})
+	return count, err
+}
+
+// ClaimWebhookDeliveries leases the deliveries that are due.
+func (r *RetryingRepository) ClaimWebhookDeliveries(limit int, lease time.Duration) (deliveries []WebhookDelivery, err error) {
+	err = r.write(func() error {
+		deliveries, err = r.Repository.ClaimWebhookDeliveries(limit, lease)
+		return err
+	})
+	return deliveries, err
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt.
+func (r *RetryingRepository) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	return r.write(func() error { return r.Repository.UpdateWebhookDelivery(delivery) })
+}
+
+// ListWebhookDeliveries lists a page of the deliveries of a webhook.
+func (r *RetryingRepository) ListWebhookDeliveries(webhookID int, filter WebhookDeliveryFilter) (deliveries []WebhookDelivery, err error) {
+	err = r.read(func() error {
+		deliveries, err = r.Repository.ListWebhookDeliveries(webhookID, filter)
+		return err
+	})
+	return deliveries, err
+}
+
+// RetryWebhookDelivery schedules a delivery to be sent again at once.
+func (r *RetryingRepository) RetryWebhookDelivery(webhookID int, id int64) (delivery *WebhookDelivery, err error) {
+	err = r.write(func() error {
+		delivery, err = r.Repository.RetryWebhookDelivery(webhookID, id)
+		return err
+	})
+	return delivery, err
+}
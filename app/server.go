@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -27,15 +28,84 @@ func NewServer(db *sql.DB) *Server {
 	}
 }
 
-// Start starts the HTTP server and listens for incoming requests.
-func (s *Server) Start(port string) error {
+// APIVersionPrefix is the path prefix of the current API version.
+const APIVersionPrefix = "/v1"
+
+// Deprecation and sunset dates of the unprefixed routes, which predate APIVersionPrefix.
+var (
+	LegacyRoutesDeprecated = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	LegacyRoutesSunset     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// NewRouter creates a router with all API endpoints registered on the given handler under
+// APIVersionPrefix, and as deprecated aliases at their original unprefixed paths.
+//
+// Each API version registers its own routes, whose handlers exchange that version's request and
+// response types with clients and map them to and from the repository types, like
+// SensorMetadataV1 for version 1. A later version can share the Repository, and the helpers of
+// Handler by embedding it, while changing the shapes it exchanges with clients.
+func NewRouter(handler *Handler) *mux.Router {
 	router := mux.NewRouter()
+	router.Use(handler.sessionToken)
+
+	registerV1Routes(router.PathPrefix(APIVersionPrefix).Subrouter(), handler)
+
+	legacy := router.NewRoute().Subrouter()
+	legacy.Use(deprecatedAlias(APIVersionPrefix))
+	registerV1Routes(legacy, handler)
+	// Before version 1 named the sensor in the path, updates identified it by the ID in the body
+	legacy.HandleFunc("/sensors", handler.UpdateSensorMetadataByID).Methods(http.MethodPut)
+
+	return router
+}
+
+// registerV1Routes registers the endpoints of version 1 of the API.
+func registerV1Routes(router *mux.Router, handler *Handler) {
+	router.HandleFunc("/sensors", handler.CreateSensorMetadata).Methods(http.MethodPost)
+	router.HandleFunc("/sensors", handler.GetSensorMetadata).Methods(http.MethodGet)
+	router.HandleFunc("/sensors/nearest", handler.GetNearestSensorMetadata).Methods(http.MethodGet)
+	router.HandleFunc("/sensors/within", handler.GetSensorMetadataWithin).Methods(http.MethodGet)
+	router.HandleFunc("/sensors/within", handler.PostSensorMetadataWithin).Methods(http.MethodPost)
+	router.HandleFunc("/sensors:batch", handler.BatchSensorMetadata).Methods(http.MethodPost)
+	router.HandleFunc("/sensors/import", handler.ImportSensorMetadata).Methods(http.MethodPost)
+	router.HandleFunc("/sensors/export", handler.ExportSensorMetadata).Methods(http.MethodGet)
+	router.HandleFunc("/sensors/events", handler.StreamSensorEvents).Methods(http.MethodGet)
+	router.HandleFunc("/sensors/{name}", handler.UpdateSensorMetadata).Methods(http.MethodPut)
+	router.HandleFunc("/sensors/{name}", handler.DeleteSensorMetadata).Methods(http.MethodDelete)
+	router.HandleFunc("/sensors/{name}/tags", handler.AddSensorTags).Methods(http.MethodPost)
+	router.HandleFunc("/sensors/{name}/tags/{tag}", handler.RemoveSensorTag).Methods(http.MethodDelete)
+	router.HandleFunc("/tags", handler.GetTags).Methods(http.MethodGet)
+	router.HandleFunc("/sensor-types", handler.CreateSensorType).Methods(http.MethodPost)
+	router.HandleFunc("/sensor-types", handler.GetSensorTypes).Methods(http.MethodGet)
+	router.HandleFunc("/sensor-types/{name}", handler.GetSensorType).Methods(http.MethodGet)
+	router.HandleFunc("/sensor-types/{name}", handler.UpdateSensorType).Methods(http.MethodPut)
+	router.HandleFunc("/webhooks", handler.CreateWebhook).Methods(http.MethodPost)
+	router.HandleFunc("/webhooks", handler.GetWebhooks).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{id}", handler.GetWebhook).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{id}", handler.UpdateWebhook).Methods(http.MethodPut)
+	router.HandleFunc("/webhooks/{id}", handler.DeleteWebhook).Methods(http.MethodDelete)
+	router.HandleFunc("/webhooks/{id}/deliveries", handler.GetWebhookDeliveries).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{id}/deliveries/{delivery}:retry", handler.RetryWebhookDelivery).Methods(http.MethodPost)
+	router.HandleFunc("/graphql", handler.GraphQL).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/openapi.json", handler.GetOpenAPISpec).Methods(http.MethodGet)
+}
+
+// deprecatedAlias marks responses as deprecated (RFC 9745) with a sunset date (RFC 8594),
+// and links to the same resource under the prefix of the version that replaces them.
+func deprecatedAlias(prefix string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", LegacyRoutesDeprecated.Unix()))
+			w.Header().Set("Sunset", LegacyRoutesSunset.Format(http.TimeFormat))
+			w.Header().Add("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", prefix, r.URL.RequestURI()))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-	// Define API endpoints
-	router.HandleFunc("/sensors", s.handler.CreateSensorMetadata).Methods(http.MethodPost)
-	router.HandleFunc("/sensors", s.handler.GetSensorMetadata).Methods(http.MethodGet)
-	router.HandleFunc("/sensors", s.handler.UpdateSensorMetadata).Methods(http.MethodPut)
-	router.HandleFunc("/sensors/nearest", s.handler.GetNearestSensorMetadata).Methods(http.MethodGet)
+// Start starts the HTTP server and listens for incoming requests.
+func (s *Server) Start(port string) error {
+	router := NewRouter(s.handler)
 
 	addr := fmt.Sprintf(":%s", port)
 	log.Printf("Server started. Listening on %s", addr)
@@ -0,0 +1,326 @@
+package app
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// SpatialIndex is an in-memory index of sensor locations answering nearest, radius and bounding
+// box queries. Locations are indexed as unit vectors in 3D, whose straight-line (chord) distance
+// grows with their great-circle distance, so a k-d tree over them answers geodesic queries without
+// the distortions of latitude and longitude near the poles and the antimeridian.
+//
+// Updates are incremental: insertions descend the tree, and deletions leave tombstones. The tree is
+// rebuilt balanced once it has doubled in size or is half tombstones, which keeps updates cheap
+// when amortized. A SpatialIndex isn't safe for concurrent use.
+type SpatialIndex struct {
+	root  *kdNode
+	nodes map[int]*kdNode
+	// size counts the nodes of the tree, tombstones included, and built its size after the last rebuild
+	size  int
+	built int
+}
+
+// kdNode is a node of the k-d tree, splitting space along the axis at its depth.
+type kdNode struct {
+	point       [3]float64
+	sensor      SensorMetadata
+	deleted     bool
+	axis        int
+	left, right *kdNode
+}
+
+// NewSpatialIndex creates an index of the sensors.
+func NewSpatialIndex(sensors []SensorMetadata) *SpatialIndex {
+	index := &SpatialIndex{nodes: make(map[int]*kdNode, len(sensors))}
+	for _, sensorMetadata := range sensors {
+		index.nodes[sensorMetadata.ID] = &kdNode{point: unitVector(sensorMetadata.Location), sensor: sensorMetadata}
+	}
+	index.rebuild()
+	return index
+}
+
+// Len returns the number of sensors in the index.
+func (index *SpatialIndex) Len() int {
+	return len(index.nodes)
+}
+
+// Get returns the sensor with the ID, and whether the index has it.
+func (index *SpatialIndex) Get(id int) (SensorMetadata, bool) {
+	node, ok := index.nodes[id]
+	if !ok {
+		return SensorMetadata{}, false
+	}
+	return *cloneSensorMetadata(node.sensor), true
+}
+
+// Insert adds a sensor to the index, replacing the sensor with the same ID.
+func (index *SpatialIndex) Insert(sensorMetadata SensorMetadata) {
+	index.Remove(sensorMetadata.ID)
+
+	node := &kdNode{point: unitVector(sensorMetadata.Location), sensor: sensorMetadata}
+	index.nodes[sensorMetadata.ID] = node
+	index.size++
+	if index.size > 2*index.built {
+		index.rebuild()
+		return
+	}
+
+	link := &index.root
+	for depth := 0; *link != nil; depth++ {
+		parent := *link
+		if node.point[parent.axis] < parent.point[parent.axis] {
+			link = &parent.left
+		} else {
+			link = &parent.right
+		}
+		node.axis = (depth + 1) % 3
+	}
+	*link = node
+}
+
+// Remove deletes the sensor with the ID from the index, and reports whether it was there.
+func (index *SpatialIndex) Remove(id int) bool {
+	node, ok := index.nodes[id]
+	if !ok {
+		return false
+	}
+	node.deleted = true
+	delete(index.nodes, id)
+	if index.size > 2*len(index.nodes) {
+		index.rebuild()
+	}
+	return true
+}
+
+// rebuild builds a balanced tree of the live nodes, dropping the tombstones.
+func (index *SpatialIndex) rebuild() {
+	nodes := make([]*kdNode, 0, len(index.nodes))
+	for _, node := range index.nodes {
+		nodes = append(nodes, node)
+	}
+	// Build the same tree whatever the order of the map
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].sensor.ID < nodes[j].sensor.ID
+	})
+	index.root = buildKDTree(nodes, 0)
+	index.size = len(nodes)
+	index.built = len(nodes)
+}
+
+// buildKDTree builds a balanced tree, splitting the nodes at their median along the axis of each depth.
+func buildKDTree(nodes []*kdNode, depth int) *kdNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	axis := depth % 3
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].point[axis] < nodes[j].point[axis]
+	})
+	median := len(nodes) / 2
+	// Nodes equal to the median along the axis must go right, where insertions look for them
+	for median > 0 && nodes[median-1].point[axis] == nodes[median].point[axis] {
+		median--
+	}
+
+	node := nodes[median]
+	node.axis = axis
+	node.left = buildKDTree(nodes[:median], depth+1)
+	node.right = buildKDTree(nodes[median+1:], depth+1)
+	return node
+}
+
+// Nearest returns up to k sensors matching the predicate, nearest first, with their great-circle
+// distance in meters from the point. A nil predicate matches every sensor.
+func (index *SpatialIndex) Nearest(latitude, longitude float64, k int, match func(SensorMetadata) bool) []NearestSensor {
+	if k <= 0 {
+		return []NearestSensor{}
+	}
+	target := unitVector(Location{Latitude: latitude, Longitude: longitude})
+
+	// Keep the k nearest nodes found so far in a max-heap, to drop the farthest as nearer ones are found
+	best := &kdCandidates{}
+	var search func(node *kdNode)
+	search = func(node *kdNode) {
+		if node == nil {
+			return
+		}
+		if !node.deleted && (match == nil || match(node.sensor)) {
+			distance := chordDistance2(target, node.point)
+			if best.Len() < k {
+				heap.Push(best, kdCandidate{node: node, distance: distance})
+			} else if distance < (*best)[0].distance {
+				(*best)[0] = kdCandidate{node: node, distance: distance}
+				heap.Fix(best, 0)
+			}
+		}
+
+		offset := target[node.axis] - node.point[node.axis]
+		near, far := node.left, node.right
+		if offset >= 0 {
+			near, far = far, near
+		}
+		search(near)
+		// The far side can only hold nearer nodes if the splitting plane is nearer than the farthest kept
+		if best.Len() < k || offset*offset < (*best)[0].distance {
+			search(far)
+		}
+	}
+	search(index.root)
+
+	sensors := make([]NearestSensor, best.Len())
+	for i := len(sensors) - 1; i >= 0; i-- {
+		candidate := heap.Pop(best).(kdCandidate)
+		sensors[i].SensorMetadata = *cloneSensorMetadata(candidate.node.sensor)
+		sensors[i].Distance = greatCircleDistance(latitude, longitude, sensors[i].Location.Latitude, sensors[i].Location.Longitude)
+	}
+	return sensors
+}
+
+// WithinRadius returns the sensors matching the predicate within a great-circle distance in meters
+// of the point, nearest first, with their distance. A nil predicate matches every sensor.
+func (index *SpatialIndex) WithinRadius(latitude, longitude, radius float64, match func(SensorMetadata) bool) []NearestSensor {
+	target := unitVector(Location{Latitude: latitude, Longitude: longitude})
+	chord := 2 * math.Sin(math.Min(radius/earthRadius, math.Pi)/2)
+
+	// Search the cube around the sphere cap, widened slightly against rounding, then keep the nodes inside the cap
+	var lower, upper [3]float64
+	for axis := range target {
+		lower[axis], upper[axis] = target[axis]-chord-1e-9, target[axis]+chord+1e-9
+	}
+	sensors := []NearestSensor{}
+	index.searchRange(index.root, lower, upper, func(node *kdNode) {
+		distance := greatCircleDistance(latitude, longitude, node.sensor.Location.Latitude, node.sensor.Location.Longitude)
+		if distance > radius || (match != nil && !match(node.sensor)) {
+			return
+		}
+		sensors = append(sensors, NearestSensor{SensorMetadata: *cloneSensorMetadata(node.sensor), Distance: distance})
+	})
+
+	sort.SliceStable(sensors, func(i, j int) bool {
+		return sensors[i].Distance < sensors[j].Distance
+	})
+	return sensors
+}
+
+// WithinBoundingBox returns the sensors matching the predicate inside the box, ordered by ID.
+// A nil predicate matches every sensor.
+func (index *SpatialIndex) WithinBoundingBox(box BoundingBox, match func(SensorMetadata) bool) []SensorMetadata {
+	sensors := []SensorMetadata{}
+	for _, part := range box.split() {
+		lower, upper := part.unitVectorBounds()
+		index.searchRange(index.root, lower, upper, func(node *kdNode) {
+			location := node.sensor.Location
+			if !part.Contains(location.Latitude, location.Longitude) || (match != nil && !match(node.sensor)) {
+				return
+			}
+			sensors = append(sensors, *cloneSensorMetadata(node.sensor))
+		})
+	}
+
+	sort.Slice(sensors, func(i, j int) bool {
+		return sensors[i].ID < sensors[j].ID
+	})
+	return sensors
+}
+
+// searchRange calls visit for every live node inside the axis-aligned box from lower to upper.
+func (index *SpatialIndex) searchRange(node *kdNode, lower, upper [3]float64, visit func(*kdNode)) {
+	if node == nil {
+		return
+	}
+	inside := true
+	for axis := range node.point {
+		if node.point[axis] < lower[axis] || node.point[axis] > upper[axis] {
+			inside = false
+			break
+		}
+	}
+	if inside && !node.deleted {
+		visit(node)
+	}
+	if lower[node.axis] < node.point[node.axis] {
+		index.searchRange(node.left, lower, upper, visit)
+	}
+	if upper[node.axis] >= node.point[node.axis] {
+		index.searchRange(node.right, lower, upper, visit)
+	}
+}
+
+// unitVectorBounds returns the axis-aligned box around the unit vectors of the points inside a box
+// that doesn't cross the antimeridian. Each coordinate is bounded from the ranges of the sines and
+// cosines of the latitudes and longitudes, widened slightly against rounding.
+func (b BoundingBox) unitVectorBounds() (lower, upper [3]float64) {
+	const epsilon = 1e-9
+	minLat, maxLat := b.MinLat*math.Pi/180, b.MaxLat*math.Pi/180
+	minLon, maxLon := b.MinLon*math.Pi/180, b.MaxLon*math.Pi/180
+
+	// cos(latitude) is never negative, since the box doesn't cross the poles
+	minCosLat, maxCosLat := trigRange(math.Cos, minLat, maxLat, 0)
+	minCosLon, maxCosLon := trigRange(math.Cos, minLon, maxLon, 0)
+	minSinLon, maxSinLon := trigRange(math.Sin, minLon, maxLon, math.Pi/2)
+
+	lower = [3]float64{
+		math.Min(minCosLat*minCosLon, maxCosLat*minCosLon),
+		math.Min(minCosLat*minSinLon, maxCosLat*minSinLon),
+		math.Sin(minLat),
+	}
+	upper = [3]float64{
+		math.Max(minCosLat*maxCosLon, maxCosLat*maxCosLon),
+		math.Max(minCosLat*maxSinLon, maxCosLat*maxSinLon),
+		math.Sin(maxLat),
+	}
+	for axis := range lower {
+		lower[axis] -= epsilon
+		upper[axis] += epsilon
+	}
+	return lower, upper
+}
+
+// trigRange returns the range of a sine or cosine over the angles from min to max, in radians
+// within [-π, π]. peak is the angle where the function is 1, and its opposite where it is -1.
+func trigRange(f func(float64) float64, min, max, peak float64) (float64, float64) {
+	lowest, highest := math.Min(f(min), f(max)), math.Max(f(min), f(max))
+	trough := peak - math.Pi
+	if min <= peak && peak <= max {
+		highest = 1
+	}
+	if (min <= trough && trough <= max) || (min <= trough+2*math.Pi && trough+2*math.Pi <= max) {
+		lowest = -1
+	}
+	return lowest, highest
+}
+
+// unitVector returns the point of the unit sphere at a location.
+func unitVector(location Location) [3]float64 {
+	lat := location.Latitude * math.Pi / 180
+	lon := location.Longitude * math.Pi / 180
+	return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+// chordDistance2 returns the squared straight-line distance between two unit vectors.
+func chordDistance2(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// kdCandidate is a node found by a nearest search, with its squared chord distance to the target.
+type kdCandidate struct {
+	node     *kdNode
+	distance float64
+}
+
+// kdCandidates is a max-heap of candidates, farthest first.
+type kdCandidates []kdCandidate
+
+func (c kdCandidates) Len() int            { return len(c) }
+func (c kdCandidates) Less(i, j int) bool  { return c[i].distance > c[j].distance }
+func (c kdCandidates) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *kdCandidates) Push(x interface{}) { *c = append(*c, x.(kdCandidate)) }
+func (c *kdCandidates) Pop() interface{} {
+	old := *c
+	candidate := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return candidate
+}
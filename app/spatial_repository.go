@@ -0,0 +1,248 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SpatialIndexRepository decorates a Repository with a SpatialIndex of every sensor, which answers
+// nearest and within queries without a round trip to the database. The index follows the change
+// log: it catches up after every write made through the decorator, so a replica reads its own
+// writes, and after the changes notified by a SensorChangeListener when subscribed to one.
+// Nearest queries ranking by altitude or floor aren't indexed, and go to the repository.
+type SpatialIndexRepository struct {
+	Repository
+
+	// mu guards the index, and syncMu serializes the catch-ups, which read the change log without
+	// holding up queries
+	mu          sync.RWMutex
+	syncMu      sync.Mutex
+	index       *SpatialIndex
+	lastEventID int64
+}
+
+// spatialIndexPageSize is the number of sensors or events read at a time to fill the index.
+const spatialIndexPageSize = 1000
+
+// NewSpatialIndexRepository creates the decorator, and fills its index with every sensor of the repository.
+func NewSpatialIndexRepository(repo Repository) (*SpatialIndexRepository, error) {
+	r := &SpatialIndexRepository{Repository: repo}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load replaces the index with one of every sensor of the repository.
+func (r *SpatialIndexRepository) load() error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	// Read the position in the change log first: changes made while the sensors are read are
+	// applied again, which leaves the sensors in their latest state
+	lastEventID, err := r.Repository.LastSensorEventID()
+	if err != nil {
+		return err
+	}
+	// Sensors are read from the primary, which has every change up to the position read
+	var sensors []SensorMetadata
+	filter := SensorFilter{Limit: spatialIndexPageSize}
+	for {
+		page, err := primaryOf(r.Repository).ListSensorMetadata(filter)
+		if err != nil {
+			return err
+		}
+		sensors = append(sensors, page...)
+		if len(page) < filter.Limit {
+			break
+		}
+		filter.AfterID = page[len(page)-1].ID
+	}
+
+	r.mu.Lock()
+	r.index = NewSpatialIndex(sensors)
+	r.lastEventID = lastEventID
+	r.mu.Unlock()
+
+	return r.catchUp()
+}
+
+// catchUp applies the changes recorded in the change log since the index was last updated.
+// The caller must hold syncMu.
+func (r *SpatialIndexRepository) catchUp() error {
+	for {
+		events, err := r.Repository.ListSensorEvents(r.lastEventID, spatialIndexPageSize)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		r.mu.Lock()
+		for _, event := range events {
+			if event.Type == SensorDeleted {
+				r.index.Remove(event.Sensor.ID)
+			} else {
+				r.index.Insert(event.Sensor)
+			}
+			r.lastEventID = event.ID
+		}
+		r.mu.Unlock()
+	}
+}
+
+// sync brings the index up to date after a write. The write has succeeded whatever happens to
+// the index, so a failure is only logged; the next catch-up applies the missed changes. An index
+// left behind by the events pruned from the change log is reloaded.
+func (r *SpatialIndexRepository) sync() {
+	r.syncMu.Lock()
+	err := r.catchUp()
+	r.syncMu.Unlock()
+	if errors.Is(err, ErrSensorEventsPruned) {
+		err = r.load()
+	}
+	if err != nil {
+		log.Println("Error updating the spatial index:", err)
+	}
+}
+
+// GetNearestSensorMetadata retrieves the sensor metadata nearest to a location from the index.
+func (r *SpatialIndexRepository) GetNearestSensorMetadata(latitude, longitude string) (*NearestSensor, error) {
+	query, err := parseNearestQuery(latitude, longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	sensors, err := r.FindNearestSensorMetadata(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(sensors) == 0 {
+		return nil, ErrSensorNotFound
+	}
+
+	return &sensors[0], nil
+}
+
+// FindNearestSensorMetadata retrieves the sensors nearest to a location that match the query's tag filter.
+func (r *SpatialIndexRepository) FindNearestSensorMetadata(query NearestQuery) ([]NearestSensor, error) {
+	if query.Use3D || query.PreferSameFloor {
+		return r.Repository.FindNearestSensorMetadata(query)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.index.Nearest(query.Latitude, query.Longitude, query.Limit, func(sensorMetadata SensorMetadata) bool {
+		return query.Tags == nil || query.Tags.Match(sensorMetadata.Tags)
+	}), nil
+}
+
+// FindNeighbourSensorMetadata retrieves the sensors nearest to each of the query's sensors that
+// match its tag filter from the index.
+func (r *SpatialIndexRepository) FindNeighbourSensorMetadata(query NeighbourQuery) (map[int][]NearestSensor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	neighbours := map[int][]NearestSensor{}
+	for _, id := range query.IDs {
+		origin, ok := r.index.Get(id)
+		if !ok {
+			continue
+		}
+		neighbours[id] = r.index.Nearest(origin.Location.Latitude, origin.Location.Longitude, query.Limit, func(sensorMetadata SensorMetadata) bool {
+			return sensorMetadata.ID != id && (query.Tags == nil || query.Tags.Match(sensorMetadata.Tags))
+		})
+	}
+	return neighbours, nil
+}
+
+// FindSensorMetadataWithin retrieves a page of the sensors inside a bounding box or polygons, ordered by ID.
+func (r *SpatialIndexRepository) FindSensorMetadataWithin(query WithinQuery) ([]SensorMetadata, error) {
+	// Polygons are searched through their bounding boxes
+	boxes := []BoundingBox{}
+	if query.BoundingBox != nil {
+		boxes = append(boxes, *query.BoundingBox)
+	}
+	for _, polygon := range query.Polygons {
+		boxes = append(boxes, polygon.Bounds())
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := map[int]bool{}
+	sensors := []SensorMetadata{}
+	for _, box := range boxes {
+		for _, sensorMetadata := range r.index.WithinBoundingBox(box, query.SensorFilter.match) {
+			if !found[sensorMetadata.ID] && query.Contains(sensorMetadata.Location) {
+				found[sensorMetadata.ID] = true
+				sensors = append(sensors, sensorMetadata)
+			}
+		}
+	}
+
+	sort.Slice(sensors, func(i, j int) bool {
+		return sensors[i].ID < sensors[j].ID
+	})
+	if query.Limit > 0 && len(sensors) > query.Limit {
+		sensors = sensors[:query.Limit]
+	}
+	return sensors, nil
+}
+
+// CreateSensorMetadata creates a new sensor metadata entry, and adds it to the index.
+func (r *SpatialIndexRepository) CreateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	defer r.sync()
+	return r.Repository.CreateSensorMetadata(sensorMetadata)
+}
+
+// UpdateSensorMetadata updates an existing sensor metadata entry, and updates it in the index.
+func (r *SpatialIndexRepository) UpdateSensorMetadata(sensorMetadata *SensorMetadata) error {
+	defer r.sync()
+	return r.Repository.UpdateSensorMetadata(sensorMetadata)
+}
+
+// AddSensorTags adds tags to a sensor, and updates it in the index.
+func (r *SpatialIndexRepository) AddSensorTags(name string, tags []string) (*SensorMetadata, error) {
+	defer r.sync()
+	return r.Repository.AddSensorTags(name, tags)
+}
+
+// RemoveSensorTag removes a tag from a sensor, and updates it in the index.
+func (r *SpatialIndexRepository) RemoveSensorTag(name, tag string) (*SensorMetadata, error) {
+	defer r.sync()
+	return r.Repository.RemoveSensorTag(name, tag)
+}
+
+// ApplySensorBatch applies a batch of operations, and updates the index with their changes.
+func (r *SpatialIndexRepository) ApplySensorBatch(operations []BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	defer r.sync()
+	return r.Repository.ApplySensorBatch(operations, atomic)
+}
+
+// SensorChanged updates the index with a change made by any replica.
+func (r *SpatialIndexRepository) SensorChanged(SensorChange) {
+	r.sync()
+}
+
+// ResyncSensors refills the index after changes may have been missed.
+func (r *SpatialIndexRepository) ResyncSensors() {
+	if err := r.load(); err != nil {
+		log.Println("Error reloading the spatial index:", err)
+	}
+}
+
+// Primary returns a view of the repository that reads from the primary, bypassing the index.
+func (r *SpatialIndexRepository) Primary() Repository {
+	return primaryOf(r.Repository)
+}
+
+// ReplicaStaleness bounds how long a write may take to be seen by the reads sent to replicas.
+func (r *SpatialIndexRepository) ReplicaStaleness() time.Duration {
+	return replicaStaleness(r.Repository)
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// StatementCache holds the statements prepared by a PostgresRepository on each of its databases,
+// so that a query is parsed once per connection rather than prepared and closed on every call,
+// which costs two more round trips. A statement lives as long as the cache: database/sql prepares
+// it again on every new connection of the pool, such as those replacing connections lost.
+// A StatementCache is safe for concurrent use.
+type StatementCache struct {
+	mu    sync.Mutex
+	stmts map[statementKey]*sql.Stmt
+}
+
+// statementKey identifies a statement by its database and query.
+type statementKey struct {
+	db    *sql.DB
+	query string
+}
+
+// NewStatementCache creates an empty cache.
+func NewStatementCache() *StatementCache {
+	return &StatementCache{stmts: make(map[statementKey]*sql.Stmt)}
+}
+
+// prepare returns the statement of the query on db, preparing it the first time. The statement
+// is prepared without holding the lock, so queries being prepared don't hold up the others; when
+// two callers race to prepare the same query, the first statement stored is kept.
+func (c *StatementCache) prepare(db *sql.DB, query string) (*sql.Stmt, error) {
+	key := statementKey{db: db, query: query}
+	c.mu.Lock()
+	stmt, ok := c.stmts[key]
+	c.mu.Unlock()
+	if ok {
+		return stmt, nil
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stored, ok := c.stmts[key]; ok {
+		stmt.Close()
+		return stored, nil
+	}
+	c.stmts[key] = stmt
+	return stmt, nil
+}
+
+// Close closes every statement of the cache.
+func (c *StatementCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var firstErr error
+	for key, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.stmts, key)
+	}
+	return firstErr
+}
+
+// prepare returns a statement of the query on db, from the repository's cache when it has one,
+// and a function to call once the statement has been used.
+func (r *PostgresRepository) prepare(db *sql.DB, query string) (*sql.Stmt, func(), error) {
+	if r.Statements == nil {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stmt, func() { stmt.Close() }, nil
+	}
+
+	stmt, err := r.Statements.prepare(db, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stmt, func() {}, nil
+}
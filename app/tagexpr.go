@@ -0,0 +1,243 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTagExpr is returned when a tag filter expression cannot be parsed.
+var ErrInvalidTagExpr = errors.New("invalid tag expression")
+
+// TagExpr is a compiled tag filter such as `vendor:acme AND (floor:3 OR floor:4) AND NOT retired`.
+// It can be evaluated against a sensor's tags in memory or compiled to a PostgreSQL predicate.
+type TagExpr interface {
+	// Match reports whether the given tags satisfy the expression.
+	Match(tags []string) bool
+	// String returns the expression in its canonical textual form.
+	String() string
+	// sql appends the expression's parameters to q and returns the SQL predicate.
+	sql(q *sqlQuery) string
+}
+
+// ParseTagExpr parses a tag filter expression.
+//
+// Terms are tags, optionally written as key:value for structured tags, or key:* to
+// match any value for the key. Terms are combined with the upper-case operators AND,
+// OR and NOT and may be grouped with parentheses. NOT binds tighter than AND, which
+// binds tighter than OR.
+func ParseTagExpr(input string) (TagExpr, error) {
+	p := &tagExprParser{tokens: tokenizeTagExpr(input)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("%w: expression is empty", ErrInvalidTagExpr)
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidTagExpr, p.tokens[p.pos])
+	}
+
+	return expr, nil
+}
+
+// tokenizeTagExpr splits an expression into parentheses and whitespace separated words.
+func tokenizeTagExpr(input string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// tagExprParser is a recursive descent parser over the tokens of a tag expression.
+type tagExprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *tagExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagExprParser) parseOr() (TagExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagExprParser) parseAnd() (TagExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "AND" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *tagExprParser) parseNot() (TagExpr, error) {
+	if p.peek() == "NOT" {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *tagExprParser) parsePrimary() (TagExpr, error) {
+	token := p.peek()
+	switch token {
+	case "":
+		return nil, fmt.Errorf("%w: unexpected end of expression", ErrInvalidTagExpr)
+	case "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", ErrInvalidTagExpr)
+		}
+		p.pos++
+		return expr, nil
+	case ")", "AND", "OR":
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidTagExpr, token)
+	}
+
+	p.pos++
+
+	// key:* matches any structured tag with the given key
+	if strings.HasSuffix(token, ":*") {
+		key, err := NormalizeTag(strings.TrimSuffix(token, ":*"))
+		if err != nil || strings.Contains(key, ":") {
+			return nil, fmt.Errorf("%w: invalid key in %q", ErrInvalidTagExpr, token)
+		}
+		return tagKeyExpr{key}, nil
+	}
+
+	tag, err := NormalizeTag(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTagExpr, err)
+	}
+	return tagTermExpr{tag}, nil
+}
+
+// tagTermExpr matches sensors carrying the exact tag.
+type tagTermExpr struct {
+	tag string
+}
+
+func (e tagTermExpr) Match(tags []string) bool {
+	for _, tag := range tags {
+		if tag == e.tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (e tagTermExpr) String() string { return e.tag }
+
+func (e tagTermExpr) sql(q *sqlQuery) string {
+	return fmt.Sprintf("tags @> ARRAY[%s]::VARCHAR(255)[]", q.arg(e.tag))
+}
+
+// tagKeyExpr matches sensors carrying any structured tag with the key.
+type tagKeyExpr struct {
+	key string
+}
+
+func (e tagKeyExpr) Match(tags []string) bool {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, e.key+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func (e tagKeyExpr) String() string { return e.key + ":*" }
+
+func (e tagKeyExpr) sql(q *sqlQuery) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE starts_with(tag, %s))", q.arg(e.key+":"))
+}
+
+type andExpr struct {
+	left, right TagExpr
+}
+
+func (e andExpr) Match(tags []string) bool { return e.left.Match(tags) && e.right.Match(tags) }
+
+func (e andExpr) String() string { return "(" + e.left.String() + " AND " + e.right.String() + ")" }
+
+func (e andExpr) sql(q *sqlQuery) string {
+	return "(" + e.left.sql(q) + " AND " + e.right.sql(q) + ")"
+}
+
+type orExpr struct {
+	left, right TagExpr
+}
+
+func (e orExpr) Match(tags []string) bool { return e.left.Match(tags) || e.right.Match(tags) }
+
+func (e orExpr) String() string { return "(" + e.left.String() + " OR " + e.right.String() + ")" }
+
+func (e orExpr) sql(q *sqlQuery) string {
+	return "(" + e.left.sql(q) + " OR " + e.right.sql(q) + ")"
+}
+
+type notExpr struct {
+	operand TagExpr
+}
+
+func (e notExpr) Match(tags []string) bool { return !e.operand.Match(tags) }
+
+func (e notExpr) String() string { return "NOT " + e.operand.String() }
+
+func (e notExpr) sql(q *sqlQuery) string {
+	// IS NOT TRUE keeps sensors whose tags column is NULL
+	return "(" + e.operand.sql(q) + ") IS NOT TRUE"
+}
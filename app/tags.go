@@ -0,0 +1,56 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxTagLength is the maximum length of a normalized tag.
+const MaxTagLength = 64
+
+// ErrInvalidTag is returned when a tag does not satisfy the normalization rules.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// tagPattern lists the characters allowed in a normalized tag.
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:\-]*$`)
+
+// TagCount represents a distinct tag together with the number of sensors using it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag trims and case folds a tag and checks it against the tag rules.
+func NormalizeTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(tag))
+	if normalized == "" {
+		return "", fmt.Errorf("%w: tag must not be empty", ErrInvalidTag)
+	}
+	if len(normalized) > MaxTagLength {
+		return "", fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTag, normalized, MaxTagLength)
+	}
+	if !tagPattern.MatchString(normalized) {
+		return "", fmt.Errorf("%w: %q may only contain letters, digits, '_', '.', ':' and '-'", ErrInvalidTag, normalized)
+	}
+	return normalized, nil
+}
+
+// NormalizeTags normalizes every tag and removes duplicates, keeping the first occurrence.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		t, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	return normalized, nil
+}
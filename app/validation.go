@@ -0,0 +1,74 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/skartikey/sensor-metadata/app/jsonschema"
+)
+
+// FieldError represents a validation failure of a single request field.
+// Field is the dotted JSON path of the field, e.g. location.latitude or attributes.units.0.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// newValidator creates a validator that names fields by their JSON names.
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate
+}
+
+// validationFields lists the field failures behind a validation error, if any.
+func validationFields(err error) []FieldError {
+	var fields []FieldError
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		for _, fieldErr := range validationErrors {
+			// The namespace starts with the name of the validated struct
+			_, field, _ := strings.Cut(fieldErr.Namespace(), ".")
+			fields = append(fields, FieldError{Field: field, Message: validationMessage(fieldErr)})
+		}
+	}
+
+	var schemaErr *jsonschema.ValidationError
+	if errors.As(err, &schemaErr) {
+		for _, attributeErr := range schemaErr.Errors {
+			field := "attributes" + strings.ReplaceAll(strings.TrimSuffix(attributeErr.Path, "/"), "/", ".")
+			fields = append(fields, FieldError{Field: field, Message: attributeErr.Message})
+		}
+	}
+
+	return fields
+}
+
+// validationMessage describes a failed validation rule.
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fieldErr.Param()
+	case "max", "lte":
+		return "must be at most " + fieldErr.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	case "http_url":
+		return "must be an HTTP or HTTPS URL"
+	default:
+		return fmt.Sprintf("failed the '%s' rule", fieldErr.Tag())
+	}
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"strings"
+)
+
+// WaypointOptions controls how sensors are grouped and styled in KML and GPX documents.
+type WaypointOptions struct {
+	// GroupBy is the tag key whose value names the KML folder of each sensor; empty for no folders.
+	GroupBy string
+	// StyleBy is the tag key whose value picks the style of each sensor.
+	StyleBy string
+}
+
+// DefaultStyleBy is the tag key that styles sensors unless WaypointOptions says otherwise.
+const DefaultStyleBy = "status"
+
+// Sensor statuses with a style of their own. Sensors without a status tag are active.
+const (
+	StatusActive         = "active"
+	StatusStale          = "stale"
+	StatusDecommissioned = "decommissioned"
+)
+
+// tagValue returns the value of the first key:value tag with the given key.
+func tagValue(tags []string, key string) (string, bool) {
+	for _, tag := range tags {
+		if parsed, ok := ParseTag(tag); ok && parsed.Key == key {
+			return parsed.Value, true
+		}
+	}
+	return "", false
+}
+
+// status returns the value of the sensor's StyleBy tag, or StatusActive when it has none.
+func (options WaypointOptions) status(sensorMetadata SensorMetadata) string {
+	key := options.StyleBy
+	if key == "" {
+		key = DefaultStyleBy
+	}
+	if value, ok := tagValue(sensorMetadata.Tags, key); ok {
+		return value
+	}
+	return StatusActive
+}
+
+// describeSensor summarizes the tags and type of a sensor for a waypoint description.
+func describeSensor(sensorMetadata SensorMetadata) string {
+	var parts []string
+	if sensorMetadata.Type != "" {
+		parts = append(parts, "Type: "+sensorMetadata.Type)
+	}
+	if len(sensorMetadata.Tags) > 0 {
+		parts = append(parts, "Tags: "+strings.Join(sensorMetadata.Tags, ", "))
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// WebhookDispatcher sends the deliveries of the webhook outbox. Each round it moves the new
+// events of the change log into the outbox, then sends the deliveries that are due. Deliveries
+// are claimed for a lease, so several API replicas can share the outbox, and a delivery whose
+// dispatcher crashed is sent again once its lease expires.
+type WebhookDispatcher struct {
+	repo Repository
+	wake chan struct{}
+
+	// Client sends the deliveries. Its timeout must be shorter than Lease. The default client
+	// doesn't follow redirects, and refuses to connect to the addresses rejected for webhooks,
+	// whatever the host name of the URL resolves to, unless AllowPrivateAddresses is set.
+	Client *http.Client
+	// AllowPrivateAddresses lets the default client connect to loopback, private and link-local
+	// addresses.
+	AllowPrivateAddresses bool
+	// MaxAttempts is the number of attempts after which a delivery is dead-lettered.
+	MaxAttempts int
+	// Backoff returns the delay before the attempt following the given number of failed attempts.
+	Backoff func(attempts int) time.Duration
+	// PollInterval is the delay between rounds.
+	PollInterval time.Duration
+	// Lease is how long a claimed delivery is reserved for its dispatcher.
+	Lease time.Duration
+	// Concurrency bounds the number of deliveries sent at once.
+	Concurrency int
+}
+
+// Defaults of a WebhookDispatcher. With them a delivery is attempted over about 6 hours
+// before it is dead-lettered.
+const (
+	defaultWebhookMaxAttempts  = 12
+	defaultWebhookTimeout      = 10 * time.Second
+	defaultWebhookPollInterval = time.Second
+	defaultWebhookLease        = time.Minute
+	defaultWebhookConcurrency  = 8
+	webhookBackoffBase         = 10 * time.Second
+	webhookBackoffMax          = 4 * time.Hour
+	webhookBatchSize           = 100
+)
+
+// NewWebhookDispatcher creates a dispatcher for the webhooks of the repository.
+func NewWebhookDispatcher(repo Repository) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		repo:         repo,
+		wake:         make(chan struct{}, 1),
+		MaxAttempts:  defaultWebhookMaxAttempts,
+		Backoff:      WebhookBackoff,
+		PollInterval: defaultWebhookPollInterval,
+		Lease:        defaultWebhookLease,
+		Concurrency:  defaultWebhookConcurrency,
+	}
+
+	// Check the address actually dialed, after the host name was resolved, so that a name
+	// resolving to an internal address, or changing to one, can't reach it. Proxies would
+	// connect on the dispatcher's behalf, unchecked, so none is used.
+	dialer := &net.Dialer{
+		Timeout:   defaultWebhookTimeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if d.AllowPrivateAddresses {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrWebhookAddressNotAllowed, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	d.Client = &http.Client{
+		Transport: transport,
+		Timeout:   defaultWebhookTimeout,
+		// Redirects aren't followed, so a receiver can't pass the signed payload on to another URL;
+		// the redirect response fails the attempt
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// WebhookBackoff doubles the delay after every failed attempt, from 10 seconds up to 4 hours,
+// and spreads it by up to a fifth either way so that retries to a recovering endpoint don't
+// arrive all at once.
+func WebhookBackoff(attempts int) time.Duration {
+	delay := webhookBackoffBase
+	for i := 1; i < attempts && delay < webhookBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookBackoffMax {
+		delay = webhookBackoffMax
+	}
+	return delay + time.Duration((rand.Float64()*0.4-0.2)*float64(delay))
+}
+
+// SensorChanged wakes the dispatcher, so the deliveries of a change are sent without waiting for the next round.
+func (d *WebhookDispatcher) SensorChanged(SensorChange) {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ResyncSensors wakes the dispatcher, which reads every missed change from the change log.
+func (d *WebhookDispatcher) ResyncSensors() {
+	d.SensorChanged(SensorChange{})
+}
+
+// Run dispatches deliveries until the context is cancelled. Rounds run every PollInterval, and
+// as soon as a change is notified when the dispatcher subscribes to a SensorChangeListener.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			log.Println("Error dispatching webhooks:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// Dispatch runs one round: it enqueues the deliveries of every new event, then sends the
+// deliveries that are due and records their outcomes.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) error {
+	for {
+		n, err := d.repo.EnqueueWebhookDeliveries(webhookBatchSize)
+		if err != nil {
+			return err
+		}
+		if n < webhookBatchSize {
+			break
+		}
+	}
+
+	for {
+		deliveries, err := d.repo.ClaimWebhookDeliveries(webhookBatchSize, d.Lease)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		webhooks, err := d.repo.ListWebhooks()
+		if err != nil {
+			return err
+		}
+		byID := make(map[int]Webhook, len(webhooks))
+		for _, webhook := range webhooks {
+			byID[webhook.ID] = webhook
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, len(deliveries))
+		semaphore := make(chan struct{}, d.Concurrency)
+		for i := range deliveries {
+			webhook, ok := byID[deliveries[i].WebhookID]
+			if !ok {
+				// The webhook was deleted, together with its deliveries
+				continue
+			}
+
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(delivery *WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				errs <- d.deliver(ctx, webhook, delivery)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < webhookBatchSize || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// deliver sends a delivery and records the outcome, scheduling another attempt after a failure
+// or dead-lettering the delivery once it runs out of attempts.
+func (d *WebhookDispatcher) deliver(ctx context.Context, webhook Webhook, delivery *WebhookDelivery) error {
+	delivery.Attempts++
+	err := d.send(ctx, webhook, delivery)
+	switch {
+	case err == nil:
+		delivery.Status = DeliveryDelivered
+		delivery.LastError = ""
+	case ctx.Err() != nil:
+		// Shutting down; the delivery is sent again when its lease expires
+		return nil
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = DeliveryDead
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = time.Now().Add(d.Backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	return d.repo.UpdateWebhookDelivery(delivery)
+}
+
+// send posts the signed payload of a delivery to the webhook's URL.
+func (d *WebhookDispatcher) send(ctx context.Context, webhook Webhook, delivery *WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sensor-metadata-webhooks")
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,458 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrWebhookNotFound is returned when no webhook has the requested ID.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrWebhookAddressNotAllowed is returned when a webhook URL points at a loopback, private,
+// link-local or unspecified address, such as a service on the API's own network.
+var ErrWebhookAddressNotAllowed = errors.New("webhook address not allowed")
+
+// ErrWebhookDeliveryNotFound is returned when a webhook has no delivery with the requested ID.
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+// Types of events a webhook can subscribe to, besides those of SensorEvent. Moves and retags are
+// updates that changed the location or the tags of a sensor, and are delivered as updates.
+const (
+	SensorMoved    = "moved"
+	SensorRetagged = "retagged"
+)
+
+// Statuses of a webhook delivery. A pending delivery is retried with exponential backoff until it
+// succeeds, or until it runs out of attempts and is dead-lettered.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookRepository represents the storage of webhooks and of their outbox of deliveries.
+type WebhookRepository interface {
+	CreateWebhook(webhook *Webhook) error
+	GetWebhook(id int) (*Webhook, error)
+	ListWebhooks() ([]Webhook, error)
+	UpdateWebhook(webhook *Webhook) error
+	DeleteWebhook(id int) error
+	EnqueueWebhookDeliveries(limit int) (int, error)
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery *WebhookDelivery) error
+	ListWebhookDeliveries(webhookID int, filter WebhookDeliveryFilter) ([]WebhookDelivery, error)
+	RetryWebhookDelivery(webhookID int, id int64) (*WebhookDelivery, error)
+}
+
+// Webhook represents the subscription of an external system to changes to sensors.
+// Events lists the types of events to deliver, all of them when empty, and Tags is a tag filter
+// expression selecting the sensors. The secret signs deliveries and is only returned on creation.
+// Webhooks receive the events recorded after AfterEventID, the last event when they were created.
+type Webhook struct {
+	ID           int      `json:"id"`
+	URL          string   `json:"url" validate:"required,http_url,max=2048"`
+	Secret       string   `json:"secret,omitempty" validate:"omitempty,min=16,max=255"`
+	Events       []string `json:"events,omitempty" validate:"dive,oneof=created updated deleted moved retagged"`
+	Tags         string   `json:"tags,omitempty" validate:"max=1024"`
+	AfterEventID int64    `json:"-"`
+}
+
+// WebhookDelivery represents an event to send to a webhook, as recorded in the outbox.
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int             `json:"webhook_id"`
+	EventID       int64           `json:"event_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+}
+
+// WebhookDeliveryFilter represents the criteria for listing the deliveries of a webhook.
+// Results are ordered by ID; AfterID is the keyset cursor of the previous page.
+type WebhookDeliveryFilter struct {
+	Status  string
+	AfterID int64
+	Limit   int
+}
+
+// WebhookDeliveryList represents a page of webhook deliveries.
+type WebhookDeliveryList struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Next       string            `json:"next,omitempty"`
+}
+
+// WebhookPayload represents the body of a webhook delivery. Previous is the sensor before an update.
+type WebhookPayload struct {
+	ID       int64             `json:"id"`
+	Type     string            `json:"type"`
+	Sensor   SensorMetadataV1  `json:"sensor"`
+	Previous *SensorMetadataV1 `json:"previous,omitempty"`
+}
+
+// Headers of a webhook delivery. The signature is "sha256=" followed by SignWebhookPayload.
+const (
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// SignWebhookPayload returns the hex-encoded HMAC-SHA256, keyed with the webhook's secret, of the
+// delivery's Unix timestamp and body joined by a dot. Signing the timestamp lets receivers reject
+// replayed deliveries.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribes reports whether the webhook subscribes to the event.
+func (webhook Webhook) subscribes(event SensorEvent, tags TagExpr) bool {
+	if event.ID <= webhook.AfterEventID || !(SensorEventFilter{Tags: tags}).Match(event) {
+		return false
+	}
+	if len(webhook.Events) == 0 || containsString(webhook.Events, event.Type) {
+		return true
+	}
+	if event.Type != SensorUpdated || event.Previous == nil {
+		return false
+	}
+	return (containsString(webhook.Events, SensorMoved) && !reflect.DeepEqual(event.Sensor.Location, event.Previous.Location)) ||
+		(containsString(webhook.Events, SensorRetagged) && !reflect.DeepEqual(event.Sensor.Tags, event.Previous.Tags))
+}
+
+// webhookDeliveries returns the deliveries of the events to the webhooks subscribed to them.
+func webhookDeliveries(webhooks []Webhook, events []SensorEvent) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	for _, webhook := range webhooks {
+		// Expressions are validated when webhooks are saved
+		tags, err := parseWebhookTags(webhook.Tags)
+		if err != nil {
+			continue
+		}
+
+		for _, event := range events {
+			if !webhook.subscribes(event, tags) {
+				continue
+			}
+
+			payload := WebhookPayload{ID: event.ID, Type: event.Type, Sensor: NewSensorMetadataV1(event.Sensor)}
+			if event.Previous != nil {
+				previous := NewSensorMetadataV1(*event.Previous)
+				payload.Previous = &previous
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return nil, err
+			}
+
+			deliveries = append(deliveries, WebhookDelivery{
+				WebhookID: webhook.ID,
+				EventID:   event.ID,
+				Event:     event.Type,
+				Payload:   data,
+				Status:    DeliveryPending,
+			})
+		}
+	}
+	return deliveries, nil
+}
+
+// parseWebhookTags parses the tag filter expression of a webhook, which is nil when it is empty.
+func parseWebhookTags(tags string) (TagExpr, error) {
+	if tags == "" {
+		return nil, nil
+	}
+	return ParseTagExpr(tags)
+}
+
+// newWebhookSecret generates a random secret for a webhook.
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// CreateWebhook handles the HTTP POST request to create a webhook. The response includes the
+// webhook's secret, which is generated when the request doesn't set one.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhook Webhook
+	err := json.NewDecoder(r.Body).Decode(&webhook)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Validate the input
+	if err := h.prepareWebhook(&webhook); err != nil {
+		sendValidationErrorResponse(w, err)
+		return
+	}
+	if webhook.Secret == "" {
+		webhook.Secret, err = newWebhookSecret()
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, "Failed to create webhook")
+			return
+		}
+	}
+
+	// Save the webhook
+	err = h.repo.CreateWebhook(&webhook)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, webhook)
+}
+
+// GetWebhooks handles the HTTP GET request to list webhooks.
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.repo.ListWebhooks()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list webhooks")
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	jsonResponse(w, http.StatusOK, webhooks)
+}
+
+// GetWebhook handles the HTTP GET request to retrieve a webhook by ID.
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	webhook, err := h.repo.GetWebhook(id)
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get webhook")
+		return
+	}
+
+	webhook.Secret = ""
+	jsonResponse(w, http.StatusOK, webhook)
+}
+
+// UpdateWebhook handles the HTTP PUT request to update a webhook. The secret is kept when the
+// request doesn't set one.
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	var webhook Webhook
+	err = json.NewDecoder(r.Body).Decode(&webhook)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	webhook.ID = id
+
+	// Validate the input
+	if err := h.prepareWebhook(&webhook); err != nil {
+		sendValidationErrorResponse(w, err)
+		return
+	}
+
+	// Update the webhook
+	err = h.repo.UpdateWebhook(&webhook)
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteWebhook handles the HTTP DELETE request to delete a webhook together with its deliveries.
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	err = h.repo.DeleteWebhook(id)
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries handles the HTTP GET request to list the deliveries of a webhook,
+// optionally those with the given 'status' only, such as the dead-lettered ones.
+func (h *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	filter, err := parseWebhookDeliveryFilter(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.repo.GetWebhook(id); err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list webhook deliveries")
+		return
+	}
+
+	deliveries, err := h.repo.ListWebhookDeliveries(id, filter)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list webhook deliveries")
+		return
+	}
+
+	deliveryList := WebhookDeliveryList{Deliveries: deliveries}
+	if len(deliveries) == filter.Limit {
+		deliveryList.Next = strconv.FormatInt(deliveries[len(deliveries)-1].ID, 10)
+	}
+	jsonResponse(w, http.StatusOK, deliveryList)
+}
+
+// RetryWebhookDelivery handles the HTTP POST request to send a delivery again, typically a
+// dead-lettered one, with a fresh set of attempts.
+func (h *Handler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook delivery not found")
+		return
+	}
+	deliveryID, err := strconv.ParseInt(mux.Vars(r)["delivery"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook delivery not found")
+		return
+	}
+
+	delivery, err := h.repo.RetryWebhookDelivery(id, deliveryID)
+	if err != nil {
+		if errors.Is(err, ErrWebhookDeliveryNotFound) {
+			sendErrorResponse(w, http.StatusNotFound, "Webhook delivery not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to retry webhook delivery")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, delivery)
+}
+
+// Helper function to validate a webhook from a request, including its tag filter expression and,
+// unless private webhooks are allowed, the address of its URL.
+func (h *Handler) prepareWebhook(webhook *Webhook) error {
+	if err := h.validator.Struct(webhook); err != nil {
+		return err
+	}
+	if _, err := parseWebhookTags(webhook.Tags); err != nil {
+		return err
+	}
+	if !h.AllowPrivateWebhooks {
+		return checkWebhookURL(webhook.URL)
+	}
+	return nil
+}
+
+// checkWebhookURL rejects the URLs whose host is a non-public IP address or a localhost name.
+// Other host names are resolved when deliveries are sent, and their addresses checked then by
+// the dispatcher's client, since they may resolve differently by that time.
+func checkWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressNotAllowed, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressNotAllowed, host)
+	}
+	return nil
+}
+
+// isPublicIP reports whether webhooks may be delivered to an IP address: loopback, private,
+// link-local (including cloud metadata services), unspecified and multicast addresses are
+// reserved to the local network.
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		// 0.0.0.0/8 reaches the local host on most systems
+		return false
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// Helper function to parse the 'status', 'after' and 'limit' parameters of a delivery list.
+func parseWebhookDeliveryFilter(r *http.Request) (WebhookDeliveryFilter, error) {
+	filter := WebhookDeliveryFilter{Limit: defaultListLimit}
+	params := r.URL.Query()
+
+	switch status := params.Get("status"); status {
+	case "", DeliveryPending, DeliveryDelivered, DeliveryDead:
+		filter.Status = status
+	default:
+		return filter, errors.New("Invalid 'status' parameter")
+	}
+
+	var err error
+	if after := params.Get("after"); after != "" {
+		filter.AfterID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return filter, errors.New("Invalid 'after' parameter")
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxListLimit {
+			return filter, errors.New("Invalid 'limit' parameter")
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,203 @@
+// Package client is a Go client for the Sensor Metadata API. It shares its model types with
+// the app package and reports the same errors as the server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// RetryPolicy controls how requests are retried while the API responds 503 Service Unavailable.
+// The backoff doubles after every attempt, up to MaxBackoff, with up to half of it as jitter.
+// A Retry-After header overrides the backoff.
+type RetryPolicy struct {
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of clients created without WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// Client calls the Sensor Metadata API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	auth       Authenticator
+	retry      RetryPolicy
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithHTTPClient sets the HTTP client that sends requests. It defaults to http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAuth sets the authenticator that adds credentials to requests.
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithRetryPolicy sets the retry policy; a zero RetryPolicy disables retries.
+func WithRetryPolicy(retry RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = retry
+	}
+}
+
+// New creates a client for the API at baseURL, e.g. https://sensors.example.com.
+func New(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// request describes an API request. Query is encoded as is; attribute filters, whose
+// operators url.Values would escape, are appended from RawQuery.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	rawQuery    []string
+	body        []byte
+	contentType string
+	accept      string
+	errors      resourceErrors
+}
+
+// do sends a request, retrying while the API is unavailable, and returns the response if
+// its status is 2xx. Otherwise it returns an *Error, along with the response for callers
+// that read a body from error responses; its body is then already read into the *Error.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	target := c.baseURL + app.APIVersionPrefix + req.path
+	var query []string
+	if encoded := req.query.Encode(); encoded != "" {
+		query = append(query, encoded)
+	}
+	query = append(query, req.rawQuery...)
+	if len(query) > 0 {
+		target += "?" + strings.Join(query, "&")
+	}
+
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, target, bytes.NewReader(req.body))
+		if err != nil {
+			return nil, err
+		}
+		if req.body == nil {
+			httpReq.Body, httpReq.ContentLength = http.NoBody, 0
+		}
+		if req.contentType != "" {
+			httpReq.Header.Set("Content-Type", req.contentType)
+		}
+		if req.accept != "" {
+			httpReq.Header.Set("Accept", req.accept)
+		}
+		if c.auth != nil {
+			if err := c.auth.Authenticate(httpReq); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		if resp.StatusCode == http.StatusServiceUnavailable && attempt < c.retry.MaxRetries {
+			wait := c.backoff(attempt, resp.Header.Get("Retry-After"))
+			resp.Body.Close()
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		// Keep the body for callers that decode error responses other than ErrorResponse
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		var errorResponse app.ErrorResponse
+		_ = json.Unmarshal(body, &errorResponse)
+		return resp, newError(resp.StatusCode, errorResponse, req.errors)
+	}
+}
+
+// backoff returns how long to wait before retrying after the given attempt.
+func (c *Client) backoff(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	wait := c.retry.MinBackoff << attempt
+	if wait > c.retry.MaxBackoff || wait <= 0 {
+		wait = c.retry.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// doJSON sends in, if not nil, as a JSON body and decodes a JSON response into out, if not nil.
+func (c *Client) doJSON(ctx context.Context, req request, in, out interface{}) error {
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		req.body, req.contentType = data, "application/json"
+	}
+	req.accept = "application/json"
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
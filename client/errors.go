@@ -0,0 +1,66 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// ErrInvalidRequest is wrapped by the errors of requests the API rejected as invalid.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ErrUnavailable is wrapped by the errors of requests that still found the API unavailable
+// after every retry.
+var ErrUnavailable = errors.New("service unavailable")
+
+// Error represents an error response from the API. It wraps the error the server reported,
+// so that errors.Is(err, app.ErrSensorNotFound) works as it does on the server; Fields
+// details the failures of a request that didn't pass validation.
+type Error struct {
+	StatusCode int
+	Message    string
+	Fields     []app.FieldError
+
+	err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Unwrap returns the server error that the status code stands for, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// resourceErrors are the errors a status code stands for, which depend on the resource requested.
+type resourceErrors struct {
+	notFound error
+	conflict error
+}
+
+var (
+	sensorErrors     = resourceErrors{notFound: app.ErrSensorNotFound, conflict: app.ErrSensorExists}
+	sensorTypeErrors = resourceErrors{notFound: app.ErrSensorTypeNotFound, conflict: app.ErrSensorTypeExists}
+)
+
+// newError converts an error response into an *Error wrapping the matching server error.
+func newError(statusCode int, response app.ErrorResponse, errs resourceErrors) *Error {
+	err := &Error{StatusCode: statusCode, Message: response.Message, Fields: response.Fields}
+	if err.Message == "" {
+		err.Message = http.StatusText(statusCode)
+	}
+	switch statusCode {
+	case http.StatusBadRequest:
+		err.err = ErrInvalidRequest
+	case http.StatusNotFound:
+		err.err = errs.notFound
+	case http.StatusConflict:
+		err.err = errs.conflict
+	case http.StatusServiceUnavailable:
+		err.err = ErrUnavailable
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// SensorIterator iterates over the sensors of a paged list:
+//
+//	it := c.Sensors(ctx, client.ListOptions{Tags: "vendor:acme"})
+//	for it.Next() {
+//		sensor := it.Sensor()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SensorIterator struct {
+	options ListOptions
+	fetch   func(options ListOptions) (*app.SensorListV1, error)
+
+	page []app.SensorMetadataV1
+	next int
+	done bool
+	err  error
+}
+
+func newSensorIterator(options ListOptions, fetch func(options ListOptions) (*app.SensorListV1, error)) *SensorIterator {
+	return &SensorIterator{options: options, fetch: fetch, next: -1}
+}
+
+// Next advances to the next sensor, fetching the next page when needed. It returns false
+// after the last sensor or on an error, which Err then returns.
+func (it *SensorIterator) Next() bool {
+	for it.next+1 >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		sensorList, err := it.fetch(it.options)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.next = sensorList.Sensors, -1
+		if sensorList.Next == "" {
+			it.done = true
+		} else if it.options.After, err = strconv.Atoi(sensorList.Next); err != nil {
+			it.err = fmt.Errorf("invalid cursor %q", sensorList.Next)
+			return false
+		}
+	}
+
+	it.next++
+	return true
+}
+
+// Sensor returns the current sensor.
+func (it *SensorIterator) Sensor() app.SensorMetadataV1 {
+	return it.page[it.next]
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *SensorIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,316 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// ListOptions filters and pages sensor lists.
+type ListOptions struct {
+	// Tags is a tag filter expression, e.g. "vendor:acme AND NOT retired".
+	Tags string
+	// Attributes are attribute filters, e.g. "sampling_rate>=10" or `model="x1"`.
+	Attributes []string
+	// After is the cursor of the previous page; Limit is the page size, or the server's default when zero.
+	After int
+	Limit int
+}
+
+// query encodes the options as query parameters and raw attribute filters.
+func (o ListOptions) query() (url.Values, []string) {
+	query := url.Values{}
+	if o.Tags != "" {
+		query.Set("tags", o.Tags)
+	}
+	if o.After > 0 {
+		query.Set("after", strconv.Itoa(o.After))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	rawQuery := make([]string, len(o.Attributes))
+	for i, filter := range o.Attributes {
+		rawQuery[i] = url.QueryEscape("attr." + filter)
+	}
+	return query, rawQuery
+}
+
+// WithinArea is the area of a within query: a bounding box, or a GeoJSON Polygon, MultiPolygon,
+// Feature or FeatureCollection.
+type WithinArea struct {
+	BoundingBox *app.BoundingBox
+	Geometry    json.RawMessage
+}
+
+// NearestOptions describes a nearest query. Use3D ranks by 3D distance from Altitude;
+// PreferSameFloor ranks sensors on Floor ahead of all others.
+type NearestOptions struct {
+	Latitude        float64
+	Longitude       float64
+	Tags            string
+	Altitude        *float64
+	AltitudeDatum   string
+	Use3D           bool
+	Floor           *int
+	PreferSameFloor bool
+}
+
+// ImportOptions controls an import. Format is app.FormatCSV or app.FormatGeoJSON.
+type ImportOptions struct {
+	Format string
+	DryRun bool
+}
+
+func sensorPath(name string) string {
+	return "/sensors/" + url.PathEscape(name)
+}
+
+// CreateSensor creates a sensor. It returns an error wrapping app.ErrSensorExists if the name is taken.
+func (c *Client) CreateSensor(ctx context.Context, sensorMetadata *app.SensorMetadataV1) error {
+	return c.doJSON(ctx, request{method: http.MethodPost, path: "/sensors", errors: sensorErrors}, sensorMetadata, nil)
+}
+
+// GetSensor returns the sensor with the given name, or an error wrapping app.ErrSensorNotFound.
+func (c *Client) GetSensor(ctx context.Context, name string) (*app.SensorMetadataV1, error) {
+	var sensorMetadata app.SensorMetadataV1
+	req := request{method: http.MethodGet, path: "/sensors", query: url.Values{"name": {name}}, errors: sensorErrors}
+	if err := c.doJSON(ctx, req, nil, &sensorMetadata); err != nil {
+		return nil, err
+	}
+	return &sensorMetadata, nil
+}
+
+// UpdateSensor replaces the sensor with the given name by sensorMetadata, which may rename it.
+func (c *Client) UpdateSensor(ctx context.Context, name string, sensorMetadata *app.SensorMetadataV1) error {
+	return c.doJSON(ctx, request{method: http.MethodPut, path: sensorPath(name), errors: sensorErrors}, sensorMetadata, nil)
+}
+
+// DeleteSensor deletes the sensor with the given name.
+func (c *Client) DeleteSensor(ctx context.Context, name string) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: sensorPath(name), errors: sensorErrors}, nil, nil)
+}
+
+// ListSensors returns a page of sensors. Use Sensors to iterate over every page.
+func (c *Client) ListSensors(ctx context.Context, options ListOptions) (*app.SensorListV1, error) {
+	query, rawQuery := options.query()
+	var sensorList app.SensorListV1
+	req := request{method: http.MethodGet, path: "/sensors", query: query, rawQuery: rawQuery, errors: sensorErrors}
+	if err := c.doJSON(ctx, req, nil, &sensorList); err != nil {
+		return nil, err
+	}
+	return &sensorList, nil
+}
+
+// Sensors iterates over every sensor matching the options, fetching pages as needed.
+func (c *Client) Sensors(ctx context.Context, options ListOptions) *SensorIterator {
+	return newSensorIterator(options, func(options ListOptions) (*app.SensorListV1, error) {
+		return c.ListSensors(ctx, options)
+	})
+}
+
+// FindSensorsWithin returns a page of the sensors inside an area. Use SensorsWithin to iterate over every page.
+func (c *Client) FindSensorsWithin(ctx context.Context, area WithinArea, options ListOptions) (*app.SensorListV1, error) {
+	query, rawQuery := options.query()
+	req := request{path: "/sensors/within", query: query, rawQuery: rawQuery, errors: sensorErrors}
+	var body interface{}
+	switch {
+	case area.BoundingBox != nil:
+		box := area.BoundingBox
+		req.method = http.MethodGet
+		req.query.Set("bbox", fmt.Sprintf("%s,%s,%s,%s", formatFloat(box.MinLon), formatFloat(box.MinLat), formatFloat(box.MaxLon), formatFloat(box.MaxLat)))
+	case area.Geometry != nil:
+		req.method, body = http.MethodPost, area.Geometry
+	default:
+		return nil, errors.New("within query needs a bounding box or a geometry")
+	}
+
+	var sensorList app.SensorListV1
+	if err := c.doJSON(ctx, req, body, &sensorList); err != nil {
+		return nil, err
+	}
+	return &sensorList, nil
+}
+
+// SensorsWithin iterates over every sensor inside an area, fetching pages as needed.
+func (c *Client) SensorsWithin(ctx context.Context, area WithinArea, options ListOptions) *SensorIterator {
+	return newSensorIterator(options, func(options ListOptions) (*app.SensorListV1, error) {
+		return c.FindSensorsWithin(ctx, area, options)
+	})
+}
+
+// NearestSensor returns the sensor nearest to a location, with its distance in meters.
+// It returns an error wrapping app.ErrSensorNotFound if no sensor matches.
+func (c *Client) NearestSensor(ctx context.Context, options NearestOptions) (*app.NearestSensorV1, error) {
+	query := url.Values{"latitude": {formatFloat(options.Latitude)}, "longitude": {formatFloat(options.Longitude)}}
+	if options.Tags != "" {
+		query.Set("tags", options.Tags)
+	}
+	if options.Altitude != nil {
+		query.Set("altitude", formatFloat(*options.Altitude))
+	}
+	if options.AltitudeDatum != "" {
+		query.Set("altitude_datum", options.AltitudeDatum)
+	}
+	if options.Use3D {
+		query.Set("distance", "3d")
+	}
+	if options.Floor != nil {
+		query.Set("floor", strconv.Itoa(*options.Floor))
+	}
+	if options.PreferSameFloor {
+		query.Set("prefer_same_floor", "true")
+	}
+
+	var sensor app.NearestSensorV1
+	req := request{method: http.MethodGet, path: "/sensors/nearest", query: query, errors: sensorErrors}
+	if err := c.doJSON(ctx, req, nil, &sensor); err != nil {
+		return nil, err
+	}
+	return &sensor, nil
+}
+
+// Batch applies a batch of operations. The results are returned along with the error of an
+// atomic batch that failed, so callers can tell which operation caused the failure.
+func (c *Client) Batch(ctx context.Context, batch app.BatchRequest) (*app.BatchResponse, error) {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	req := request{method: http.MethodPost, path: "/sensors:batch", body: data, contentType: "application/json", accept: "application/json", errors: sensorErrors}
+	resp, err := c.do(ctx, req)
+	if resp == nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response app.BatchResponse
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&response); decodeErr != nil || response.Results == nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid response: %v", decodeErr)
+	}
+	return &response, err
+}
+
+// Import upserts the sensors of a CSV or GeoJSON file. Rows the server rejected are listed
+// in the result rather than returned as an error.
+func (c *Client) Import(ctx context.Context, r io.Reader, options ImportOptions) (*app.ImportResult, error) {
+	contentType := "text/csv"
+	switch options.Format {
+	case "", app.FormatCSV:
+	case app.FormatGeoJSON:
+		contentType = app.GeoJSONMediaType
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", options.Format)
+	}
+
+	// Read the file up front so that the request can be retried
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+
+	req := request{method: http.MethodPost, path: "/sensors/import", query: query, body: data, contentType: contentType, accept: "application/json", errors: sensorErrors}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result app.ImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &result, nil
+}
+
+// Export streams every sensor matching the options as app.FormatCSV or app.FormatGeoJSON.
+// The caller must close the returned reader; reading fails if the export is cut short.
+func (c *Client) Export(ctx context.Context, format string, options ListOptions) (io.ReadCloser, error) {
+	query, rawQuery := options.query()
+	query.Set("format", format)
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/sensors/export", query: query, rawQuery: rawQuery, errors: sensorErrors})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// AddSensorTags adds tags to a sensor and returns the updated sensor.
+func (c *Client) AddSensorTags(ctx context.Context, name string, tags []string) (*app.SensorMetadataV1, error) {
+	var sensorMetadata app.SensorMetadataV1
+	req := request{method: http.MethodPost, path: sensorPath(name) + "/tags", errors: sensorErrors}
+	if err := c.doJSON(ctx, req, app.TagsRequest{Tags: tags}, &sensorMetadata); err != nil {
+		return nil, err
+	}
+	return &sensorMetadata, nil
+}
+
+// RemoveSensorTag removes a tag from a sensor and returns the updated sensor.
+func (c *Client) RemoveSensorTag(ctx context.Context, name, tag string) (*app.SensorMetadataV1, error) {
+	var sensorMetadata app.SensorMetadataV1
+	req := request{method: http.MethodDelete, path: sensorPath(name) + "/tags/" + url.PathEscape(tag), errors: sensorErrors}
+	if err := c.doJSON(ctx, req, nil, &sensorMetadata); err != nil {
+		return nil, err
+	}
+	return &sensorMetadata, nil
+}
+
+// Tags lists the distinct tags with the number of sensors using each.
+func (c *Client) Tags(ctx context.Context) ([]app.TagCount, error) {
+	var tagCounts []app.TagCount
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: "/tags"}, nil, &tagCounts); err != nil {
+		return nil, err
+	}
+	return tagCounts, nil
+}
+
+func sensorTypePath(name string) string {
+	return "/sensor-types/" + url.PathEscape(name)
+}
+
+// CreateSensorType creates a sensor type. It returns an error wrapping app.ErrSensorTypeExists if the name is taken.
+func (c *Client) CreateSensorType(ctx context.Context, sensorType *app.SensorType) error {
+	return c.doJSON(ctx, request{method: http.MethodPost, path: "/sensor-types", errors: sensorTypeErrors}, sensorType, nil)
+}
+
+// GetSensorType returns the sensor type with the given name, or an error wrapping app.ErrSensorTypeNotFound.
+func (c *Client) GetSensorType(ctx context.Context, name string) (*app.SensorType, error) {
+	var sensorType app.SensorType
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: sensorTypePath(name), errors: sensorTypeErrors}, nil, &sensorType); err != nil {
+		return nil, err
+	}
+	return &sensorType, nil
+}
+
+// ListSensorTypes lists every sensor type.
+func (c *Client) ListSensorTypes(ctx context.Context) ([]app.SensorType, error) {
+	var sensorTypes []app.SensorType
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: "/sensor-types", errors: sensorTypeErrors}, nil, &sensorTypes); err != nil {
+		return nil, err
+	}
+	return sensorTypes, nil
+}
+
+// UpdateSensorType replaces the description and schema of a sensor type.
+func (c *Client) UpdateSensorType(ctx context.Context, sensorType *app.SensorType) error {
+	return c.doJSON(ctx, request{method: http.MethodPut, path: sensorTypePath(sensorType.Name), errors: sensorTypeErrors}, sensorType, nil)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
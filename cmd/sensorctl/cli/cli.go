@@ -0,0 +1,227 @@
+// Package cli implements sensorctl, a command-line client for the Sensor Metadata API.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/skartikey/sensor-metadata/client"
+)
+
+// Exit codes of sensorctl. Scripts can tell a missing sensor from a failing server.
+const (
+	ExitOK          = 0
+	ExitError       = 1 // The request was rejected, e.g. invalid input, or failed locally
+	ExitUsage       = 2
+	ExitNotFound    = 3
+	ExitConflict    = 4
+	ExitServerError = 5 // The server failed or could not be reached
+)
+
+// Output formats.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// command represents a sensorctl subcommand.
+type command struct {
+	usage   string
+	summary string
+	run     func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"get":     {"get NAME", "Show a sensor", runGet},
+	"list":    {"list [--tags EXPR] [--attr FILTER]... [--limit N] [--after ID] [--all]", "List sensors", runList},
+	"create":  {"create (--file FILE | --name NAME --latitude LAT --longitude LON) [--tags TAGS] [--type TYPE]", "Create a sensor", runCreate},
+	"update":  {"update NAME [--file FILE] [--name NAME] [--latitude LAT] [--longitude LON] [--tags TAGS] [--type TYPE]", "Change a sensor", runUpdate},
+	"delete":  {"delete NAME", "Delete a sensor", runDelete},
+	"nearest": {"nearest --latitude LAT --longitude LON [--tags EXPR] [--altitude ALT --distance 3d] [--floor N --prefer-same-floor]", "Find the sensor nearest to a location", runNearest},
+	"import":  {"import FILE [--format csv|geojson] [--dry-run]", "Upsert sensors from a CSV or GeoJSON file", runImport},
+	"export":  {"export [--format csv|geojson] [--file FILE] [--tags EXPR] [--attr FILTER]...", "Download sensors as CSV or GeoJSON", runExport},
+}
+
+// env carries the streams, the common flags and the API client of a command.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	configFile string
+	url        string
+	token      string
+	output     string
+
+	ctx context.Context
+	api *client.Client
+}
+
+// usageError is returned for invalid command lines.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// Run runs sensorctl with the given arguments, without the program name, and returns its exit code.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return ExitUsage
+		}
+		return ExitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "sensorctl: unknown command %q\n", args[0])
+		printUsage(stderr)
+		return ExitUsage
+	}
+
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	err := cmd.run(e, args[1:])
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	fmt.Fprintf(stderr, "sensorctl: %v\n", err)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		for _, field := range apiErr.Fields {
+			fmt.Fprintf(stderr, "  %s: %s\n", field.Field, field.Message)
+		}
+	}
+
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(stderr, "usage: sensorctl %s\n", cmd.usage)
+		return ExitUsage
+	}
+	return exitCode(err)
+}
+
+// exitCode maps the error of a command to an exit code.
+func exitCode(err error) int {
+	var apiErr *client.Error
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, app.ErrSensorNotFound), errors.Is(err, app.ErrSensorTypeNotFound):
+		return ExitNotFound
+	case errors.Is(err, app.ErrSensorExists), errors.Is(err, app.ErrSensorTypeExists):
+		return ExitConflict
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode >= 500 {
+			return ExitServerError
+		}
+		return ExitError
+	case errors.As(err, &urlErr):
+		// The server could not be reached
+		return ExitServerError
+	default:
+		return ExitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: sensorctl COMMAND [ARGS] [--config FILE] [--url URL] [--token TOKEN] [--output table|json|yaml]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+}
+
+// flags returns a flag set for a command with the common flags registered.
+func (e *env) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.StringVar(&e.configFile, "config", "", "config file (default $SENSORCTL_CONFIG or sensorctl/config.yaml in the user config directory)")
+	fs.StringVar(&e.url, "url", "", "base URL of the API, overriding the config file and $SENSORCTL_URL")
+	fs.StringVar(&e.token, "token", "", "bearer token, overriding the config file and $SENSORCTL_TOKEN")
+	fs.StringVar(&e.output, "output", OutputTable, "output format: table, json or yaml")
+	fs.StringVar(&e.output, "o", OutputTable, "shorthand for --output")
+	return fs
+}
+
+// parse parses flags, which may come before or after the positional arguments, checks the
+// number of positional arguments and sets up the API client.
+func (e *env) parse(fs *flag.FlagSet, args []string, positional ...string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, &usageError{message: err.Error()}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	if len(rest) != len(positional) {
+		if len(positional) == 0 {
+			return nil, usagef("%s takes no arguments", fs.Name())
+		}
+		return nil, usagef("%s takes %s", fs.Name(), strings.Join(positional, " "))
+	}
+
+	switch e.output {
+	case OutputTable, OutputJSON, OutputYAML:
+	default:
+		return nil, usagef("invalid output format %q", e.output)
+	}
+
+	config, err := loadConfig(e.configFile)
+	if err != nil {
+		return nil, err
+	}
+	if e.url != "" {
+		config.URL = e.url
+	}
+	if e.token != "" {
+		config.Token = e.token
+	}
+	var options []client.Option
+	options = append(options, client.WithHTTPClient(&http.Client{Timeout: 5 * time.Minute}))
+	switch {
+	case config.Token != "":
+		options = append(options, client.WithAuth(client.BearerToken(config.Token)))
+	case config.Username != "":
+		options = append(options, client.WithAuth(client.BasicAuth(config.Username, config.Password)))
+	}
+	if e.api, err = client.New(config.URL, options...); err != nil {
+		return nil, err
+	}
+	e.ctx = context.Background()
+
+	return rest, nil
+}
@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/skartikey/sensor-metadata/client"
+)
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func runGet(e *env, args []string) error {
+	fs := e.flags("get")
+	args, err := e.parse(fs, args, "NAME")
+	if err != nil {
+		return err
+	}
+
+	sensorMetadata, err := e.api.GetSensor(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return e.print(sensorMetadata, func(w *tabwriter.Writer) {
+		printSensorDetails(w, *sensorMetadata)
+	})
+}
+
+func runList(e *env, args []string) error {
+	fs := e.flags("list")
+	tags := fs.String("tags", "", "tag filter expression, e.g. 'vendor:acme AND NOT retired'")
+	var filters stringList
+	fs.Var(&filters, "attr", "attribute filter such as 'model=x1' or 'sampling_rate>=10'; may be repeated")
+	limit := fs.Int("limit", 0, "page size (default the server's)")
+	after := fs.Int("after", 0, "list sensors after this ID")
+	all := fs.Bool("all", false, "follow the cursor and list every page")
+	if _, err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	options := client.ListOptions{Tags: *tags, Attributes: filters, After: *after, Limit: *limit}
+	var sensorList app.SensorListV1
+	if *all {
+		sensorList.Sensors = []app.SensorMetadataV1{}
+		it := e.api.Sensors(e.ctx, options)
+		for it.Next() {
+			sensorList.Sensors = append(sensorList.Sensors, it.Sensor())
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+	} else {
+		page, err := e.api.ListSensors(e.ctx, options)
+		if err != nil {
+			return err
+		}
+		sensorList = *page
+	}
+
+	if err := e.print(sensorList, func(w *tabwriter.Writer) {
+		printSensorTable(w, sensorList.Sensors)
+	}); err != nil {
+		return err
+	}
+	if e.output == OutputTable && sensorList.Next != "" {
+		fmt.Fprintf(e.stderr, "More sensors follow; list them with --after %s, or use --all\n", sensorList.Next)
+	}
+	return nil
+}
+
+// sensorFlags are the flags that describe a sensor to create or update.
+type sensorFlags struct {
+	fs         *flag.FlagSet
+	file       string
+	name       string
+	sensorType string
+	tags       string
+	latitude   float64
+	longitude  float64
+}
+
+func addSensorFlags(fs *flag.FlagSet) *sensorFlags {
+	flags := &sensorFlags{fs: fs}
+	fs.StringVar(&flags.file, "file", "", "JSON file with the sensor, or - for standard input")
+	fs.StringVar(&flags.name, "name", "", "sensor name")
+	fs.StringVar(&flags.sensorType, "type", "", "sensor type")
+	fs.StringVar(&flags.tags, "tags", "", "comma-separated tags, replacing any existing ones")
+	fs.Float64Var(&flags.latitude, "latitude", 0, "latitude in degrees")
+	fs.Float64Var(&flags.longitude, "longitude", 0, "longitude in degrees")
+	return flags
+}
+
+// apply reads the file, if any, over the sensor and then applies the flags that were given.
+func (f *sensorFlags) apply(e *env, sensorMetadata *app.SensorMetadataV1) error {
+	if f.file != "" {
+		data, err := readInput(e, f.file)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, sensorMetadata); err != nil {
+			return fmt.Errorf("invalid sensor in %s: %v", f.file, err)
+		}
+	}
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "name":
+			sensorMetadata.Name = f.name
+		case "type":
+			sensorMetadata.Type = f.sensorType
+		case "tags":
+			sensorMetadata.Tags = nil
+			for _, tag := range strings.Split(f.tags, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					sensorMetadata.Tags = append(sensorMetadata.Tags, tag)
+				}
+			}
+		case "latitude":
+			sensorMetadata.Location.Latitude = f.latitude
+		case "longitude":
+			sensorMetadata.Location.Longitude = f.longitude
+		}
+	})
+
+	// Structured tags are merged into the tags by the API, so they would undo removed tags
+	sensorMetadata.StructuredTags = nil
+	return nil
+}
+
+func runCreate(e *env, args []string) error {
+	fs := e.flags("create")
+	flags := addSensorFlags(fs)
+	if _, err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	var sensorMetadata app.SensorMetadataV1
+	if err := flags.apply(e, &sensorMetadata); err != nil {
+		return err
+	}
+	if err := e.api.CreateSensor(e.ctx, &sensorMetadata); err != nil {
+		return err
+	}
+
+	return printSensor(e, sensorMetadata.Name)
+}
+
+func runUpdate(e *env, args []string) error {
+	fs := e.flags("update")
+	flags := addSensorFlags(fs)
+	args, err := e.parse(fs, args, "NAME")
+	if err != nil {
+		return err
+	}
+
+	sensorMetadata, err := e.api.GetSensor(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	id := sensorMetadata.ID
+	if err := flags.apply(e, sensorMetadata); err != nil {
+		return err
+	}
+	sensorMetadata.ID = id
+	if err := e.api.UpdateSensor(e.ctx, args[0], sensorMetadata); err != nil {
+		return err
+	}
+
+	return printSensor(e, sensorMetadata.Name)
+}
+
+// printSensor fetches a sensor that was just saved and prints it.
+func printSensor(e *env, name string) error {
+	sensorMetadata, err := e.api.GetSensor(e.ctx, name)
+	if err != nil {
+		return err
+	}
+	return e.print(sensorMetadata, func(w *tabwriter.Writer) {
+		printSensorDetails(w, *sensorMetadata)
+	})
+}
+
+func runDelete(e *env, args []string) error {
+	fs := e.flags("delete")
+	args, err := e.parse(fs, args, "NAME")
+	if err != nil {
+		return err
+	}
+
+	if err := e.api.DeleteSensor(e.ctx, args[0]); err != nil {
+		return err
+	}
+	if e.output == OutputTable {
+		fmt.Fprintf(e.stdout, "Deleted sensor %q\n", args[0])
+	}
+	return nil
+}
+
+func runNearest(e *env, args []string) error {
+	fs := e.flags("nearest")
+	var options client.NearestOptions
+	var altitude float64
+	var floor int
+	fs.Float64Var(&options.Latitude, "latitude", 0, "latitude in degrees")
+	fs.Float64Var(&options.Longitude, "longitude", 0, "longitude in degrees")
+	fs.StringVar(&options.Tags, "tags", "", "tag filter expression")
+	fs.Float64Var(&altitude, "altitude", 0, "altitude in meters")
+	distance := fs.String("distance", "", "set to 3d to rank by 3D distance using --altitude")
+	fs.IntVar(&floor, "floor", 0, "floor of the location")
+	fs.BoolVar(&options.PreferSameFloor, "prefer-same-floor", false, "rank sensors on --floor first")
+	if _, err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+	if !set["latitude"] || !set["longitude"] {
+		return usagef("nearest needs --latitude and --longitude")
+	}
+	if set["altitude"] {
+		options.Altitude = &altitude
+	}
+	if set["floor"] {
+		options.Floor = &floor
+	}
+	switch *distance {
+	case "", "2d":
+	case "3d":
+		options.Use3D = true
+	default:
+		return usagef("invalid distance %q, expected 2d or 3d", *distance)
+	}
+
+	sensor, err := e.api.NearestSensor(e.ctx, options)
+	if err != nil {
+		return err
+	}
+	return e.print(sensor, func(w *tabwriter.Writer) {
+		printNearestSensorDetails(w, *sensor)
+	})
+}
+
+func runImport(e *env, args []string) error {
+	fs := e.flags("import")
+	format := fs.String("format", "", "file format: csv or geojson (default from the file extension)")
+	dryRun := fs.Bool("dry-run", false, "validate the file without saving anything")
+	args, err := e.parse(fs, args, "FILE")
+	if err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = app.FormatCSV
+		if ext := strings.ToLower(filepath.Ext(args[0])); ext == ".geojson" || ext == ".json" {
+			*format = app.FormatGeoJSON
+		}
+	}
+	if *format != app.FormatCSV && *format != app.FormatGeoJSON {
+		return usagef("invalid import format %q", *format)
+	}
+
+	data, err := readInput(e, args[0])
+	if err != nil {
+		return err
+	}
+	result, err := e.api.Import(e.ctx, bytes.NewReader(data), client.ImportOptions{Format: *format, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	if err := e.print(result, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Rows:\t%d\nValid:\t%d\nImported:\t%d\n", result.Rows, result.Valid, result.Imported)
+		if len(result.Errors) > 0 {
+			fmt.Fprintln(w, "\nROW\tNAME\tERROR")
+			for _, rowErr := range result.Errors {
+				row := "line " + strconv.Itoa(rowErr.Line)
+				if rowErr.Feature != nil {
+					row = "feature " + strconv.Itoa(*rowErr.Feature)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", row, orDash(rowErr.Name), rowErr.Error)
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d of %d rows rejected", len(result.Errors), result.Rows)
+	}
+	return nil
+}
+
+func runExport(e *env, args []string) error {
+	fs := e.flags("export")
+	format := fs.String("format", app.FormatCSV, "file format: csv or geojson")
+	file := fs.String("file", "", "file to write (default standard output)")
+	tags := fs.String("tags", "", "tag filter expression")
+	var filters stringList
+	fs.Var(&filters, "attr", "attribute filter; may be repeated")
+	if _, err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	export, err := e.api.Export(e.ctx, *format, client.ListOptions{Tags: *tags, Attributes: filters})
+	if err != nil {
+		return err
+	}
+	defer export.Close()
+
+	if *file == "" {
+		if _, err := io.Copy(e.stdout, export); err != nil {
+			return fmt.Errorf("export interrupted: %v", err)
+		}
+		return nil
+	}
+
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, export); err != nil {
+		f.Close()
+		return fmt.Errorf("export interrupted: %v", err)
+	}
+	return f.Close()
+}
+
+// readInput reads a file, or standard input for "-".
+func readInput(e *env, file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(e.stdin)
+	}
+	return os.ReadFile(file)
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultURL is the base URL of the API unless the config file, environment or flags say otherwise.
+const DefaultURL = "http://localhost:8080"
+
+// Config represents the sensorctl config file.
+type Config struct {
+	// URL is the base URL of the API.
+	URL string `yaml:"url"`
+	// Token is sent as a bearer token; Username and Password are sent with basic authentication.
+	Token    string `yaml:"token,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// configPath returns the config file to read: the given path, then $SENSORCTL_CONFIG,
+// then sensorctl/config.yaml in the user's config directory.
+func configPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if path := os.Getenv("SENSORCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sensorctl", "config.yaml")
+}
+
+// loadConfig reads the config file, then applies $SENSORCTL_URL and $SENSORCTL_TOKEN over it.
+// A missing config file is only an error when its path was given explicitly.
+func loadConfig(path string) (Config, error) {
+	config := Config{URL: DefaultURL}
+
+	file := configPath(path)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(data, &config); err != nil {
+				return config, fmt.Errorf("invalid config file %s: %v", file, err)
+			}
+		case !os.IsNotExist(err) || path != "":
+			return config, err
+		}
+	}
+
+	if url := os.Getenv("SENSORCTL_URL"); url != "" {
+		config.URL = url
+	}
+	if token := os.Getenv("SENSORCTL_TOKEN"); token != "" {
+		config.Token = token
+	}
+	return config, nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"gopkg.in/yaml.v3"
+)
+
+// print writes value as JSON or YAML, or calls table to write it as a table.
+func (e *env) print(value interface{}, table func(w *tabwriter.Writer)) error {
+	switch e.output {
+	case OutputJSON:
+		encoder := json.NewEncoder(e.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case OutputYAML:
+		// Round trip through JSON so YAML keys match the API's field names
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(e.stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	}
+}
+
+// printSensorTable writes sensors as a table with a row per sensor.
+func printSensorTable(w *tabwriter.Writer, sensors []app.SensorMetadataV1) {
+	fmt.Fprintln(w, "ID\tNAME\tLATITUDE\tLONGITUDE\tTYPE\tTAGS")
+	for _, sensorMetadata := range sensors {
+		location := sensorMetadata.Location
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", sensorMetadata.ID, sensorMetadata.Name,
+			formatFloat(location.Latitude), formatFloat(location.Longitude),
+			orDash(sensorMetadata.Type), orDash(strings.Join(sensorMetadata.Tags, ",")))
+	}
+}
+
+// printSensorDetails writes a single sensor as a table of fields.
+func printSensorDetails(w *tabwriter.Writer, sensorMetadata app.SensorMetadataV1) {
+	location := sensorMetadata.Location
+	fmt.Fprintf(w, "ID:\t%d\n", sensorMetadata.ID)
+	fmt.Fprintf(w, "Name:\t%s\n", sensorMetadata.Name)
+	fmt.Fprintf(w, "Type:\t%s\n", orDash(sensorMetadata.Type))
+	fmt.Fprintf(w, "Latitude:\t%s\n", formatFloat(location.Latitude))
+	fmt.Fprintf(w, "Longitude:\t%s\n", formatFloat(location.Longitude))
+	if location.Altitude != nil {
+		fmt.Fprintf(w, "Altitude:\t%s m (%s)\n", formatFloat(*location.Altitude), location.AltitudeDatum)
+	}
+	if location.Floor != nil {
+		fmt.Fprintf(w, "Floor:\t%d\n", *location.Floor)
+	}
+	if location.AccuracyM != nil {
+		fmt.Fprintf(w, "Accuracy:\t%s m\n", formatFloat(*location.AccuracyM))
+	}
+	fmt.Fprintf(w, "Tags:\t%s\n", orDash(strings.Join(sensorMetadata.Tags, ", ")))
+	if len(sensorMetadata.Attributes) > 0 {
+		attributes, _ := json.Marshal(sensorMetadata.Attributes)
+		fmt.Fprintf(w, "Attributes:\t%s\n", attributes)
+	}
+}
+
+// printNearestSensorDetails writes the sensor nearest to a location as a table of fields, with its distance.
+func printNearestSensorDetails(w *tabwriter.Writer, sensor app.NearestSensorV1) {
+	printSensorDetails(w, sensor.SensorMetadataV1)
+	fmt.Fprintf(w, "Distance:\t%s m\n", strconv.FormatFloat(sensor.Distance, 'f', 1, 64))
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
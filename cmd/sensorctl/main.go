@@ -0,0 +1,12 @@
+// Command sensorctl manages sensor metadata through the Sensor Metadata API.
+package main
+
+import (
+	"os"
+
+	"github.com/skartikey/sensor-metadata/cmd/sensorctl/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
-
-	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 
 	"github.com/skartikey/sensor-metadata/app"
 )
@@ -17,25 +23,101 @@ func main() {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
-	// Create a new instance of the PostgresRepository
-	repo, err := app.NewPostgresRepository()
+	// Create the repository for the configured backend
+	repo, err := app.NewRepository()
 	if err != nil {
-		log.Fatal("Error creating Postgres repository:", err)
+		log.Fatal("Error creating repository:", err)
+	}
+
+	// Answer spatial queries from an in-memory index when enabled
+	var spatialIndex *app.SpatialIndexRepository
+	if os.Getenv("SPATIAL_INDEX") == "true" {
+		spatialIndex, err = app.NewSpatialIndexRepository(repo)
+		if err != nil {
+			log.Fatal("Error creating spatial index:", err)
+		}
+		repo = spatialIndex
 	}
 
-	// Create a new router
-	router := mux.NewRouter()
+	// Cache the sensors looked up by name when a cache size is configured
+	var cache *app.CachingRepository
+	if size, _ := strconv.Atoi(os.Getenv("SENSOR_CACHE_SIZE")); size > 0 {
+		cache = app.NewCachingRepository(repo, size)
+		if ttl, err := time.ParseDuration(os.Getenv("SENSOR_CACHE_TTL")); err == nil {
+			cache.TTL = ttl
+		}
+		expvar.Publish("sensor_cache", expvar.Func(func() interface{} { return cache.Stats() }))
+		repo = cache
+	}
 
 	// Create a new handler and register the routes
 	handler := app.NewHandler(repo)
+	allowPrivateWebhooks := os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+	handler.AllowPrivateWebhooks = allowPrivateWebhooks
+	router := app.NewRouter(handler)
+	if os.Getenv("SWAGGER_UI") == "true" {
+		app.RegisterSwaggerUI(router)
+	}
+
+	// Send the deliveries of the webhook outbox in the background
+	dispatcher := app.NewWebhookDispatcher(repo)
+	dispatcher.AllowPrivateAddresses = allowPrivateWebhooks
+	go dispatcher.Run(context.Background())
+
+	// Prune the change log hourly, keeping a week of events unless configured otherwise
+	retention := 7 * 24 * time.Hour
+	if value, err := time.ParseDuration(os.Getenv("SENSOR_EVENT_RETENTION")); err == nil {
+		retention = value
+	}
+	go app.PruneSensorEvents(context.Background(), repo, retention, time.Hour)
+
+	// Follow the changes made to sensors by other replicas of a shared database
+	if os.Getenv("DB_BACKEND") != "memory" {
+		listener := app.NewSensorChangeListener(repo)
+		listener.Subscribe(dispatcher)
+		if spatialIndex != nil {
+			listener.Subscribe(spatialIndex)
+		}
+		if cache != nil {
+			listener.Subscribe(cache)
+		}
+		go func() {
+			log.Fatal("Error listening for sensor changes:", listener.Run(context.Background()))
+		}()
+	}
+
+	// Create the gRPC server over the same repository
+	grpcServer := grpc.NewServer()
+	app.NewGRPCServer(repo).Register(grpcServer)
+	reflection.Register(grpcServer)
 
-	// Routes
-	router.HandleFunc("/sensors", handler.CreateSensorMetadata).Methods("POST")
-	router.HandleFunc("/sensors", handler.GetSensorMetadata).Methods("GET")
-	router.HandleFunc("/sensors/{name}", handler.UpdateSensorMetadata).Methods("PUT")
-	router.HandleFunc("/sensors/nearest", handler.GetNearestSensorMetadata).Methods("GET")
+	// Serve gRPC on its own port when one is configured, or alongside the routes otherwise
+	var httpHandler http.Handler = router
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		listener, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatal("Error listening for gRPC:", err)
+		}
+		log.Printf("gRPC server started on port %s", grpcPort)
+		go func() {
+			log.Fatal(grpcServer.Serve(listener))
+		}()
+	} else {
+		httpHandler = app.MultiplexGRPC(grpcServer, router)
+	}
+
+	// Serve the process variables on a separate admin address when one is configured, since they
+	// include the command line and memory statistics
+	if adminAddr := os.Getenv("ADMIN_ADDR"); adminAddr != "" {
+		admin := http.NewServeMux()
+		admin.Handle("/debug/vars", expvar.Handler())
+		log.Printf("Admin server started on %s", adminAddr)
+		go func() {
+			log.Fatal(http.ListenAndServe(adminAddr, admin))
+		}()
+	}
 
 	// Start the HTTP server
 	log.Println("Server started on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	log.Fatal(http.ListenAndServe(":8080", httpHandler))
 }
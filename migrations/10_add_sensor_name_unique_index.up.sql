@@ -0,0 +1,5 @@
+-- 10_add_sensor_name_unique_index.up.sql
+
+-- Sensors are addressed by name, so make names unique. This also lets batch upserts
+-- use INSERT ... ON CONFLICT (name). Remove any duplicate names before applying it.
+CREATE UNIQUE INDEX idx_sensor_metadata_name ON sensor_metadata (name);
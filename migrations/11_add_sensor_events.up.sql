@@ -0,0 +1,37 @@
+-- 11_add_sensor_events.up.sql
+
+-- Create the change log streamed by GET /sensors/events. Each event holds the sensor row
+-- after the change, or before a delete, and for updates the row before the change as well.
+CREATE TABLE sensor_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(16) NOT NULL CHECK (type IN ('created', 'updated', 'deleted')),
+    sensor JSONB NOT NULL,
+    previous JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Record every change to a sensor, whichever statement makes it
+CREATE FUNCTION record_sensor_event() RETURNS TRIGGER AS $$
+BEGIN
+    -- Clients resume after the highest event ID they have seen, so events must become visible
+    -- in ID order. Holding this lock until commit serializes the transactions that change
+    -- sensors from the moment they allocate an event ID.
+    PERFORM pg_advisory_xact_lock(hashtext('sensor_events'));
+
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO sensor_events (type, sensor) VALUES ('created', to_jsonb(NEW));
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO sensor_events (type, sensor, previous) VALUES ('updated', to_jsonb(NEW), to_jsonb(OLD));
+    ELSE
+        INSERT INTO sensor_events (type, sensor) VALUES ('deleted', to_jsonb(OLD));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sensor_metadata_events AFTER INSERT OR DELETE ON sensor_metadata
+FOR EACH ROW EXECUTE FUNCTION record_sensor_event();
+
+-- Updates that change nothing, such as adding a tag the sensor already has, aren't recorded
+CREATE TRIGGER sensor_metadata_update_events AFTER UPDATE ON sensor_metadata
+FOR EACH ROW WHEN (OLD IS DISTINCT FROM NEW) EXECUTE FUNCTION record_sensor_event();
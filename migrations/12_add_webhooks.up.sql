@@ -0,0 +1,41 @@
+-- 12_add_webhooks.up.sql
+
+-- Create the table for webhook subscriptions. An empty events array subscribes to every type of
+-- event, and an empty tags expression to every sensor. Webhooks receive the events recorded
+-- after after_event_id, the last event when they were created.
+CREATE TABLE webhooks (
+    id SERIAL PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    events VARCHAR(16)[] NOT NULL DEFAULT ARRAY[]::VARCHAR(16)[],
+    tags VARCHAR(1024) NOT NULL DEFAULT '',
+    after_event_id BIGINT NOT NULL
+);
+
+-- Create the outbox of webhook deliveries. Pending deliveries are sent once next_attempt_at
+-- has passed, and are dead-lettered after too many failed attempts.
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES sensor_events (id),
+    event VARCHAR(16) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Create an index for claiming the deliveries that are due, and one for listing those of a webhook
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+
+-- Record the position of the outbox in the change log. Its single row is locked while the
+-- deliveries of new events are added, so each event is enqueued once.
+CREATE TABLE webhook_outbox (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_event_id BIGINT NOT NULL
+);
+
+INSERT INTO webhook_outbox (last_event_id) SELECT COALESCE(MAX(id), 0) FROM sensor_events;
@@ -0,0 +1,28 @@
+-- 13_notify_sensor_changes.up.sql
+
+-- Notify the API replicas of every change to a sensor on the sensor_metadata_changed channel,
+-- so they can invalidate their local state. The payload names the change log event, which
+-- listeners use to detect missed notifications, and the sensor's ID and names, old and new.
+-- Notifications are sent when the transaction commits, in commit order.
+CREATE OR REPLACE FUNCTION record_sensor_event() RETURNS TRIGGER AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    -- Clients resume after the highest event ID they have seen, so events must become visible
+    -- in ID order. Holding this lock until commit serializes the transactions that change
+    -- sensors from the moment they allocate an event ID.
+    PERFORM pg_advisory_xact_lock(hashtext('sensor_events'));
+
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO sensor_events (type, sensor) VALUES ('created', to_jsonb(NEW)) RETURNING id INTO event_id;
+        PERFORM pg_notify('sensor_metadata_changed', json_build_object('event_id', event_id, 'type', 'created', 'id', NEW.id, 'name', NEW.name)::text);
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO sensor_events (type, sensor, previous) VALUES ('updated', to_jsonb(NEW), to_jsonb(OLD)) RETURNING id INTO event_id;
+        PERFORM pg_notify('sensor_metadata_changed', json_build_object('event_id', event_id, 'type', 'updated', 'id', NEW.id, 'name', NEW.name, 'previous_name', OLD.name)::text);
+    ELSE
+        INSERT INTO sensor_events (type, sensor) VALUES ('deleted', to_jsonb(OLD)) RETURNING id INTO event_id;
+        PERFORM pg_notify('sensor_metadata_changed', json_build_object('event_id', event_id, 'type', 'deleted', 'id', OLD.id, 'name', OLD.name)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
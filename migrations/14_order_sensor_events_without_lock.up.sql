@@ -0,0 +1,69 @@
+-- 14_order_sensor_events_without_lock.up.sql
+
+-- Readers of the change log resume after the highest event ID they have seen, so they must never
+-- be handed an event while a transaction that may still commit a lower ID is in flight. Instead
+-- of serializing the transactions that change sensors, each one announces the lowest ID it may
+-- take with a shared advisory lock, taken before its first event, and readers stop short of the
+-- lowest ID announced. The lock keys carry the ID in their low 48 bits, under the prefix 0x5345.
+
+-- Return the ID below which every event of the change log is final: committed, or rolled back
+-- for good. The last ID handed out is read before the locks, so a transaction holding a lower ID
+-- has announced it by then. Events must be read in a later statement, whose snapshot sees the
+-- transactions that ended before the locks were read.
+CREATE FUNCTION sensor_events_watermark() RETURNS BIGINT AS $$
+DECLARE
+    next_id BIGINT;
+    in_flight BIGINT;
+BEGIN
+    SELECT last_value + CASE WHEN is_called THEN 1 ELSE 0 END INTO next_id FROM sensor_events_id_seq;
+
+    SELECT MIN(((classid::BIGINT & 65535) << 32) | objid::BIGINT) INTO in_flight
+    FROM pg_locks
+    WHERE locktype = 'advisory' AND objsubid = 1 AND classid::BIGINT >> 16 = 21317
+        AND database = (SELECT oid FROM pg_database WHERE datname = current_database());
+
+    RETURN LEAST(next_id, in_flight);
+END;
+$$ LANGUAGE plpgsql VOLATILE;
+
+CREATE OR REPLACE FUNCTION record_sensor_event() RETURNS TRIGGER AS $$
+DECLARE
+    event_id BIGINT;
+    next_id BIGINT;
+BEGIN
+    -- Announce the lowest ID the transaction may take, once per transaction. IDs handed out
+    -- later are higher, since the sequence isn't cached per session.
+    IF current_setting('sensor_events.announced', true) IS DISTINCT FROM 'true' THEN
+        SELECT last_value + CASE WHEN is_called THEN 1 ELSE 0 END INTO next_id FROM sensor_events_id_seq;
+        PERFORM pg_advisory_xact_lock_shared((21317::BIGINT << 48) | next_id);
+        PERFORM set_config('sensor_events.announced', 'true', true);
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO sensor_events (type, sensor) VALUES ('created', to_jsonb(NEW)) RETURNING id INTO event_id;
+        PERFORM pg_notify('sensor_metadata_changed', json_build_object('event_id', event_id, 'type', 'created', 'id', NEW.id, 'name', NEW.name)::text);
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO sensor_events (type, sensor, previous) VALUES ('updated', to_jsonb(NEW), to_jsonb(OLD)) RETURNING id INTO event_id;
+        PERFORM pg_notify('sensor_metadata_changed', json_build_object('event_id', event_id, 'type', 'updated', 'id', NEW.id, 'name', NEW.name, 'previous_name', OLD.name)::text);
+    ELSE
+        INSERT INTO sensor_events (type, sensor) VALUES ('deleted', to_jsonb(OLD)) RETURNING id INTO event_id;
+        PERFORM pg_notify('sensor_metadata_changed', json_build_object('event_id', event_id, 'type', 'deleted', 'id', OLD.id, 'name', OLD.name)::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Old events are pruned, so deliveries keep their payload without referencing their event
+ALTER TABLE webhook_deliveries DROP CONSTRAINT webhook_deliveries_event_id_fkey;
+
+-- Create an index for pruning events by age
+CREATE INDEX idx_sensor_events_created_at ON sensor_events (created_at);
+
+-- Record the highest event ID pruned, so that readers resuming from before it learn they missed
+-- events rather than silently skipping them.
+CREATE TABLE sensor_events_retention (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    pruned_through BIGINT NOT NULL
+);
+
+INSERT INTO sensor_events_retention (pruned_through) VALUES (0);
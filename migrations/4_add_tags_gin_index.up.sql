@@ -0,0 +1,4 @@
+-- 4_add_tags_gin_index.up.sql
+
+-- Create a GIN index so tag filter expressions (tags @> ARRAY[...]) don't scan the whole table
+CREATE INDEX idx_sensor_metadata_tags ON sensor_metadata USING GIN (tags);
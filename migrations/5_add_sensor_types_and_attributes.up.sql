@@ -0,0 +1,16 @@
+-- 5_add_sensor_types_and_attributes.up.sql
+
+-- Create the table for sensor types, each defining a JSON Schema for sensor attributes
+CREATE TABLE sensor_types (
+    name VARCHAR(255) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    schema JSONB NOT NULL
+);
+
+-- Add the type and free-form attributes columns to the sensor_metadata table
+ALTER TABLE sensor_metadata
+ADD COLUMN sensor_type VARCHAR(255) REFERENCES sensor_types (name),
+ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}'::JSONB;
+
+-- Create a GIN index for attribute equality filters (attributes @> jsonb)
+CREATE INDEX idx_sensor_metadata_attributes ON sensor_metadata USING GIN (attributes jsonb_path_ops);
@@ -0,0 +1,9 @@
+-- 6_add_location_altitude_floor_accuracy.up.sql
+
+-- Add the optional altitude, floor and positional accuracy columns to the sensor_metadata table.
+-- Existing rows keep NULL in every new column.
+ALTER TABLE sensor_metadata
+ADD COLUMN location_altitude DOUBLE PRECISION,
+ADD COLUMN location_altitude_datum VARCHAR(8) CHECK (location_altitude_datum IN ('WGS84', 'MSL')),
+ADD COLUMN location_floor INTEGER,
+ADD COLUMN location_accuracy_m DOUBLE PRECISION CHECK (location_accuracy_m >= 0);
@@ -0,0 +1,5 @@
+-- 7_add_location_point_gist_index.up.sql
+
+-- Create a GiST index on the location as a geometric point, so bounding box and
+-- polygon containment queries (point(...) <@ box/polygon) can use an index
+CREATE INDEX idx_sensor_metadata_location_point ON sensor_metadata USING GIST (point(location_longitude, location_latitude));
@@ -0,0 +1,6 @@
+-- 8_add_location_geography.up.sql
+
+-- The geography column used by the postgis repository backend has moved to
+-- migrations/postgis/1_add_location_geography.up.sql, so that the other backends don't need
+-- PostGIS. This migration is kept empty so that migration versions stay contiguous.
+SELECT 1;
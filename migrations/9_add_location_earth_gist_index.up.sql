@@ -0,0 +1,5 @@
+-- 9_add_location_earth_gist_index.up.sql
+
+-- Create a functional GiST index on the location as an earth cube, so nearest-sensor
+-- queries can prefilter with earth_box(...) @> ll_to_earth(...) instead of scanning every row
+CREATE INDEX idx_sensor_metadata_location_earth ON sensor_metadata USING GIST (ll_to_earth(location_latitude, location_longitude));
@@ -0,0 +1,16 @@
+-- 1_add_location_geography.up.sql
+
+-- The migrations in this directory are only needed by the postgis repository backend
+-- (DB_BACKEND=postgis), and are applied after those of migrations/. Databases that applied
+-- the former migration 8 already have the column and index, so they are only created if missing.
+
+-- PostGIS provides the geography type used by the postgis repository backend
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- Derive a geography point from the existing latitude and longitude columns.
+-- As a generated column it is backfilled for existing rows and kept in sync on every write.
+ALTER TABLE sensor_metadata
+ADD COLUMN IF NOT EXISTS location geography(Point, 4326) GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(location_longitude, location_latitude), 4326)::geography) STORED;
+
+-- Create a GiST index on the geography point for KNN (<->) ordering
+CREATE INDEX IF NOT EXISTS idx_sensor_metadata_location_geography ON sensor_metadata USING GIST (location);
@@ -0,0 +1,415 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: sensor/v1/sensor.proto
+
+// Package sensor.v1 is the gRPC API of the sensor metadata service. It mirrors version 1 of
+// the REST API and is served over the same repository.
+
+package sensorv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SensorMetadataService_CreateSensor_FullMethodName   = "/sensor.v1.SensorMetadataService/CreateSensor"
+	SensorMetadataService_GetSensor_FullMethodName      = "/sensor.v1.SensorMetadataService/GetSensor"
+	SensorMetadataService_UpdateSensor_FullMethodName   = "/sensor.v1.SensorMetadataService/UpdateSensor"
+	SensorMetadataService_DeleteSensor_FullMethodName   = "/sensor.v1.SensorMetadataService/DeleteSensor"
+	SensorMetadataService_ListSensors_FullMethodName    = "/sensor.v1.SensorMetadataService/ListSensors"
+	SensorMetadataService_StreamSensors_FullMethodName  = "/sensor.v1.SensorMetadataService/StreamSensors"
+	SensorMetadataService_NearestSensors_FullMethodName = "/sensor.v1.SensorMetadataService/NearestSensors"
+	SensorMetadataService_SensorsWithin_FullMethodName  = "/sensor.v1.SensorMetadataService/SensorsWithin"
+)
+
+// SensorMetadataServiceClient is the client API for SensorMetadataService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SensorMetadataServiceClient interface {
+	// CreateSensor creates a sensor. It fails with ALREADY_EXISTS if the name is taken.
+	CreateSensor(ctx context.Context, in *CreateSensorRequest, opts ...grpc.CallOption) (*CreateSensorResponse, error)
+	// GetSensor returns a sensor by name, or fails with NOT_FOUND.
+	GetSensor(ctx context.Context, in *GetSensorRequest, opts ...grpc.CallOption) (*GetSensorResponse, error)
+	// UpdateSensor replaces the sensor with the ID of the given sensor, which may rename it.
+	UpdateSensor(ctx context.Context, in *UpdateSensorRequest, opts ...grpc.CallOption) (*UpdateSensorResponse, error)
+	// DeleteSensor deletes a sensor by name, or fails with NOT_FOUND.
+	DeleteSensor(ctx context.Context, in *DeleteSensorRequest, opts ...grpc.CallOption) (*DeleteSensorResponse, error)
+	// ListSensors returns a page of sensors.
+	ListSensors(ctx context.Context, in *ListSensorsRequest, opts ...grpc.CallOption) (*ListSensorsResponse, error)
+	// StreamSensors streams every sensor matching the filter, without paging.
+	StreamSensors(ctx context.Context, in *StreamSensorsRequest, opts ...grpc.CallOption) (SensorMetadataService_StreamSensorsClient, error)
+	// NearestSensors returns the sensors nearest to a location, nearest first.
+	NearestSensors(ctx context.Context, in *NearestSensorsRequest, opts ...grpc.CallOption) (*NearestSensorsResponse, error)
+	// SensorsWithin returns a page of the sensors inside a bounding box or polygons.
+	SensorsWithin(ctx context.Context, in *SensorsWithinRequest, opts ...grpc.CallOption) (*SensorsWithinResponse, error)
+}
+
+type sensorMetadataServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSensorMetadataServiceClient(cc grpc.ClientConnInterface) SensorMetadataServiceClient {
+	return &sensorMetadataServiceClient{cc}
+}
+
+func (c *sensorMetadataServiceClient) CreateSensor(ctx context.Context, in *CreateSensorRequest, opts ...grpc.CallOption) (*CreateSensorResponse, error) {
+	out := new(CreateSensorResponse)
+	err := c.cc.Invoke(ctx, SensorMetadataService_CreateSensor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorMetadataServiceClient) GetSensor(ctx context.Context, in *GetSensorRequest, opts ...grpc.CallOption) (*GetSensorResponse, error) {
+	out := new(GetSensorResponse)
+	err := c.cc.Invoke(ctx, SensorMetadataService_GetSensor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorMetadataServiceClient) UpdateSensor(ctx context.Context, in *UpdateSensorRequest, opts ...grpc.CallOption) (*UpdateSensorResponse, error) {
+	out := new(UpdateSensorResponse)
+	err := c.cc.Invoke(ctx, SensorMetadataService_UpdateSensor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorMetadataServiceClient) DeleteSensor(ctx context.Context, in *DeleteSensorRequest, opts ...grpc.CallOption) (*DeleteSensorResponse, error) {
+	out := new(DeleteSensorResponse)
+	err := c.cc.Invoke(ctx, SensorMetadataService_DeleteSensor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorMetadataServiceClient) ListSensors(ctx context.Context, in *ListSensorsRequest, opts ...grpc.CallOption) (*ListSensorsResponse, error) {
+	out := new(ListSensorsResponse)
+	err := c.cc.Invoke(ctx, SensorMetadataService_ListSensors_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorMetadataServiceClient) StreamSensors(ctx context.Context, in *StreamSensorsRequest, opts ...grpc.CallOption) (SensorMetadataService_StreamSensorsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SensorMetadataService_ServiceDesc.Streams[0], SensorMetadataService_StreamSensors_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sensorMetadataServiceStreamSensorsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SensorMetadataService_StreamSensorsClient interface {
+	Recv() (*StreamSensorsResponse, error)
+	grpc.ClientStream
+}
+
+type sensorMetadataServiceStreamSensorsClient struct {
+	grpc.ClientStream
+}
+
+func (x *sensorMetadataServiceStreamSensorsClient) Recv() (*StreamSensorsResponse, error) {
+	m := new(StreamSensorsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sensorMetadataServiceClient) NearestSensors(ctx context.Context, in *NearestSensorsRequest, opts ...grpc.CallOption) (*NearestSensorsResponse, error) {
+	out := new(NearestSensorsResponse)
+	err := c.cc.Invoke(ctx, SensorMetadataService_NearestSensors_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorMetadataServiceClient) SensorsWithin(ctx context.Context, in *SensorsWithinRequest, opts ...grpc.CallOption) (*SensorsWithinResponse, error) {
+	out := new(SensorsWithinResponse)
+	err := c.cc.Invoke(ctx, SensorMetadataService_SensorsWithin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SensorMetadataServiceServer is the server API for SensorMetadataService service.
+// All implementations must embed UnimplementedSensorMetadataServiceServer
+// for forward compatibility
+type SensorMetadataServiceServer interface {
+	// CreateSensor creates a sensor. It fails with ALREADY_EXISTS if the name is taken.
+	CreateSensor(context.Context, *CreateSensorRequest) (*CreateSensorResponse, error)
+	// GetSensor returns a sensor by name, or fails with NOT_FOUND.
+	GetSensor(context.Context, *GetSensorRequest) (*GetSensorResponse, error)
+	// UpdateSensor replaces the sensor with the ID of the given sensor, which may rename it.
+	UpdateSensor(context.Context, *UpdateSensorRequest) (*UpdateSensorResponse, error)
+	// DeleteSensor deletes a sensor by name, or fails with NOT_FOUND.
+	DeleteSensor(context.Context, *DeleteSensorRequest) (*DeleteSensorResponse, error)
+	// ListSensors returns a page of sensors.
+	ListSensors(context.Context, *ListSensorsRequest) (*ListSensorsResponse, error)
+	// StreamSensors streams every sensor matching the filter, without paging.
+	StreamSensors(*StreamSensorsRequest, SensorMetadataService_StreamSensorsServer) error
+	// NearestSensors returns the sensors nearest to a location, nearest first.
+	NearestSensors(context.Context, *NearestSensorsRequest) (*NearestSensorsResponse, error)
+	// SensorsWithin returns a page of the sensors inside a bounding box or polygons.
+	SensorsWithin(context.Context, *SensorsWithinRequest) (*SensorsWithinResponse, error)
+	mustEmbedUnimplementedSensorMetadataServiceServer()
+}
+
+// UnimplementedSensorMetadataServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSensorMetadataServiceServer struct {
+}
+
+func (UnimplementedSensorMetadataServiceServer) CreateSensor(context.Context, *CreateSensorRequest) (*CreateSensorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSensor not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) GetSensor(context.Context, *GetSensorRequest) (*GetSensorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSensor not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) UpdateSensor(context.Context, *UpdateSensorRequest) (*UpdateSensorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSensor not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) DeleteSensor(context.Context, *DeleteSensorRequest) (*DeleteSensorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSensor not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) ListSensors(context.Context, *ListSensorsRequest) (*ListSensorsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSensors not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) StreamSensors(*StreamSensorsRequest, SensorMetadataService_StreamSensorsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSensors not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) NearestSensors(context.Context, *NearestSensorsRequest) (*NearestSensorsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NearestSensors not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) SensorsWithin(context.Context, *SensorsWithinRequest) (*SensorsWithinResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SensorsWithin not implemented")
+}
+func (UnimplementedSensorMetadataServiceServer) mustEmbedUnimplementedSensorMetadataServiceServer() {}
+
+// UnsafeSensorMetadataServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SensorMetadataServiceServer will
+// result in compilation errors.
+type UnsafeSensorMetadataServiceServer interface {
+	mustEmbedUnimplementedSensorMetadataServiceServer()
+}
+
+func RegisterSensorMetadataServiceServer(s grpc.ServiceRegistrar, srv SensorMetadataServiceServer) {
+	s.RegisterService(&SensorMetadataService_ServiceDesc, srv)
+}
+
+func _SensorMetadataService_CreateSensor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorMetadataServiceServer).CreateSensor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorMetadataService_CreateSensor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorMetadataServiceServer).CreateSensor(ctx, req.(*CreateSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorMetadataService_GetSensor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorMetadataServiceServer).GetSensor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorMetadataService_GetSensor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorMetadataServiceServer).GetSensor(ctx, req.(*GetSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorMetadataService_UpdateSensor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorMetadataServiceServer).UpdateSensor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorMetadataService_UpdateSensor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorMetadataServiceServer).UpdateSensor(ctx, req.(*UpdateSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorMetadataService_DeleteSensor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorMetadataServiceServer).DeleteSensor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorMetadataService_DeleteSensor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorMetadataServiceServer).DeleteSensor(ctx, req.(*DeleteSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorMetadataService_ListSensors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSensorsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorMetadataServiceServer).ListSensors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorMetadataService_ListSensors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorMetadataServiceServer).ListSensors(ctx, req.(*ListSensorsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorMetadataService_StreamSensors_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSensorsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SensorMetadataServiceServer).StreamSensors(m, &sensorMetadataServiceStreamSensorsServer{stream})
+}
+
+type SensorMetadataService_StreamSensorsServer interface {
+	Send(*StreamSensorsResponse) error
+	grpc.ServerStream
+}
+
+type sensorMetadataServiceStreamSensorsServer struct {
+	grpc.ServerStream
+}
+
+func (x *sensorMetadataServiceStreamSensorsServer) Send(m *StreamSensorsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SensorMetadataService_NearestSensors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NearestSensorsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorMetadataServiceServer).NearestSensors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorMetadataService_NearestSensors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorMetadataServiceServer).NearestSensors(ctx, req.(*NearestSensorsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorMetadataService_SensorsWithin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SensorsWithinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorMetadataServiceServer).SensorsWithin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorMetadataService_SensorsWithin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorMetadataServiceServer).SensorsWithin(ctx, req.(*SensorsWithinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SensorMetadataService_ServiceDesc is the grpc.ServiceDesc for SensorMetadataService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SensorMetadataService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sensor.v1.SensorMetadataService",
+	HandlerType: (*SensorMetadataServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSensor",
+			Handler:    _SensorMetadataService_CreateSensor_Handler,
+		},
+		{
+			MethodName: "GetSensor",
+			Handler:    _SensorMetadataService_GetSensor_Handler,
+		},
+		{
+			MethodName: "UpdateSensor",
+			Handler:    _SensorMetadataService_UpdateSensor_Handler,
+		},
+		{
+			MethodName: "DeleteSensor",
+			Handler:    _SensorMetadataService_DeleteSensor_Handler,
+		},
+		{
+			MethodName: "ListSensors",
+			Handler:    _SensorMetadataService_ListSensors_Handler,
+		},
+		{
+			MethodName: "NearestSensors",
+			Handler:    _SensorMetadataService_NearestSensors_Handler,
+		},
+		{
+			MethodName: "SensorsWithin",
+			Handler:    _SensorMetadataService_SensorsWithin_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSensors",
+			Handler:       _SensorMetadataService_StreamSensors_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sensor/v1/sensor.proto",
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAttributeFilters(t *testing.T) {
+	filters, err := app.ParseAttributeFilters("name=x&attr.sampling_rate>=10&attr.model=%22x100%22&attr.enabled!=false&attr.units=celsius")
+	assert.NoError(t, err)
+
+	var rendered []string
+	for _, filter := range filters {
+		rendered = append(rendered, filter.String())
+	}
+	assert.Equal(t, []string{`attr.sampling_rate>=10`, `attr.model="x100"`, `attr.enabled!=false`, `attr.units="celsius"`}, rendered)
+
+	_, err = app.ParseAttributeFilters("attr.sampling_rate")
+	assert.ErrorIs(t, err, app.ErrInvalidAttributeFilter)
+}
+
+func TestAttributeFilterMatch(t *testing.T) {
+	attributes := map[string]interface{}{"sampling_rate": 10.0, "model": "x100", "enabled": true}
+
+	for query, expected := range map[string]bool{
+		"attr.sampling_rate>=10":  true,
+		"attr.sampling_rate>10":   false,
+		"attr.sampling_rate<=9.5": false,
+		"attr.sampling_rate=10":   true,
+		"attr.model=x100":         true,
+		"attr.model>x":            true,
+		"attr.model=10":           false,
+		"attr.enabled=true":       true,
+		"attr.enabled>true":       false,
+		"attr.missing=1":          false,
+		"attr.model.nested=1":     false,
+	} {
+		filters, err := app.ParseAttributeFilters(query)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, filters[0].Match(attributes), query)
+	}
+}
+
+func TestSensorTypeValidateAttributes(t *testing.T) {
+	sensorType := app.SensorType{
+		Name:   "thermometer",
+		Schema: []byte(`{"type": "object", "properties": {"units": {"enum": ["celsius", "kelvin"]}}, "additionalProperties": false}`),
+	}
+
+	assert.NoError(t, sensorType.ValidateAttributes(nil))
+	assert.NoError(t, sensorType.ValidateAttributes(map[string]interface{}{"units": "kelvin"}))
+	assert.Error(t, sensorType.ValidateAttributes(map[string]interface{}{"units": "fahrenheit"}))
+	assert.Error(t, sensorType.ValidateAttributes(map[string]interface{}{"firmware": "1.0"}))
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// slowLookupRepository counts the lookups by name that reach the repository, and holds them
+// until release is closed when it's set.
+type slowLookupRepository struct {
+	app.Repository
+	lookups atomic.Int64
+	release chan struct{}
+}
+
+func (r *slowLookupRepository) GetSensorMetadataByName(name string) (*app.SensorMetadata, error) {
+	r.lookups.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.Repository.GetSensorMetadataByName(name)
+}
+
+func newCachingRepository(t *testing.T, capacity int) (*app.CachingRepository, *slowLookupRepository) {
+	counting := &slowLookupRepository{Repository: newSeededMemoryRepository(t)}
+	return app.NewCachingRepository(counting, capacity), counting
+}
+
+func TestCachingRepository_GetSensorMetadataByName(t *testing.T) {
+	cache, counting := newCachingRepository(t, 10)
+
+	for i := 0; i < 3; i++ {
+		sensorMetadata, err := cache.GetSensorMetadataByName("London")
+		assert.NoError(t, err)
+		assert.Equal(t, "London", sensorMetadata.Name)
+		assert.Equal(t, []string{"vendor:acme", "floor:3"}, sensorMetadata.Tags)
+
+		// Callers get copies they can change
+		sensorMetadata.Tags[0] = "changed"
+	}
+	assert.Equal(t, int64(1), counting.lookups.Load())
+
+	// Names without a sensor are cached too
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetSensorMetadataByName("Madrid")
+		assert.ErrorIs(t, err, app.ErrSensorNotFound)
+	}
+	assert.Equal(t, int64(2), counting.lookups.Load())
+	assert.Equal(t, app.CacheStats{Hits: 3, Misses: 2, Size: 2}, cache.Stats())
+}
+
+func TestCachingRepository_Expiry(t *testing.T) {
+	cache, counting := newCachingRepository(t, 10)
+	cache.TTL = 20 * time.Millisecond
+	cache.NegativeTTL = 0
+
+	_, err := cache.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = cache.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), counting.lookups.Load())
+
+	// Without negative caching, every lookup of a missing name reaches the repository
+	for i := 0; i < 2; i++ {
+		_, err = cache.GetSensorMetadataByName("Madrid")
+		assert.ErrorIs(t, err, app.ErrSensorNotFound)
+	}
+	assert.Equal(t, int64(4), counting.lookups.Load())
+}
+
+func TestCachingRepository_Eviction(t *testing.T) {
+	cache, counting := newCachingRepository(t, 2)
+
+	// Paris is the least recently used when Berlin is added
+	for _, name := range []string{"London", "Paris", "London", "Berlin", "London", "Paris"} {
+		_, err := cache.GetSensorMetadataByName(name)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(4), counting.lookups.Load())
+	assert.Equal(t, app.CacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2}, cache.Stats())
+}
+
+func TestCachingRepository_Invalidation(t *testing.T) {
+	cache, _ := newCachingRepository(t, 10)
+	lookUp := func(name string) *app.SensorMetadata {
+		sensorMetadata, _ := cache.GetSensorMetadataByName(name)
+		return sensorMetadata
+	}
+	for _, name := range []string{"London", "Paris", "Berlin", "Madrid"} {
+		lookUp(name)
+	}
+
+	// Writes through the cache invalidate the sensors they touch, under their old names too
+	london := lookUp("London")
+	london.Name = "Greenwich"
+	assert.NoError(t, cache.UpdateSensorMetadata(london))
+	assert.Nil(t, lookUp("London"))
+	assert.NotNil(t, lookUp("Greenwich"))
+
+	_, err := cache.AddSensorTags("Paris", []string{"outdoor"})
+	assert.NoError(t, err)
+	assert.Contains(t, lookUp("Paris").Tags, "outdoor")
+
+	assert.NoError(t, cache.CreateSensorMetadata(&app.SensorMetadata{Name: "Madrid", Location: app.Location{Latitude: 40.4168, Longitude: -3.7038}}))
+	assert.NotNil(t, lookUp("Madrid"))
+
+	_, err = cache.ApplySensorBatch([]app.BatchOperation{{Op: app.BatchDelete, Name: "Berlin"}}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, lookUp("Berlin"))
+
+	// Changes notified by other replicas invalidate their sensors as well
+	underlying := cache.Repository
+	_, err = underlying.RemoveSensorTag("Paris", "outdoor")
+	assert.NoError(t, err)
+	assert.Contains(t, lookUp("Paris").Tags, "outdoor")
+	cache.SensorChanged(app.SensorChange{Type: app.SensorUpdated, ID: 2, Name: "Paris", PreviousName: "Paris"})
+	assert.NotContains(t, lookUp("Paris").Tags, "outdoor")
+
+	cache.ResyncSensors()
+	assert.Equal(t, 0, cache.Stats().Size)
+}
+
+func TestCachingRepository_SharedLookups(t *testing.T) {
+	cache, counting := newCachingRepository(t, 10)
+	counting.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sensorMetadata, err := cache.GetSensorMetadataByName("London")
+			if assert.NoError(t, err) {
+				assert.Equal(t, "London", sensorMetadata.Name)
+			}
+		}()
+	}
+
+	// Concurrent misses wait for the lookup in flight
+	assert.Eventually(t, func() bool { return cache.Stats().Misses == 10 }, time.Second, time.Millisecond)
+	close(counting.release)
+	wg.Wait()
+	assert.Equal(t, int64(1), counting.lookups.Load())
+	assert.Equal(t, uint64(9), cache.Stats().Shared)
+}
+
+func TestCachingRepository_WriteDuringLookup(t *testing.T) {
+	cache, counting := newCachingRepository(t, 10)
+	counting.release = make(chan struct{})
+
+	// A lookup that read the sensor before a write doesn't cache it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := cache.GetSensorMetadataByName("London")
+		assert.NoError(t, err)
+	}()
+	assert.Eventually(t, func() bool { return counting.lookups.Load() == 1 }, time.Second, time.Millisecond)
+	_, err := cache.AddSensorTags("London", []string{"outdoor"})
+	assert.NoError(t, err)
+	close(counting.release)
+	<-done
+
+	sensorMetadata, err := cache.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	assert.Contains(t, sensorMetadata.Tags, "outdoor")
+	assert.Equal(t, int64(2), counting.lookups.Load())
+}
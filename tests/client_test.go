@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/skartikey/sensor-metadata/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T, handler http.Handler, options ...client.Option) *client.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := client.New(server.URL, options...)
+	assert.NoError(t, err)
+	return c
+}
+
+func TestClientSensorLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, app.NewRouter(app.NewHandler(newSeededMemoryRepository(t))))
+
+	madrid := &app.SensorMetadataV1{Name: "Madrid", Location: app.LocationV1{Latitude: 40.4168, Longitude: -3.7038}, Tags: []string{"Vendor:Acme"}}
+	assert.NoError(t, c.CreateSensor(ctx, madrid))
+	assert.ErrorIs(t, c.CreateSensor(ctx, madrid), app.ErrSensorExists)
+
+	sensor, err := c.GetSensor(ctx, "Madrid")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, sensor.ID)
+	assert.Equal(t, []string{"vendor:acme"}, sensor.Tags)
+
+	sensor.Name, sensor.StructuredTags = "Madrid Centro", nil
+	assert.NoError(t, c.UpdateSensor(ctx, "Madrid", sensor))
+	_, err = c.GetSensor(ctx, "Madrid")
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+
+	sensor, err = c.AddSensorTags(ctx, "Madrid Centro", []string{"outdoor"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vendor:acme", "outdoor"}, sensor.Tags)
+
+	assert.NoError(t, c.DeleteSensor(ctx, "Madrid Centro"))
+	assert.ErrorIs(t, c.DeleteSensor(ctx, "Madrid Centro"), app.ErrSensorNotFound)
+}
+
+func TestClientValidationError(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, app.NewRouter(app.NewHandler(app.NewMemoryRepository())))
+
+	err := c.CreateSensorType(ctx, &app.SensorType{Name: "thermometer", Schema: json.RawMessage(`{"type": "object", "properties": {"sampling_rate": {"type": "integer"}}}`)})
+	assert.NoError(t, err)
+	_, err = c.GetSensorType(ctx, "hygrometer")
+	assert.ErrorIs(t, err, app.ErrSensorTypeNotFound)
+
+	err = c.CreateSensor(ctx, &app.SensorMetadataV1{Location: app.LocationV1{Longitude: 1}})
+	assert.ErrorIs(t, err, client.ErrInvalidRequest)
+	var apiErr *client.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, []app.FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "location.latitude", Message: "is required"},
+	}, apiErr.Fields)
+
+	err = c.CreateSensor(ctx, &app.SensorMetadataV1{Name: "T1", Type: "thermometer", Location: app.LocationV1{Latitude: 1, Longitude: 1}, Attributes: map[string]interface{}{"sampling_rate": "fast"}})
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, []app.FieldError{{Field: "attributes.sampling_rate", Message: "must be of type integer"}}, apiErr.Fields)
+}
+
+func TestClientSensorIterator(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, app.NewRouter(app.NewHandler(newSeededMemoryRepository(t))))
+
+	var names []string
+	it := c.Sensors(ctx, client.ListOptions{Limit: 1})
+	for it.Next() {
+		names = append(names, it.Sensor().Name)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"London", "Paris", "Berlin"}, names)
+
+	names = nil
+	it = c.SensorsWithin(ctx, client.WithinArea{BoundingBox: &app.BoundingBox{MinLon: -1, MinLat: 48, MaxLon: 3, MaxLat: 52}}, client.ListOptions{Tags: "vendor:acme", Limit: 1})
+	for it.Next() {
+		names = append(names, it.Sensor().Name)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []string{"London", "Paris"}, names)
+
+	it = c.Sensors(ctx, client.ListOptions{Tags: "vendor:acme AND"})
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), client.ErrInvalidRequest)
+}
+
+func TestClientNearestImportExport(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, app.NewRouter(app.NewHandler(newSeededMemoryRepository(t))))
+
+	sensor, err := c.NearestSensor(ctx, client.NearestOptions{Latitude: 48.8, Longitude: 2.3, Tags: "NOT retired"})
+	assert.NoError(t, err)
+	assert.Equal(t, "London", sensor.Name)
+	assert.Greater(t, sensor.Distance, 0.0)
+
+	result, err := c.Import(ctx, strings.NewReader("name,latitude,longitude\nMadrid,40.4168,-3.7038\n"), client.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+
+	export, err := c.Export(ctx, app.FormatCSV, client.ListOptions{Tags: "NOT vendor:acme"})
+	assert.NoError(t, err)
+	data, err := io.ReadAll(export)
+	assert.NoError(t, err)
+	assert.NoError(t, export.Close())
+	assert.Equal(t, 3, strings.Count(string(data), "\n"))
+
+	response, err := c.Batch(ctx, app.BatchRequest{Atomic: true, Operations: []app.BatchOperationV1{
+		{Op: app.BatchDelete, Name: "Madrid"},
+		{Op: app.BatchDelete, Name: "Lisbon"},
+	}})
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+	assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+	assert.Equal(t, http.StatusNotFound, response.Results[1].Status)
+}
+
+func TestClientRetriesUnavailable(t *testing.T) {
+	ctx := context.Background()
+	router := app.NewRouter(app.NewHandler(newSeededMemoryRepository(t)))
+
+	var attempts int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+
+	c := newTestClient(t, flaky, client.WithRetryPolicy(client.RetryPolicy{MaxRetries: 2}))
+	_, err := c.GetSensor(ctx, "Paris")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), attempts)
+
+	atomic.StoreInt32(&attempts, 0)
+	c = newTestClient(t, flaky, client.WithRetryPolicy(client.RetryPolicy{MaxRetries: 1}))
+	_, err = c.GetSensor(ctx, "Paris")
+	assert.ErrorIs(t, err, client.ErrUnavailable)
+	assert.Equal(t, int32(2), attempts)
+}
+
+func TestClientAuth(t *testing.T) {
+	router := app.NewRouter(app.NewHandler(newSeededMemoryRepository(t)))
+	protected := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+
+	_, err := newTestClient(t, protected).GetSensor(context.Background(), "Paris")
+	var apiErr *client.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	_, err = newTestClient(t, protected, client.WithAuth(client.BearerToken("secret"))).GetSensor(context.Background(), "Paris")
+	assert.NoError(t, err)
+}
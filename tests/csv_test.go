@@ -0,0 +1,176 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+const importCSV = `name,latitude,longitude,tags,attributes
+Sensor1,51.5,-0.12,Vendor:Acme;floor:3,
+Sensor2,abc,2.35,,
+,48.85,2.35,,
+Sensor3,52.52,13.4,bad tag!,
+Sensor4,40.41,-3.70
+Sensor5,41.9,12.5,,"{""model"": ""x1""}"
+`
+
+func TestHandlerImportSensorMetadataDryRun(t *testing.T) {
+	repo := app.NewMemoryRepository()
+
+	req, err := http.NewRequest(http.MethodPost, "/sensors/import?dry_run=true", strings.NewReader(importCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var result app.ImportResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 6, result.Rows)
+	assert.Equal(t, 2, result.Valid)
+	assert.Equal(t, 0, result.Imported)
+
+	lines := make([]int, len(result.Errors))
+	for i, rowError := range result.Errors {
+		lines[i] = rowError.Line
+	}
+	assert.Equal(t, []int{3, 4, 5, 6}, lines)
+	assert.Contains(t, result.Errors[0].Error, "invalid latitude")
+	assert.Contains(t, result.Errors[1].Error, "'name'")
+	assert.Contains(t, result.Errors[2].Error, "bad tag!")
+	assert.Contains(t, result.Errors[3].Error, "expected 5 fields")
+
+	// Nothing was saved
+	_, err = repo.GetSensorMetadataByName("Sensor1")
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+}
+
+func TestHandlerImportSensorMetadata(t *testing.T) {
+	repo := app.NewMemoryRepository()
+	assert.NoError(t, repo.CreateSensorMetadata(&app.SensorMetadata{Name: "Sensor1", Location: app.Location{Latitude: 1, Longitude: 1}}))
+
+	req, err := http.NewRequest(http.MethodPost, "/sensors/import", strings.NewReader(importCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var result app.ImportResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Imported)
+	assert.Len(t, result.Errors, 4)
+
+	// Existing sensors are updated
+	sensor, err := repo.GetSensorMetadataByName("Sensor1")
+	assert.NoError(t, err)
+	assert.Equal(t, 51.5, sensor.Location.Latitude)
+	assert.Equal(t, []string{"vendor:acme", "floor:3"}, sensor.Tags)
+
+	sensor, err = repo.GetSensorMetadataByName("Sensor5")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"model": "x1"}, sensor.Attributes)
+}
+
+func TestHandlerImportSensorMetadataInvalidHeader(t *testing.T) {
+	for _, body := range []string{"", "name,latitude\n", "name,latitude,longitude,colour\n", "name,latitude,longitude,name\n"} {
+		req, err := http.NewRequest(http.MethodPost, "/sensors/import", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		app.NewRouter(app.NewHandler(app.NewMemoryRepository())).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestHandlerExportSensorMetadata(t *testing.T) {
+	repo := app.NewMemoryRepository()
+	altitude, floor := 35.5, 2
+	assert.NoError(t, repo.CreateSensorMetadata(&app.SensorMetadata{
+		Name:       "Sensor, the first",
+		Location:   app.Location{Latitude: 51.5, Longitude: -0.12, Altitude: &altitude, AltitudeDatum: app.DatumWGS84, Floor: &floor},
+		Tags:       []string{"vendor:acme", "floor:2"},
+		Attributes: map[string]interface{}{"model": "x1"},
+	}))
+	// Enough sensors to span more than one page
+	for i := 2; i <= 1001; i++ {
+		assert.NoError(t, repo.CreateSensorMetadata(&app.SensorMetadata{Name: fmt.Sprintf("Sensor%d", i), Location: app.Location{Latitude: 1, Longitude: 1}}))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors/export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+
+	export := rr.Body.String()
+	records, err := csv.NewReader(strings.NewReader(export)).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 1002)
+	assert.Equal(t, []string{"name", "latitude", "longitude", "altitude", "altitude_datum", "floor", "accuracy_m", "tags", "type", "attributes"}, records[0])
+	assert.Equal(t, []string{"Sensor, the first", "51.5", "-0.12", "35.5", "WGS84", "2", "", "vendor:acme;floor:2", "", `{"model":"x1"}`}, records[1])
+	assert.Equal(t, "Sensor1001", records[1001][0])
+
+	// The export can be imported again
+	req, err = http.NewRequest(http.MethodPost, "/sensors/import?dry_run=true", strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"valid":1001`)
+}
+
+func TestHandlerExportSensorMetadataEscapesFormulas(t *testing.T) {
+	repo := app.NewMemoryRepository()
+	for _, name := range []string{`=HYPERLINK("http://example.com")`, "@SUM(A1)", "-1+2", "Sensor'1"} {
+		assert.NoError(t, repo.CreateSensorMetadata(&app.SensorMetadata{Name: name, Location: app.Location{Latitude: 1, Longitude: -1}}))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sensors/export", nil)
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Cells that a spreadsheet would run are quoted, and numbers are left alone
+	export := rr.Body.String()
+	records, err := csv.NewReader(strings.NewReader(export)).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`'=HYPERLINK("http://example.com")`, "1", "-1"}, records[1][:3])
+	assert.Equal(t, "'@SUM(A1)", records[2][0])
+	assert.Equal(t, "'-1+2", records[3][0])
+	assert.Equal(t, "Sensor'1", records[4][0])
+
+	// The quotes are removed again on import
+	imported := app.NewMemoryRepository()
+	req = httptest.NewRequest(http.MethodPost, "/sensors/import", strings.NewReader(export))
+	rr = httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(imported)).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	sensors, err := imported.ListSensorMetadata(app.SensorFilter{Limit: 10})
+	assert.NoError(t, err)
+	names := []string{}
+	for _, sensorMetadata := range sensors {
+		names = append(names, sensorMetadata.Name)
+	}
+	assert.Equal(t, []string{`=HYPERLINK("http://example.com")`, "@SUM(A1)", "-1+2", "Sensor'1"}, names)
+}
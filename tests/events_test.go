@@ -0,0 +1,238 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+func TestMemoryRepository_ListSensorEvents(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	// Changes that change nothing and failed atomic batches leave no events
+	_, err := repo.AddSensorTags("London", []string{"floor:3"})
+	assert.NoError(t, err)
+	_, err = repo.ApplySensorBatch([]app.BatchOperation{{Op: app.BatchDelete, Name: "Berlin"}, {Op: app.BatchDelete, Name: "Madrid"}}, true)
+	assert.NoError(t, err)
+
+	_, err = repo.RemoveSensorTag("Paris", "retired")
+	assert.NoError(t, err)
+	_, err = repo.ApplySensorBatch([]app.BatchOperation{{Op: app.BatchDelete, Name: "Berlin"}}, false)
+	assert.NoError(t, err)
+
+	lastID, err := repo.LastSensorEventID()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), lastID)
+
+	events, err := repo.ListSensorEvents(2, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, int64(3), events[0].ID)
+		assert.Equal(t, app.SensorCreated, events[0].Type)
+		assert.Equal(t, "Berlin", events[0].Sensor.Name)
+		assert.Nil(t, events[0].Previous)
+
+		assert.Equal(t, app.SensorUpdated, events[1].Type)
+		assert.Equal(t, []string{"vendor:acme"}, events[1].Sensor.Tags)
+		if assert.NotNil(t, events[1].Previous) {
+			assert.Equal(t, []string{"vendor:acme", "retired"}, events[1].Previous.Tags)
+		}
+
+		assert.Equal(t, app.SensorDeleted, events[2].Type)
+		assert.Equal(t, "Berlin", events[2].Sensor.Name)
+	}
+
+	events, err = repo.ListSensorEvents(0, 2)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestMemoryRepository_PruneSensorEvents(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	// Events the webhook outbox hasn't read are kept, however old
+	deleted, err := repo.PruneSensorEvents(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	_, err = repo.EnqueueWebhookDeliveries(2)
+	assert.NoError(t, err)
+	deleted, err = repo.PruneSensorEvents(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	// Readers resuming from before the events pruned are told so
+	_, err = repo.ListSensorEvents(1, 10)
+	assert.ErrorIs(t, err, app.ErrSensorEventsPruned)
+	events, err := repo.ListSensorEvents(2, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, sensorEventIDs(events))
+
+	// IDs carry on after the events pruned
+	_, err = repo.AddSensorTags("Paris", []string{"outdoor"})
+	assert.NoError(t, err)
+	lastID, err := repo.LastSensorEventID()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), lastID)
+	events, err = repo.ListSensorEvents(3, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4}, sensorEventIDs(events))
+}
+
+// sensorEventIDs returns the IDs of events.
+func sensorEventIDs(events []app.SensorEvent) []int64 {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+// sseEvent is an event read from a Server-Sent Events stream.
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// openSensorEvents opens the event stream at the target, and returns a function reading its next event.
+func openSensorEvents(t *testing.T, repo app.Repository, target string, headers ...string) func() sseEvent {
+	server := httptest.NewServer(app.NewRouter(app.NewHandler(repo)))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+target, nil)
+	assert.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	return func() sseEvent {
+		var event sseEvent
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading event: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" && event.ID != "" {
+				return event
+			}
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				event.ID = value
+			case "event":
+				event.Event = value
+			case "data":
+				event.Data = value
+			}
+		}
+	}
+}
+
+func TestStreamSensorEventsResume(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	next := openSensorEvents(t, repo, "/v1/sensors/events?tags=vendor:acme", "Last-Event-ID", "1")
+
+	// Events after the last one received are replayed, without those of other sensors
+	event := next()
+	assert.Equal(t, sseEvent{ID: "2", Event: "created"}, sseEvent{ID: event.ID, Event: event.Event})
+	var sensor app.SensorMetadataV1
+	assert.NoError(t, json.Unmarshal([]byte(event.Data), &sensor))
+	assert.Equal(t, "Paris", sensor.Name)
+	assert.Equal(t, []app.Tag{{Key: "vendor", Value: "acme"}}, sensor.StructuredTags)
+
+	// Later changes are streamed as they happen
+	_, err := repo.AddSensorTags("Berlin", []string{"vendor:acme"})
+	assert.NoError(t, err)
+	event = next()
+	assert.Equal(t, "4", event.ID)
+	assert.Equal(t, "updated", event.Event)
+	assert.Contains(t, event.Data, `"name":"Berlin"`)
+}
+
+func TestStreamSensorEventsFromNow(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	next := openSensorEvents(t, repo, "/v1/sensors/events")
+
+	_, err := repo.ApplySensorBatch([]app.BatchOperation{{Op: app.BatchDelete, Name: "Paris"}}, false)
+	assert.NoError(t, err)
+	event := next()
+	assert.Equal(t, "4", event.ID)
+	assert.Equal(t, "deleted", event.Event)
+	assert.Contains(t, event.Data, `"name":"Paris"`)
+}
+
+func TestStreamSensorEventsWithinBoundingBox(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	// London moves out of the box, Berlin changes outside of it, and Paris moves into it
+	london, err := repo.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	london.Location = app.Location{Latitude: 40.4168, Longitude: -3.7038}
+	assert.NoError(t, repo.UpdateSensorMetadata(london))
+	_, err = repo.RemoveSensorTag("Berlin", "vendor:other")
+	assert.NoError(t, err)
+	paris, err := repo.GetSensorMetadataByName("Paris")
+	assert.NoError(t, err)
+	paris.Location = app.Location{Latitude: 51.4545, Longitude: -2.5879}
+	assert.NoError(t, repo.UpdateSensorMetadata(paris))
+
+	next := openSensorEvents(t, repo, "/v1/sensors/events?bbox=-3,50,1,53&last_event_id=3")
+	event := next()
+	assert.Equal(t, "4", event.ID)
+	assert.Contains(t, event.Data, `"name":"London"`)
+	event = next()
+	assert.Equal(t, "6", event.ID)
+	assert.Contains(t, event.Data, `"name":"Paris"`)
+}
+
+func TestStreamSensorEventsInvalidParameters(t *testing.T) {
+	router := app.NewRouter(app.NewHandler(newSeededMemoryRepository(t)))
+
+	for _, target := range []string{
+		"/v1/sensors/events?bbox=1,2,3",
+		"/v1/sensors/events?tags=(",
+		"/v1/sensors/events?last_event_id=-1",
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, target)
+	}
+}
+
+func TestStreamSensorEventsPruned(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	_, err := repo.EnqueueWebhookDeliveries(10)
+	assert.NoError(t, err)
+	_, err = repo.PruneSensorEvents(time.Now())
+	assert.NoError(t, err)
+	router := app.NewRouter(app.NewHandler(repo))
+
+	// Clients resuming from before the events retained must reload the sensors
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/sensors/events", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusGone, rr.Code)
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBoundingBox(t *testing.T) {
+	bbox, err := app.ParseBoundingBox("-0.5,51.3,0.3,51.7")
+	assert.NoError(t, err)
+	assert.Equal(t, app.BoundingBox{MinLon: -0.5, MinLat: 51.3, MaxLon: 0.3, MaxLat: 51.7}, bbox)
+	assert.False(t, bbox.CrossesAntimeridian())
+	assert.True(t, bbox.Contains(51.5074, -0.1278))
+	assert.False(t, bbox.Contains(48.8566, 2.3522))
+
+	for _, invalid := range []string{"", "1,2,3", "a,2,3,4", "0,60,1,50", "0,-91,1,50", "-181,0,1,1"} {
+		_, err := app.ParseBoundingBox(invalid)
+		assert.ErrorIs(t, err, app.ErrInvalidGeometry, invalid)
+	}
+}
+
+func TestBoundingBoxCrossingAntimeridian(t *testing.T) {
+	bbox, err := app.ParseBoundingBox("170,-20,-170,-10")
+	assert.NoError(t, err)
+	assert.True(t, bbox.CrossesAntimeridian())
+
+	assert.True(t, bbox.Contains(-15, 178))
+	assert.True(t, bbox.Contains(-15, -175))
+	assert.False(t, bbox.Contains(-15, 0))
+}
+
+func TestParseGeoJSONPolygonWithHole(t *testing.T) {
+	polygons, err := app.ParseGeoJSONPolygons([]byte(`{
+		"type": "Feature",
+		"geometry": {
+			"type": "Polygon",
+			"coordinates": [
+				[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+				[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]
+			]
+		}
+	}`))
+	assert.NoError(t, err)
+	assert.Len(t, polygons, 1)
+
+	polygon := polygons[0]
+	assert.True(t, polygon.Contains(2, 2))
+	assert.True(t, polygon.Contains(0, 5), "a point on the outer boundary is inside")
+	assert.False(t, polygon.Contains(5, 5), "a point in the hole is outside")
+	assert.False(t, polygon.Contains(11, 5))
+}
+
+func TestParseGeoJSONPolygonCrossingAntimeridian(t *testing.T) {
+	polygons, err := app.ParseGeoJSONPolygons([]byte(`{
+		"type": "MultiPolygon",
+		"coordinates": [[[[170, -20], [-170, -20], [-170, -10], [170, -10], [170, -20]]]]
+	}`))
+	assert.NoError(t, err)
+	assert.Len(t, polygons, 1)
+
+	polygon := polygons[0]
+	assert.True(t, polygon.Contains(-15, 178))
+	assert.True(t, polygon.Contains(-15, -175))
+	assert.False(t, polygon.Contains(-15, 0))
+	assert.True(t, polygon.Bounds().CrossesAntimeridian())
+}
+
+func TestParseGeoJSONPolygonsInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		`[]`,
+		`{"type": "Point", "coordinates": [0, 0]}`,
+		`{"type": "Feature", "geometry": null}`,
+		`{"type": "Polygon", "coordinates": []}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 95], [0, 0]]]}`,
+		`{"type": "MultiPolygon", "coordinates": []}`,
+	} {
+		_, err := app.ParseGeoJSONPolygons([]byte(invalid))
+		assert.ErrorIs(t, err, app.ErrInvalidGeometry, invalid)
+	}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerListSensorMetadataGeoJSON(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors?tags=vendor:acme&limit=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/geo+json, application/json;q=0.5")
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, app.GeoJSONMediaType, rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"features": [{
+			"type": "Feature",
+			"id": 1,
+			"geometry": {"type": "Point", "coordinates": [-0.1278, 51.5074]},
+			"properties": {"name": "London", "tags": ["vendor:acme", "floor:3"]}
+		}],
+		"next": "1"
+	}`, rr.Body.String())
+}
+
+func TestHandlerGetNearestSensorMetadataGeoJSON(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors/nearest?latitude=48.8&longitude=2.3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/geo+json")
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var collection app.FeatureCollection
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &collection))
+	assert.Len(t, collection.Features, 1)
+	assert.Equal(t, "Paris", collection.Features[0].Properties.Name)
+	assert.Greater(t, collection.Features[0].Properties.Distance, 0.0)
+}
+
+func TestHandlerImportSensorMetadataGeoJSON(t *testing.T) {
+	repo := app.NewMemoryRepository()
+
+	body := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-0.1278, 51.5074, 35]}, "properties": {"name": "London", "tags": ["Vendor:Acme"]}},
+		{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}, "properties": {"name": "Area"}},
+		{"type": "Feature", "geometry": null, "properties": {"name": "Nowhere"}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}, "properties": {}}
+	]}`
+	req, err := http.NewRequest(http.MethodPost, "/sensors/import", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/geo+json")
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var result app.ImportResult
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(t, 4, result.Rows)
+	assert.Equal(t, 1, result.Imported)
+	assert.Len(t, result.Errors, 3)
+	assert.Equal(t, 1, *result.Errors[0].Feature)
+	assert.Equal(t, "Area", result.Errors[0].Name)
+	assert.Contains(t, result.Errors[0].Error, `geometry must be a Point, got "Polygon"`)
+	assert.Contains(t, result.Errors[1].Error, "feature has no geometry")
+	assert.Equal(t, 3, *result.Errors[2].Feature)
+
+	sensor, err := repo.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	assert.Equal(t, 35.0, *sensor.Location.Altitude)
+	assert.Equal(t, app.DatumWGS84, sensor.Location.AltitudeDatum)
+	assert.Equal(t, []string{"vendor:acme"}, sensor.Tags)
+}
+
+func TestHandlerImportSensorMetadataGeoJSONStream(t *testing.T) {
+	importGeoJSON := func(repo app.Repository, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/sensors/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/geo+json")
+		rr := httptest.NewRecorder()
+		app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A single Feature, whatever the order of its members
+	repo := app.NewMemoryRepository()
+	rr := importGeoJSON(repo, `{"properties": {"name": "Paris"}, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}, "type": "Feature"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	_, err := repo.GetSensorMetadataByName("Paris")
+	assert.NoError(t, err)
+
+	// A document cut short stops the import
+	rr = importGeoJSON(repo, `{"type": "FeatureCollection", "bbox": [0, 0, 1, 1], "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-0.1278, 51.5074]}, "properties": {"name": "London"}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [13.4, 52.5]}, "properties": {"name": "Berlin"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "body is not a GeoJSON object")
+
+	rr = importGeoJSON(repo, `{"type": "GeometryCollection", "geometries": []}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `expected a FeatureCollection or Feature, got \"GeometryCollection\"`)
+
+	// Bodies over the import limit are rejected as too large, in either format
+	padding := strings.Repeat(" ", 32<<20)
+	rr = importGeoJSON(app.NewMemoryRepository(), `{"type": "FeatureCollection", "features": [`+padding+`]}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/sensors/import", strings.NewReader("name,latitude,longitude\nSensor1,1,1,"+padding+"\n"))
+	rr = httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(app.NewMemoryRepository())).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestHandlerExportSensorMetadataGeoJSON(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors/export?tags=NOT+retired", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/geo+json")
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, app.GeoJSONMediaType, rr.Header().Get("Content-Type"))
+	var collection app.FeatureCollection
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	assert.Len(t, collection.Features, 2)
+	assert.Equal(t, "Berlin", collection.Features[1].Properties.Name)
+}
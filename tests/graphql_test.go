@@ -0,0 +1,307 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// countingRepository counts the calls made to the repository it wraps.
+type countingRepository struct {
+	app.Repository
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newCountingRepository(repo app.Repository) *countingRepository {
+	return &countingRepository{Repository: repo, calls: map[string]int{}}
+}
+
+func (r *countingRepository) count(method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[method]++
+}
+
+func (r *countingRepository) GetSensorMetadataByName(name string) (*app.SensorMetadata, error) {
+	r.count("GetSensorMetadataByName")
+	return r.Repository.GetSensorMetadataByName(name)
+}
+
+func (r *countingRepository) ListSensorMetadata(filter app.SensorFilter) ([]app.SensorMetadata, error) {
+	r.count("ListSensorMetadata")
+	return r.Repository.ListSensorMetadata(filter)
+}
+
+func (r *countingRepository) GetSensorType(name string) (*app.SensorType, error) {
+	r.count("GetSensorType")
+	return r.Repository.GetSensorType(name)
+}
+
+func (r *countingRepository) ListSensorTypes() ([]app.SensorType, error) {
+	r.count("ListSensorTypes")
+	return r.Repository.ListSensorTypes()
+}
+
+func (r *countingRepository) FindNearestSensorMetadata(query app.NearestQuery) ([]app.NearestSensor, error) {
+	r.count("FindNearestSensorMetadata")
+	return r.Repository.FindNearestSensorMetadata(query)
+}
+
+func (r *countingRepository) FindNeighbourSensorMetadata(query app.NeighbourQuery) (map[int][]app.NearestSensor, error) {
+	r.count("FindNeighbourSensorMetadata")
+	return r.Repository.FindNeighbourSensorMetadata(query)
+}
+
+type graphQLResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Path       []interface{}          `json:"path"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func runGraphQL(t *testing.T, repo app.Repository, query string, variables map[string]interface{}) (int, graphQLResponse) {
+	body, err := json.Marshal(app.GraphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(string(body)))
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	var response graphQLResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), rr.Body.String())
+	return rr.Code, response
+}
+
+func TestGraphQLQueryInOneRoundTrip(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	code, response := runGraphQL(t, repo, `{
+		london: sensor(name: "London") { id name structuredTags { key value } }
+		lisbon: sensor(name: "Lisbon") { id }
+		sensors(filter: {tags: "vendor:acme"}, first: 1) { nodes { name } next }
+		nearest(lat: 51.5, lon: 0, k: 2) { name distance }
+		tags { tag count }
+	}`, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"id": 1, "name": "London", "structuredTags": [{"key": "vendor", "value": "acme"}, {"key": "floor", "value": "3"}]}`, string(response.Data["london"]))
+	assert.JSONEq(t, `null`, string(response.Data["lisbon"]))
+	assert.JSONEq(t, `{"nodes": [{"name": "London"}], "next": "1"}`, string(response.Data["sensors"]))
+	assert.Contains(t, string(response.Data["nearest"]), `"name":"Paris"`)
+	assert.Contains(t, string(response.Data["tags"]), `{"count":2,"tag":"vendor:acme"}`)
+}
+
+func TestGraphQLBatchesRepositoryCalls(t *testing.T) {
+	memory := newSeededMemoryRepository(t)
+	for _, sensorType := range []string{"thermometer", "hygrometer"} {
+		assert.NoError(t, memory.CreateSensorType(&app.SensorType{Name: sensorType, Schema: json.RawMessage(`{"type": "object"}`)}))
+	}
+	for i, name := range []string{"London", "Paris", "Berlin"} {
+		sensor, err := memory.GetSensorMetadataByName(name)
+		assert.NoError(t, err)
+		sensor.Type = []string{"thermometer", "hygrometer", "thermometer"}[i]
+		assert.NoError(t, memory.UpdateSensorMetadata(sensor))
+	}
+	repo := newCountingRepository(memory)
+
+	code, response := runGraphQL(t, repo, `{
+		a: sensor(name: "London") { name type { name } }
+		b: sensor(name: "Paris") { name type { name } }
+		c: sensor(name: "London") { id }
+		sensors { nodes { name type { name schema } } }
+	}`, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"name": "Paris", "type": {"name": "hygrometer"}}`, string(response.Data["b"]))
+	assert.Contains(t, string(response.Data["sensors"]), `{"name":"Berlin","type":{"name":"thermometer","schema":{"type":"object"}}}`)
+
+	// One query for the sensors by name, one for the page, and one for all their types
+	assert.Equal(t, map[string]int{"ListSensorMetadata": 2, "ListSensorTypes": 1}, repo.calls)
+}
+
+func TestGraphQLBatchesNeighbours(t *testing.T) {
+	repo := newCountingRepository(newSeededMemoryRepository(t))
+
+	code, response := runGraphQL(t, repo, `{
+		sensors {
+			nodes {
+				name
+				neighbours(k: 1) { name }
+				active: neighbours(k: 1, tags: "NOT retired") { name }
+			}
+		}
+	}`, nil)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"nodes": [
+		{"name": "London", "neighbours": [{"name": "Paris"}], "active": [{"name": "Berlin"}]},
+		{"name": "Paris", "neighbours": [{"name": "London"}], "active": [{"name": "London"}]},
+		{"name": "Berlin", "neighbours": [{"name": "Paris"}], "active": [{"name": "London"}]}
+	]}`, string(response.Data["sensors"]))
+
+	// One query for the page, and one for the neighbours of all its sensors per set of arguments
+	assert.Equal(t, map[string]int{"ListSensorMetadata": 1, "FindNeighbourSensorMetadata": 2}, repo.calls)
+
+	_, response = runGraphQL(t, repo, `{ sensors { nodes { neighbours(k: 0) { name } } } }`, nil)
+	if assert.NotEmpty(t, response.Errors) {
+		assert.Equal(t, "BAD_USER_INPUT", response.Errors[0].Extensions["code"])
+	}
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	code, response := runGraphQL(t, repo, `mutation($input: SensorInput!) {
+		createSensor(input: $input) { id name tags location { altitude altitudeDatum floor } attributes }
+	}`, map[string]interface{}{"input": map[string]interface{}{
+		"name":       "Madrid",
+		"location":   map[string]interface{}{"latitude": 40.4168, "longitude": -3.7038, "altitude": 667, "floor": 2},
+		"tags":       []string{"Vendor:Acme"},
+		"attributes": map[string]interface{}{"model": "x1"},
+	}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"id": 4, "name": "Madrid", "tags": ["vendor:acme"], "location": {"altitude": 667, "altitudeDatum": "WGS84", "floor": 2}, "attributes": {"model": "x1"}}`, string(response.Data["createSensor"]))
+
+	_, response = runGraphQL(t, repo, `mutation {
+		updateSensor(name: "Madrid", input: {name: "Madrid Centro", location: {latitude: 40.4, longitude: -3.7}}) { id name tags }
+		addSensorTags(name: "Madrid Centro", tags: ["Outdoor"]) { tags }
+		removeSensorTag(name: "Madrid Centro", tag: "outdoor") { tags }
+		deleteSensor(name: "Madrid Centro")
+	}`, nil)
+	assert.Empty(t, response.Errors)
+	assert.JSONEq(t, `{"id": 4, "name": "Madrid Centro", "tags": []}`, string(response.Data["updateSensor"]))
+	assert.JSONEq(t, `{"tags": ["outdoor"]}`, string(response.Data["addSensorTags"]))
+	assert.JSONEq(t, `{"tags": []}`, string(response.Data["removeSensorTag"]))
+	assert.JSONEq(t, `true`, string(response.Data["deleteSensor"]))
+
+	_, err := repo.GetSensorMetadataByName("Madrid Centro")
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	_, response := runGraphQL(t, repo, `mutation {
+		createSensor(input: {name: "London", location: {latitude: 1, longitude: 1}}) { id }
+	}`, nil)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "ALREADY_EXISTS", response.Errors[0].Extensions["code"])
+		assert.Equal(t, []interface{}{"createSensor"}, response.Errors[0].Path)
+	}
+
+	_, response = runGraphQL(t, repo, `mutation {
+		createSensor(input: {name: "", location: {latitude: 1, longitude: 1, altitudeDatum: "sea"}}) { id }
+	}`, nil)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "BAD_USER_INPUT", response.Errors[0].Extensions["code"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"field": "input.name", "message": "is required"},
+			map[string]interface{}{"field": "input.location.altitudeDatum", "message": "must be one of WGS84, MSL"},
+		}, response.Errors[0].Extensions["fields"])
+	}
+
+	_, response = runGraphQL(t, repo, `mutation { deleteSensor(name: "Lisbon") }`, nil)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "NOT_FOUND", response.Errors[0].Extensions["code"])
+	}
+
+	_, response = runGraphQL(t, repo, `{ sensors(first: 0) { next } }`, nil)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "BAD_USER_INPUT", response.Errors[0].Extensions["code"])
+	}
+
+	// Documents that don't validate don't run
+	code, response := runGraphQL(t, repo, `{ sensor(name: "London") { colour } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Nil(t, response.Data)
+	assert.Len(t, response.Errors, 1)
+}
+
+func TestGraphQLComplexityLimit(t *testing.T) {
+	repo := newCountingRepository(newSeededMemoryRepository(t))
+
+	// A full page of sensors is allowed
+	code, response := runGraphQL(t, repo, `{ sensors(first: 1000) { nodes { id name tags location { latitude longitude } } } }`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+
+	// Aliases, fragments and variables all count
+	code, response = runGraphQL(t, repo, `query($k: Int) {
+		a: sensors(first: 1000) { ...page }
+		b: sensors(first: 1000) { ...page }
+		nearest(lat: 0, lon: 0, k: $k) { name }
+	}
+	fragment page on SensorConnection { nodes { id name tags location { latitude longitude } } }`, map[string]interface{}{"k": 1000})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Nil(t, response.Data)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "QUERY_TOO_COMPLEX", response.Errors[0].Extensions["code"])
+		assert.Contains(t, response.Errors[0].Message, "Query complexity 15003 exceeds the limit of 15000")
+	}
+	assert.Equal(t, 1, repo.calls["ListSensorMetadata"])
+
+	// So do the neighbours of every sensor of a page
+	code, response = runGraphQL(t, repo, `{ sensors(first: 1000) { nodes { neighbours(k: 20) { name } } } }`, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Contains(t, response.Errors[0].Message, "Query complexity 22001 exceeds the limit of 15000")
+	}
+}
+
+func TestGraphQLGet(t *testing.T) {
+	router := app.NewRouter(app.NewHandler(newSeededMemoryRepository(t)))
+
+	query := url.Values{"query": {`query($name: String!) { sensor(name: $name) { id } }`}, "variables": {`{"name": "Berlin"}`}}
+	req := httptest.NewRequest(http.MethodGet, "/v1/graphql?"+query.Encode(), nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"data": {"sensor": {"id": 3}}}`, rr.Body.String())
+
+	// Mutations aren't run for GET requests
+	query = url.Values{"query": {`mutation { deleteSensor(name: "Berlin") }`}}
+	req = httptest.NewRequest(http.MethodGet, "/v1/graphql?"+query.Encode(), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, http.MethodPost, rr.Header().Get("Allow"))
+}
+
+// failingRepository fails to list sensors.
+type failingRepository struct {
+	app.Repository
+}
+
+func (failingRepository) ListSensorMetadata(app.SensorFilter) ([]app.SensorMetadata, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestGraphQLRepositoryFailure(t *testing.T) {
+	code, response := runGraphQL(t, failingRepository{newSeededMemoryRepository(t)}, `{ sensor(name: "London") { id } tags { tag } }`, nil)
+
+	// The sensor fails on its own, with a code but without the details
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `null`, string(response.Data["sensor"]))
+	assert.NotEqual(t, `null`, string(response.Data["tags"]))
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "Failed to get sensor metadata", response.Errors[0].Message)
+		assert.Equal(t, "INTERNAL_SERVER_ERROR", response.Errors[0].Extensions["code"])
+	}
+}
@@ -0,0 +1,291 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/skartikey/sensor-metadata/app"
+	sensorv1 "github.com/skartikey/sensor-metadata/proto/sensor/v1"
+)
+
+func newGRPCClient(t *testing.T, repo app.Repository) sensorv1.SensorMetadataServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	app.NewGRPCServer(repo).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return sensorv1.NewSensorMetadataServiceClient(conn)
+}
+
+func TestGRPCSensorLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newGRPCClient(t, newSeededMemoryRepository(t))
+
+	altitude := 650.0
+	madrid := &sensorv1.Sensor{
+		Name:     "Madrid",
+		Location: &sensorv1.Location{Latitude: 40.4168, Longitude: -3.7038, Altitude: &altitude},
+		Tags:     []string{"Vendor:Acme"},
+		Attributes: map[string]*sensorv1.AttributeValue{
+			"model":  {Kind: &sensorv1.AttributeValue_String_{String_: "x1"}},
+			"limits": {Kind: &sensorv1.AttributeValue_Json{Json: `{"min": -40}`}},
+		},
+	}
+	created, err := c.CreateSensor(ctx, &sensorv1.CreateSensorRequest{Sensor: madrid})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), created.Sensor.Id)
+	assert.Equal(t, []string{"vendor:acme"}, created.Sensor.Tags)
+	assert.Equal(t, app.DatumWGS84, created.Sensor.Location.AltitudeDatum)
+	assert.Equal(t, "x1", created.Sensor.Attributes["model"].GetString_())
+	assert.JSONEq(t, `{"min": -40}`, created.Sensor.Attributes["limits"].GetJson())
+
+	_, err = c.CreateSensor(ctx, &sensorv1.CreateSensorRequest{Sensor: madrid})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	sensor := created.Sensor
+	sensor.Name = "Madrid Centro"
+	updated, err := c.UpdateSensor(ctx, &sensorv1.UpdateSensorRequest{Sensor: sensor})
+	assert.NoError(t, err)
+	assert.Equal(t, "Madrid Centro", updated.Sensor.Name)
+
+	_, err = c.GetSensor(ctx, &sensorv1.GetSensorRequest{Name: "Madrid"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	got, err := c.GetSensor(ctx, &sensorv1.GetSensorRequest{Name: "Madrid Centro"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), got.Sensor.Id)
+
+	// Renaming onto another sensor's name conflicts, and an unknown ID isn't found
+	sensor.Name = "London"
+	_, err = c.UpdateSensor(ctx, &sensorv1.UpdateSensorRequest{Sensor: sensor})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	sensor.Id, sensor.Name = 99, "Nowhere"
+	_, err = c.UpdateSensor(ctx, &sensorv1.UpdateSensorRequest{Sensor: sensor})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = c.DeleteSensor(ctx, &sensorv1.DeleteSensorRequest{Name: "Madrid Centro"})
+	assert.NoError(t, err)
+	_, err = c.DeleteSensor(ctx, &sensorv1.DeleteSensorRequest{Name: "Madrid Centro"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+	c := newGRPCClient(t, &laggingRepository{Repository: app.NewMemoryRepository(), replica: app.NewMemoryRepository()})
+	get := func(pairs ...string) codes.Code {
+		_, err := c.GetSensor(metadata.NewOutgoingContext(ctx, metadata.Pairs(pairs...)), &sensorv1.GetSensorRequest{Name: "Madrid"})
+		return status.Code(err)
+	}
+
+	// Writes read the sensor back from the primary, and return a session token
+	var header metadata.MD
+	created, err := c.CreateSensor(ctx, &sensorv1.CreateSensorRequest{Sensor: &sensorv1.Sensor{
+		Name:     "Madrid",
+		Location: &sensorv1.Location{Latitude: 40.4168, Longitude: -3.7038},
+	}}, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Equal(t, "Madrid", created.Sensor.Name)
+	tokens := header.Get(app.SessionTokenMetadataKey)
+	assert.Len(t, tokens, 1)
+
+	created.Sensor.Tags = []string{"floor:2"}
+	updated, err := c.UpdateSensor(ctx, &sensorv1.UpdateSensorRequest{Sensor: created.Sensor})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"floor:2"}, updated.Sensor.Tags)
+
+	// The replica hasn't seen the writes, unless the client asks to read them
+	assert.Equal(t, codes.NotFound, get())
+	assert.Equal(t, codes.OK, get(app.ReadYourWritesMetadataKey, "true"))
+	assert.Equal(t, codes.OK, get(app.SessionTokenMetadataKey, tokens[0]))
+	expired := strconv.FormatInt(time.Now().Add(-2*time.Minute).UnixMilli(), 10)
+	assert.Equal(t, codes.NotFound, get(app.SessionTokenMetadataKey, expired))
+}
+
+func TestGRPCInvalidSensor(t *testing.T) {
+	c := newGRPCClient(t, app.NewMemoryRepository())
+
+	_, err := c.CreateSensor(context.Background(), &sensorv1.CreateSensorRequest{Sensor: &sensorv1.Sensor{
+		Location: &sensorv1.Location{Latitude: 1, Longitude: 1, AltitudeDatum: "sea"},
+	}})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	var violations []string
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				violations = append(violations, violation.Field+": "+violation.Description)
+			}
+		}
+	}
+	assert.Equal(t, []string{"name: is required", "location.altitude_datum: must be one of WGS84, MSL"}, violations)
+
+	_, err = c.CreateSensor(context.Background(), &sensorv1.CreateSensorRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCListSensors(t *testing.T) {
+	ctx := context.Background()
+	c := newGRPCClient(t, newSeededMemoryRepository(t))
+
+	page, err := c.ListSensors(ctx, &sensorv1.ListSensorsRequest{PageSize: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.Sensors, 2)
+	assert.Equal(t, "2", page.NextPageToken)
+
+	page, err = c.ListSensors(ctx, &sensorv1.ListSensorsRequest{PageSize: 2, PageToken: page.NextPageToken})
+	assert.NoError(t, err)
+	assert.Len(t, page.Sensors, 1)
+	assert.Equal(t, "Berlin", page.Sensors[0].Name)
+	assert.Empty(t, page.NextPageToken)
+
+	page, err = c.ListSensors(ctx, &sensorv1.ListSensorsRequest{Filter: &sensorv1.SensorFilter{Tags: "vendor:acme AND NOT retired"}})
+	assert.NoError(t, err)
+	assert.Len(t, page.Sensors, 1)
+	assert.Equal(t, "London", page.Sensors[0].Name)
+
+	for _, request := range []*sensorv1.ListSensorsRequest{
+		{PageSize: 1001},
+		{PageToken: "next"},
+		{Filter: &sensorv1.SensorFilter{Tags: "vendor:acme AND"}},
+		{Filter: &sensorv1.SensorFilter{Attributes: []string{"model=x1"}}},
+	} {
+		_, err := c.ListSensors(ctx, request)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), request.String())
+	}
+}
+
+func TestGRPCStreamSensors(t *testing.T) {
+	repo := app.NewMemoryRepository()
+	// Enough sensors to span more than one page of the repository
+	for i := 0; i < 1500; i++ {
+		sensor := app.SensorMetadata{Name: fmt.Sprintf("Sensor%d", i), Location: app.Location{Latitude: 1, Longitude: 1}}
+		if i%2 == 0 {
+			sensor.Attributes = map[string]interface{}{"sampling_rate": float64(i)}
+		}
+		assert.NoError(t, repo.CreateSensorMetadata(&sensor))
+	}
+	c := newGRPCClient(t, repo)
+
+	count := func(filter *sensorv1.SensorFilter) int {
+		stream, err := c.StreamSensors(context.Background(), &sensorv1.StreamSensorsRequest{Filter: filter})
+		assert.NoError(t, err)
+		n := 0
+		for {
+			_, err := stream.Recv()
+			if err == io.EOF {
+				return n
+			}
+			if !assert.NoError(t, err) {
+				return n
+			}
+			n++
+		}
+	}
+	assert.Equal(t, 1500, count(nil))
+	assert.Equal(t, 250, count(&sensorv1.SensorFilter{Attributes: []string{"attr.sampling_rate>=1000"}}))
+}
+
+func TestGRPCNearestSensors(t *testing.T) {
+	ctx := context.Background()
+	c := newGRPCClient(t, newSeededMemoryRepository(t))
+
+	response, err := c.NearestSensors(ctx, &sensorv1.NearestSensorsRequest{Latitude: 51.5, Longitude: 0, Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, response.Sensors, 2) {
+		assert.Equal(t, "London", response.Sensors[0].Sensor.Name)
+		assert.Equal(t, "Paris", response.Sensors[1].Sensor.Name)
+		assert.InDelta(t, 8900, response.Sensors[0].Distance, 100)
+	}
+
+	response, err = c.NearestSensors(ctx, &sensorv1.NearestSensorsRequest{Latitude: 51.5, Longitude: 0, Filter: &sensorv1.SensorFilter{Tags: "vendor:other"}})
+	assert.NoError(t, err)
+	if assert.Len(t, response.Sensors, 1) {
+		assert.Equal(t, "Berlin", response.Sensors[0].Sensor.Name)
+	}
+
+	_, err = c.NearestSensors(ctx, &sensorv1.NearestSensorsRequest{DistanceMode: sensorv1.DistanceMode_DISTANCE_MODE_3D})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = c.NearestSensors(ctx, &sensorv1.NearestSensorsRequest{PreferSameFloor: true})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCSensorsWithin(t *testing.T) {
+	ctx := context.Background()
+	c := newGRPCClient(t, newSeededMemoryRepository(t))
+
+	response, err := c.SensorsWithin(ctx, &sensorv1.SensorsWithinRequest{Area: &sensorv1.SensorsWithinRequest_BoundingBox{
+		BoundingBox: &sensorv1.BoundingBox{MinLongitude: -1, MinLatitude: 48, MaxLongitude: 3, MaxLatitude: 52},
+	}})
+	assert.NoError(t, err)
+	assert.Len(t, response.Sensors, 2)
+
+	response, err = c.SensorsWithin(ctx, &sensorv1.SensorsWithinRequest{Area: &sensorv1.SensorsWithinRequest_Geojson{
+		Geojson: `{"type": "Polygon", "coordinates": [[[13, 52], [14, 52], [14, 53], [13, 53], [13, 52]]]}`,
+	}})
+	assert.NoError(t, err)
+	if assert.Len(t, response.Sensors, 1) {
+		assert.Equal(t, "Berlin", response.Sensors[0].Name)
+	}
+
+	for _, request := range []*sensorv1.SensorsWithinRequest{
+		{},
+		{Area: &sensorv1.SensorsWithinRequest_BoundingBox{BoundingBox: &sensorv1.BoundingBox{MinLatitude: 10, MaxLatitude: -10}}},
+		{Area: &sensorv1.SensorsWithinRequest_Geojson{Geojson: `{"type": "Point", "coordinates": [0, 0]}`}},
+	} {
+		_, err := c.SensorsWithin(ctx, request)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), request.String())
+	}
+}
+
+func TestGRPCMultiplexedWithREST(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	grpcServer := grpc.NewServer()
+	app.NewGRPCServer(repo).Register(grpcServer)
+	server := httptest.NewServer(app.MultiplexGRPC(grpcServer, app.NewRouter(app.NewHandler(repo))))
+	t.Cleanup(server.Close)
+
+	conn, err := grpc.DialContext(context.Background(), strings.TrimPrefix(server.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	response, err := sensorv1.NewSensorMetadataServiceClient(conn).GetSensor(context.Background(), &sensorv1.GetSensorRequest{Name: "Paris"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), response.Sensor.Id)
+
+	// REST requests on the same port still reach the router
+	rr, err := server.Client().Get(server.URL + "/v1/sensors?name=Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rr.Body.Close()
+	assert.Equal(t, http.StatusOK, rr.StatusCode)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/skartikey/sensor-metadata/app"
 	"github.com/stretchr/testify/assert"
@@ -87,6 +88,486 @@ func TestGetSensorMetadata(t *testing.T) {
 	assert.Equal(t, expectedSensor, responseSensor)
 }
 
+func TestHandlerAddSensorTags(t *testing.T) {
+	// Prepare mock data
+	expectedSensor := app.SensorMetadata{
+		ID:   1,
+		Name: "Sensor1",
+		Location: app.Location{
+			Latitude:  51.5,
+			Longitude: -0.12,
+		},
+		Tags: []string{"tag1", "floor:3"},
+	}
+	payload := []byte(`{"tags": [" Floor:3 ", "floor:3"]}`)
+
+	// Create a request with the payload
+	req, err := http.NewRequest(http.MethodPost, "/sensors/Sensor1/tags", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a ResponseRecorder to capture the response
+	rr := httptest.NewRecorder()
+
+	// Create a mock repository expecting the normalized, de-duplicated tags
+	repo := &MockRepository{}
+	repo.On("AddSensorTags", "Sensor1", []string{"floor:3"}).Return(&expectedSensor, nil)
+
+	// Serve the request through the router so path variables are populated
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	// Assert the status code and response
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var responseSensor app.SensorMetadata
+	err = json.Unmarshal(rr.Body.Bytes(), &responseSensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expectedSensor, responseSensor)
+	repo.AssertExpectations(t)
+}
+
+func TestHandlerAddSensorTagsInvalidTag(t *testing.T) {
+	payload := []byte(`{"tags": ["bad tag!"]}`)
+
+	req, err := http.NewRequest(http.MethodPost, "/sensors/Sensor1/tags", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	repo.AssertNotCalled(t, "AddSensorTags", mock.Anything, mock.Anything)
+}
+
+func TestHandlerRemoveSensorTagNotFound(t *testing.T) {
+	req, err := http.NewRequest(http.MethodDelete, "/sensors/Missing/tags/TAG1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	repo.On("RemoveSensorTag", "Missing", "tag1").Return((*app.SensorMetadata)(nil), app.ErrSensorNotFound)
+
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	repo.AssertExpectations(t)
+}
+
+func TestHandlerGetTags(t *testing.T) {
+	expectedTagCounts := []app.TagCount{{Tag: "floor:3", Count: 2}, {Tag: "tag1", Count: 1}}
+
+	req, err := http.NewRequest(http.MethodGet, "/tags", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	repo.On("GetTagCounts").Return(expectedTagCounts, nil)
+
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var responseTagCounts []app.TagCount
+	err = json.Unmarshal(rr.Body.Bytes(), &responseTagCounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expectedTagCounts, responseTagCounts)
+}
+
+func TestHandlerListSensorMetadata(t *testing.T) {
+	sensors := []app.SensorMetadata{
+		{ID: 3, Name: "Sensor3", Location: app.Location{Latitude: 51.5, Longitude: -0.12}, Tags: []string{"vendor:acme", "floor:3"}},
+		{ID: 7, Name: "Sensor7", Location: app.Location{Latitude: 51.6, Longitude: -0.13}, Tags: []string{"vendor:acme", "floor:4"}},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors?tags=vendor:acme+AND+(floor:3+OR+floor:4)&limit=2&after=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	repo.On("ListSensorMetadata", mock.MatchedBy(func(filter app.SensorFilter) bool {
+		return filter.Tags.String() == "(vendor:acme AND (floor:3 OR floor:4))" && filter.AfterID == 1 && filter.Limit == 2
+	})).Return(sensors, nil)
+
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var sensorList app.SensorListV1
+	err = json.Unmarshal(rr.Body.Bytes(), &sensorList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "7", sensorList.Next)
+	assert.Len(t, sensorList.Sensors, 2)
+	assert.Equal(t, []app.Tag{{Key: "vendor", Value: "acme"}, {Key: "floor", Value: "4"}}, sensorList.Sensors[1].StructuredTags)
+	repo.AssertExpectations(t)
+}
+
+func TestHandlerListSensorMetadataInvalidTags(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/sensors?tags=vendor:acme+AND", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHandlerCreateSensorMetadataStructuredTags(t *testing.T) {
+	payload := []byte(`{"name": "Sensor1", "location": {"latitude": 51.5, "longitude": -0.12}, "tags": ["Retired"], "structured_tags": [{"key": "Floor", "value": "3"}]}`)
+
+	req, err := http.NewRequest(http.MethodPost, "/sensors", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	repo.On("CreateSensorMetadata", mock.MatchedBy(func(sensor *app.SensorMetadata) bool {
+		return assert.ObjectsAreEqual([]string{"retired", "floor:3"}, sensor.Tags)
+	})).Return(nil)
+
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	repo.AssertExpectations(t)
+}
+
+func TestHandlerGetNearestSensorMetadataWithTags(t *testing.T) {
+	nearest := app.NearestSensor{SensorMetadata: app.SensorMetadata{ID: 2, Name: "Sensor2", Location: app.Location{Latitude: 51.5, Longitude: -0.12}, Tags: []string{"vendor:acme"}}, Distance: 42}
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors/nearest?latitude=51.5&longitude=-0.1&tags=vendor:acme+AND+NOT+retired", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	repo.On("FindNearestSensorMetadata", mock.MatchedBy(func(query app.NearestQuery) bool {
+		return query.Latitude == 51.5 && query.Longitude == -0.1 && query.Limit == 1 && query.Tags.String() == "(vendor:acme AND NOT retired)"
+	})).Return([]app.NearestSensor{nearest}, nil)
+
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var responseSensor app.NearestSensorV1
+	err = json.Unmarshal(rr.Body.Bytes(), &responseSensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Sensor2", responseSensor.Name)
+	assert.Equal(t, []app.Tag{{Key: "vendor", Value: "acme"}}, responseSensor.StructuredTags)
+	assert.Equal(t, 42.0, responseSensor.Distance)
+	repo.AssertExpectations(t)
+}
+
+func TestHandlerCreateSensorTypeInvalidSchema(t *testing.T) {
+	payload := []byte(`{"name": "thermometer", "schema": {"type": "thing"}}`)
+
+	req, err := http.NewRequest(http.MethodPost, "/sensor-types", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	repo.AssertNotCalled(t, "CreateSensorType", mock.Anything)
+}
+
+func TestHandlerSensorTypeUnsupportedKeyword(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/sensor-types"},
+		{http.MethodPut, "/sensor-types/thermometer"},
+	} {
+		payload := []byte(`{"name": "thermometer", "schema": {"type": "object", "properties": {"serial": {"type": "string", "format": "uuid"}}}}`)
+
+		req, err := http.NewRequest(tc.method, tc.path, bytes.NewBuffer(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		repo := &MockRepository{}
+		app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tc.method)
+		assert.Contains(t, rr.Body.String(), `unsupported keyword \"format\"`, tc.method)
+		repo.AssertNotCalled(t, "CreateSensorType", mock.Anything)
+		repo.AssertNotCalled(t, "UpdateSensorType", mock.Anything)
+	}
+}
+
+func TestHandlerCreateSensorMetadataValidatesAttributes(t *testing.T) {
+	thermometer := app.SensorType{
+		Name:   "thermometer",
+		Schema: []byte(`{"type": "object", "required": ["sampling_rate"], "properties": {"sampling_rate": {"type": "number", "minimum": 1}}}`),
+	}
+
+	for _, tc := range []struct {
+		attributes string
+		status     int
+	}{
+		{`{"sampling_rate": 10}`, http.StatusCreated},
+		{`{"sampling_rate": 0}`, http.StatusBadRequest},
+		{`{}`, http.StatusBadRequest},
+	} {
+		payload := []byte(`{"name": "Sensor1", "type": "thermometer", "location": {"latitude": 51.5, "longitude": -0.12}, "attributes": ` + tc.attributes + `}`)
+
+		req, err := http.NewRequest(http.MethodPost, "/sensors", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+
+		repo := &MockRepository{}
+		repo.On("GetSensorType", "thermometer").Return(&thermometer, nil)
+		repo.On("CreateSensorMetadata", mock.AnythingOfType("*app.SensorMetadata")).Return(nil)
+
+		app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code, "attributes %s", tc.attributes)
+	}
+}
+
+func TestHandlerCreateSensorMetadataUnknownType(t *testing.T) {
+	payload := []byte(`{"name": "Sensor1", "type": "barometer", "location": {"latitude": 51.5, "longitude": -0.12}}`)
+
+	req, err := http.NewRequest(http.MethodPost, "/sensors", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	repo := &MockRepository{}
+	repo.On("GetSensorType", "barometer").Return((*app.SensorType)(nil), app.ErrSensorTypeNotFound)
+
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	repo.AssertNotCalled(t, "CreateSensorMetadata", mock.Anything)
+}
+
+func TestHandlerGetNearestSensorMetadataOptions(t *testing.T) {
+	repo := &MockRepository{}
+	repo.On("FindNearestSensorMetadata", mock.MatchedBy(func(query app.NearestQuery) bool {
+		return query.Use3D && *query.Altitude == 120 && query.AltitudeDatum == app.DatumMSL && query.PreferSameFloor && *query.Floor == 4
+	})).Return([]app.NearestSensor{{SensorMetadata: app.SensorMetadata{ID: 1, Name: "Sensor1"}}}, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors/nearest?latitude=51.5&longitude=-0.1&altitude=120&altitude_datum=MSL&distance=3d&floor=4&prefer_same_floor=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	repo.AssertExpectations(t)
+
+	for _, query := range []string{"distance=3d", "prefer_same_floor=true", "altitude=high", "altitude=1&altitude_datum=EGM96"} {
+		req, err := http.NewRequest(http.MethodGet, "/sensors/nearest?latitude=51.5&longitude=-0.1&"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestHandlerGetSensorMetadataWithin(t *testing.T) {
+	repo := &MockRepository{}
+	repo.On("FindSensorMetadataWithin", mock.MatchedBy(func(query app.WithinQuery) bool {
+		return query.BoundingBox != nil && query.BoundingBox.CrossesAntimeridian() && query.Tags.String() == "vendor:acme" && query.Limit == 2
+	})).Return([]app.SensorMetadata{{ID: 3, Name: "Fiji"}, {ID: 7, Name: "Samoa"}}, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "/sensors/within?bbox=170,-20,-170,-10&tags=vendor:acme&limit=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"sensors":[{"id":3,"name":"Fiji","location":{"latitude":0,"longitude":0},"tags":null},{"id":7,"name":"Samoa","location":{"latitude":0,"longitude":0},"tags":null}],"next":"7"}`, rr.Body.String())
+	repo.AssertExpectations(t)
+
+	for _, query := range []string{"", "?bbox=1,2,3", "?bbox=0,60,1,50"} {
+		req, err := http.NewRequest(http.MethodGet, "/sensors/within"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestHandlerPostSensorMetadataWithin(t *testing.T) {
+	repo := &MockRepository{}
+	repo.On("FindSensorMetadataWithin", mock.MatchedBy(func(query app.WithinQuery) bool {
+		return query.BoundingBox == nil && len(query.Polygons) == 1 && len(query.Polygons[0].Rings) == 2
+	})).Return([]app.SensorMetadata{{ID: 1, Name: "Sensor1"}}, nil)
+
+	body := []byte(`{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6],[4,4]]]}`)
+	req, err := http.NewRequest(http.MethodPost, "/sensors/within", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	repo.AssertExpectations(t)
+
+	req, err = http.NewRequest(http.MethodPost, "/sensors/within", bytes.NewBufferString(`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHandlerBatchSensorMetadata(t *testing.T) {
+	repo := &MockRepository{}
+	repo.On("ApplySensorBatch", mock.MatchedBy(func(operations []app.BatchOperation) bool {
+		return len(operations) == 3 && operations[0].Sensor.Tags[0] == "floor:3" && operations[2].Name == "Sensor9"
+	}), false).Return([]app.BatchOutcome{{Created: true}, {Err: app.ErrSensorExists}, {Err: app.ErrSensorNotFound}}, nil).Run(func(args mock.Arguments) {
+		args.Get(0).([]app.BatchOperation)[0].Sensor.ID = 7
+	})
+
+	body := []byte(`{"operations": [
+		{"op": "create", "sensor": {"name": "Sensor1", "location": {"latitude": 1, "longitude": 2}, "tags": ["Floor:3"]}},
+		{"op": "create", "sensor": {"location": {"latitude": 1, "longitude": 2}}},
+		{"op": "upsert", "sensor": {"name": "Sensor2", "location": {"latitude": 1, "longitude": 2}}},
+		{"op": "delete", "name": "Sensor9"},
+		{"op": "rename", "name": "Sensor3"}
+	]}`)
+	req, err := http.NewRequest(http.MethodPost, "/sensors:batch", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response app.BatchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, []int{http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusNotFound, http.StatusBadRequest}, []int{
+		response.Results[0].Status, response.Results[1].Status, response.Results[2].Status, response.Results[3].Status, response.Results[4].Status,
+	})
+	assert.Equal(t, 7, response.Results[0].ID)
+	assert.Equal(t, "Sensor2", response.Results[2].Name)
+	repo.AssertExpectations(t)
+}
+
+func TestHandlerBatchSensorMetadataAtomic(t *testing.T) {
+	repo := &MockRepository{}
+
+	// An invalid operation aborts an atomic batch before it reaches the repository
+	body := []byte(`{"atomic": true, "operations": [
+		{"op": "create", "sensor": {"name": "Sensor1", "location": {"latitude": 1, "longitude": 2}}},
+		{"op": "delete"}
+	]}`)
+	req, err := http.NewRequest(http.MethodPost, "/sensors:batch", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"results":[{"status":424,"name":"Sensor1","error":"batch aborted"},{"status":400,"error":"Missing 'name'"}]}`, rr.Body.String())
+	repo.AssertNotCalled(t, "ApplySensorBatch", mock.Anything, mock.Anything)
+
+	// A failed operation responds with its status
+	repo.On("ApplySensorBatch", mock.Anything, true).Return([]app.BatchOutcome{{Err: app.ErrBatchAborted}, {Err: app.ErrSensorNotFound}}, nil)
+
+	body = []byte(`{"atomic": true, "operations": [
+		{"op": "create", "sensor": {"name": "Sensor1", "location": {"latitude": 1, "longitude": 2}}},
+		{"op": "delete", "name": "Sensor9"}
+	]}`)
+	req, err = http.NewRequest(http.MethodPost, "/sensors:batch", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	repo.AssertExpectations(t)
+}
+
+func TestHandlerCreateSensorMetadataConflict(t *testing.T) {
+	repo := &MockRepository{}
+	repo.On("CreateSensorMetadata", mock.Anything).Return(app.ErrSensorExists)
+
+	req, err := http.NewRequest(http.MethodPost, "/sensors", bytes.NewBufferString(`{"name": "Sensor1", "location": {"latitude": 1, "longitude": 2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestHandlerUpdateSensorMetadata(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	router := app.NewRouter(app.NewHandler(repo))
+
+	put := func(target, body string) int {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, target, bytes.NewBufferString(body)))
+		return rr.Code
+	}
+
+	// The path names the sensor, so a body with another sensor's ID is rejected
+	assert.Equal(t, http.StatusBadRequest, put("/v1/sensors/London", `{"id": 2, "name": "Lutetia", "location": {"latitude": 48.8566, "longitude": 2.3522}}`))
+	paris, err := repo.GetSensorMetadataByName("Paris")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, paris.ID)
+
+	// The ID may be left out, and the body may rename the sensor
+	assert.Equal(t, http.StatusOK, put("/v1/sensors/London", `{"name": "Londinium", "location": {"latitude": 51.5074, "longitude": -0.1278}}`))
+	london, err := repo.GetSensorMetadataByName("Londinium")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, london.ID)
+
+	assert.Equal(t, http.StatusNotFound, put("/v1/sensors/London", `{"name": "London", "location": {"latitude": 51.5074, "longitude": -0.1278}}`))
+	assert.Equal(t, http.StatusConflict, put("/v1/sensors/Londinium", `{"id": 1, "name": "Paris", "location": {"latitude": 51.5074, "longitude": -0.1278}}`))
+}
+
 // Define a mock repository for testing
 type MockRepository struct {
 	mock.Mock
@@ -107,7 +588,132 @@ func (m *MockRepository) UpdateSensorMetadata(sensorMetadata *app.SensorMetadata
 	return args.Error(0)
 }
 
-func (m *MockRepository) GetNearestSensorMetadata(latitude, longitude string) (*app.SensorMetadata, error) {
+func (m *MockRepository) GetNearestSensorMetadata(latitude, longitude string) (*app.NearestSensor, error) {
 	args := m.Called(latitude, longitude)
+	return args.Get(0).(*app.NearestSensor), args.Error(1)
+}
+
+func (m *MockRepository) AddSensorTags(name string, tags []string) (*app.SensorMetadata, error) {
+	args := m.Called(name, tags)
 	return args.Get(0).(*app.SensorMetadata), args.Error(1)
 }
+
+func (m *MockRepository) RemoveSensorTag(name, tag string) (*app.SensorMetadata, error) {
+	args := m.Called(name, tag)
+	return args.Get(0).(*app.SensorMetadata), args.Error(1)
+}
+
+func (m *MockRepository) GetTagCounts() ([]app.TagCount, error) {
+	args := m.Called()
+	return args.Get(0).([]app.TagCount), args.Error(1)
+}
+
+func (m *MockRepository) ListSensorMetadata(filter app.SensorFilter) ([]app.SensorMetadata, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]app.SensorMetadata), args.Error(1)
+}
+
+func (m *MockRepository) FindNearestSensorMetadata(query app.NearestQuery) ([]app.NearestSensor, error) {
+	args := m.Called(query)
+	return args.Get(0).([]app.NearestSensor), args.Error(1)
+}
+
+func (m *MockRepository) FindNeighbourSensorMetadata(query app.NeighbourQuery) (map[int][]app.NearestSensor, error) {
+	args := m.Called(query)
+	return args.Get(0).(map[int][]app.NearestSensor), args.Error(1)
+}
+
+func (m *MockRepository) FindSensorMetadataWithin(query app.WithinQuery) ([]app.SensorMetadata, error) {
+	args := m.Called(query)
+	return args.Get(0).([]app.SensorMetadata), args.Error(1)
+}
+
+func (m *MockRepository) ApplySensorBatch(operations []app.BatchOperation, atomic bool) ([]app.BatchOutcome, error) {
+	args := m.Called(operations, atomic)
+	return args.Get(0).([]app.BatchOutcome), args.Error(1)
+}
+
+func (m *MockRepository) CreateSensorType(sensorType *app.SensorType) error {
+	args := m.Called(sensorType)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetSensorType(name string) (*app.SensorType, error) {
+	args := m.Called(name)
+	return args.Get(0).(*app.SensorType), args.Error(1)
+}
+
+func (m *MockRepository) ListSensorTypes() ([]app.SensorType, error) {
+	args := m.Called()
+	return args.Get(0).([]app.SensorType), args.Error(1)
+}
+
+func (m *MockRepository) UpdateSensorType(sensorType *app.SensorType) error {
+	args := m.Called(sensorType)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListSensorEvents(afterID int64, limit int) ([]app.SensorEvent, error) {
+	args := m.Called(afterID, limit)
+	return args.Get(0).([]app.SensorEvent), args.Error(1)
+}
+
+func (m *MockRepository) LastSensorEventID() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) PruneSensorEvents(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) CreateWebhook(webhook *app.Webhook) error {
+	args := m.Called(webhook)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetWebhook(id int) (*app.Webhook, error) {
+	args := m.Called(id)
+	return args.Get(0).(*app.Webhook), args.Error(1)
+}
+
+func (m *MockRepository) ListWebhooks() ([]app.Webhook, error) {
+	args := m.Called()
+	return args.Get(0).([]app.Webhook), args.Error(1)
+}
+
+func (m *MockRepository) UpdateWebhook(webhook *app.Webhook) error {
+	args := m.Called(webhook)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteWebhook(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) EnqueueWebhookDeliveries(limit int) (int, error) {
+	args := m.Called(limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]app.WebhookDelivery, error) {
+	args := m.Called(limit, lease)
+	return args.Get(0).([]app.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockRepository) UpdateWebhookDelivery(delivery *app.WebhookDelivery) error {
+	args := m.Called(delivery)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListWebhookDeliveries(webhookID int, filter app.WebhookDeliveryFilter) ([]app.WebhookDelivery, error) {
+	args := m.Called(webhookID, filter)
+	return args.Get(0).([]app.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockRepository) RetryWebhookDelivery(webhookID int, id int64) (*app.WebhookDelivery, error) {
+	args := m.Called(webhookID, id)
+	return args.Get(0).(*app.WebhookDelivery), args.Error(1)
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app/jsonschema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSchemaValidate(t *testing.T) {
+	schema, err := jsonschema.Parse([]byte(`{
+		"$defs": {
+			"rate": {"type": "integer", "minimum": 1, "maximum": 1000}
+		},
+		"type": "object",
+		"required": ["model", "sampling_rate"],
+		"properties": {
+			"model": {"type": "string", "pattern": "^[A-Z]", "maxLength": 8},
+			"sampling_rate": {"$ref": "#/$defs/rate"},
+			"units": {"type": "array", "items": {"enum": ["C", "F"]}, "minItems": 1},
+			"firmware": {"type": ["string", "null"]}
+		},
+		"additionalProperties": false
+	}`))
+	assert.NoError(t, err)
+
+	assert.NoError(t, schema.ValidateJSON([]byte(`{"model": "X100", "sampling_rate": 10, "units": ["C"], "firmware": null}`)))
+
+	err = schema.ValidateJSON([]byte(`{"model": "x100-long-name", "sampling_rate": 2.5, "units": ["K"], "extra": 1}`))
+	var validationErr *jsonschema.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []jsonschema.Error{
+		{Path: "/extra", Message: "is not an allowed property"},
+		{Path: "/model", Message: "must be at most 8 characters long"},
+		{Path: "/model", Message: `must match pattern "^[A-Z]"`},
+		{Path: "/sampling_rate", Message: "must be of type integer"},
+		{Path: "/units/0", Message: `must be one of ["C","F"]`},
+	}, validationErr.Errors)
+
+	err = schema.ValidateJSON([]byte(`{"model": "X"}`))
+	assert.EqualError(t, err, "/sampling_rate: is required")
+}
+
+func TestJSONSchemaCombinators(t *testing.T) {
+	schema, err := jsonschema.Parse([]byte(`{"oneOf": [{"type": "string"}, {"type": "number", "exclusiveMinimum": 0}], "not": {"const": "none"}}`))
+	assert.NoError(t, err)
+
+	assert.NoError(t, schema.Validate("abc"))
+	assert.NoError(t, schema.Validate(3))
+	assert.Error(t, schema.Validate(0))
+	assert.Error(t, schema.Validate("none"))
+	assert.Error(t, schema.Validate(true))
+}
+
+func TestJSONSchemaParseRejectsMalformedSchemas(t *testing.T) {
+	for _, schema := range []string{
+		`[]`,
+		`{"type": "thing"}`,
+		`{"pattern": "("}`,
+		`{"minimum": "1"}`,
+		`{"properties": {"a": 1}}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"anyOf": []}`,
+		`not json`,
+	} {
+		_, err := jsonschema.Parse([]byte(schema))
+		assert.Error(t, err, schema)
+	}
+}
+
+func TestJSONSchemaParseStrictRejectsUnsupportedKeywords(t *testing.T) {
+	for schema, keyword := range map[string]string{
+		`{"type": "string", "format": "email"}`:                               "#: unsupported keyword \"format\"",
+		`{"properties": {"rate": {"type": "number", "multipleOf": 5}}}`:       "#/properties/rate: unsupported keyword \"multipleOf\"",
+		`{"type": "array", "items": {"type": "string"}, "uniqueItems": true}`: "#: unsupported keyword \"uniqueItems\"",
+		`{"$defs": {"a": {"patternProperties": {"^x": {}}}}}`:                 "#/$defs/a: unsupported keyword \"patternProperties\"",
+		`{"anyOf": [{"minProperties": 1}]}`:                                   "#/anyOf/0: unsupported keyword \"minProperties\"",
+		`{"prefixItems": [{"type": "string"}]}`:                               "#: unsupported keyword \"prefixItems\"",
+		`{"dependentRequired": {"a": ["b"]}}`:                                 "#: unsupported keyword \"dependentRequired\"",
+	} {
+		_, err := jsonschema.Parse([]byte(schema))
+		assert.NoError(t, err, schema)
+
+		_, err = jsonschema.ParseStrict([]byte(schema))
+		assert.EqualError(t, err, keyword, schema)
+	}
+
+	// Annotations constrain nothing, and are accepted
+	_, err := jsonschema.ParseStrict([]byte(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "Thermometer", "type": "object",
+		"properties": {"rate": {"type": "number", "description": "Samples per second", "default": 1, "examples": [10]}}}`))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSeededMemoryRepository(t *testing.T) *app.MemoryRepository {
+	repo := app.NewMemoryRepository()
+	for _, sensor := range []app.SensorMetadata{
+		{Name: "London", Location: app.Location{Latitude: 51.5074, Longitude: -0.1278}, Tags: []string{"vendor:acme", "floor:3"}},
+		{Name: "Paris", Location: app.Location{Latitude: 48.8566, Longitude: 2.3522}, Tags: []string{"vendor:acme", "retired"}},
+		{Name: "Berlin", Location: app.Location{Latitude: 52.52, Longitude: 13.405}, Tags: []string{"vendor:other"}},
+	} {
+		sensor := sensor
+		assert.NoError(t, repo.CreateSensorMetadata(&sensor))
+	}
+	return repo
+}
+
+func TestMemoryRepository_GetSensorMetadataByName(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	sensor, err := repo.GetSensorMetadataByName("Paris")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sensor.ID)
+
+	_, err = repo.GetSensorMetadataByName("Madrid")
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+
+	// Renamed sensors are found under their new name only
+	sensor.Name = "Lutetia"
+	assert.NoError(t, repo.UpdateSensorMetadata(sensor))
+	_, err = repo.GetSensorMetadataByName("Paris")
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+	sensor, err = repo.GetSensorMetadataByName("Lutetia")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sensor.ID)
+	assert.NoError(t, repo.CreateSensorMetadata(&app.SensorMetadata{Name: "Paris", Location: app.Location{Latitude: 48.8566, Longitude: 2.3522}}))
+
+	sensor.ID = 99
+	assert.ErrorIs(t, repo.UpdateSensorMetadata(sensor), app.ErrSensorNotFound)
+}
+
+func TestMemoryRepository_ListSensorMetadata(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	tags, err := app.ParseTagExpr("vendor:acme AND NOT retired")
+	assert.NoError(t, err)
+
+	sensors, err := repo.ListSensorMetadata(app.SensorFilter{Tags: tags, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, sensors, 1)
+	assert.Equal(t, "London", sensors[0].Name)
+
+	page, err := repo.ListSensorMetadata(app.SensorFilter{AfterID: 1, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.Equal(t, "Paris", page[0].Name)
+}
+
+func TestMemoryRepository_FindNearestSensorMetadata(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	// Brussels is closest to Paris, but Paris is retired
+	tags, err := app.ParseTagExpr("NOT retired")
+	assert.NoError(t, err)
+
+	sensors, err := repo.FindNearestSensorMetadata(app.NearestQuery{Latitude: 50.8503, Longitude: 4.3517, Tags: tags, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, sensors, 2)
+	assert.Equal(t, "London", sensors[0].Name)
+	assert.InDelta(t, 320000, sensors[0].Distance, 5000)
+	assert.Equal(t, "Berlin", sensors[1].Name)
+}
+
+func TestMemoryRepository_FindNeighbourSensorMetadata(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	tags, err := app.ParseTagExpr("NOT retired")
+	assert.NoError(t, err)
+
+	// A sensor isn't its own neighbour, and unknown sensors have none
+	neighbours, err := repo.FindNeighbourSensorMetadata(app.NeighbourQuery{IDs: []int{1, 2, 5}, Tags: tags, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, neighbours, 2)
+	if assert.Len(t, neighbours[1], 1) {
+		assert.Equal(t, "Berlin", neighbours[1][0].Name)
+		assert.InDelta(t, 930000, neighbours[1][0].Distance, 10000)
+	}
+	if assert.Len(t, neighbours[2], 2) {
+		assert.Equal(t, "London", neighbours[2][0].Name)
+		assert.Equal(t, "Berlin", neighbours[2][1].Name)
+	}
+}
+
+func TestMemoryRepository_Tags(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	sensor, err := repo.AddSensorTags("Berlin", []string{"vendor:other", "floor:3"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vendor:other", "floor:3"}, sensor.Tags)
+
+	sensor, err = repo.RemoveSensorTag("Berlin", "vendor:other")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"floor:3"}, sensor.Tags)
+
+	tagCounts, err := repo.GetTagCounts()
+	assert.NoError(t, err)
+	assert.Equal(t, []app.TagCount{{Tag: "floor:3", Count: 2}, {Tag: "vendor:acme", Count: 2}, {Tag: "retired", Count: 1}}, tagCounts)
+}
+
+func TestMemoryRepository_ListSensorMetadataByAttributes(t *testing.T) {
+	repo := app.NewMemoryRepository()
+	for _, sensor := range []app.SensorMetadata{
+		{Name: "Slow", Attributes: map[string]interface{}{"sampling_rate": 1, "calibration": map[string]interface{}{"lab": "north"}}},
+		{Name: "Fast", Attributes: map[string]interface{}{"sampling_rate": 50, "calibration": map[string]interface{}{"lab": "south"}}},
+		{Name: "Unknown"},
+	} {
+		sensor := sensor
+		assert.NoError(t, repo.CreateSensorMetadata(&sensor))
+	}
+
+	filters, err := app.ParseAttributeFilters("limit=10&attr.sampling_rate%3E%3D10&attr.calibration.lab=south")
+	assert.NoError(t, err)
+
+	sensors, err := repo.ListSensorMetadata(app.SensorFilter{Attributes: filters, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, sensors, 1)
+	assert.Equal(t, "Fast", sensors[0].Name)
+	assert.Equal(t, 50.0, sensors[0].Attributes["sampling_rate"])
+}
+
+func TestMemoryRepository_SensorTypes(t *testing.T) {
+	repo := app.NewMemoryRepository()
+
+	sensorType := &app.SensorType{Name: "thermometer", Schema: []byte(`{"type": "object"}`)}
+	assert.NoError(t, repo.CreateSensorType(sensorType))
+	assert.ErrorIs(t, repo.CreateSensorType(sensorType), app.ErrSensorTypeExists)
+	assert.ErrorIs(t, repo.UpdateSensorType(&app.SensorType{Name: "barometer"}), app.ErrSensorTypeNotFound)
+
+	sensorTypes, err := repo.ListSensorTypes()
+	assert.NoError(t, err)
+	assert.Equal(t, []app.SensorType{*sensorType}, sensorTypes)
+}
+
+func TestMemoryRepository_FindNearestSensorMetadata3DSameFloor(t *testing.T) {
+	repo := app.NewMemoryRepository()
+
+	floor := func(f int) *int { return &f }
+	altitude := func(a float64) *float64 { return &a }
+	for _, sensor := range []app.SensorMetadata{
+		// Directly below the query point, 100m down
+		{Name: "Basement", Location: app.Location{Latitude: 51.5, Longitude: -0.12, Altitude: altitude(0), AltitudeDatum: app.DatumWGS84, Floor: floor(0)}},
+		// 50m away horizontally at the same height
+		{Name: "Tower", Location: app.Location{Latitude: 51.50045, Longitude: -0.12, Altitude: altitude(100), AltitudeDatum: app.DatumWGS84, Floor: floor(25)}},
+	} {
+		sensor := sensor
+		assert.NoError(t, repo.CreateSensorMetadata(&sensor))
+	}
+
+	query := app.NearestQuery{Latitude: 51.5, Longitude: -0.12, Altitude: altitude(100), AltitudeDatum: app.DatumWGS84, Limit: 2}
+
+	sensors, err := repo.FindNearestSensorMetadata(query)
+	assert.NoError(t, err)
+	assert.Equal(t, "Basement", sensors[0].Name)
+
+	query.Use3D = true
+	sensors, err = repo.FindNearestSensorMetadata(query)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tower", sensors[0].Name)
+	assert.InDelta(t, 100, sensors[1].Distance, 0.01)
+
+	query.Use3D = false
+	query.Floor = floor(25)
+	query.PreferSameFloor = true
+	sensors, err = repo.FindNearestSensorMetadata(query)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tower", sensors[0].Name)
+}
+
+func TestMemoryRepository_FindSensorMetadataWithin(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	bbox, err := app.ParseBoundingBox("-1,48,3,52")
+	assert.NoError(t, err)
+
+	sensors, err := repo.FindSensorMetadataWithin(app.WithinQuery{BoundingBox: &bbox, SensorFilter: app.SensorFilter{Limit: 10}})
+	assert.NoError(t, err)
+	assert.Len(t, sensors, 2)
+	assert.Equal(t, "London", sensors[0].Name)
+	assert.Equal(t, "Paris", sensors[1].Name)
+
+	// A triangle around London and Berlin, with a hole cut around Berlin
+	polygons, err := app.ParseGeoJSONPolygons([]byte(`{"type":"Polygon","coordinates":[
+		[[-5,50],[20,50],[7,58],[-5,50]],
+		[[13,52],[14,52],[14,53],[13,53],[13,52]]
+	]}`))
+	assert.NoError(t, err)
+
+	sensors, err = repo.FindSensorMetadataWithin(app.WithinQuery{Polygons: polygons, SensorFilter: app.SensorFilter{Limit: 10}})
+	assert.NoError(t, err)
+	assert.Len(t, sensors, 1)
+	assert.Equal(t, "London", sensors[0].Name)
+}
+
+func TestMemoryRepository_ApplySensorBatch(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	madrid := app.SensorMetadata{Name: "Madrid", Location: app.Location{Latitude: 40.4168, Longitude: -3.7038}}
+	paris := app.SensorMetadata{Name: "Paris", Location: app.Location{Latitude: 48.8566, Longitude: 2.3522}, Tags: []string{"vendor:other"}}
+	london := app.SensorMetadata{Name: "London", Location: app.Location{Latitude: 51.5074, Longitude: -0.1278}}
+	outcomes, err := repo.ApplySensorBatch([]app.BatchOperation{
+		{Op: app.BatchCreate, Sensor: &madrid},
+		{Op: app.BatchUpsert, Sensor: &paris},
+		{Op: app.BatchCreate, Sensor: &london},
+		{Op: app.BatchDelete, Name: "Berlin"},
+		{Op: app.BatchDelete, Name: "Rome"},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []app.BatchOutcome{{Created: true}, {}, {Created: true, Err: app.ErrSensorExists}, {}, {Err: app.ErrSensorNotFound}}, outcomes)
+	assert.Equal(t, 4, madrid.ID)
+	assert.Equal(t, 2, paris.ID)
+
+	sensor, err := repo.GetSensorMetadataByName("Paris")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vendor:other"}, sensor.Tags)
+	_, err = repo.GetSensorMetadataByName("Berlin")
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+}
+
+func TestMemoryRepository_ApplySensorBatchAtomic(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	madrid := app.SensorMetadata{Name: "Madrid", Location: app.Location{Latitude: 40.4168, Longitude: -3.7038}}
+	outcomes, err := repo.ApplySensorBatch([]app.BatchOperation{
+		{Op: app.BatchCreate, Sensor: &madrid},
+		{Op: app.BatchDelete, Name: "Berlin"},
+		{Op: app.BatchDelete, Name: "Rome"},
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []app.BatchOutcome{{Err: app.ErrBatchAborted}, {Err: app.ErrBatchAborted}, {Err: app.ErrSensorNotFound}}, outcomes)
+
+	// Nothing was applied
+	_, err = repo.GetSensorMetadataByName("Madrid")
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+	_, err = repo.GetSensorMetadataByName("Berlin")
+	assert.NoError(t, err)
+}
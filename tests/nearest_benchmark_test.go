@@ -0,0 +1,106 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// BenchmarkNearestSensorMetadata compares the earth_box prefiltered nearest query against the
+// previous full scan on a seeded table, over every sensor and over the sparse few tagged rare,
+// for which the prefiltered radii miss. It needs a PostgreSQL database with the cube and
+// earthdistance extensions, given as a connection string in SENSOR_BENCH_DSN. The sensors are
+// seeded into a separate bench_nearest schema, sized by SENSOR_BENCH_SENSORS (default 500000).
+//
+//	SENSOR_BENCH_DSN="host=localhost dbname=sensor_metadata user=admin password=password sslmode=disable" \
+//		go test ./tests -run '^$' -bench NearestSensorMetadata -benchtime 200x
+func BenchmarkNearestSensorMetadata(b *testing.B) {
+	dsn := os.Getenv("SENSOR_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("SENSOR_BENCH_DSN is not set")
+	}
+
+	sensors := 500000
+	if value := os.Getenv("SENSOR_BENCH_SENSORS"); value != "" {
+		var err error
+		if sensors, err = strconv.Atoi(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn+" search_path=bench_nearest,public")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	seedNearestBenchmark(b, db, sensors)
+
+	// Query locations are spread over the same area as the seeded sensors
+	random := rand.New(rand.NewSource(1))
+	locations := make([][2]float64, 1000)
+	for i := range locations {
+		locations[i] = [2]float64{35 + random.Float64()*35, -10 + random.Float64()*40}
+	}
+
+	rare, err := app.ParseTagExpr("rare")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		where string
+		tags  app.TagExpr
+	}{
+		{"dense", "", nil},
+		{"filtered", "WHERE tags @> ARRAY['rare']::VARCHAR(255)[] ", rare},
+	} {
+		b.Run(tc.name+"/full_scan", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				location := locations[i%len(locations)]
+				var id int
+				err := db.QueryRow("SELECT id FROM sensor_metadata "+tc.where+"ORDER BY earth_distance(ll_to_earth($1, $2), ll_to_earth(location_latitude, location_longitude)) LIMIT 1", location[0], location[1]).Scan(&id)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(tc.name+"/earth_box", func(b *testing.B) {
+			repo := &app.PostgresRepository{Db: db}
+			for i := 0; i < b.N; i++ {
+				location := locations[i%len(locations)]
+				if _, err := repo.FindNearestSensorMetadata(app.NearestQuery{Latitude: location[0], Longitude: location[1], Tags: tc.tags, Limit: 1}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// seedNearestBenchmark recreates the bench_nearest schema with a copy of the sensor_metadata
+// table, its indexes included, filled with sensors at random locations across Europe. One sensor
+// in 10000 is tagged rare, which leaves a few hundred kilometres between them.
+func seedNearestBenchmark(b *testing.B, db *sql.DB, sensors int) {
+	b.Helper()
+
+	for _, statement := range []string{
+		"DROP SCHEMA IF EXISTS bench_nearest CASCADE",
+		"CREATE SCHEMA bench_nearest",
+		"CREATE TABLE bench_nearest.sensor_metadata (LIKE public.sensor_metadata INCLUDING DEFAULTS INCLUDING INDEXES)",
+		fmt.Sprintf("INSERT INTO bench_nearest.sensor_metadata (name, location_latitude, location_longitude, tags, attributes) "+
+			"SELECT 'sensor-' || i, 35 + random() * 35, -10 + random() * 40, "+
+			"CASE WHEN i %% 10000 = 0 THEN ARRAY['rare']::VARCHAR(255)[] ELSE ARRAY[]::VARCHAR(255)[] END, '{}' FROM generate_series(1, %d) AS i", sensors),
+		"ANALYZE bench_nearest.sensor_metadata",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			b.Fatalf("seeding benchmark: %v", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// changeRecorder records the changes and resyncs it's notified of.
+type changeRecorder struct {
+	mu      sync.Mutex
+	changes []app.SensorChange
+	resyncs int
+}
+
+func (r *changeRecorder) SensorChanged(change app.SensorChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+func (r *changeRecorder) ResyncSensors() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resyncs++
+}
+
+func (r *changeRecorder) eventIDs() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]int64, len(r.changes))
+	for i, change := range r.changes {
+		ids[i] = change.EventID
+	}
+	return ids
+}
+
+// notifySensorChange returns the notification migration 13 sends for an event.
+func notifySensorChange(eventID int64, extra string) *pq.Notification {
+	return &pq.Notification{Channel: app.SensorChangeChannel, Extra: `{"event_id": ` + strconv.FormatInt(eventID, 10) + `, ` + extra + `}`}
+}
+
+func TestSensorChangeListener(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	listener := app.NewSensorChangeListener(repo)
+	listener.MaxCatchUp = 3
+	recorder := &changeRecorder{}
+	listener.Subscribe(recorder)
+
+	notifications := make(chan *pq.Notification)
+	done := make(chan struct{})
+	go func() {
+		listener.Consume(context.Background(), notifications)
+		close(done)
+	}()
+
+	// Changes made before the listener started are skipped
+	notifications <- notifySensorChange(3, `"type": "created", "id": 3, "name": "Berlin"`)
+
+	// Notifications are passed on as they arrive
+	_, err := repo.RemoveSensorTag("Paris", "retired")
+	assert.NoError(t, err)
+	notifications <- notifySensorChange(4, `"type": "updated", "id": 2, "name": "Paris", "previous_name": "Paris"`)
+
+	// A lost notification is replayed from the change log, and not passed on twice
+	london, err := repo.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	london.Name = "Greenwich"
+	assert.NoError(t, repo.UpdateSensorMetadata(london))
+	_, err = repo.ApplySensorBatch([]app.BatchOperation{{Op: app.BatchDelete, Name: "Berlin"}}, false)
+	assert.NoError(t, err)
+	notifications <- notifySensorChange(6, `"type": "deleted", "id": 3, "name": "Berlin"`)
+	notifications <- notifySensorChange(6, `"type": "deleted", "id": 3, "name": "Berlin"`)
+
+	// After a reconnection, the changes made while the connection was down are replayed too
+	_, err = repo.AddSensorTags("Paris", []string{"outdoor"})
+	assert.NoError(t, err)
+	notifications <- nil
+	notifications <- notifySensorChange(7, `"type": "updated", "id": 2, "name": "Paris", "previous_name": "Paris"`)
+
+	// Subscribers resync when too many changes were missed
+	for _, tag := range []string{"a", "b", "c", "d"} {
+		_, err = repo.AddSensorTags("Paris", []string{tag})
+		assert.NoError(t, err)
+	}
+	notifications <- nil
+	notifications <- notifySensorChange(12, `"type": "updated", "id": 2, "name": "Paris", "previous_name": "Paris"`)
+	close(notifications)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener didn't stop")
+	}
+	assert.Equal(t, []int64{4, 5, 6, 7, 12}, recorder.eventIDs())
+	assert.Equal(t, 1, recorder.resyncs)
+	assert.Equal(t, app.SensorChange{EventID: 5, Type: app.SensorUpdated, ID: 1, Name: "Greenwich", PreviousName: "London"}, recorder.changes[1])
+	assert.Equal(t, app.SensorChange{EventID: 6, Type: app.SensorDeleted, ID: 3, Name: "Berlin"}, recorder.changes[2])
+}
+
+// inFlightRepository holds back the events of the change log from the one of a transaction still
+// in flight, as the watermark of migration 14 does.
+type inFlightRepository struct {
+	app.Repository
+
+	mu       sync.Mutex
+	inFlight int64
+}
+
+func (r *inFlightRepository) setInFlight(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight = id
+}
+
+func (r *inFlightRepository) ListSensorEvents(afterID int64, limit int) ([]app.SensorEvent, error) {
+	events, err := r.Repository.ListSensorEvents(afterID, limit)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, event := range events {
+		if r.inFlight > 0 && event.ID >= r.inFlight {
+			return events[:i], err
+		}
+	}
+	return events, err
+}
+
+func TestSensorChangeListenerOutOfOrderCommits(t *testing.T) {
+	repo := &inFlightRepository{Repository: newSeededMemoryRepository(t)}
+	listener := app.NewSensorChangeListener(repo)
+	listener.RetryInterval = 10 * time.Millisecond
+	recorder := &changeRecorder{}
+	listener.Subscribe(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifications := make(chan *pq.Notification)
+	go listener.Consume(ctx, notifications)
+	notifications <- notifySensorChange(3, `"type": "created", "id": 3, "name": "Berlin"`)
+
+	_, err := repo.AddSensorTags("Paris", []string{"outdoor"})
+	assert.NoError(t, err)
+	_, err = repo.AddSensorTags("Berlin", []string{"outdoor"})
+	assert.NoError(t, err)
+
+	// Event 5 commits first, and is held back while event 4 is in flight
+	repo.setInFlight(4)
+	notifications <- notifySensorChange(5, `"type": "updated", "id": 3, "name": "Berlin", "previous_name": "Berlin"`)
+	repo.setInFlight(0)
+	notifications <- notifySensorChange(4, `"type": "updated", "id": 2, "name": "Paris", "previous_name": "Paris"`)
+
+	// Event 5 is caught up with without another notification
+	assert.Eventually(t, func() bool { return len(recorder.eventIDs()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int64{4, 5}, recorder.eventIDs())
+}
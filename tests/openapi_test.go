@@ -0,0 +1,231 @@
+package app
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/skartikey/sensor-metadata/app/jsonschema"
+	"github.com/stretchr/testify/assert"
+)
+
+// openAPISpec parses the OpenAPI document, both as plain JSON and as a schema to validate against.
+func openAPISpec(t *testing.T) (map[string]interface{}, *jsonschema.Schema) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(app.OpenAPISpec, &document); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := jsonschema.New(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document, schema
+}
+
+// escapePointer escapes a JSON pointer token.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// assertResponseMatchesSpec sends a request through the router and checks that the OpenAPI document
+// describes the response: its route, method, status code, content type and, for JSON, its body.
+func assertResponseMatchesSpec(t *testing.T, router *mux.Router, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	document, schema := openAPISpec(t)
+
+	var match mux.RouteMatch
+	if !router.Match(req, &match) || match.Route == nil {
+		t.Fatalf("%s %s matches no route", req.Method, req.URL)
+	}
+	path, err := match.Route.GetPathTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The document describes the routes relative to its /v1 server URL
+	path = strings.TrimPrefix(path, app.APIVersionPrefix)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	operation, ok := lookup(document, "paths", path, strings.ToLower(req.Method))
+	if !ok {
+		t.Fatalf("%s %s is not documented", req.Method, path)
+	}
+	status := strconv.Itoa(rr.Code)
+	response, ok := lookup(operation, "responses", status)
+	if !ok {
+		t.Fatalf("%s %s: status %s is not documented; body %s", req.Method, path, status, rr.Body.String())
+	}
+
+	content, hasContent := lookup(response, "content")
+	if rr.Body.Len() == 0 {
+		assert.False(t, hasContent, "%s %s %s: documented body is missing", req.Method, path, status)
+		return rr
+	}
+	mediaType, _, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("%s %s %s: invalid Content-Type %q", req.Method, path, status, rr.Header().Get("Content-Type"))
+	}
+	if _, ok := lookup(content, mediaType); !ok {
+		t.Fatalf("%s %s %s: content type %s is not documented", req.Method, path, status, mediaType)
+	}
+
+	if mediaType == "application/json" || mediaType == app.GeoJSONMediaType {
+		ref := "#/" + strings.Join([]string{"paths", escapePointer(path), strings.ToLower(req.Method), "responses", status, "content", escapePointer(mediaType), "schema"}, "/")
+		var body interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s %s: invalid JSON: %v", req.Method, path, status, err)
+		}
+		assert.NoError(t, schema.ValidateRef(ref, body), "%s %s %s: %s", req.Method, path, status, rr.Body.String())
+	}
+	return rr
+}
+
+// lookup follows keys through nested JSON objects.
+func lookup(node interface{}, keys ...string) (interface{}, bool) {
+	for _, key := range keys {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if node, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	document, _ := openAPISpec(t)
+	assert.Equal(t, "3.1.0", document["openapi"])
+
+	var routes, legacyRoutes, documented []string
+	err := app.NewRouter(app.NewHandler(app.NewMemoryRepository())).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			// Subrouters have no path of their own
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if strings.HasPrefix(path, app.APIVersionPrefix+"/") {
+				routes = append(routes, method+" "+strings.TrimPrefix(path, app.APIVersionPrefix))
+			} else {
+				legacyRoutes = append(legacyRoutes, method+" "+path)
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	paths, _ := lookup(document, "paths")
+	for path, item := range paths.(map[string]interface{}) {
+		for method := range item.(map[string]interface{}) {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(legacyRoutes)
+	sort.Strings(documented)
+	assert.Equal(t, routes, documented)
+
+	// The unprefixed routes alias every route, and keep the update by ID that predates version 1
+	aliases := append([]string{"PUT /sensors"}, routes...)
+	sort.Strings(aliases)
+	assert.Equal(t, aliases, legacyRoutes)
+}
+
+func TestOpenAPISpecMatchesResponses(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	router := app.NewRouter(app.NewHandler(repo))
+
+	request := func(method, target, body string, headers ...string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		return req
+	}
+
+	for _, req := range []*http.Request{
+		request(http.MethodPost, "/sensor-types", `{"name": "thermometer", "schema": {"type": "object", "properties": {"sampling_rate": {"type": "integer"}}}}`),
+		request(http.MethodPost, "/sensor-types", `{"name": "thermometer", "schema": {"type": "object"}}`),
+		request(http.MethodPost, "/sensor-types", `{"schema": {"type": "object"}}`),
+		request(http.MethodGet, "/sensor-types", ""),
+		request(http.MethodGet, "/sensor-types/thermometer", ""),
+		request(http.MethodGet, "/sensor-types/hygrometer", ""),
+		request(http.MethodPut, "/sensor-types/thermometer", `{"description": "Temperature", "schema": {"type": "object"}}`),
+		request(http.MethodPost, "/sensors", `{"name": "Madrid", "type": "thermometer", "location": {"latitude": 40.4168, "longitude": -3.7038, "altitude": 667, "altitude_datum": "MSL", "floor": 2, "accuracy_m": 5}, "tags": ["vendor:acme"], "attributes": {"sampling_rate": 10}}`),
+		request(http.MethodPost, "/sensors", `{"name": "Madrid", "location": {"latitude": 40.4168, "longitude": -3.7038}, "tags": []}`),
+		request(http.MethodPost, "/sensors", `{"location": {"longitude": -3.7038}}`),
+		request(http.MethodGet, "/sensors?name=Madrid", ""),
+		request(http.MethodGet, "/sensors?name=Lisbon", ""),
+		request(http.MethodGet, "/sensors?limit=2", ""),
+		request(http.MethodGet, "/sensors?limit=0", ""),
+		request(http.MethodGet, "/sensors?tags=vendor:acme", "", "Accept", app.GeoJSONMediaType),
+		request(http.MethodGet, "/sensors?format=kml&group_by=vendor", ""),
+		request(http.MethodGet, "/sensors/nearest?latitude=48.8&longitude=2.3&altitude=35&distance=3d", ""),
+		request(http.MethodGet, "/sensors/nearest?latitude=48.8&longitude=2.3&format=geojson", ""),
+		request(http.MethodGet, "/sensors/nearest?latitude=48.8&longitude=2.3&tags=missing", ""),
+		request(http.MethodGet, "/sensors/nearest?latitude=48.8", ""),
+		request(http.MethodGet, "/sensors/within?bbox=-1,48,3,52", ""),
+		request(http.MethodGet, "/sensors/within?bbox=-1,48,3,52&format=gpx", ""),
+		request(http.MethodGet, "/sensors/within?bbox=1,2,3", ""),
+		request(http.MethodPost, "/sensors/within", `{"type": "Polygon", "coordinates": [[[-1, 48], [3, 48], [3, 52], [-1, 52], [-1, 48]]]}`),
+		request(http.MethodPut, "/sensors/Madrid", `{"id": 4, "name": "Madrid", "location": {"latitude": 40.4, "longitude": -3.7}, "tags": ["vendor:acme"]}`),
+		request(http.MethodPut, "/sensors/Madrid", `{"id": 4, "name": "Paris", "location": {"latitude": 40.4, "longitude": -3.7}}`),
+		request(http.MethodPut, "/sensors/Madrid", `{"id": 1, "name": "Madrid", "location": {"latitude": 40.4, "longitude": -3.7}}`),
+		request(http.MethodPut, "/sensors/Lisbon", `{"name": "Lisbon", "location": {"latitude": 38.7, "longitude": -9.1}}`),
+		request(http.MethodPost, "/sensors/Madrid/tags", `{"tags": ["floor:2"]}`),
+		request(http.MethodPost, "/sensors/Madrid/tags", `{"tags": []}`),
+		request(http.MethodDelete, "/sensors/Madrid/tags/floor:2", ""),
+		request(http.MethodDelete, "/sensors/Lisbon/tags/floor:2", ""),
+		request(http.MethodGet, "/tags", ""),
+		request(http.MethodPost, "/sensors:batch", `{"operations": [{"op": "upsert", "sensor": {"name": "Rome", "location": {"latitude": 41.9, "longitude": 12.5}}}, {"op": "delete", "name": "Lisbon"}]}`),
+		request(http.MethodPost, "/sensors:batch", `{"atomic": true, "operations": [{"op": "create", "sensor": {"name": "Oslo", "location": {"latitude": 59.9, "longitude": 10.7}}}, {"op": "delete", "name": "Lisbon"}]}`),
+		request(http.MethodPost, "/sensors:batch", `{"atomic": true, "operations": [{"op": "create"}]}`),
+		request(http.MethodPost, "/sensors:batch", `{"operations": []}`),
+		request(http.MethodPost, "/sensors/import?dry_run=true", "name,latitude,longitude\nVienna,48.2,16.4\nNowhere,x,\n", "Content-Type", "text/csv"),
+		request(http.MethodPost, "/sensors/import", `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [16.4, 48.2]}, "properties": {"name": "Vienna"}}]}`, "Content-Type", app.GeoJSONMediaType),
+		request(http.MethodPost, "/sensors/import", "latitude\n", "Content-Type", "text/csv"),
+		request(http.MethodGet, "/sensors/export", ""),
+		request(http.MethodGet, "/sensors/export?format=geojson", ""),
+		request(http.MethodGet, "/sensors/events?bbox=1,2,3", ""),
+		request(http.MethodGet, "/sensors/events?last_event_id=x", ""),
+		request(http.MethodDelete, "/sensors/Madrid", ""),
+		request(http.MethodDelete, "/sensors/Madrid", ""),
+		request(http.MethodPost, "/webhooks", `{"url": "https://example.com/hooks", "events": ["moved", "deleted"], "tags": "vendor:acme"}`),
+		request(http.MethodPost, "/webhooks", `{"url": "ftp://example.com", "events": ["exploded"]}`),
+		request(http.MethodPost, "/webhooks", `{"url": "https://example.com/hooks", "tags": "("}`),
+		request(http.MethodGet, "/webhooks", ""),
+		request(http.MethodGet, "/webhooks/1", ""),
+		request(http.MethodGet, "/webhooks/9", ""),
+		request(http.MethodPut, "/webhooks/1", `{"url": "https://example.com/v2/hooks"}`),
+		request(http.MethodGet, "/webhooks/1/deliveries?status=dead", ""),
+		request(http.MethodGet, "/webhooks/1/deliveries?status=lost", ""),
+		request(http.MethodPost, "/webhooks/1/deliveries/7:retry", ""),
+		request(http.MethodDelete, "/webhooks/1", ""),
+		request(http.MethodDelete, "/webhooks/1", ""),
+		request(http.MethodPost, "/graphql", `{"query": "query($name: String!) { sensor(name: $name) { id name type { name } location { latitude altitudeDatum } } }", "variables": {"name": "London"}}`),
+		request(http.MethodPost, "/graphql", `{"query": "mutation { deleteSensor(name: \"Lisbon\") }"}`),
+		request(http.MethodPost, "/graphql", `{"query": "{ sensor(name: 1) { id } }"}`),
+		request(http.MethodGet, "/graphql?query=%7Btags%7Btag%20count%7D%7D", ""),
+		request(http.MethodGet, "/graphql?query=mutation%7BdeleteSensor(name:%22Lisbon%22)%7D", ""),
+		request(http.MethodGet, "/openapi.json", ""),
+	} {
+		assertResponseMatchesSpec(t, router, req)
+	}
+}
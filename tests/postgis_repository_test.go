@@ -0,0 +1,78 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostGISRepository_FindNearestSensorMetadata(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostGISRepository{PostgresRepository: &app.PostgresRepository{Db: mockDB}}
+
+	tags, err := app.ParseTagExpr("vendor:acme")
+	assert.NoError(t, err)
+
+	point := "ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography"
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes, " +
+		"ST_Distance(location, " + point + ") AS distance FROM sensor_metadata WHERE tags @> ARRAY[$3]::VARCHAR(255)[] ORDER BY location <-> " + point + " LIMIT $4"
+
+	mock.ExpectQuery(expectedQuery).WithArgs(-0.1, 51.5, "vendor:acme", 2).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes", "distance"}).
+			AddRow(1, "Sensor1", 51.5, -0.12, nil, nil, nil, nil, pq.Array([]string{"vendor:acme"}), nil, []byte("{}"), 1385.7),
+	)
+
+	sensors, err := repo.FindNearestSensorMetadata(app.NearestQuery{Latitude: 51.5, Longitude: -0.1, Tags: tags, Limit: 2})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, []app.NearestSensor{{
+		SensorMetadata: app.SensorMetadata{
+			ID:       1,
+			Name:     "Sensor1",
+			Location: app.Location{Latitude: 51.5, Longitude: -0.12},
+			Tags:     []string{"vendor:acme"},
+		},
+		Distance: 1385.7,
+	}}, sensors)
+}
+
+func TestPostGISRepository_GetNearestSensorMetadataNotFound(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostGISRepository{PostgresRepository: &app.PostgresRepository{Db: mockDB}}
+
+	point := "ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography"
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes, " +
+		"ST_Distance(location, " + point + ") AS distance FROM sensor_metadata ORDER BY location <-> " + point + " LIMIT $3"
+
+	mock.ExpectQuery(expectedQuery).WithArgs(-0.1, 51.5, 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes", "distance"}),
+	)
+
+	sensor, err := repo.GetNearestSensorMetadata("51.5", "-0.1")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Nil(t, sensor)
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+}
+
+func TestNewRepository(t *testing.T) {
+	t.Setenv("DB_BACKEND", "memory")
+	repo, err := app.NewRepository()
+	assert.NoError(t, err)
+	assert.IsType(t, &app.MemoryRepository{}, repo)
+
+	t.Setenv("DB_BACKEND", "mongo")
+	repo, err = app.NewRepository()
+	assert.Error(t, err)
+	assert.Nil(t, repo)
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+const replicaLagQuery = "SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END"
+
+// expectSensorLookup expects a lookup of Sensor1 by name on a database.
+func expectSensorLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectPrepare("SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes FROM sensor_metadata WHERE name = $1").
+		ExpectQuery().WithArgs("Sensor1").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}).
+			AddRow(1, "Sensor1", 51.5, -0.1, nil, nil, nil, nil, pq.Array([]string{}), nil, nil))
+}
+
+func TestPostgresRepository_Replicas(t *testing.T) {
+	primaryDB, primary, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer primaryDB.Close()
+	replicaDB, replica, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer replicaDB.Close()
+
+	replicas := app.NewReplicaSet([]string{"replica1"}, []*sql.DB{replicaDB})
+	repo := &app.PostgresRepository{Db: primaryDB, Replicas: replicas}
+	assert.Equal(t, 15*time.Second, repo.ReplicaStaleness())
+	lookUp := func(repo app.Repository) {
+		_, err := repo.GetSensorMetadataByName("Sensor1")
+		assert.NoError(t, err)
+	}
+
+	// Reads go to the primary until the replica is checked
+	expectSensorLookup(primary)
+	lookUp(repo)
+
+	replica.ExpectQuery(replicaLagQuery).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.5))
+	replicas.Check(context.Background())
+	assert.Equal(t, 1, replicas.Healthy())
+	expectSensorLookup(replica)
+	lookUp(repo)
+
+	// Reads from the primary view go to the primary whatever the state of the replicas
+	expectSensorLookup(primary)
+	lookUp(repo.Primary())
+
+	// A replica lagging too far behind is evicted
+	replica.ExpectQuery(replicaLagQuery).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(30.0))
+	replicas.Check(context.Background())
+	assert.Equal(t, 0, replicas.Healthy())
+	expectSensorLookup(primary)
+	lookUp(repo)
+
+	// Caught up, it serves reads again, until it fails a check
+	replica.ExpectQuery(replicaLagQuery).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.0))
+	replicas.Check(context.Background())
+	expectSensorLookup(replica)
+	lookUp(repo)
+	replica.ExpectQuery(replicaLagQuery).WillReturnError(errors.New("connection refused"))
+	replicas.Check(context.Background())
+	assert.Equal(t, 0, replicas.Healthy())
+	expectSensorLookup(primary)
+	lookUp(repo)
+
+	// Writes always go to the primary
+	primary.ExpectPrepare("UPDATE sensor_metadata SET tags = array_remove(tags, $2) WHERE name = $1 RETURNING id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes").
+		ExpectQuery().WithArgs("Sensor1", "outdoor").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}).
+			AddRow(1, "Sensor1", 51.5, -0.1, nil, nil, nil, nil, pq.Array([]string{}), nil, nil))
+	_, err = repo.RemoveSensorTag("Sensor1", "outdoor")
+	assert.NoError(t, err)
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
+
+// laggingRepository writes to a primary, and looks sensors up by name on a replica that hasn't
+// received any of its writes.
+type laggingRepository struct {
+	app.Repository
+	replica app.Repository
+}
+
+func (r *laggingRepository) GetSensorMetadataByName(name string) (*app.SensorMetadata, error) {
+	return r.replica.GetSensorMetadataByName(name)
+}
+
+func (r *laggingRepository) Primary() app.Repository {
+	return r.Repository
+}
+
+func (r *laggingRepository) ReplicaStaleness() time.Duration {
+	return time.Minute
+}
+
+func TestReadYourWrites(t *testing.T) {
+	router := app.NewRouter(app.NewHandler(&laggingRepository{Repository: app.NewMemoryRepository(), replica: app.NewMemoryRepository()}))
+	get := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/sensors?name=Madrid", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Empty(t, rr.Header().Get(app.SessionTokenHeader))
+		return rr.Code
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/sensors", strings.NewReader(`{"name":"Madrid","location":{"latitude":40.4168,"longitude":-3.7038}}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	token := rr.Header().Get(app.SessionTokenHeader)
+	assert.NotEmpty(t, token)
+
+	// The replica hasn't seen the write, unless the client asks to read it
+	assert.Equal(t, http.StatusNotFound, get("", ""))
+	assert.Equal(t, http.StatusOK, get(app.ReadYourWritesHeader, "true"))
+	assert.Equal(t, http.StatusOK, get(app.SessionTokenHeader, token))
+	expired := strconv.FormatInt(time.Now().Add(-2*time.Minute).UnixMilli(), 10)
+	assert.Equal(t, http.StatusNotFound, get(app.SessionTokenHeader, expired))
+
+	// Failed writes and repositories without replicas don't hand out tokens
+	req = httptest.NewRequest(http.MethodPost, "/v1/sensors", strings.NewReader(`{"name":""}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Empty(t, rr.Header().Get(app.SessionTokenHeader))
+
+	router = app.NewRouter(app.NewHandler(app.NewMemoryRepository()))
+	req = httptest.NewRequest(http.MethodPost, "/v1/sensors", strings.NewReader(`{"name":"Madrid","location":{"latitude":40.4168,"longitude":-3.7038}}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Empty(t, rr.Header().Get(app.SessionTokenHeader))
+}
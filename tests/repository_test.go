@@ -2,8 +2,12 @@ package app
 
 import (
 	"database/sql/driver"
-	"os"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/lib/pq"
@@ -12,7 +16,7 @@ import (
 )
 
 func TestPostgresRepository_CreateSensorMetadata(t *testing.T) {
-	mockDB, mock, err := sqlmock.New()
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	assert.NoError(t, err)
 	defer mockDB.Close()
 
@@ -27,10 +31,10 @@ func TestPostgresRepository_CreateSensorMetadata(t *testing.T) {
 		Tags: []string{"tag1", "tag2"},
 	}
 
-	expectedQuery := "INSERT INTO sensor_metadata (name, location_latitude, location_longitude, tags) VALUES ($1, $2, $3, $4)"
+	expectedQuery := "INSERT INTO sensor_metadata (name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''), $10)"
 
-	mock.ExpectExec(expectedQuery).
-		WithArgs(sqlmock.AnyArg(), 123.456, 789.012, AnyEmptyArray()).
+	mock.ExpectPrepare(expectedQuery).ExpectExec().
+		WithArgs(sqlmock.AnyArg(), 123.456, 789.012, nil, "", nil, nil, AnyEmptyArray(), "", "{}").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	err = repo.CreateSensorMetadata(sensor)
@@ -40,7 +44,7 @@ func TestPostgresRepository_CreateSensorMetadata(t *testing.T) {
 }
 
 func TestPostgresRepository_GetSensorMetadataByName(t *testing.T) {
-	mockDB, mock, err := sqlmock.New()
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	assert.NoError(t, err)
 	defer mockDB.Close()
 
@@ -56,12 +60,12 @@ func TestPostgresRepository_GetSensorMetadataByName(t *testing.T) {
 		Tags: []string{"tag1", "tag2"},
 	}
 
-	expectedQuery := "SELECT id, name, location_latitude, location_longitude, tags FROM sensor_metadata WHERE name = $1"
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes FROM sensor_metadata WHERE name = $1"
 	expectedArgs := []driver.Value{"Sensor1"}
 
 	mock.ExpectPrepare(expectedQuery).ExpectQuery().WithArgs(expectedArgs...).WillReturnRows(
-		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "tags"}).
-			AddRow(expectedSensor.ID, expectedSensor.Name, expectedSensor.Location.Latitude, expectedSensor.Location.Longitude, pq.Array(expectedSensor.Tags)),
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}).
+			AddRow(expectedSensor.ID, expectedSensor.Name, expectedSensor.Location.Latitude, expectedSensor.Location.Longitude, nil, nil, nil, nil, pq.Array(expectedSensor.Tags), nil, []byte("{}")),
 	)
 
 	sensor, err := repo.GetSensorMetadataByName("Sensor1")
@@ -72,7 +76,7 @@ func TestPostgresRepository_GetSensorMetadataByName(t *testing.T) {
 }
 
 func TestPostgresRepository_UpdateSensorMetadata(t *testing.T) {
-	mockDB, mock, err := sqlmock.New()
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	assert.NoError(t, err)
 	defer mockDB.Close()
 
@@ -88,8 +92,8 @@ func TestPostgresRepository_UpdateSensorMetadata(t *testing.T) {
 		Tags: []string{"tag1", "tag2"},
 	}
 
-	expectedQuery := "UPDATE sensor_metadata SET name = $1, location_latitude = $2, location_longitude = $3, tags = $4 WHERE id = $5"
-	expectedArgs := []driver.Value{"Sensor1", 123.456, 789.012, pq.Array([]string{"tag1", "tag2"}), 1}
+	expectedQuery := "UPDATE sensor_metadata SET name = $1, location_latitude = $2, location_longitude = $3, location_altitude = $4, location_altitude_datum = NULLIF($5, ''), location_floor = $6, location_accuracy_m = $7, tags = $8, sensor_type = NULLIF($9, ''), attributes = $10 WHERE id = $11"
+	expectedArgs := []driver.Value{"Sensor1", 123.456, 789.012, nil, "", nil, nil, AnyEmptyArray(), "", "{}", 1}
 
 	mock.ExpectPrepare(expectedQuery).ExpectExec().WithArgs(expectedArgs...).WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -97,10 +101,144 @@ func TestPostgresRepository_UpdateSensorMetadata(t *testing.T) {
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 	assert.NoError(t, err)
+
+	// Updating an unknown ID reports that the sensor isn't found
+	expectedArgs[len(expectedArgs)-1] = 99
+	mock.ExpectPrepare(expectedQuery).ExpectExec().WithArgs(expectedArgs...).WillReturnResult(sqlmock.NewResult(0, 0))
+	sensor.ID = 99
+	assert.ErrorIs(t, repo.UpdateSensorMetadata(sensor), app.ErrSensorNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestPostgresRepository_GetNearestSensorMetadata(t *testing.T) {
-	mockDB, mock, err := sqlmock.New()
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	expectedSensor := &app.NearestSensor{
+		SensorMetadata: app.SensorMetadata{
+			ID:   1,
+			Name: "Sensor1",
+			Location: app.Location{
+				Latitude:  123.456,
+				Longitude: 789.012,
+			},
+			Tags: []string{"tag1", "tag2"},
+		},
+		Distance: 12.5,
+	}
+
+	distance := "earth_distance(ll_to_earth($1, $2), ll_to_earth(location_latitude, location_longitude))"
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes, " + distance + " AS distance " +
+		"FROM sensor_metadata WHERE earth_box(ll_to_earth($1, $2), $3) @> ll_to_earth(location_latitude, location_longitude) AND " + distance + " <= $3 ORDER BY distance LIMIT $4"
+	expectedArgs := []driver.Value{123.456, 789.012, 1000.0, 1}
+
+	mock.ExpectQuery(expectedQuery).WithArgs(expectedArgs...).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes", "distance"}).
+			AddRow(expectedSensor.ID, expectedSensor.Name, expectedSensor.Location.Latitude, expectedSensor.Location.Longitude, nil, nil, nil, nil, pq.Array(expectedSensor.Tags), nil, []byte("{}"), expectedSensor.Distance),
+	)
+
+	sensor, err := repo.GetNearestSensorMetadata("123.456", "789.012")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSensor, sensor)
+}
+
+func TestPostgresRepository_FindNearestSensorMetadataExpandsRadius(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	distance := "earth_distance(ll_to_earth($1, $2), ll_to_earth(location_latitude, location_longitude))"
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes, " + distance + " AS distance " +
+		"FROM sensor_metadata WHERE earth_box(ll_to_earth($1, $2), $3) @> ll_to_earth(location_latitude, location_longitude) AND " + distance + " <= $3 ORDER BY distance LIMIT $4"
+	columns := []string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes", "distance"}
+
+	// Nothing within 1km, both sensors within 10km
+	mock.ExpectQuery(expectedQuery).WithArgs(51.5, -0.1, 1000.0, 2).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(expectedQuery).WithArgs(51.5, -0.1, 10000.0, 2).WillReturnRows(
+		sqlmock.NewRows(columns).
+			AddRow(1, "Sensor1", 51.55, -0.1, nil, nil, nil, nil, pq.Array([]string{}), nil, nil, 5560.0).
+			AddRow(2, "Sensor2", 51.52, -0.1, nil, nil, nil, nil, pq.Array([]string{}), nil, nil, 2224.0),
+	)
+
+	sensors, err := repo.FindNearestSensorMetadata(app.NearestQuery{Latitude: 51.5, Longitude: -0.1, Limit: 2})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Len(t, sensors, 2)
+	assert.Equal(t, "Sensor2", sensors[1].Name)
+}
+
+func TestPostgresRepository_FindNearestSensorMetadataFallsBackToFullScan(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	distance := "earth_distance(ll_to_earth($1, $2), ll_to_earth(location_latitude, location_longitude))"
+	selectQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes, " + distance + " AS distance FROM sensor_metadata "
+	columns := []string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes", "distance"}
+
+	// A sparse fleet misses within 1km and 10km, and is scanned in full without widening further
+	for _, radius := range []float64{1e3, 1e4} {
+		mock.ExpectQuery(selectQuery+"WHERE earth_box(ll_to_earth($1, $2), $3) @> ll_to_earth(location_latitude, location_longitude) AND "+distance+" <= $3 ORDER BY distance LIMIT $4").
+			WithArgs(51.5, -0.1, radius, 1).WillReturnRows(sqlmock.NewRows(columns))
+	}
+	mock.ExpectQuery(selectQuery+"ORDER BY distance LIMIT $3").WithArgs(51.5, -0.1, 1).WillReturnRows(
+		sqlmock.NewRows(columns).AddRow(1, "Sensor1", 48.85, 2.35, nil, nil, nil, nil, pq.Array([]string{}), nil, nil, 343000.0),
+	)
+
+	sensors, err := repo.FindNearestSensorMetadata(app.NearestQuery{Latitude: 51.5, Longitude: -0.1, Limit: 1})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	if assert.Len(t, sensors, 1) {
+		assert.Equal(t, "Sensor1", sensors[0].Name)
+	}
+}
+
+func TestPostgresRepository_FindNeighbourSensorMetadata(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	distance := "earth_distance(ll_to_earth(origin.location_latitude, origin.location_longitude), ll_to_earth(location_latitude, location_longitude))"
+	expectedQuery := "SELECT neighbour.*, origin.id FROM sensor_metadata origin CROSS JOIN LATERAL (" +
+		"SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes, " + distance + " AS distance " +
+		"FROM sensor_metadata WHERE id <> origin.id AND tags @> ARRAY[$1]::VARCHAR(255)[] ORDER BY distance LIMIT $2) neighbour " +
+		"WHERE origin.id = ANY($3) ORDER BY origin.id, neighbour.distance"
+	columns := []string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes", "distance", "id"}
+
+	// The neighbours of both sensors are found with one query
+	mock.ExpectQuery(expectedQuery).WithArgs("vendor:acme", 1, pq.Int64Array{1, 2}).WillReturnRows(
+		sqlmock.NewRows(columns).
+			AddRow(2, "Sensor2", 51.9, -0.1, nil, nil, nil, nil, pq.Array([]string{"vendor:acme"}), nil, nil, 44480.0, 1).
+			AddRow(1, "Sensor1", 51.5, -0.1, nil, nil, nil, nil, pq.Array([]string{"vendor:acme"}), nil, nil, 44480.0, 2),
+	)
+
+	tags, err := app.ParseTagExpr("vendor:acme")
+	assert.NoError(t, err)
+	neighbours, err := repo.FindNeighbourSensorMetadata(app.NeighbourQuery{IDs: []int{1, 2}, Tags: tags, Limit: 1})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Len(t, neighbours, 2)
+	assert.Equal(t, "Sensor2", neighbours[1][0].Name)
+	assert.Equal(t, 44480.0, neighbours[1][0].Distance)
+	assert.Equal(t, "Sensor1", neighbours[2][0].Name)
+}
+
+func TestPostgresRepository_AddSensorTags(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	assert.NoError(t, err)
 	defer mockDB.Close()
 
@@ -110,40 +248,474 @@ func TestPostgresRepository_GetNearestSensorMetadata(t *testing.T) {
 		ID:   1,
 		Name: "Sensor1",
 		Location: app.Location{
-			Latitude:  123.456,
-			Longitude: 789.012,
+			Latitude:  51.5,
+			Longitude: -0.12,
 		},
 		Tags: []string{"tag1", "tag2"},
 	}
 
-	expectedQuery := "SELECT id, name, location_latitude, location_longitude, tags, earth_distance(ll_to_earth($1, $2), ll_to_earth(location_latitude, location_longitude)) AS distance FROM sensor_metadata ORDER BY distance LIMIT 1"
-	expectedArgs := []driver.Value{"123.456", "789.012"}
+	expectedQuery := "UPDATE sensor_metadata SET tags = COALESCE(tags, ARRAY[]::VARCHAR(255)[]) || ARRAY(SELECT t FROM unnest($2::VARCHAR(255)[]) WITH ORDINALITY AS n(t, i) WHERE NOT t = ANY(COALESCE(tags, ARRAY[]::VARCHAR(255)[])) ORDER BY i) WHERE name = $1 RETURNING id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes"
 
-	mock.ExpectPrepare(expectedQuery).ExpectQuery().WithArgs(expectedArgs...).WillReturnRows(
-		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "tags"}).
-			AddRow(expectedSensor.ID, expectedSensor.Name, expectedSensor.Location.Latitude, expectedSensor.Location.Longitude, pq.Array(expectedSensor.Tags)),
+	mock.ExpectPrepare(expectedQuery).ExpectQuery().WithArgs("Sensor1", AnyEmptyArray()).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}).
+			AddRow(expectedSensor.ID, expectedSensor.Name, expectedSensor.Location.Latitude, expectedSensor.Location.Longitude, nil, nil, nil, nil, pq.Array(expectedSensor.Tags), nil, []byte("{}")),
 	)
 
-	sensor, err := repo.GetNearestSensorMetadata("123.456", "789.012")
+	sensor, err := repo.AddSensorTags("Sensor1", []string{"tag2"})
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 	assert.NoError(t, err)
 	assert.Equal(t, expectedSensor, sensor)
 }
 
+func TestPostgresRepository_RemoveSensorTagNotFound(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	expectedQuery := "UPDATE sensor_metadata SET tags = array_remove(tags, $2) WHERE name = $1 RETURNING id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes"
+
+	mock.ExpectPrepare(expectedQuery).ExpectQuery().WithArgs("Missing", "tag1").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}),
+	)
+
+	sensor, err := repo.RemoveSensorTag("Missing", "tag1")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.ErrorIs(t, err, app.ErrSensorNotFound)
+	assert.Nil(t, sensor)
+}
+
+func TestPostgresRepository_GetTagCounts(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	expectedQuery := "SELECT tag, COUNT(*) FROM sensor_metadata CROSS JOIN LATERAL unnest(tags) AS tag GROUP BY tag ORDER BY COUNT(*) DESC, tag"
+
+	mock.ExpectQuery(expectedQuery).WillReturnRows(
+		sqlmock.NewRows([]string{"tag", "count"}).AddRow("tag1", 3).AddRow("tag2", 1),
+	)
+
+	tagCounts, err := repo.GetTagCounts()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, []app.TagCount{{Tag: "tag1", Count: 3}, {Tag: "tag2", Count: 1}}, tagCounts)
+}
+
+func TestPostgresRepository_ListSensorMetadata(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	tags, err := app.ParseTagExpr("vendor:acme AND NOT floor:*")
+	assert.NoError(t, err)
+
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes FROM sensor_metadata WHERE id > $1 AND (tags @> ARRAY[$2]::VARCHAR(255)[] AND (EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE starts_with(tag, $3))) IS NOT TRUE) AND attributes @@ $4::jsonpath AND attributes @> $5::jsonb ORDER BY id LIMIT $6"
+
+	mock.ExpectQuery(expectedQuery).WithArgs(5, "vendor:acme", "floor:", `$."sampling_rate" >= 10`, `{"calibration":{"lab":"north"}}`, 10).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}).
+			AddRow(6, "Sensor6", 51.5, -0.12, nil, nil, nil, nil, pq.Array([]string{"vendor:acme"}), "thermometer", []byte(`{"sampling_rate": 10, "calibration": {"lab": "north"}}`)),
+	)
+
+	attributes, err := app.ParseAttributeFilters("attr.sampling_rate>=10&attr.calibration.lab=north")
+	assert.NoError(t, err)
+
+	sensors, err := repo.ListSensorMetadata(app.SensorFilter{Tags: tags, Attributes: attributes, AfterID: 5, Limit: 10})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, []app.SensorMetadata{{
+		ID:         6,
+		Name:       "Sensor6",
+		Type:       "thermometer",
+		Location:   app.Location{Latitude: 51.5, Longitude: -0.12},
+		Tags:       []string{"vendor:acme"},
+		Attributes: map[string]interface{}{"sampling_rate": 10.0, "calibration": map[string]interface{}{"lab": "north"}},
+	}}, sensors)
+}
+
+func TestPostgresRepository_FindSensorMetadataWithin(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	bbox, err := app.ParseBoundingBox("170,-20,-170,-10")
+	assert.NoError(t, err)
+
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes FROM sensor_metadata WHERE (point(location_longitude, location_latitude) <@ box(point($1, $2), point($3, $4)) OR point(location_longitude, location_latitude) <@ box(point($5, $6), point($7, $8))) AND id > $9 ORDER BY id LIMIT $10"
+
+	mock.ExpectQuery(expectedQuery).WithArgs(170.0, -20.0, 180.0, -10.0, -180.0, -20.0, -170.0, -10.0, 0, 10).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}).
+			AddRow(1, "Fiji", -17.7, 178.0, nil, nil, nil, nil, pq.Array([]string{}), nil, nil),
+	)
+
+	sensors, err := repo.FindSensorMetadataWithin(app.WithinQuery{BoundingBox: &bbox, SensorFilter: app.SensorFilter{Limit: 10}})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Len(t, sensors, 1)
+	assert.Equal(t, "Fiji", sensors[0].Name)
+}
+
+func TestPostgresRepository_FindNearestSensorMetadata3DSameFloor(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	altitude, floor := 42.0, 3
+	expectedSensor := app.NearestSensor{
+		SensorMetadata: app.SensorMetadata{
+			ID:   1,
+			Name: "Sensor1",
+			Location: app.Location{
+				Latitude:      51.5,
+				Longitude:     -0.12,
+				Altitude:      &altitude,
+				AltitudeDatum: app.DatumMSL,
+				Floor:         &floor,
+			},
+			Tags: []string{},
+		},
+		Distance: 12.5,
+	}
+
+	surface := "earth_distance(ll_to_earth($1, $2), ll_to_earth(location_latitude, location_longitude))"
+	expectedQuery := "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes, " +
+		"CASE WHEN location_altitude IS NOT NULL AND location_altitude_datum = $3 THEN sqrt(power(" + surface + ", 2) + power(location_altitude - $4, 2)) ELSE " + surface + " END AS distance " +
+		"FROM sensor_metadata ORDER BY location_floor IS NOT DISTINCT FROM $5 DESC, distance LIMIT $6"
+
+	mock.ExpectQuery(expectedQuery).WithArgs(51.5, -0.1, app.DatumMSL, 40.0, 3, 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes", "distance"}).
+			AddRow(1, "Sensor1", 51.5, -0.12, altitude, app.DatumMSL, floor, nil, pq.Array([]string{}), nil, []byte("{}"), 12.5),
+	)
+
+	queryAltitude, queryFloor := 40.0, 3
+	sensors, err := repo.FindNearestSensorMetadata(app.NearestQuery{
+		Latitude:        51.5,
+		Longitude:       -0.1,
+		Altitude:        &queryAltitude,
+		AltitudeDatum:   app.DatumMSL,
+		Floor:           &queryFloor,
+		Use3D:           true,
+		PreferSameFloor: true,
+		Limit:           1,
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, []app.NearestSensor{expectedSensor}, sensors)
+}
+
+func TestPostgresRepository_ApplySensorBatch(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	insert := "INSERT INTO sensor_metadata (name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes) VALUES "
+	row := func(n int) string {
+		return fmt.Sprintf("($%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d, $%d, $%d, NULLIF($%d, ''), $%d)", n, n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9)
+	}
+
+	// Both creates run as one statement; Sensor2 already exists
+	mock.ExpectQuery(insert+row(1)+", "+row(11)+" ON CONFLICT (name) DO NOTHING RETURNING id, name, TRUE").
+		WithArgs("Sensor1", 1.0, 2.0, nil, "", nil, nil, "{}", "", "{}", "Sensor2", 3.0, 4.0, nil, "", nil, nil, "{}", "", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "bool"}).AddRow(5, "Sensor1", true))
+	// Deleting Sensor1 starts a new run, and so does deleting it a second time
+	mock.ExpectQuery("DELETE FROM sensor_metadata WHERE name = ANY($1) RETURNING id, name, FALSE").
+		WithArgs("{\"Sensor1\"}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "bool"}).AddRow(5, "Sensor1", false))
+	mock.ExpectQuery("DELETE FROM sensor_metadata WHERE name = ANY($1) RETURNING id, name, FALSE").
+		WithArgs("{\"Sensor1\"}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "bool"}))
+
+	sensor1 := app.SensorMetadata{Name: "Sensor1", Location: app.Location{Latitude: 1, Longitude: 2}, Tags: []string{}}
+	sensor2 := app.SensorMetadata{Name: "Sensor2", Location: app.Location{Latitude: 3, Longitude: 4}, Tags: []string{}}
+	outcomes, err := repo.ApplySensorBatch([]app.BatchOperation{
+		{Op: app.BatchCreate, Sensor: &sensor1},
+		{Op: app.BatchCreate, Sensor: &sensor2},
+		{Op: app.BatchDelete, Name: "Sensor1"},
+		{Op: app.BatchDelete, Name: "Sensor1"},
+	}, false)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, []app.BatchOutcome{{Created: true}, {Err: app.ErrSensorExists}, {}, {Err: app.ErrSensorNotFound}}, outcomes)
+	assert.Equal(t, 5, sensor1.ID)
+}
+
+func TestPostgresRepository_ApplySensorBatchRetriesFailedRun(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	insert := "INSERT INTO sensor_metadata (name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes) VALUES "
+	row := func(n int) string {
+		return fmt.Sprintf("($%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d, $%d, $%d, NULLIF($%d, ''), $%d)", n, n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9)
+	}
+	create := " ON CONFLICT (name) DO NOTHING RETURNING id, name, TRUE"
+	columns := []string{"id", "name", "bool"}
+	sensor1 := []driver.Value{"Sensor1", 1.0, 2.0, nil, "", nil, nil, "{}", "", "{}"}
+	sensor2 := []driver.Value{"Sensor2", 3.0, 4.0, nil, "", nil, nil, "{}", "hygrometer", "{}"}
+	sensor3 := []driver.Value{"Sensor3", 5.0, 6.0, nil, "", nil, nil, "{}", "", "{}"}
+
+	// Sensor2's type was deleted, which fails the whole statement
+	fkViolation := &pq.Error{Code: "23503", Message: "insert or update on table \"sensor_metadata\" violates foreign key constraint"}
+	mock.ExpectQuery(insert + row(1) + ", " + row(11) + ", " + row(21) + create).
+		WithArgs(append(append(append([]driver.Value{}, sensor1...), sensor2...), sensor3...)...).
+		WillReturnError(fkViolation)
+	// so each sensor is created alone, and only Sensor2 fails
+	mock.ExpectQuery(insert + row(1) + create).WithArgs(sensor1...).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, "Sensor1", true))
+	mock.ExpectQuery(insert + row(1) + create).WithArgs(sensor2...).WillReturnError(fkViolation)
+	mock.ExpectQuery(insert + row(1) + create).WithArgs(sensor3...).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(6, "Sensor3", true))
+
+	outcomes, err := repo.ApplySensorBatch([]app.BatchOperation{
+		{Op: app.BatchCreate, Sensor: &app.SensorMetadata{Name: "Sensor1", Location: app.Location{Latitude: 1, Longitude: 2}, Tags: []string{}}},
+		{Op: app.BatchCreate, Sensor: &app.SensorMetadata{Name: "Sensor2", Type: "hygrometer", Location: app.Location{Latitude: 3, Longitude: 4}, Tags: []string{}}},
+		{Op: app.BatchCreate, Sensor: &app.SensorMetadata{Name: "Sensor3", Location: app.Location{Latitude: 5, Longitude: 6}, Tags: []string{}}},
+	}, false)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, []app.BatchOutcome{{Created: true}, {Err: fkViolation}, {Created: true}}, outcomes)
+}
+
+func TestPostgresRepository_ApplySensorBatchAtomic(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	upsert := "INSERT INTO sensor_metadata (name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes) " +
+		"VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''), $10) ON CONFLICT (name) DO UPDATE SET location_latitude = EXCLUDED.location_latitude, location_longitude = EXCLUDED.location_longitude, " +
+		"location_altitude = EXCLUDED.location_altitude, location_altitude_datum = EXCLUDED.location_altitude_datum, location_floor = EXCLUDED.location_floor, " +
+		"location_accuracy_m = EXCLUDED.location_accuracy_m, tags = EXCLUDED.tags, sensor_type = EXCLUDED.sensor_type, attributes = EXCLUDED.attributes RETURNING id, name, xmax = 0"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(upsert).WithArgs("Sensor1", 1.0, 2.0, nil, "", nil, nil, "{}", "", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "bool"}).AddRow(5, "Sensor1", false))
+	mock.ExpectQuery("DELETE FROM sensor_metadata WHERE name = ANY($1) RETURNING id, name, FALSE").
+		WithArgs("{\"Sensor9\"}").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "bool"}))
+	mock.ExpectRollback()
+
+	sensor1 := app.SensorMetadata{Name: "Sensor1", Location: app.Location{Latitude: 1, Longitude: 2}, Tags: []string{}}
+	outcomes, err := repo.ApplySensorBatch([]app.BatchOperation{
+		{Op: app.BatchUpsert, Sensor: &sensor1},
+		{Op: app.BatchDelete, Name: "Sensor9"},
+	}, true)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, []app.BatchOutcome{{Err: app.ErrBatchAborted}, {Err: app.ErrSensorNotFound}}, outcomes)
+}
+
+const sensorEventsWatermarkQuery = "SELECT sensor_events_watermark(), pruned_through FROM sensor_events_retention"
+
+func TestPostgresRepository_ListSensorEvents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	expectedQuery := "SELECT id, type, sensor, previous FROM sensor_events WHERE id > $1 AND id < $2 ORDER BY id LIMIT $3"
+
+	// Rows are stored as encoded by to_jsonb, generated columns included
+	before := `{"id": 6, "name": "Sensor6", "location_latitude": 51.5, "location_longitude": -0.12, "location_altitude": null, "location_altitude_datum": null, "location_floor": null, "location_accuracy_m": null, "tags": ["vendor:acme"], "sensor_type": null, "attributes": {}, "location": "0101000020E6100000"}`
+	after := `{"id": 6, "name": "Sensor6", "location_latitude": 51.5, "location_longitude": -0.12, "location_altitude": 35, "location_altitude_datum": "WGS84", "location_floor": 2, "location_accuracy_m": null, "tags": ["vendor:acme"], "sensor_type": "thermometer", "attributes": {"sampling_rate": 10}}`
+	// Events are read below the watermark, after it
+	mock.ExpectQuery(sensorEventsWatermarkQuery).WillReturnRows(sqlmock.NewRows([]string{"watermark", "pruned_through"}).AddRow(44, 0))
+	mock.ExpectQuery(expectedQuery).WithArgs(41, 44, 10).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "type", "sensor", "previous"}).
+			AddRow(42, "created", []byte(before), nil).
+			AddRow(43, "updated", []byte(after), []byte(before)),
+	)
+
+	events, err := repo.ListSensorEvents(41, 10)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	created := app.SensorMetadata{
+		ID:       6,
+		Name:     "Sensor6",
+		Location: app.Location{Latitude: 51.5, Longitude: -0.12},
+		Tags:     []string{"vendor:acme"},
+	}
+	altitude, floor := 35.0, 2
+	assert.Equal(t, []app.SensorEvent{
+		{ID: 42, Type: app.SensorCreated, Sensor: created},
+		{ID: 43, Type: app.SensorUpdated, Sensor: app.SensorMetadata{
+			ID:         6,
+			Name:       "Sensor6",
+			Type:       "thermometer",
+			Location:   app.Location{Latitude: 51.5, Longitude: -0.12, Altitude: &altitude, AltitudeDatum: app.DatumWGS84, Floor: &floor},
+			Tags:       []string{"vendor:acme"},
+			Attributes: map[string]interface{}{"sampling_rate": 10.0},
+		}, Previous: &created},
+	}, events)
+
+	// Resuming from before the events pruned fails
+	mock.ExpectQuery(sensorEventsWatermarkQuery).WillReturnRows(sqlmock.NewRows([]string{"watermark", "pruned_through"}).AddRow(44, 20))
+	_, err = repo.ListSensorEvents(10, 10)
+	assert.ErrorIs(t, err, app.ErrSensorEventsPruned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepository_PruneSensorEvents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pruneQuery := "WITH pruned AS (DELETE FROM sensor_events WHERE created_at < $1 AND id <= (SELECT last_event_id FROM webhook_outbox) RETURNING id) " +
+		"SELECT COUNT(*), COALESCE(MAX(id), 0) FROM pruned"
+
+	// The highest ID pruned is recorded
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pruned_through FROM sensor_events_retention FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"pruned_through"}).AddRow(10))
+	mock.ExpectQuery(pruneQuery).WithArgs(before).WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(5, 15))
+	mock.ExpectExec("UPDATE sensor_events_retention SET pruned_through = $1").WithArgs(15).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deleted, err := repo.PruneSensorEvents(before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+
+	// And left alone when nothing was pruned
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pruned_through FROM sensor_events_retention FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"pruned_through"}).AddRow(15))
+	mock.ExpectQuery(pruneQuery).WithArgs(before).WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(0, 0))
+	mock.ExpectCommit()
+
+	deleted, err = repo.PruneSensorEvents(before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepository_EnqueueWebhookDeliveries(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+
+	repo := &app.PostgresRepository{Db: mockDB}
+
+	acme := `{"id": 6, "name": "Sensor6", "location_latitude": 51.5, "location_longitude": -0.12, "tags": ["vendor:acme"], "attributes": {}}`
+	other := `{"id": 7, "name": "Sensor7", "location_latitude": 48.8, "location_longitude": 2.35, "tags": ["vendor:other"], "attributes": {}}`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT last_event_id FROM webhook_outbox FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"last_event_id"}).AddRow(41))
+	mock.ExpectQuery(sensorEventsWatermarkQuery).WillReturnRows(sqlmock.NewRows([]string{"watermark", "pruned_through"}).AddRow(44, 0))
+	mock.ExpectQuery("SELECT id, type, sensor, previous FROM sensor_events WHERE id > $1 AND id < $2 ORDER BY id LIMIT $3").WithArgs(41, 44, 100).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "type", "sensor", "previous"}).
+			AddRow(42, "created", []byte(acme), nil).
+			AddRow(43, "deleted", []byte(other), nil),
+	)
+	// The first webhook only wants acme sensors, and the second one, created after event 42, only deletions
+	mock.ExpectQuery("SELECT id, url, secret, events, tags, after_event_id FROM webhooks ORDER BY id").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "url", "secret", "events", "tags", "after_event_id"}).
+			AddRow(1, "https://example.com/acme", "0123456789abcdef", "{}", "vendor:acme", 0).
+			AddRow(2, "https://example.com/deleted", "0123456789abcdef", "{deleted}", "", 42),
+	)
+	mock.ExpectExec("INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload) VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)").
+		WithArgs(1, 42, "created", sqlmock.AnyArg(), 2, 43, "deleted", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE webhook_outbox SET last_event_id = $1").WithArgs(43).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.EnqueueWebhookDeliveries(100)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
 func TestNewPostgresRepository(t *testing.T) {
+	// The test needs a database; without one, it would wait for it until DB_CONNECT_TIMEOUT
+	conn, err := net.DialTimeout("tcp", "localhost:5432", time.Second)
+	if err != nil {
+		t.Skip("no database is listening on localhost:5432")
+	}
+	conn.Close()
+
 	// Set the required environment variables for the test
-	os.Setenv("DB_HOST", "localhost")
-	os.Setenv("DB_PORT", "5432")
-	os.Setenv("DB_NAME", "testdb")
-	os.Setenv("DB_USER", "testuser")
-	os.Setenv("DB_PASSWORD", "testpassword")
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_PORT", "5432")
+	t.Setenv("DB_NAME", "testdb")
+	t.Setenv("DB_USER", "testuser")
+	t.Setenv("DB_PASSWORD", "testpassword")
+	t.Setenv("DB_CONNECT_TIMEOUT", "2s")
 	repo, err := app.NewPostgresRepository()
 
 	assert.NoError(t, err)
 	assert.NotNil(t, repo.Db)
 }
 
+func TestNewPostgresRepositoryFailsFast(t *testing.T) {
+	// A server rejecting every login, as PostgreSQL does with a wrong password
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			var header [4]byte
+			if _, err := io.ReadFull(conn, header[:]); err == nil {
+				io.CopyN(io.Discard, conn, int64(binary.BigEndian.Uint32(header[:]))-4)
+				fields := "SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00"
+				message := append([]byte{'E', 0, 0, 0, 0}, fields...)
+				binary.BigEndian.PutUint32(message[1:], uint32(len(message)-1))
+				conn.Write(message)
+			}
+			conn.Close()
+		}
+	}()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	assert.NoError(t, err)
+	t.Setenv("DB_HOST", "127.0.0.1")
+	t.Setenv("DB_PORT", port)
+	t.Setenv("DB_NAME", "testdb")
+	t.Setenv("DB_USER", "testuser")
+	t.Setenv("DB_PASSWORD", "wrong")
+	t.Setenv("DB_CONNECT_TIMEOUT", "30s")
+
+	// The login failure is returned at once rather than retried until the timeout
+	start := time.Now()
+	_, err = app.NewPostgresRepository()
+	var pqErr *pq.Error
+	if assert.ErrorAs(t, err, &pqErr) {
+		assert.Equal(t, pq.ErrorCode("28P01"), pqErr.Code)
+	}
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
 func AnyEmptyArray() interface{} {
 	return sqlmock.AnyArg()
 }
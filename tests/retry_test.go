@@ -0,0 +1,81 @@
+package app
+
+import (
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+const tagCountsQuery = "SELECT tag, COUNT(*) FROM sensor_metadata CROSS JOIN LATERAL unnest(tags) AS tag GROUP BY tag ORDER BY COUNT(*) DESC, tag"
+
+const createSensorTypeQuery = "INSERT INTO sensor_types (name, description, schema) VALUES ($1, $2, $3)"
+
+func newRetryingRepository(t *testing.T) (*app.RetryingRepository, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	policy := app.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return &app.RetryingRepository{Repository: &app.PostgresRepository{Db: mockDB}, Reads: policy, Writes: policy}, mock
+}
+
+func TestRetryingRepository_Reads(t *testing.T) {
+	repo, mock := newRetryingRepository(t)
+
+	// Serialization failures and lost connections are retried
+	mock.ExpectQuery(tagCountsQuery).WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectQuery(tagCountsQuery).WillReturnError(io.ErrUnexpectedEOF)
+	mock.ExpectQuery(tagCountsQuery).WillReturnRows(sqlmock.NewRows([]string{"tag", "count"}).AddRow("tag1", 3))
+	tagCounts, err := repo.GetTagCounts()
+	assert.NoError(t, err)
+	assert.Equal(t, []app.TagCount{{Tag: "tag1", Count: 3}}, tagCounts)
+
+	// Up to MaxAttempts
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery(tagCountsQuery).WillReturnError(&pq.Error{Code: "57P01"})
+	}
+	_, err = repo.GetTagCounts()
+	assert.Equal(t, &pq.Error{Code: "57P01"}, err)
+
+	// Other errors fail at once
+	mock.ExpectQuery(tagCountsQuery).WillReturnError(&pq.Error{Code: "42P01"})
+	_, err = repo.GetTagCounts()
+	assert.Equal(t, &pq.Error{Code: "42P01"}, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryingRepository_Writes(t *testing.T) {
+	repo, mock := newRetryingRepository(t)
+	sensorType := &app.SensorType{Name: "thermometer", Schema: []byte(`{"type":"object"}`)}
+
+	// Deadlocks and refused connections are retried, since nothing was written
+	mock.ExpectPrepare(createSensorTypeQuery).ExpectExec().WillReturnError(&pq.Error{Code: "40P01"})
+	mock.ExpectPrepare(createSensorTypeQuery).ExpectExec().WillReturnError(syscall.ECONNREFUSED)
+	mock.ExpectPrepare(createSensorTypeQuery).ExpectExec().
+		WithArgs("thermometer", "", `{"type":"object"}`).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.CreateSensorType(sensorType))
+
+	// A connection lost in flight may have committed the write, so it isn't retried
+	mock.ExpectPrepare(createSensorTypeQuery).ExpectExec().WillReturnError(syscall.ECONNRESET)
+	assert.ErrorIs(t, repo.CreateSensorType(sensorType), syscall.ECONNRESET)
+
+	// Nor are the errors that a retry can't fix
+	mock.ExpectPrepare(createSensorTypeQuery).ExpectExec().WillReturnError(&pq.Error{Code: "23505"})
+	assert.ErrorIs(t, repo.CreateSensorType(sensorType), app.ErrSensorTypeExists)
+
+	// A server that can't take connections yet is retried up to MaxAttempts
+	for i := 0; i < 3; i++ {
+		mock.ExpectPrepare(createSensorTypeQuery).WillReturnError(&pq.Error{Code: "57P03"})
+	}
+	assert.Equal(t, &pq.Error{Code: "57P03"}, repo.CreateSensorType(sensorType))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/skartikey/sensor-metadata/cmd/sensorctl/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+// runSensorctl runs sensorctl against server and returns its exit code, stdout and stderr.
+func runSensorctl(t *testing.T, server *httptest.Server, stdin string, args ...string) (int, string, string) {
+	t.Setenv("SENSORCTL_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(append(args, "--url", server.URL), strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func newSensorctlServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(app.NewRouter(app.NewHandler(newSeededMemoryRepository(t))))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSensorctlGet(t *testing.T) {
+	server := newSensorctlServer(t)
+
+	code, stdout, _ := runSensorctl(t, server, "", "get", "London")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "Name:       London")
+	assert.Contains(t, stdout, "Tags:       vendor:acme, floor:3")
+
+	code, stdout, _ = runSensorctl(t, server, "", "get", "London", "-o", "json")
+	assert.Equal(t, cli.ExitOK, code)
+	var sensor app.SensorMetadataV1
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &sensor))
+	assert.Equal(t, 1, sensor.ID)
+
+	code, stdout, _ = runSensorctl(t, server, "", "get", "London", "--output", "yaml")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "name: London\n")
+
+	code, _, stderr := runSensorctl(t, server, "", "get", "Madrid")
+	assert.Equal(t, cli.ExitNotFound, code)
+	assert.Contains(t, stderr, "Sensor metadata not found")
+}
+
+func TestSensorctlList(t *testing.T) {
+	server := newSensorctlServer(t)
+
+	code, stdout, stderr := runSensorctl(t, server, "", "list", "--tags", "vendor:acme", "--limit", "1")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "London")
+	assert.NotContains(t, stdout, "Paris")
+	assert.Contains(t, stderr, "--after 1")
+
+	code, stdout, _ = runSensorctl(t, server, "", "list", "--limit", "1", "--all", "-o", "json")
+	assert.Equal(t, cli.ExitOK, code)
+	var sensorList app.SensorListV1
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &sensorList))
+	assert.Len(t, sensorList.Sensors, 3)
+	assert.Empty(t, sensorList.Next)
+}
+
+func TestSensorctlCreateUpdateDelete(t *testing.T) {
+	server := newSensorctlServer(t)
+
+	code, stdout, _ := runSensorctl(t, server, "", "create", "--name", "Madrid", "--latitude", "40.4168", "--longitude", "-3.7038", "--tags", "vendor:acme,outdoor")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "ID:         4")
+
+	code, _, stderr := runSensorctl(t, server, "", "create", "--name", "Madrid", "--latitude", "40.4168", "--longitude", "-3.7038")
+	assert.Equal(t, cli.ExitConflict, code)
+	assert.Contains(t, stderr, "already exists")
+
+	code, _, stderr = runSensorctl(t, server, "", "create", "--name", "Nowhere")
+	assert.Equal(t, cli.ExitError, code)
+	assert.Contains(t, stderr, "  location.latitude: is required\n")
+
+	code, stdout, _ = runSensorctl(t, server, `{"type": "", "tags": ["vendor:other"]}`, "update", "Madrid", "--file", "-", "--latitude", "40.5", "-o", "json")
+	assert.Equal(t, cli.ExitOK, code)
+	var sensor app.SensorMetadataV1
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &sensor))
+	assert.Equal(t, 4, sensor.ID)
+	assert.Equal(t, 40.5, sensor.Location.Latitude)
+	assert.Equal(t, -3.7038, sensor.Location.Longitude)
+	assert.Equal(t, []string{"vendor:other"}, sensor.Tags)
+
+	code, _, _ = runSensorctl(t, server, "", "update", "Lisbon", "--latitude", "38.7")
+	assert.Equal(t, cli.ExitNotFound, code)
+
+	code, stdout, _ = runSensorctl(t, server, "", "delete", "Madrid")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Equal(t, "Deleted sensor \"Madrid\"\n", stdout)
+
+	code, _, _ = runSensorctl(t, server, "", "delete", "Madrid")
+	assert.Equal(t, cli.ExitNotFound, code)
+}
+
+func TestSensorctlNearest(t *testing.T) {
+	server := newSensorctlServer(t)
+
+	code, stdout, _ := runSensorctl(t, server, "", "nearest", "--latitude", "48.8", "--longitude", "2.3")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout, "Paris")
+	assert.Contains(t, stdout, "Distance:")
+
+	code, _, stderr := runSensorctl(t, server, "", "nearest", "--latitude", "48.8")
+	assert.Equal(t, cli.ExitUsage, code)
+	assert.Contains(t, stderr, "usage: sensorctl nearest")
+}
+
+func TestSensorctlImportExport(t *testing.T) {
+	server := newSensorctlServer(t)
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "sensors.csv")
+	assert.NoError(t, os.WriteFile(file, []byte("name,latitude,longitude,tags\nMadrid,40.4168,-3.7038,vendor:acme\nNowhere,,,\n"), 0o644))
+	code, stdout, stderr := runSensorctl(t, server, "", "import", file)
+	assert.Equal(t, cli.ExitError, code)
+	assert.Contains(t, stdout, "Imported:  1")
+	assert.Contains(t, stdout, "line 3")
+	assert.Contains(t, stderr, "1 of 2 rows rejected")
+
+	export := filepath.Join(dir, "export.geojson")
+	code, _, _ = runSensorctl(t, server, "", "export", "--format", "geojson", "--tags", "vendor:acme", "--file", export)
+	assert.Equal(t, cli.ExitOK, code)
+	data, err := os.ReadFile(export)
+	assert.NoError(t, err)
+	var collection app.FeatureCollection
+	assert.NoError(t, json.Unmarshal(data, &collection))
+	assert.Len(t, collection.Features, 3)
+
+	code, stdout, _ = runSensorctl(t, server, "", "export")
+	assert.Equal(t, cli.ExitOK, code)
+	assert.True(t, strings.HasPrefix(stdout, "name,latitude,longitude,"))
+}
+
+func TestSensorctlConfig(t *testing.T) {
+	server := newSensorctlServer(t)
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(config, []byte("url: "+server.URL+"\ntoken: secret\n"), 0o644))
+	t.Setenv("SENSORCTL_CONFIG", config)
+	var stdout, stderr bytes.Buffer
+	code := cli.Run([]string{"get", "Paris", "--config", config}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, cli.ExitOK, code)
+	assert.Contains(t, stdout.String(), "Paris")
+
+	code = cli.Run([]string{"get", "Paris"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, cli.ExitOK, code)
+
+	code = cli.Run([]string{"get", "Paris", "--url", "http://127.0.0.1:1"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, cli.ExitServerError, code)
+
+	code = cli.Run([]string{"frobnicate"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, cli.ExitUsage, code)
+}
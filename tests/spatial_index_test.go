@@ -0,0 +1,212 @@
+package app
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// randomSensor returns a sensor at a random location, a quarter of them near the poles or the antimeridian.
+func randomSensor(rng *rand.Rand, id int) app.SensorMetadata {
+	location := app.Location{Latitude: rng.Float64()*180 - 90, Longitude: rng.Float64()*360 - 180}
+	switch rng.Intn(8) {
+	case 0:
+		location.Latitude = 89 + rng.Float64()
+	case 1:
+		location.Longitude = 179 + rng.Float64()
+	}
+	return app.SensorMetadata{ID: id, Name: "Sensor" + strconv.Itoa(id), Location: location}
+}
+
+// haversine returns the great-circle distance in meters between two points, on the sphere the repositories use.
+func haversine(latitude, longitude float64, location app.Location) float64 {
+	phi1, phi2 := latitude*math.Pi/180, location.Latitude*math.Pi/180
+	dPhi, dLambda := phi2-phi1, (location.Longitude-longitude)*math.Pi/180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * 6378168.0 * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// sensorIDs returns the IDs of the sensors, in order.
+func sensorIDs(sensors []app.SensorMetadata) []int {
+	ids := []int{}
+	for _, sensorMetadata := range sensors {
+		ids = append(ids, sensorMetadata.ID)
+	}
+	return ids
+}
+
+// nearestSensorIDs returns the IDs of the sensors found by a distance query, in order.
+func nearestSensorIDs(sensors []app.NearestSensor) []int {
+	ids := []int{}
+	for _, sensor := range sensors {
+		ids = append(ids, sensor.ID)
+	}
+	return ids
+}
+
+func TestSpatialIndexMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sensors := map[int]app.SensorMetadata{}
+	var initial []app.SensorMetadata
+	for id := 1; id <= 500; id++ {
+		sensors[id] = randomSensor(rng, id)
+		initial = append(initial, sensors[id])
+	}
+	index := app.NewSpatialIndex(initial)
+
+	// Move, add and remove sensors, enough for the tree to be rebuilt along the way
+	for i := 0; i < 2000; i++ {
+		id := 1 + rng.Intn(800)
+		if rng.Intn(3) == 0 {
+			_, ok := sensors[id]
+			assert.Equal(t, ok, index.Remove(id))
+			delete(sensors, id)
+		} else {
+			sensors[id] = randomSensor(rng, id)
+			index.Insert(sensors[id])
+		}
+	}
+	assert.Equal(t, len(sensors), index.Len())
+
+	byDistance := func(latitude, longitude float64) []app.NearestSensor {
+		var all []app.NearestSensor
+		for _, sensorMetadata := range sensors {
+			all = append(all, app.NearestSensor{SensorMetadata: sensorMetadata, Distance: haversine(latitude, longitude, sensorMetadata.Location)})
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Distance < all[j].Distance })
+		return all
+	}
+
+	for i := 0; i < 100; i++ {
+		target := randomSensor(rng, 0).Location
+		expected := byDistance(target.Latitude, target.Longitude)
+
+		nearest := index.Nearest(target.Latitude, target.Longitude, 5, nil)
+		if assert.Len(t, nearest, 5) {
+			for j := range nearest {
+				assert.InDelta(t, expected[j].Distance, nearest[j].Distance, 1e-6)
+			}
+		}
+
+		radius := rng.Float64() * 3000000
+		var within []int
+		for _, sensor := range expected {
+			if sensor.Distance <= radius {
+				within = append(within, sensor.ID)
+			}
+		}
+		assert.ElementsMatch(t, within, nearestSensorIDs(index.WithinRadius(target.Latitude, target.Longitude, radius, nil)))
+
+		box := app.BoundingBox{MinLon: rng.Float64()*360 - 180, MinLat: rng.Float64()*100 - 60, MaxLon: rng.Float64()*360 - 180}
+		box.MaxLat = box.MinLat + rng.Float64()*(90-box.MinLat)
+		inside := []int{}
+		for _, sensorMetadata := range sensors {
+			if box.Contains(sensorMetadata.Location.Latitude, sensorMetadata.Location.Longitude) {
+				inside = append(inside, sensorMetadata.ID)
+			}
+		}
+		sort.Ints(inside)
+		assert.Equal(t, inside, sensorIDs(index.WithinBoundingBox(box, nil)), box)
+	}
+}
+
+func TestSpatialIndexFilters(t *testing.T) {
+	index := app.NewSpatialIndex([]app.SensorMetadata{
+		{ID: 1, Name: "London", Location: app.Location{Latitude: 51.5074, Longitude: -0.1278}, Tags: []string{"vendor:acme"}},
+		{ID: 2, Name: "Paris", Location: app.Location{Latitude: 48.8566, Longitude: 2.3522}},
+		{ID: 3, Name: "Fiji", Location: app.Location{Latitude: -17.7134, Longitude: 178.065}},
+		{ID: 4, Name: "Samoa", Location: app.Location{Latitude: -13.759, Longitude: -172.1046}},
+	})
+
+	acme := func(sensorMetadata app.SensorMetadata) bool { return len(sensorMetadata.Tags) > 0 }
+	assert.Equal(t, []int{1}, nearestSensorIDs(index.Nearest(48.8566, 2.3522, 2, acme)))
+	assert.Equal(t, []int{2, 1}, nearestSensorIDs(index.WithinRadius(48.8566, 2.3522, 400000, nil)))
+
+	// Boxes and distances across the antimeridian
+	assert.Equal(t, []int{3, 4}, sensorIDs(index.WithinBoundingBox(app.BoundingBox{MinLon: 170, MinLat: -20, MaxLon: -170, MaxLat: -10}, nil)))
+	nearest := index.Nearest(-15, 180, 1, nil)
+	if assert.Len(t, nearest, 1) {
+		assert.Equal(t, "Fiji", nearest[0].Name)
+	}
+}
+
+func TestSpatialIndexRepository(t *testing.T) {
+	underlying := newSeededMemoryRepository(t)
+	repo, err := app.NewSpatialIndexRepository(underlying)
+	assert.NoError(t, err)
+
+	// Queries are answered as the repository would
+	acme, err := app.ParseTagExpr("vendor:acme")
+	assert.NoError(t, err)
+	queries := []app.NearestQuery{
+		{Latitude: 50, Longitude: 5, Limit: 2},
+		{Latitude: 50, Longitude: 5, Limit: 3, Tags: acme},
+	}
+	for _, query := range queries {
+		expected, err := underlying.FindNearestSensorMetadata(query)
+		assert.NoError(t, err)
+		actual, err := repo.FindNearestSensorMetadata(query)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	neighbours := app.NeighbourQuery{IDs: []int{1, 2, 3, 5}, Tags: acme, Limit: 2}
+	expectedNeighbours, err := underlying.FindNeighbourSensorMetadata(neighbours)
+	assert.NoError(t, err)
+	actualNeighbours, err := repo.FindNeighbourSensorMetadata(neighbours)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedNeighbours, actualNeighbours)
+	box := app.BoundingBox{MinLon: -1, MinLat: 48, MaxLon: 14, MaxLat: 53}
+	within := app.WithinQuery{SensorFilter: app.SensorFilter{AfterID: 1, Limit: 10}, BoundingBox: &box}
+	expected, err := underlying.FindSensorMetadataWithin(within)
+	assert.NoError(t, err)
+	actual, err := repo.FindSensorMetadataWithin(within)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// Writes through the decorator are indexed at once
+	london, err := repo.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	london.Location = app.Location{Latitude: 40.4168, Longitude: -3.7038}
+	assert.NoError(t, repo.UpdateSensorMetadata(london))
+	_, err = repo.ApplySensorBatch([]app.BatchOperation{{Op: app.BatchDelete, Name: "Paris"}}, false)
+	assert.NoError(t, err)
+	nearest, err := repo.GetNearestSensorMetadata("48.8566", "2.3522")
+	assert.NoError(t, err)
+	assert.Equal(t, "Berlin", nearest.Name)
+
+	// Writes made elsewhere are indexed once notified
+	assert.NoError(t, underlying.CreateSensorMetadata(&app.SensorMetadata{Name: "Brussels", Location: app.Location{Latitude: 50.8503, Longitude: 4.3517}}))
+	nearest, err = repo.GetNearestSensorMetadata("48.8566", "2.3522")
+	assert.NoError(t, err)
+	assert.Equal(t, "Berlin", nearest.Name)
+	repo.SensorChanged(app.SensorChange{Type: app.SensorCreated, Name: "Brussels"})
+	nearest, err = repo.GetNearestSensorMetadata("48.8566", "2.3522")
+	assert.NoError(t, err)
+	assert.Equal(t, "Brussels", nearest.Name)
+
+	repo.ResyncSensors()
+	sensors, err := repo.FindSensorMetadataWithin(app.WithinQuery{SensorFilter: app.SensorFilter{Limit: 10}, BoundingBox: &box})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, sensorIDs(sensors))
+}
+
+func BenchmarkSpatialIndexNearest(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	sensors := make([]app.SensorMetadata, 500000)
+	for i := range sensors {
+		sensors[i] = randomSensor(rng, i+1)
+	}
+	index := app.NewSpatialIndex(sensors)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		target := sensors[i%len(sensors)].Location
+		index.Nearest(target.Latitude, target.Longitude, 5, nil)
+	}
+}
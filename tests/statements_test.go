@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+const sensorByNameQuery = "SELECT id, name, location_latitude, location_longitude, location_altitude, location_altitude_datum, location_floor, location_accuracy_m, tags, sensor_type, attributes FROM sensor_metadata WHERE name = $1"
+
+func sensorByNameRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "location_latitude", "location_longitude", "location_altitude", "location_altitude_datum", "location_floor", "location_accuracy_m", "tags", "sensor_type", "attributes"}).
+		AddRow(1, "Sensor1", 51.5, -0.1, nil, nil, nil, nil, pq.Array([]string{}), nil, nil)
+}
+
+func TestStatementCache(t *testing.T) {
+	primaryDB, primary, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer primaryDB.Close()
+	replicaDB, replica, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer replicaDB.Close()
+
+	replicas := app.NewReplicaSet([]string{"replica1"}, []*sql.DB{replicaDB})
+	repo := &app.PostgresRepository{Db: primaryDB, Replicas: replicas, Statements: app.NewStatementCache()}
+
+	// A statement is prepared once on each database, and shared with the primary view
+	prepared := primary.ExpectPrepare(sensorByNameQuery)
+	for i := 0; i < 3; i++ {
+		prepared.ExpectQuery().WithArgs("Sensor1").WillReturnRows(sensorByNameRows())
+	}
+	for i := 0; i < 2; i++ {
+		_, err := repo.GetSensorMetadataByName("Sensor1")
+		assert.NoError(t, err)
+	}
+	_, err = repo.Primary().GetSensorMetadataByName("Sensor1")
+	assert.NoError(t, err)
+
+	replica.ExpectQuery(replicaLagQuery).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.0))
+	replicas.Check(context.Background())
+	prepared = replica.ExpectPrepare(sensorByNameQuery)
+	prepared.ExpectQuery().WithArgs("Sensor1").WillReturnRows(sensorByNameRows())
+	_, err = repo.GetSensorMetadataByName("Sensor1")
+	assert.NoError(t, err)
+
+	// A failed preparation isn't cached
+	primary.ExpectPrepare(createSensorTypeQuery).WillReturnError(&pq.Error{Code: "42P01"})
+	sensorType := &app.SensorType{Name: "thermometer", Schema: []byte(`{"type":"object"}`)}
+	assert.Error(t, repo.CreateSensorType(sensorType))
+	primary.ExpectPrepare(createSensorTypeQuery).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.CreateSensorType(sensorType))
+
+	primary.ExpectClose()
+	assert.NoError(t, primaryDB.Close())
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
+
+// BenchmarkPreparedStatements compares looking sensors up by name with a statement prepared and
+// closed for every call against one prepared once, on a mock database answering every round
+// trip after a simulated network latency of 1ms.
+func BenchmarkPreparedStatements(b *testing.B) {
+	const latency = time.Millisecond
+
+	for _, cached := range []bool{false, true} {
+		name := "per_call"
+		if cached {
+			name = "cached"
+		}
+		b.Run(name, func(b *testing.B) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer mockDB.Close()
+			repo := &app.PostgresRepository{Db: mockDB}
+			if cached {
+				repo.Statements = app.NewStatementCache()
+			}
+
+			prepared := mock.ExpectPrepare(sensorByNameQuery).WillDelayFor(latency)
+			for i := 0; i < b.N; i++ {
+				if i > 0 && !cached {
+					prepared = mock.ExpectPrepare(sensorByNameQuery).WillDelayFor(latency)
+				}
+				prepared.ExpectQuery().WithArgs("Sensor1").WillDelayFor(latency).WillReturnRows(sensorByNameRows())
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetSensorMetadataByName("Sensor1"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPreparedStatementsPostgres compares the same lookups on a seeded table, from concurrent
+// clients. Like BenchmarkNearestSensorMetadata, it needs a migrated PostgreSQL database given as a
+// connection string in SENSOR_BENCH_DSN, and seeds the bench_nearest schema with
+// SENSOR_BENCH_SENSORS sensors (default 10000).
+//
+//	SENSOR_BENCH_DSN="host=localhost dbname=sensor_metadata user=admin password=password sslmode=disable" \
+//		go test ./tests -run '^$' -bench PreparedStatementsPostgres
+func BenchmarkPreparedStatementsPostgres(b *testing.B) {
+	dsn := os.Getenv("SENSOR_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("SENSOR_BENCH_DSN is not set")
+	}
+
+	sensors := 10000
+	if value := os.Getenv("SENSOR_BENCH_SENSORS"); value != "" {
+		var err error
+		if sensors, err = strconv.Atoi(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn+" search_path=bench_nearest,public")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxIdleConns(64)
+
+	seedNearestBenchmark(b, db, sensors)
+
+	for _, cached := range []bool{false, true} {
+		name := "per_call"
+		if cached {
+			name = "cached"
+		}
+		b.Run(name, func(b *testing.B) {
+			repo := &app.PostgresRepository{Db: db}
+			if cached {
+				repo.Statements = app.NewStatementCache()
+				defer repo.Statements.Close()
+			}
+
+			var next sync.Mutex
+			i := 0
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					next.Lock()
+					i++
+					name := "sensor-" + strconv.Itoa(1+i%sensors)
+					next.Unlock()
+					if _, err := repo.GetSensorMetadataByName(name); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
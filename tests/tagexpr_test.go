@@ -0,0 +1,50 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	expr, err := app.ParseTagExpr("Vendor:acme AND (floor:3 OR floor:4) AND NOT retired")
+	assert.NoError(t, err)
+	assert.Equal(t, "((vendor:acme AND (floor:3 OR floor:4)) AND NOT retired)", expr.String())
+
+	assert.True(t, expr.Match([]string{"vendor:acme", "floor:4"}))
+	assert.False(t, expr.Match([]string{"vendor:acme", "floor:5"}))
+	assert.False(t, expr.Match([]string{"vendor:acme", "floor:3", "retired"}))
+	assert.False(t, expr.Match(nil))
+}
+
+func TestParseTagExprPrecedence(t *testing.T) {
+	expr, err := app.ParseTagExpr("a OR b AND NOT c")
+	assert.NoError(t, err)
+	assert.Equal(t, "(a OR (b AND NOT c))", expr.String())
+}
+
+func TestParseTagExprKeyWildcard(t *testing.T) {
+	expr, err := app.ParseTagExpr("NOT floor:*")
+	assert.NoError(t, err)
+
+	assert.True(t, expr.Match([]string{"vendor:acme"}))
+	assert.False(t, expr.Match([]string{"floor:2"}))
+}
+
+func TestParseTagExprErrors(t *testing.T) {
+	for _, input := range []string{"", "a AND", "(a OR b", "a b", "AND a", "a )", "NOT", "bad!tag", "a:b:*"} {
+		_, err := app.ParseTagExpr(input)
+		assert.ErrorIs(t, err, app.ErrInvalidTagExpr, "input %q", input)
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	tag, ok := app.ParseTag("floor:3")
+	assert.True(t, ok)
+	assert.Equal(t, app.Tag{Key: "floor", Value: "3"}, tag)
+	assert.Equal(t, "floor:3", tag.String())
+
+	_, ok = app.ParseTag("retired")
+	assert.False(t, ok)
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := app.NormalizeTags([]string{" Tag1 ", "tag1", "Vendor:ACME", "floor-3_a.b"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tag1", "vendor:acme", "floor-3_a.b"}, tags)
+}
+
+func TestNormalizeTagRejectsInvalidTags(t *testing.T) {
+	for _, tag := range []string{"", "   ", "has space", "emoji-☃", ":leading-colon", string(make([]byte, app.MaxTagLength+1))} {
+		_, err := app.NormalizeTag(tag)
+		assert.ErrorIs(t, err, app.ErrInvalidTag, "tag %q", tag)
+	}
+}
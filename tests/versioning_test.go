@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/skartikey/sensor-metadata/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	router := app.NewRouter(app.NewHandler(newSeededMemoryRepository(t)))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/sensors?name=Paris", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+	assert.Empty(t, rr.Header().Get("Sunset"))
+
+	req = httptest.NewRequest(http.MethodGet, "/sensors?name=Paris", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "@1792281600", rr.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", rr.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/sensors?name=Paris>; rel="successor-version"`, rr.Header().Get("Link"))
+	assert.Contains(t, rr.Body.String(), `"name":"Paris"`)
+
+	// Legacy list pages link both to their successor and to the next page
+	req = httptest.NewRequest(http.MethodGet, "/sensors?format=gpx&limit=1", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, []string{
+		`</v1/sensors?format=gpx&limit=1>; rel="successor-version"`,
+		`</sensors?after=1&format=gpx&limit=1>; rel="next"`,
+	}, rr.Header().Values("Link"))
+
+	// Updates by the ID in the body are still served at the unprefixed path only
+	req = httptest.NewRequest(http.MethodPut, "/sensors", strings.NewReader(`{"id": 2, "name": "Lutetia", "location": {"latitude": 48.8566, "longitude": 2.3522}}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "@1792281600", rr.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", rr.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/sensors>; rel="successor-version"`, rr.Header().Get("Link"))
+
+	req = httptest.NewRequest(http.MethodPut, "/sensors", strings.NewReader(`{"id": 99, "name": "Nowhere", "location": {"latitude": 1, "longitude": 1}}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/sensors", strings.NewReader(`{"id": 2, "name": "Paris", "location": {"latitude": 48.8566, "longitude": 2.3522}}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/sensors", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestClientUsesVersionedRoutes(t *testing.T) {
+	router := app.NewRouter(app.NewHandler(newSeededMemoryRepository(t)))
+	var paths []string
+	recorder := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		router.ServeHTTP(w, r)
+	})
+	c := newTestClient(t, recorder)
+
+	_, err := c.GetSensor(context.Background(), "Paris")
+	assert.NoError(t, err)
+	_, err = c.ListSensors(context.Background(), client.ListOptions{})
+	assert.NoError(t, err)
+	for _, path := range paths {
+		assert.True(t, strings.HasPrefix(path, "/v1/"), path)
+	}
+}
+
+func TestSensorMetadataV1Mapping(t *testing.T) {
+	// Structured tags of a request are merged into the tags stored
+	request := app.SensorMetadataV1{
+		Name:           "Sensor1",
+		Location:       app.LocationV1{Latitude: 51.5, Longitude: -0.12},
+		Tags:           []string{"retired"},
+		StructuredTags: []app.Tag{{Key: "floor", Value: "3"}},
+	}
+	sensorMetadata := request.SensorMetadata()
+	assert.Equal(t, app.SensorMetadata{Name: "Sensor1", Location: app.Location{Latitude: 51.5, Longitude: -0.12}, Tags: []string{"retired", "floor:3"}}, sensorMetadata)
+
+	// and derived from them in responses, with the distance of nearest sensors alongside
+	response := app.NewNearestSensorV1(app.NearestSensor{SensorMetadata: sensorMetadata, Distance: 42})
+	assert.Equal(t, []string{"retired"}, request.Tags)
+	assert.Equal(t, []string{"retired", "floor:3"}, response.Tags)
+	assert.Equal(t, []app.Tag{{Key: "floor", Value: "3"}}, response.StructuredTags)
+	assert.Equal(t, 42.0, response.Distance)
+	assert.Equal(t, app.LocationV1{Latitude: 51.5, Longitude: -0.12}, response.Location)
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skartikey/sensor-metadata/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func waypointSensors() []app.SensorMetadata {
+	altitude := 35.0
+	return []app.SensorMetadata{
+		{ID: 1, Name: "London", Location: app.Location{Latitude: 51.5074, Longitude: -0.1278, Altitude: &altitude, AltitudeDatum: app.DatumMSL}, Tags: []string{"site:north"}},
+		{ID: 2, Name: "Paris", Location: app.Location{Latitude: 48.8566, Longitude: 2.3522, Altitude: &altitude, AltitudeDatum: app.DatumWGS84}, Tags: []string{"site:north", "status:stale"}},
+		{ID: 3, Name: "Berlin", Location: app.Location{Latitude: 52.52, Longitude: 13.405}, Tags: []string{"status:decommissioned"}},
+	}
+}
+
+func TestWriteKML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, app.WriteKML(&buf, waypointSensors(), app.WaypointOptions{GroupBy: "site", StyleBy: "status"}))
+
+	var document struct {
+		Document struct {
+			Styles []struct {
+				ID    string `xml:"id,attr"`
+				Color string `xml:"IconStyle>color"`
+			} `xml:"Style"`
+			Folders []struct {
+				Name       string `xml:"name"`
+				Placemarks []struct {
+					Name        string `xml:"name"`
+					StyleURL    string `xml:"styleUrl"`
+					Coordinates string `xml:"Point>coordinates"`
+				} `xml:"Placemark"`
+			} `xml:"Folder"`
+		} `xml:"Document"`
+	}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &document))
+
+	styles := map[string]string{}
+	for _, style := range document.Document.Styles {
+		styles[style.ID] = style.Color
+	}
+	assert.Equal(t, map[string]string{"status-active": "ff00aa00", "status-decommissioned": "ff808080", "status-stale": "ff00a5ff"}, styles)
+
+	folders := document.Document.Folders
+	assert.Len(t, folders, 2)
+	assert.Equal(t, "No site", folders[0].Name)
+	assert.Equal(t, "Berlin", folders[0].Placemarks[0].Name)
+	assert.Equal(t, "#status-decommissioned", folders[0].Placemarks[0].StyleURL)
+	assert.Equal(t, "north", folders[1].Name)
+	assert.Len(t, folders[1].Placemarks, 2)
+	// Only altitudes above mean sea level are written
+	assert.Equal(t, "-0.1278,51.5074,35", folders[1].Placemarks[0].Coordinates)
+	assert.Equal(t, "2.3522,48.8566", folders[1].Placemarks[1].Coordinates)
+	assert.Equal(t, "#status-stale", folders[1].Placemarks[1].StyleURL)
+}
+
+func TestWriteGPX(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, app.WriteGPX(&buf, waypointSensors(), app.WaypointOptions{}))
+
+	var document struct {
+		Version   string `xml:"version,attr"`
+		Waypoints []struct {
+			Latitude  float64  `xml:"lat,attr"`
+			Longitude float64  `xml:"lon,attr"`
+			Elevation *float64 `xml:"ele"`
+			Name      string   `xml:"name"`
+			Symbol    string   `xml:"sym"`
+			Type      string   `xml:"type"`
+		} `xml:"wpt"`
+	}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &document))
+	assert.Equal(t, "1.1", document.Version)
+	assert.Len(t, document.Waypoints, 3)
+
+	london, paris, berlin := document.Waypoints[0], document.Waypoints[1], document.Waypoints[2]
+	assert.Equal(t, "London", london.Name)
+	assert.Equal(t, 51.5074, london.Latitude)
+	assert.Equal(t, 35.0, *london.Elevation)
+	assert.Equal(t, "Flag, Green", london.Symbol)
+	assert.Equal(t, "active", london.Type)
+	assert.Nil(t, paris.Elevation)
+	assert.Equal(t, "Flag, Red", paris.Symbol)
+	assert.Equal(t, "Block, Red", berlin.Symbol)
+	assert.Equal(t, "decommissioned", berlin.Type)
+}
+
+func TestHandlerListSensorMetadataKML(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/sensors?format=kml&group_by=vendor&limit=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, app.KMLMediaType, rr.Header().Get("Content-Type"))
+	assert.Equal(t, `</v1/sensors?after=2&format=kml&group_by=vendor&limit=2>; rel="next"`, rr.Header().Get("Link"))
+	assert.Contains(t, rr.Body.String(), "<Folder>\n      <name>acme</name>")
+}
+
+func TestHandlerGetSensorMetadataWithinGPX(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/sensors/within?bbox=-1,48,3,52", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/json;q=0.5, application/gpx+xml")
+	rr := httptest.NewRecorder()
+	app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, app.GPXMediaType, rr.Header().Get("Content-Type"))
+	assert.Empty(t, rr.Header().Get("Link"))
+	assert.Contains(t, rr.Body.String(), "<name>London</name>")
+	assert.Contains(t, rr.Body.String(), "<name>Paris</name>")
+	assert.NotContains(t, rr.Body.String(), "<name>Berlin</name>")
+}
+
+func TestHandlerListSensorMetadataFormatErrors(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+
+	for _, target := range []string{
+		"/sensors?format=shapefile",
+		"/sensors?format=kml&group_by=site:north",
+		"/sensors/nearest?latitude=48.8&longitude=2.3&format=kml",
+		"/sensors/export?format=gpx",
+	} {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		app.NewRouter(app.NewHandler(repo)).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, target)
+	}
+}
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skartikey/sensor-metadata/app"
+)
+
+// webhookReceiver records the deliveries posted to it, answering with its current status.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, status int) (*webhookReceiver, string) {
+	receiver := &webhookReceiver{status: status}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		w.WriteHeader(receiver.status)
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server.URL
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	handler := app.NewHandler(repo)
+	handler.AllowPrivateWebhooks = true
+	router := app.NewRouter(handler)
+	alerts, alertsURL := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	assets, assetsURL := newWebhookReceiver(t, http.StatusInternalServerError)
+
+	createWebhook := func(body string) app.Webhook {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/webhooks", strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var webhook app.Webhook
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &webhook))
+		return webhook
+	}
+	alertsWebhook := createWebhook(`{"url": "` + alertsURL + `", "secret": "0123456789abcdef", "events": ["moved"], "tags": "vendor:acme"}`)
+	assetsWebhook := createWebhook(`{"url": "` + assetsURL + `"}`)
+	assert.Equal(t, "0123456789abcdef", alertsWebhook.Secret)
+	assert.Len(t, assetsWebhook.Secret, 64)
+
+	// Secrets are only returned on creation
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/webhooks/1", nil))
+	assert.JSONEq(t, `{"id": 1, "url": "`+alertsURL+`", "events": ["moved"], "tags": "vendor:acme"}`, rr.Body.String())
+
+	// London moves, which both webhooks receive; Berlin is retagged, which only the second one receives
+	london, err := repo.GetSensorMetadataByName("London")
+	assert.NoError(t, err)
+	london.Location = app.Location{Latitude: 51.4545, Longitude: -2.5879}
+	assert.NoError(t, repo.UpdateSensorMetadata(london))
+	_, err = repo.AddSensorTags("Berlin", []string{"outdoor"})
+	assert.NoError(t, err)
+
+	dispatcher := app.NewWebhookDispatcher(repo)
+	dispatcher.AllowPrivateAddresses = true
+	dispatcher.MaxAttempts = 2
+	dispatcher.Backoff = func(int) time.Duration { return 0 }
+
+	// The first attempts fail, and are retried in the next round
+	assert.NoError(t, dispatcher.Dispatch(context.Background()))
+	assert.Equal(t, 1, alerts.count())
+	assert.Equal(t, 2, assets.count())
+
+	alerts.setStatus(http.StatusNoContent)
+	assert.NoError(t, dispatcher.Dispatch(context.Background()))
+	if assert.Equal(t, 2, alerts.count()) {
+		req, body := alerts.requests[1], alerts.bodies[1]
+		assert.Equal(t, "updated", req.Header.Get(app.WebhookEventHeader))
+		assert.Equal(t, alerts.requests[0].Header.Get(app.WebhookDeliveryHeader), req.Header.Get(app.WebhookDeliveryHeader))
+		timestamp, err := strconv.ParseInt(req.Header.Get(app.WebhookTimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, "sha256="+app.SignWebhookPayload("0123456789abcdef", timestamp, body), req.Header.Get(app.WebhookSignatureHeader))
+
+		var payload app.WebhookPayload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, int64(4), payload.ID)
+		assert.Equal(t, "London", payload.Sensor.Name)
+		assert.Equal(t, 51.4545, payload.Sensor.Location.Latitude)
+		if assert.NotNil(t, payload.Previous) {
+			assert.Equal(t, 51.5074, payload.Previous.Location.Latitude)
+		}
+	}
+
+	// The second webhook runs out of attempts, and its deliveries are dead-lettered
+	assert.Equal(t, 4, assets.count())
+	rr = assertResponseMatchesSpec(t, router, httptest.NewRequest(http.MethodGet, "/webhooks/2/deliveries?status=dead", nil))
+	var deliveries app.WebhookDeliveryList
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &deliveries))
+	if assert.Len(t, deliveries.Deliveries, 2) {
+		assert.Equal(t, 2, deliveries.Deliveries[0].Attempts)
+		assert.Equal(t, "unexpected status 500 Internal Server Error", deliveries.Deliveries[0].LastError)
+	}
+
+	// A dead delivery can be sent again once the receiver is fixed
+	assets.setStatus(http.StatusOK)
+	rr = assertResponseMatchesSpec(t, router, httptest.NewRequest(http.MethodPost, "/webhooks/2/deliveries/"+strconv.FormatInt(deliveries.Deliveries[1].ID, 10)+":retry", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, dispatcher.Dispatch(context.Background()))
+	assert.Equal(t, 5, assets.count())
+
+	rr = assertResponseMatchesSpec(t, router, httptest.NewRequest(http.MethodGet, "/webhooks/2/deliveries", nil))
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &deliveries))
+	if assert.Len(t, deliveries.Deliveries, 2) {
+		assert.Equal(t, app.DeliveryDead, deliveries.Deliveries[0].Status)
+		assert.Equal(t, app.DeliveryDelivered, deliveries.Deliveries[1].Status)
+	}
+
+	// Nothing is left to send
+	assert.NoError(t, dispatcher.Dispatch(context.Background()))
+	assert.Equal(t, 2, alerts.count())
+	assert.Equal(t, 5, assets.count())
+}
+
+func TestWebhookAddresses(t *testing.T) {
+	router := app.NewRouter(app.NewHandler(app.NewMemoryRepository()))
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hooks",
+		"http://localhost/hooks",
+		"http://[::1]/hooks",
+		"http://10.1.2.3/hooks",
+		"http://192.168.0.10/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hooks",
+		"http://[::ffff:127.0.0.1]/hooks",
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/webhooks", strings.NewReader(`{"url": "`+url+`"}`)))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, url)
+		assert.Contains(t, rr.Body.String(), "webhook address not allowed", url)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/webhooks", strings.NewReader(`{"url": "https://93.184.216.34/hooks"}`)))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/v1/webhooks/1", strings.NewReader(`{"url": "http://172.16.0.1/hooks"}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestWebhookDispatcherAddresses(t *testing.T) {
+	receiver, receiverURL := newWebhookReceiver(t, http.StatusOK)
+	redirect := httptest.NewServer(http.RedirectHandler(receiverURL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+
+	deliver := func(url string, allowPrivate bool) app.WebhookDelivery {
+		repo := newSeededMemoryRepository(t)
+		assert.NoError(t, repo.CreateWebhook(&app.Webhook{URL: url, Secret: "0123456789abcdef"}))
+		_, err := repo.RemoveSensorTag("Paris", "retired")
+		assert.NoError(t, err)
+
+		dispatcher := app.NewWebhookDispatcher(repo)
+		dispatcher.AllowPrivateAddresses = allowPrivate
+		dispatcher.MaxAttempts = 1
+		assert.NoError(t, dispatcher.Dispatch(context.Background()))
+		deliveries, err := repo.ListWebhookDeliveries(1, app.WebhookDeliveryFilter{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		return deliveries[0]
+	}
+
+	// Addresses are checked once host names are resolved, whichever way the webhook was saved
+	delivery := deliver(strings.Replace(receiverURL, "127.0.0.1", "localhost", 1), false)
+	assert.Equal(t, app.DeliveryDead, delivery.Status)
+	assert.Contains(t, delivery.LastError, "webhook address not allowed")
+	assert.Equal(t, 0, receiver.count())
+
+	// Redirects aren't followed
+	delivery = deliver(redirect.URL, true)
+	assert.Equal(t, app.DeliveryDead, delivery.Status)
+	assert.Equal(t, "unexpected status 302 Found", delivery.LastError)
+	assert.Equal(t, 0, receiver.count())
+
+	delivery = deliver(receiverURL, true)
+	assert.Equal(t, app.DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 1, receiver.count())
+}
+
+func TestWebhookDeliveryLease(t *testing.T) {
+	repo := newSeededMemoryRepository(t)
+	assert.NoError(t, repo.CreateWebhook(&app.Webhook{URL: "https://example.com/hooks", Secret: "0123456789abcdef"}))
+	_, err := repo.RemoveSensorTag("Paris", "retired")
+	assert.NoError(t, err)
+
+	// Only the event recorded after the webhook was created is delivered
+	n, err := repo.EnqueueWebhookDeliveries(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// A claimed delivery isn't claimed again until its lease expires
+	deliveries, err := repo.ClaimWebhookDeliveries(10, time.Minute)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	deliveries, err = repo.ClaimWebhookDeliveries(10, time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	// Events are enqueued once
+	n, err = repo.EnqueueWebhookDeliveries(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for attempts, expected := range map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		5:  160 * time.Second,
+		40: 4 * time.Hour,
+	} {
+		delay := app.WebhookBackoff(attempts)
+		assert.GreaterOrEqual(t, delay, expected*4/5, attempts)
+		assert.LessOrEqual(t, delay, expected*6/5, attempts)
+	}
+}